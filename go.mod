@@ -7,18 +7,26 @@ toolchain go1.24.7
 require (
 	github.com/Masterminds/sprig v2.22.0+incompatible
 	github.com/alecthomas/kingpin v2.2.7-0.20180312062423-a39589180ebd+incompatible
+	github.com/andybalholm/brotli v1.2.6
 	github.com/bluele/gcache v0.0.0-20190518031135-bc40bd653833
+	github.com/coreos/go-oidc/v3 v3.16.0
+	github.com/fsnotify/fsnotify v1.7.0
+	github.com/go-jose/go-jose/v4 v4.1.3
 	github.com/gorilla/mux v1.7.4
+	github.com/gorilla/websocket v1.5.3
 	github.com/hr3lxphr6j/requests v0.0.1
 	github.com/kira1928/remotetools v0.3.3
 	github.com/lthibault/jitterbug v2.0.0+incompatible
 	github.com/prometheus/client_golang v1.11.0
 	github.com/robertkrimen/otto v0.0.0-20191219234010-c382bd3c16ff
+	github.com/robfig/cron/v3 v3.0.1
 	github.com/satori/go.uuid v1.2.1-0.20181028125025-b2ce2384e17b
 	github.com/sirupsen/logrus v1.6.0
 	github.com/stretchr/testify v1.9.0
 	github.com/tidwall/gjson v1.9.3
+	go.etcd.io/bbolt v1.3.11
 	go.uber.org/mock v0.5.2
+	golang.org/x/crypto v0.39.0
 	golang.org/x/sys v0.33.0
 	gopkg.in/gomail.v2 v2.0.0-20160411212932-81ebce5c23df
 	gopkg.in/yaml.v2 v2.3.0
@@ -50,7 +58,7 @@ require (
 	github.com/tidwall/match v1.1.1 // indirect
 	github.com/tidwall/pretty v1.2.0 // indirect
 	github.com/ulikunitz/xz v0.5.15 // indirect
-	golang.org/x/crypto v0.39.0 // indirect
+	golang.org/x/oauth2 v0.28.0 // indirect
 	google.golang.org/protobuf v1.26.0-rc.1 // indirect
 	gopkg.in/alexcesaro/quotedprintable.v3 v3.0.0-20150716171945-2caba252f4dc // indirect
 	gopkg.in/sourcemap.v1 v1.0.5 // indirect
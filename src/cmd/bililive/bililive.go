@@ -16,17 +16,23 @@ import (
 	"github.com/bililive-go/bililive-go/src/cmd/bililive/internal/flag"
 	"github.com/bililive-go/bililive-go/src/configs"
 	"github.com/bililive-go/bililive-go/src/consts"
+	"github.com/bililive-go/bililive-go/src/history"
 	"github.com/bililive-go/bililive-go/src/instance"
+	"github.com/bililive-go/bililive-go/src/jobs"
 	"github.com/bililive-go/bililive-go/src/listeners"
 	"github.com/bililive-go/bililive-go/src/live"
 	"github.com/bililive-go/bililive-go/src/log"
 	"github.com/bililive-go/bililive-go/src/metrics"
+	"github.com/bililive-go/bililive-go/src/notify/webhook"
 	"github.com/bililive-go/bililive-go/src/pkg/events"
 	"github.com/bililive-go/bililive-go/src/pkg/utils"
 	"github.com/bililive-go/bililive-go/src/recorders"
+	"github.com/bililive-go/bililive-go/src/reload"
 	"github.com/bililive-go/bililive-go/src/servers"
+	"github.com/bililive-go/bililive-go/src/storage"
 	"github.com/bililive-go/bililive-go/src/tools"
 	"github.com/bililive-go/bililive-go/src/types"
+	"github.com/bililive-go/bililive-go/src/upload"
 )
 
 func getConfig() (*configs.Config, error) {
@@ -127,6 +133,23 @@ func main() {
 
 	events.NewDispatcher(ctx)
 
+	if err = history.NewStore(ctx).Start(ctx); err != nil {
+		logger.WithError(err).Error("failed to init record history, recording history is disabled")
+		inst.RecordHistory = nil
+	}
+
+	if err = storage.NewManager(ctx).Start(ctx); err != nil {
+		logger.WithError(err).Error("failed to init storage manager, disk space guard and retention are disabled")
+		inst.StorageManager = nil
+	}
+
+	if inst.Config.Upload.Enable {
+		if err = upload.NewUploader(ctx).Start(ctx); err != nil {
+			logger.WithError(err).Error("failed to init uploader, upload is disabled")
+			inst.Uploader = nil
+		}
+	}
+
 	inst.Lives = make(map[types.LiveID]live.Live)
 	for index := range inst.Config.LiveRooms {
 		room := &inst.Config.LiveRooms[index]
@@ -136,14 +159,15 @@ func main() {
 			logger.WithField("url", room).Error(liveErr.Error())
 			continue
 		}
-		if _, ok := inst.Lives[l.GetLiveId()]; ok {
+		if !inst.AddLive(l) {
 			logger.Errorf("%v is exist!", room)
 			continue
 		}
-		inst.Lives[l.GetLiveId()] = l
 		room.LiveId = l.GetLiveId()
 	}
 
+	// 任务队列需要在 recorder manager 注册后处理步骤之后再启动
+	queue := jobs.NewQueue(ctx)
 	lm := listeners.NewManager(ctx)
 	rm := recorders.NewManager(ctx)
 	if err = lm.Start(ctx); err != nil {
@@ -152,6 +176,15 @@ func main() {
 	if err = rm.Start(ctx); err != nil {
 		logger.Fatalf("failed to init recorder manager, error: %s", err)
 	}
+	if err = queue.Start(ctx); err != nil {
+		logger.WithError(err).Error("failed to init job queue, post processing will run without queue")
+		inst.JobQueue = nil
+	}
+
+	if err = webhook.NewNotifier(ctx).Start(ctx); err != nil {
+		logger.WithError(err).Error("failed to init webhook notifier, webhook is disabled")
+		inst.WebhookNotifier = nil
+	}
 
 	if err = metrics.NewCollector(ctx).Start(ctx); err != nil {
 		logger.Fatalf("failed to init metrics collector, error: %s", err)
@@ -164,7 +197,7 @@ func main() {
 		}
 	}
 
-	for _, _live := range inst.Lives {
+	for _, _live := range inst.GetLives() {
 		room, err := inst.Config.GetLiveRoomByUrl(_live.GetRawUrl())
 		if err != nil {
 			logger.WithFields(map[string]any{"room": _live.GetRawUrl()}).Error(err)
@@ -178,15 +211,50 @@ func main() {
 		time.Sleep(time.Second * 1)
 	}
 
+	if config.File != "" {
+		if err = reload.NewWatcher(ctx).Start(ctx); err != nil {
+			logger.WithError(err).Error("failed to watch config file, reload on file change is disabled")
+			inst.ConfigWatcher = nil
+		}
+	}
+
+	// SIGHUP 重新加载配置文件
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			logger.Info("SIGHUP received, reloading config")
+			reload.ReloadFile(ctx)
+		}
+	}()
+
 	c := make(chan os.Signal, 1)
-	signal.Notify(c, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM)
+	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
 	go func() {
 		<-c
-		if inst.Config.RPC.Enable {
+		if inst.ConfigWatcher != nil {
+			inst.ConfigWatcher.Close(ctx)
+		}
+		if inst.Server != nil {
 			inst.Server.Close(ctx)
 		}
 		inst.ListenerManager.Close(ctx)
 		inst.RecorderManager.Close(ctx)
+		if inst.JobQueue != nil {
+			inst.JobQueue.Close(ctx)
+		}
+		if inst.Uploader != nil {
+			inst.Uploader.Close(ctx)
+		}
+		if inst.WebhookNotifier != nil {
+			inst.WebhookNotifier.Close(ctx)
+		}
+		if inst.StorageManager != nil {
+			inst.StorageManager.Close(ctx)
+		}
+		if inst.RecordHistory != nil {
+			inst.RecordHistory.Close(ctx)
+		}
 	}()
 
 	if inst.Config.Debug {
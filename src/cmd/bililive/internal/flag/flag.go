@@ -23,6 +23,7 @@ var (
 	RPC             = app.Flag("enable-rpc", "Enable RPC server.").Default("false").Bool()
 	RPCBind         = app.Flag("rpc-bind", "RPC server bind address").Default(":8080").String()
 	NativeFlvParser = app.Flag("native-flv-parser", "use native flv parser").Default("false").Bool()
+	NativeHlsParser = app.Flag("native-hls-parser", "use native hls parser").Default("false").Bool()
 	OutputFileTmpl  = app.Flag("output-file-tmpl", "output file name template").Default("").String()
 	SplitStrategies = app.Flag("split-strategies", "video split strategies, support\"on_room_name_changed\", \"max_duration:(duration)\"").Strings()
 	// 同步（仅保留）容器内置的外部工具到目标目录，然后退出（用于 Docker 镜像构建阶段）
@@ -48,6 +49,7 @@ func GenConfigFromFlags() *configs.Config {
 	cfg.LiveRooms = configs.NewLiveRoomsWithStrings(*Input)
 	cfg.Feature = configs.Feature{
 		UseNativeFlvParser: *NativeFlvParser,
+		UseNativeHlsParser: *NativeHlsParser,
 	}
 
 	if SplitStrategies != nil && len(*SplitStrategies) > 0 {
@@ -0,0 +1,142 @@
+package configs
+
+import (
+	"fmt"
+	"net/url"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// http api 的角色
+const (
+	// RoleViewer 可以查看直播间、录制历史以及下载录制文件
+	RoleViewer = "viewer"
+	// RoleAdmin 在 viewer 的基础上可以修改配置、cookies 以及添加、删除、启停直播间
+	RoleAdmin = "admin"
+)
+
+func verifyRole(role string) error {
+	switch role {
+	case RoleViewer, RoleAdmin:
+		return nil
+	default:
+		return fmt.Errorf("invalid role %q, should be %s or %s", role, RoleViewer, RoleAdmin)
+	}
+}
+
+// Auth http api 与文件浏览的鉴权，tokens、users、oidc 任一通过即可
+type Auth struct {
+	Enable bool        `yaml:"enable"`
+	Tokens []AuthToken `yaml:"tokens,omitempty"`
+	Users  []AuthUser  `yaml:"users,omitempty"`
+	OIDC   *OIDCAuth   `yaml:"oidc,omitempty"`
+}
+
+// AuthToken 静态 api token，通过 Authorization: Bearer <token> 或 access_token 参数传递
+type AuthToken struct {
+	Name  string `yaml:"name,omitempty" secret:"key"`
+	Token string `yaml:"token" secret:"true"`
+	Role  string `yaml:"role"`
+}
+
+// AuthUser basic auth 用户，密码为 bcrypt 哈希，例如 htpasswd -nbBC 10 "" password 的输出
+type AuthUser struct {
+	Username     string `yaml:"username" secret:"key"`
+	PasswordHash string `yaml:"password_hash" secret:"true"`
+	Role         string `yaml:"role"`
+}
+
+// OIDCAuth 使用 OIDC 提供方签发的 JWT（Authorization: Bearer <id_token>）鉴权
+type OIDCAuth struct {
+	Issuer string `yaml:"issuer"`
+	// JWKSUrl 为空时通过 issuer 的 /.well-known/openid-configuration 获取
+	JWKSUrl string `yaml:"jwks_url,omitempty"`
+	// Audience 为空时不校验 aud
+	Audience string `yaml:"audience,omitempty"`
+	// RoleClaim 中包含 AdminValues 任一值的用户为 admin，其余用户为 DefaultRole
+	RoleClaim   string   `yaml:"role_claim,omitempty"`
+	AdminValues []string `yaml:"admin_values,omitempty"`
+	// DefaultRole 默认为 viewer
+	DefaultRole string `yaml:"default_role,omitempty"`
+}
+
+// GetDefaultRole 获取 OIDC 用户的默认角色
+func (o *OIDCAuth) GetDefaultRole() string {
+	if o.DefaultRole == "" {
+		return RoleViewer
+	}
+	return o.DefaultRole
+}
+
+func (o *OIDCAuth) verify() error {
+	if _, err := url.ParseRequestURI(o.Issuer); err != nil {
+		return fmt.Errorf("invalid oidc issuer %q: %w", o.Issuer, err)
+	}
+	if o.JWKSUrl != "" {
+		if _, err := url.ParseRequestURI(o.JWKSUrl); err != nil {
+			return fmt.Errorf("invalid oidc jwks_url %q: %w", o.JWKSUrl, err)
+		}
+	}
+	if len(o.AdminValues) > 0 && o.RoleClaim == "" {
+		return fmt.Errorf("oidc role_claim must be set when admin_values is set")
+	}
+	return verifyRole(o.GetDefaultRole())
+}
+
+func (a *Auth) verify() error {
+	if !a.Enable {
+		return nil
+	}
+	if len(a.Tokens) == 0 && len(a.Users) == 0 && a.OIDC == nil {
+		return fmt.Errorf("auth is enabled, but no token, user or oidc is set")
+	}
+	for _, t := range a.Tokens {
+		if t.Token == "" {
+			return fmt.Errorf("auth token %q is empty", t.Name)
+		}
+		if err := verifyRole(t.Role); err != nil {
+			return err
+		}
+	}
+	usernames := make(map[string]bool)
+	for _, u := range a.Users {
+		if u.Username == "" {
+			return fmt.Errorf("username of auth user is empty")
+		}
+		if usernames[u.Username] {
+			return fmt.Errorf("duplicate auth user %q", u.Username)
+		}
+		usernames[u.Username] = true
+		if _, err := bcrypt.Cost([]byte(u.PasswordHash)); err != nil {
+			return fmt.Errorf("password_hash of user %q is not a bcrypt hash: %w", u.Username, err)
+		}
+		if err := verifyRole(u.Role); err != nil {
+			return err
+		}
+	}
+	if a.OIDC != nil {
+		return a.OIDC.verify()
+	}
+	return nil
+}
+
+// CORS 跨域设置
+type CORS struct {
+	// AllowedOrigins 允许跨域访问的来源，例如 https://example.com，"*" 表示允许所有来源；为空时不允许跨域
+	AllowedOrigins []string `yaml:"allowed_origins,omitempty"`
+}
+
+// AllowedOrigin 返回来自 origin 的跨域请求的 Access-Control-Allow-Origin：
+// 明确列出的来源返回 origin，只能由它携带凭据；只匹配 "*" 时返回 "*"；不允许时返回空字符串
+func (c *CORS) AllowedOrigin(origin string) string {
+	ret := ""
+	for _, o := range c.AllowedOrigins {
+		if o == origin {
+			return origin
+		}
+		if o == "*" {
+			ret = "*"
+		}
+	}
+	return ret
+}
@@ -0,0 +1,22 @@
+package configs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthVerify(t *testing.T) {
+	cfg := NewConfig()
+	cfg.RPC.Auth.Enable = true
+	assert.Error(t, cfg.Verify())
+	cfg.RPC.Auth.Tokens = []AuthToken{{Token: "token", Role: "root"}}
+	assert.Error(t, cfg.Verify())
+	cfg.RPC.Auth.Tokens[0].Role = RoleAdmin
+	assert.NoError(t, cfg.Verify())
+	cfg.RPC.Auth.Users = []AuthUser{{Username: "alice", PasswordHash: "secret", Role: RoleAdmin}}
+	assert.Error(t, cfg.Verify())
+	cfg.RPC.Auth.Users = nil
+	cfg.RPC.Auth.OIDC = &OIDCAuth{Issuer: "https://issuer.example.com", AdminValues: []string{"admins"}}
+	assert.Error(t, cfg.Verify())
+}
@@ -4,11 +4,16 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/bililive-go/bililive-go/src/pkg/danmaku"
 	"github.com/bililive-go/bililive-go/src/types"
 	"gopkg.in/yaml.v2"
 )
@@ -17,6 +22,8 @@ import (
 type RPC struct {
 	Enable bool   `yaml:"enable"`
 	Bind   string `yaml:"bind"`
+	Auth   Auth   `yaml:"auth"`
+	CORS   CORS   `yaml:"cors"`
 }
 
 var defaultRPC = RPC{
@@ -34,13 +41,16 @@ func (r *RPC) verify() error {
 	if _, err := net.ResolveTCPAddr("tcp", r.Bind); err != nil {
 		return err
 	}
-	return nil
+	return r.Auth.verify()
 }
 
 // Feature info.
 type Feature struct {
 	UseNativeFlvParser         bool `yaml:"use_native_flv_parser"`
+	UseNativeHlsParser         bool `yaml:"use_native_hls_parser"`
 	RemoveSymbolOtherCharacter bool `yaml:"remove_symbol_other_character"`
+	// HlsConcurrency 内置 HLS 下载器同时下载的分片数，0 表示使用默认值 3
+	HlsConcurrency int `yaml:"hls_concurrency"`
 }
 
 // VideoSplitStrategies info.
@@ -52,12 +62,233 @@ type VideoSplitStrategies struct {
 
 // On record finished actions.
 type OnRecordFinished struct {
-	ConvertToMp4          bool   `yaml:"convert_to_mp4"`
+	ConvertToMp4 bool `yaml:"convert_to_mp4"`
+	// Remuxer 转换 mp4 使用的工具，ffmpeg（默认）或 native（内置实现，不依赖 ffmpeg，失败时回退到 ffmpeg）
+	Remuxer string `yaml:"remuxer"`
+	// FragmentedMp4 输出 fragmented mp4，moov 位于文件开头
+	FragmentedMp4         bool   `yaml:"fragmented_mp4"`
 	DeleteFlvAfterConvert bool   `yaml:"delete_flv_after_convert"`
 	CustomCommandline     string `yaml:"custom_commandline"`
 	FixFlvAtFirst         bool   `yaml:"fix_flv_at_first"`
 }
 
+// 转换 mp4 使用的工具
+const (
+	RemuxerFFmpeg = "ffmpeg"
+	RemuxerNative = "native"
+)
+
+func (o OnRecordFinished) verify() error {
+	switch o.Remuxer {
+	case "", RemuxerFFmpeg, RemuxerNative:
+		return nil
+	}
+	return fmt.Errorf(`the remuxer: "%s" is not supported`, o.Remuxer)
+}
+
+// Danmaku 弹幕录制配置，目前仅支持哔哩哔哩
+type Danmaku struct {
+	Enable bool `yaml:"enable"`
+	// Format 弹幕文件格式，xml（兼容 BililiveRecorder）或 jsonl
+	Format string `yaml:"format"`
+}
+
+// 直播流候选地址的排序方式
+const (
+	StreamSortByDefault    = ""
+	StreamSortByResolution = "resolution"
+	StreamSortByBitrate    = "bitrate"
+)
+
+// StreamFailover 在多个直播流地址（CDN）之间选择与切换的策略
+type StreamFailover struct {
+	// SortBy 候选地址的排序方式，为空时保持平台返回的顺序，可选 resolution、bitrate
+	SortBy string `yaml:"sort_by"`
+	// PreferredHosts 优先使用的 CDN 域名，越靠前优先级越高；支持 * 通配符，不含通配符时按子串匹配
+	PreferredHosts []string `yaml:"preferred_hosts"`
+	// StallTimeout 录制时超过该时长没有收到数据则视为卡住，切换到下一个地址；0 表示不检测
+	StallTimeout time.Duration `yaml:"stall_timeout"`
+}
+
+func (f *StreamFailover) verify() error {
+	switch f.SortBy {
+	case StreamSortByDefault, StreamSortByResolution, StreamSortByBitrate:
+	default:
+		return fmt.Errorf(`the stream_failover sort_by: "%s" is not supported`, f.SortBy)
+	}
+	for _, pattern := range f.PreferredHosts {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf(`invalid preferred host pattern: "%s"`, pattern)
+		}
+	}
+	if f.StallTimeout < 0 {
+		return fmt.Errorf("the stream_failover stall_timeout can not < 0")
+	}
+	return nil
+}
+
+// Storage 磁盘空间保护与录制文件保留策略
+type Storage struct {
+	// MinFreeSpace 输出目录所在磁盘的最小剩余空间（字节），低于该值时暂停开始新的录制；0 表示不检测
+	MinFreeSpace int64 `yaml:"min_free_space"`
+	// CheckInterval 检查剩余空间的间隔
+	CheckInterval time.Duration `yaml:"check_interval"`
+	Retention     Retention     `yaml:"retention"`
+}
+
+// Retention 录制文件的保留规则，按主播（直播间）分别计算，任一规则命中即删除；依赖录制历史
+type Retention struct {
+	Enable bool `yaml:"enable"`
+	// MaxAge 删除开始时间早于该时长的录制，0 表示不限制
+	MaxAge time.Duration `yaml:"max_age"`
+	// MaxSizePerStreamer 每个主播录制文件的最大总大小（字节），超出时从最早的录制开始删除，0 表示不限制
+	MaxSizePerStreamer int64 `yaml:"max_size_per_streamer"`
+	// KeepLast 每个主播只保留最近的 N 次录制，0 表示不限制
+	KeepLast int `yaml:"keep_last"`
+	// Interval 执行清理的间隔
+	Interval time.Duration `yaml:"interval"`
+	// DryRun 只记录将要删除的文件，不实际删除
+	DryRun bool `yaml:"dry_run"`
+}
+
+func (s *Storage) verify() error {
+	if s.MinFreeSpace < 0 {
+		return fmt.Errorf("the storage min_free_space can not < 0")
+	}
+	if s.MinFreeSpace > 0 && s.CheckInterval <= 0 {
+		return fmt.Errorf("the storage check_interval must > 0")
+	}
+	r := &s.Retention
+	if r.MaxAge < 0 || r.MaxSizePerStreamer < 0 || r.KeepLast < 0 {
+		return fmt.Errorf("the retention rules can not < 0")
+	}
+	if !r.Enable {
+		return nil
+	}
+	if r.MaxAge == 0 && r.MaxSizePerStreamer == 0 && r.KeepLast == 0 {
+		return fmt.Errorf("retention is enabled, but no rule is set")
+	}
+	if r.Interval <= 0 {
+		return fmt.Errorf("the retention interval must > 0")
+	}
+	return nil
+}
+
+// 后处理任务的步骤
+const (
+	JobStepFixFlv            = "fix_flv"
+	JobStepConvertMp4        = "convert_mp4"
+	JobStepCustomCommandline = "custom_commandline"
+	JobStepUpload            = "upload"
+)
+
+// Jobs 录制后处理任务队列，任务保存在 AppDataPath 下，程序重启后继续执行
+type Jobs struct {
+	// Workers 同时执行的后处理任务数，最少为 1
+	Workers int `yaml:"workers"`
+	// MaxAttempts 每个任务最多执行的次数（最少为 1），超过后标记为失败
+	MaxAttempts int `yaml:"max_attempts"`
+	// RetryBackoff 第一次重试前的等待时间，之后每次重试翻倍
+	RetryBackoff time.Duration `yaml:"retry_backoff"`
+	// Steps 后处理步骤的执行顺序，各步骤是否执行仍由 on_record_finished 决定；
+	// 为空时保持原有行为：设置了 custom_commandline 时只执行它，否则依次执行 fix_flv、convert_mp4，最后执行 upload
+	Steps []string `yaml:"steps"`
+}
+
+func (j *Jobs) verify() error {
+	if j.Workers < 0 || j.MaxAttempts < 0 || j.RetryBackoff < 0 {
+		return fmt.Errorf("the jobs workers, max_attempts and retry_backoff can not < 0")
+	}
+	for _, step := range j.Steps {
+		switch step {
+		case JobStepFixFlv, JobStepConvertMp4, JobStepCustomCommandline, JobStepUpload:
+		default:
+			return fmt.Errorf(`the jobs step: "%s" is not supported`, step)
+		}
+	}
+	return nil
+}
+
+// 上传目标
+const (
+	UploadBackendS3     = "s3"
+	UploadBackendWebDAV = "webdav"
+	UploadBackendRclone = "rclone"
+)
+
+// Upload 录制文件的上传，作为后处理任务的 upload 步骤执行，失败时按 jobs 的配置重试
+type Upload struct {
+	Enable  bool   `yaml:"enable"`
+	Backend string `yaml:"backend"`
+	// PathTmpl 远端路径的模板，可以使用 live.Info 的字段、.FileName（本地文件的路径）与 .RelPath（相对输出目录的路径）
+	PathTmpl string `yaml:"path_tmpl"`
+	// DeleteAfterUpload 上传并校验成功后删除本地文件
+	DeleteAfterUpload bool         `yaml:"delete_after_upload"`
+	S3                S3Upload     `yaml:"s3"`
+	WebDAV            WebDAVUpload `yaml:"webdav"`
+	Rclone            RcloneUpload `yaml:"rclone"`
+}
+
+// S3Upload S3 兼容对象存储（AWS S3、MinIO 等）
+type S3Upload struct {
+	// Endpoint 服务地址，例如 http://127.0.0.1:9000
+	Endpoint  string `yaml:"endpoint"`
+	Region    string `yaml:"region"`
+	Bucket    string `yaml:"bucket"`
+	AccessKey string `yaml:"access_key" secret:"true"`
+	SecretKey string `yaml:"secret_key" secret:"true"`
+	// PathStyle 使用 endpoint/bucket/key 形式的地址，MinIO 需要开启
+	PathStyle bool `yaml:"path_style"`
+	// PartSize 分片上传的分片大小（字节），不超过该大小的文件直接上传，最小 5MiB
+	PartSize int64 `yaml:"part_size"`
+}
+
+// WebDAVUpload WebDAV 服务（NAS、Nextcloud 等），上传后按文件大小与 ETag 校验
+type WebDAVUpload struct {
+	// Endpoint 上传的根目录，例如 http://nas:5005/records
+	Endpoint string `yaml:"endpoint"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password" secret:"true"`
+}
+
+// RcloneUpload 通过 rclone 上传到已配置的 remote
+type RcloneUpload struct {
+	// Remote rclone 的 remote 与根目录，例如 nas:records
+	Remote string `yaml:"remote"`
+	// Path rclone 可执行文件的路径，为空时使用 remotetools 管理的 rclone，找不到时从 PATH 查找
+	Path string `yaml:"path"`
+	// ConfigFile rclone 的配置文件，为空时使用 rclone 的默认配置
+	ConfigFile string `yaml:"config_file"`
+	// Args 传给 rclone copyto 的额外参数，例如 ["--bwlimit", "10M"]
+	Args []string `yaml:"args"`
+}
+
+func (u *Upload) verify() error {
+	if u.S3.PartSize < 0 {
+		return fmt.Errorf("the upload s3 part_size can not < 0")
+	}
+	if !u.Enable {
+		return nil
+	}
+	switch u.Backend {
+	case UploadBackendS3:
+		if u.S3.Endpoint == "" || u.S3.Bucket == "" {
+			return fmt.Errorf("the upload s3 endpoint and bucket are required")
+		}
+	case UploadBackendWebDAV:
+		if u.WebDAV.Endpoint == "" {
+			return fmt.Errorf("the upload webdav endpoint is required")
+		}
+	case UploadBackendRclone:
+		if u.Rclone.Remote == "" {
+			return fmt.Errorf("the upload rclone remote is required")
+		}
+	default:
+		return fmt.Errorf(`the upload backend: "%s" is not supported`, u.Backend)
+	}
+	return nil
+}
+
 type Log struct {
 	OutPutFolder string `yaml:"out_put_folder"`
 	SaveLastLog  bool   `yaml:"save_last_log"`
@@ -69,12 +300,13 @@ type Notify struct {
 	Telegram Telegram `yaml:"telegram"`
 	Email    Email    `yaml:"email"`
 	Ntfy     Ntfy     `yaml:"ntfy"`
+	Webhook  Webhook  `yaml:"webhook"`
 }
 
 type Telegram struct {
 	Enable           bool   `yaml:"enable"`
 	WithNotification bool   `yaml:"withNotification"`
-	BotToken         string `yaml:"botToken"`
+	BotToken         string `yaml:"botToken" secret:"true"`
 	ChatID           string `yaml:"chatID"`
 }
 
@@ -83,17 +315,71 @@ type Email struct {
 	SMTPHost       string `yaml:"smtpHost"`
 	SMTPPort       int    `yaml:"smtpPort"`
 	SenderEmail    string `yaml:"senderEmail"`
-	SenderPassword string `yaml:"senderPassword"`
+	SenderPassword string `yaml:"senderPassword" secret:"true"`
 	RecipientEmail string `yaml:"recipientEmail"`
 }
 
 type Ntfy struct {
 	Enable bool   `yaml:"enable"`
 	URL    string `yaml:"URL"`
-	Token  string `yaml:"token"`
+	Token  string `yaml:"token" secret:"true"`
 	Tag    string `yaml:"tag"`
 }
 
+// Webhook 通用的 HTTP 回调通知
+type Webhook struct {
+	Enable    bool              `yaml:"enable"`
+	Endpoints []WebhookEndpoint `yaml:"endpoints"`
+}
+
+// WebhookEvents webhook 可以推送的事件，与 webhook.Events 保持一致
+var WebhookEvents = []string{
+	"LiveStart",
+	"LiveEnd",
+	"RoomNameChanged",
+	"RecordFilterRejected",
+	"RecordFilterAccepted",
+	"RecorderStart",
+	"RecorderStop",
+	"PostProcessFinished",
+}
+
+// WebhookEndpoint 一个回调地址
+type WebhookEndpoint struct {
+	Name    string            `yaml:"name" secret:"key"`
+	URL     string            `yaml:"url" secret:"key"`
+	Method  string            `yaml:"method,omitempty"`
+	Headers map[string]string `yaml:"headers,omitempty" secret:"true"`
+	// Body 为 Go text/template 模板，为空时发送 JSON 格式的事件内容
+	Body string `yaml:"body,omitempty"`
+	// Events 为需要推送的事件，为空时推送全部事件
+	Events []string `yaml:"events,omitempty"`
+	// MaxRetries 失败后的重试次数，不设置时为 3，0 表示不重试
+	MaxRetries *int          `yaml:"max_retries,omitempty"`
+	Timeout    time.Duration `yaml:"timeout,omitempty"`
+}
+
+func (w *Webhook) verify() error {
+	if !w.Enable {
+		return nil
+	}
+	for _, endpoint := range w.Endpoints {
+		u, err := url.Parse(endpoint.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf(`invalid webhook url: "%s"`, endpoint.URL)
+		}
+		if endpoint.MaxRetries != nil && *endpoint.MaxRetries < 0 {
+			return fmt.Errorf(`max_retries of webhook "%s" can not < 0`, endpoint.Name)
+		}
+		for _, typ := range endpoint.Events {
+			if !slices.Contains(WebhookEvents, typ) {
+				return fmt.Errorf(`unknown event "%s" of webhook "%s"`, typ, endpoint.Name)
+			}
+		}
+	}
+	return nil
+}
+
 // Config content all config info.
 type Config struct {
 	File                 string               `yaml:"-"`
@@ -107,9 +393,17 @@ type Config struct {
 	LiveRooms            []LiveRoom           `yaml:"live_rooms"`
 	OutputTmpl           string               `yaml:"out_put_tmpl"`
 	VideoSplitStrategies VideoSplitStrategies `yaml:"video_split_strategies"`
-	Cookies              map[string]string    `yaml:"cookies"`
+	Cookies              map[string]string    `yaml:"cookies" secret:"true"`
+	Proxy                Proxy                `yaml:"proxy"`
+	RateLimit            RateLimit            `yaml:"rate_limit"`
 	OnRecordFinished     OnRecordFinished     `yaml:"on_record_finished"`
 	TimeoutInUs          int                  `yaml:"timeout_in_us"`
+	Danmaku              Danmaku              `yaml:"danmaku"`
+	RecordFilter         RecordFilter         `yaml:"record_filter"`
+	StreamFailover       StreamFailover       `yaml:"stream_failover"`
+	Storage              Storage              `yaml:"storage"`
+	Jobs                 Jobs                 `yaml:"jobs"`
+	Upload               Upload               `yaml:"upload"`
 	Notify               Notify               `yaml:"notify"` // 通知服务配置
 	AppDataPath          string               `yaml:"app_data_path"`
 	// 只读工具目录：如果指定，则优先从该目录查找外部工具（适用于 Docker 镜像内预置工具）
@@ -119,25 +413,56 @@ type Config struct {
 	ToolRootFolder string `yaml:"tool_root_folder"`
 
 	liveRoomIndexCache map[string]int
+	// secretRefs 敏感字段引用的环境变量或文件，按字段路径索引
+	secretRefs map[string]secretRef
 }
 
-var config *Config
+// currentConfig 重新加载配置时会被替换，可能被多个协程同时读取
+var currentConfig atomic.Pointer[Config]
 
 func SetCurrentConfig(cfg *Config) {
-	config = cfg
+	currentConfig.Store(cfg)
 }
 
 func GetCurrentConfig() *Config {
-	return config
+	return currentConfig.Load()
 }
 
 type LiveRoom struct {
-	Url         string       `yaml:"url"`
+	Url         string       `yaml:"url" secret:"key"`
 	IsListening bool         `yaml:"is_listening"`
 	LiveId      types.LiveID `yaml:"-"`
 	Quality     int          `yaml:"quality,omitempty"`
 	AudioOnly   bool         `yaml:"audio_only,omitempty"`
 	NickName    string       `yaml:"nick_name,omitempty"`
+	// 以下为房间级别的覆盖配置，未设置时使用全局配置
+	OutPutPath           string                        `yaml:"out_put_path,omitempty"`
+	OutputTmpl           string                        `yaml:"out_put_tmpl,omitempty"`
+	VideoSplitStrategies *VideoSplitStrategiesOverride `yaml:"video_split_strategies,omitempty"`
+	OnRecordFinished     *OnRecordFinishedOverride     `yaml:"on_record_finished,omitempty"`
+	// Schedule 录制时间表，未设置时全天录制
+	Schedule *Schedule `yaml:"schedule,omitempty"`
+	// RecordFilter 录制规则，设置后整体替换全局的 record_filter
+	RecordFilter *RecordFilter `yaml:"record_filter,omitempty"`
+	// Proxy 房间使用的代理，优先于全局与按域名设置的代理，值为 direct 时直连
+	Proxy string `yaml:"proxy,omitempty" secret:"true"`
+}
+
+// VideoSplitStrategiesOverride 房间级别的分段策略，未设置的字段使用全局配置
+type VideoSplitStrategiesOverride struct {
+	OnRoomNameChanged *bool          `yaml:"on_room_name_changed,omitempty"`
+	MaxDuration       *time.Duration `yaml:"max_duration,omitempty"`
+	MaxFileSize       *int           `yaml:"max_file_size,omitempty"`
+}
+
+// OnRecordFinishedOverride 房间级别的录制后处理，未设置的字段使用全局配置
+type OnRecordFinishedOverride struct {
+	ConvertToMp4          *bool   `yaml:"convert_to_mp4,omitempty"`
+	Remuxer               *string `yaml:"remuxer,omitempty"`
+	FragmentedMp4         *bool   `yaml:"fragmented_mp4,omitempty"`
+	DeleteFlvAfterConvert *bool   `yaml:"delete_flv_after_convert,omitempty"`
+	CustomCommandline     *string `yaml:"custom_commandline,omitempty"`
+	FixFlvAtFirst         *bool   `yaml:"fix_flv_at_first,omitempty"`
 }
 
 type liveRoomAlias LiveRoom
@@ -185,6 +510,7 @@ var defaultConfig = Config{
 	},
 	Feature: Feature{
 		UseNativeFlvParser:         false,
+		UseNativeHlsParser:         false,
 		RemoveSymbolOtherCharacter: false,
 	},
 	LiveRooms:          []LiveRoom{},
@@ -195,10 +521,42 @@ var defaultConfig = Config{
 	},
 	OnRecordFinished: OnRecordFinished{
 		ConvertToMp4:          false,
+		Remuxer:               RemuxerFFmpeg,
 		DeleteFlvAfterConvert: false,
 		FixFlvAtFirst:         true,
 	},
 	TimeoutInUs: 60000000,
+	RateLimit: RateLimit{
+		Backoff:    30 * time.Second,
+		MaxBackoff: 10 * time.Minute,
+	},
+	Danmaku: Danmaku{
+		Enable: false,
+		Format: "xml",
+	},
+	Storage: Storage{
+		MinFreeSpace:  0,
+		CheckInterval: time.Minute,
+		Retention: Retention{
+			Enable:   false,
+			Interval: time.Hour,
+		},
+	},
+	Jobs: Jobs{
+		Workers:      2,
+		MaxAttempts:  3,
+		RetryBackoff: 30 * time.Second,
+	},
+	Upload: Upload{
+		Enable:   false,
+		Backend:  UploadBackendS3,
+		PathTmpl: "{{ .RelPath }}",
+		S3: S3Upload{
+			Region:    "us-east-1",
+			PathStyle: true,
+			PartSize:  16 << 20,
+		},
+	},
 	Notify: Notify{
 		Telegram: Telegram{
 			Enable:           false,
@@ -260,12 +618,181 @@ func (c *Config) Verify() error {
 	if maxDur := c.VideoSplitStrategies.MaxDuration; maxDur > 0 && maxDur < time.Minute {
 		return fmt.Errorf("the minimum value of max_duration is one minute")
 	}
+	if err := c.Notify.Webhook.verify(); err != nil {
+		return err
+	}
+	if err := c.StreamFailover.verify(); err != nil {
+		return err
+	}
+	if err := c.Storage.verify(); err != nil {
+		return err
+	}
+	if err := c.Jobs.verify(); err != nil {
+		return err
+	}
+	if err := c.Upload.verify(); err != nil {
+		return err
+	}
+	if err := c.OnRecordFinished.verify(); err != nil {
+		return err
+	}
+	if err := c.RecordFilter.verify(); err != nil {
+		return fmt.Errorf("invalid record_filter: %w", err)
+	}
+	if err := c.Proxy.verify(); err != nil {
+		return fmt.Errorf("invalid proxy: %w", err)
+	}
+	if err := c.RateLimit.verify(); err != nil {
+		return fmt.Errorf("invalid rate_limit: %w", err)
+	}
+	if c.Danmaku.Enable && !danmaku.IsValidFormat(c.Danmaku.Format) {
+		return fmt.Errorf(`the danmaku format: "%s" is not supported`, c.Danmaku.Format)
+	}
+	for index := range c.LiveRooms {
+		room := &c.LiveRooms[index]
+		if room.OutPutPath != "" {
+			if _, err := os.Stat(room.OutPutPath); err != nil {
+				return fmt.Errorf(`the out put path: "%s" of room "%s" is not exist`, room.OutPutPath, room.Url)
+			}
+		}
+		if maxDur := c.GetVideoSplitStrategies(room).MaxDuration; maxDur > 0 && maxDur < time.Minute {
+			return fmt.Errorf(`the minimum value of max_duration is one minute, room: "%s"`, room.Url)
+		}
+		if err := c.GetOnRecordFinished(room).verify(); err != nil {
+			return fmt.Errorf(`%w, room: "%s"`, err, room.Url)
+		}
+		if room.Schedule != nil {
+			if err := room.Schedule.verify(); err != nil {
+				return fmt.Errorf(`invalid schedule of room "%s": %w`, room.Url, err)
+			}
+		}
+		if room.RecordFilter != nil {
+			if err := room.RecordFilter.verify(); err != nil {
+				return fmt.Errorf(`invalid record_filter of room "%s": %w`, room.Url, err)
+			}
+		}
+		if err := verifyProxy(room.Proxy); err != nil {
+			return fmt.Errorf(`invalid proxy of room "%s": %w`, room.Url, err)
+		}
+	}
 	if !c.RPC.Enable && len(c.LiveRooms) == 0 {
 		return fmt.Errorf("the RPC is not enabled, and no live room is set. the program has nothing to do using this setting")
 	}
 	return nil
 }
 
+// GetOutPutPath 获取房间实际使用的输出目录，room 为 nil 时返回全局配置
+func (c *Config) GetOutPutPath(room *LiveRoom) string {
+	if room != nil && room.OutPutPath != "" {
+		return room.OutPutPath
+	}
+	return c.OutPutPath
+}
+
+// GetOutputTmpl 获取房间实际使用的文件名模板，room 为 nil 时返回全局配置
+func (c *Config) GetOutputTmpl(room *LiveRoom) string {
+	if room != nil && room.OutputTmpl != "" {
+		return room.OutputTmpl
+	}
+	return c.OutputTmpl
+}
+
+// GetVideoSplitStrategies 获取房间实际使用的分段策略，room 为 nil 时返回全局配置
+func (c *Config) GetVideoSplitStrategies(room *LiveRoom) VideoSplitStrategies {
+	ret := c.VideoSplitStrategies
+	if room == nil || room.VideoSplitStrategies == nil {
+		return ret
+	}
+	o := room.VideoSplitStrategies
+	if o.OnRoomNameChanged != nil {
+		ret.OnRoomNameChanged = *o.OnRoomNameChanged
+	}
+	if o.MaxDuration != nil {
+		ret.MaxDuration = *o.MaxDuration
+	}
+	if o.MaxFileSize != nil {
+		ret.MaxFileSize = *o.MaxFileSize
+	}
+	return ret
+}
+
+// GetOnRecordFinished 获取房间实际使用的录制后处理配置，room 为 nil 时返回全局配置
+func (c *Config) GetOnRecordFinished(room *LiveRoom) OnRecordFinished {
+	ret := c.OnRecordFinished
+	if room == nil || room.OnRecordFinished == nil {
+		return ret
+	}
+	o := room.OnRecordFinished
+	if o.ConvertToMp4 != nil {
+		ret.ConvertToMp4 = *o.ConvertToMp4
+	}
+	if o.Remuxer != nil {
+		ret.Remuxer = *o.Remuxer
+	}
+	if o.FragmentedMp4 != nil {
+		ret.FragmentedMp4 = *o.FragmentedMp4
+	}
+	if o.DeleteFlvAfterConvert != nil {
+		ret.DeleteFlvAfterConvert = *o.DeleteFlvAfterConvert
+	}
+	if o.CustomCommandline != nil {
+		ret.CustomCommandline = *o.CustomCommandline
+	}
+	if o.FixFlvAtFirst != nil {
+		ret.FixFlvAtFirst = *o.FixFlvAtFirst
+	}
+	return ret
+}
+
+// GetSchedule 获取房间的录制时间表，room 为 nil 或未设置时返回 nil，表示全天录制
+func (room *LiveRoom) GetSchedule() *Schedule {
+	if room == nil {
+		return nil
+	}
+	return room.Schedule
+}
+
+// GetRecordFilter 获取房间实际使用的录制规则，room 为 nil 或未设置时返回全局配置
+func (c *Config) GetRecordFilter(room *LiveRoom) *RecordFilter {
+	if room != nil && room.RecordFilter != nil {
+		return room.RecordFilter
+	}
+	return &c.RecordFilter
+}
+
+// FindLiveRoomByUrl 查找房间配置，找不到时返回 nil，便于直接传给 GetOutPutPath 等方法回退到全局配置。
+// 与 GetLiveRoomByUrl 不同，它不会修改索引缓存，可以在录制协程中并发调用。
+func (c *Config) FindLiveRoomByUrl(url string) *LiveRoom {
+	for index := range c.LiveRooms {
+		if c.LiveRooms[index].Url == url {
+			return &c.LiveRooms[index]
+		}
+	}
+	return nil
+}
+
+// Copy 复制配置，房间列表与 cookies 不与原配置共用，
+// 修改后替换当前配置，避免其他协程读取到修改了一半的配置
+func (c *Config) Copy() *Config {
+	ret := *c
+	ret.LiveRooms = append([]LiveRoom(nil), c.LiveRooms...)
+	if c.Cookies != nil {
+		ret.Cookies = make(map[string]string, len(c.Cookies))
+		for k, v := range c.Cookies {
+			ret.Cookies[k] = v
+		}
+	}
+	ret.liveRoomIndexCache = map[string]int{}
+	ret.RefreshLiveRoomIndexCache()
+	if c.secretRefs != nil {
+		ret.secretRefs = make(map[string]secretRef, len(c.secretRefs))
+		for k, v := range c.secretRefs {
+			ret.secretRefs[k] = v
+		}
+	}
+	return &ret
+}
+
 // todo remove this function
 func (c *Config) RefreshLiveRoomIndexCache() {
 	for index, room := range c.LiveRooms {
@@ -310,6 +837,9 @@ func NewConfigWithBytes(b []byte) (*Config, error) {
 	if err := yaml.Unmarshal(b, &config); err != nil {
 		return nil, err
 	}
+	if err := config.resolveSecrets(); err != nil {
+		return nil, err
+	}
 	config.RefreshLiveRoomIndexCache()
 	newConfigPostProcess(&config)
 	return &config, nil
@@ -336,7 +866,12 @@ func (c *Config) Marshal() error {
 	if c.File == "" {
 		return errors.New("config path not set")
 	}
-	b, err := yaml.Marshal(c)
+	// 引用环境变量或文件的敏感字段写回引用，不保存明文
+	out, err := c.withSecretRefs()
+	if err != nil {
+		return err
+	}
+	b, err := yaml.Marshal(out)
 	if err != nil {
 		return err
 	}
@@ -3,6 +3,7 @@ package configs
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -41,4 +42,94 @@ func TestConfig_Verify(t *testing.T) {
 	cfg.OutPutPath = os.TempDir()
 	cfg.RPC.Enable = false
 	assert.Error(t, cfg.Verify())
+	cfg.RPC.Enable = true
+	cfg.Jobs.Steps = []string{JobStepConvertMp4, JobStepFixFlv}
+	assert.NoError(t, cfg.Verify())
+	cfg.Jobs.Steps = []string{"danmaku"}
+	assert.Error(t, cfg.Verify())
+	cfg.Jobs = Jobs{Workers: -1}
+	assert.Error(t, cfg.Verify())
+	cfg.Jobs = Jobs{}
+	cfg.Upload = Upload{Enable: true, Backend: UploadBackendS3}
+	assert.Error(t, cfg.Verify())
+	cfg.Upload.S3 = S3Upload{Endpoint: "http://127.0.0.1:9000", Bucket: "records"}
+	assert.NoError(t, cfg.Verify())
+	cfg.Upload.Backend = "ftp"
+	assert.Error(t, cfg.Verify())
+	cfg.Upload.Backend = UploadBackendWebDAV
+	assert.Error(t, cfg.Verify())
+	cfg.Upload.WebDAV.Endpoint = "http://nas:5005/records"
+	assert.NoError(t, cfg.Verify())
+	cfg.Upload.Backend = UploadBackendRclone
+	assert.Error(t, cfg.Verify())
+	cfg.Upload.Rclone.Remote = "nas:records"
+	assert.NoError(t, cfg.Verify())
+	cfg.Upload = Upload{}
+	cfg.Notify.Webhook = Webhook{Enable: true, Endpoints: []WebhookEndpoint{{URL: "https://example.com", Events: []string{"LiveStart"}}}}
+	assert.NoError(t, cfg.Verify())
+	cfg.Notify.Webhook.Endpoints[0].Events = []string{"LiveStarted"}
+	assert.Error(t, cfg.Verify())
+}
+
+func TestConfig_LiveRoomOverride(t *testing.T) {
+	cfg := NewConfig()
+	cfg.OutPutPath = os.TempDir()
+	cfg.VideoSplitStrategies.MaxFileSize = 1024
+	cfg.OnRecordFinished.ConvertToMp4 = true
+	maxDur := time.Hour
+	convert := false
+	cfg.LiveRooms = []LiveRoom{
+		{Url: "https://live.bilibili.com/1"},
+		{
+			Url:                  "https://live.bilibili.com/2",
+			OutPutPath:           "foobar",
+			OutputTmpl:           "{{ .HostName }}.flv",
+			VideoSplitStrategies: &VideoSplitStrategiesOverride{MaxDuration: &maxDur},
+			OnRecordFinished:     &OnRecordFinishedOverride{ConvertToMp4: &convert},
+		},
+	}
+
+	assert.Nil(t, cfg.FindLiveRoomByUrl("https://live.bilibili.com/3"))
+	room := cfg.FindLiveRoomByUrl("https://live.bilibili.com/1")
+	assert.Equal(t, cfg.OutPutPath, cfg.GetOutPutPath(room))
+	assert.Equal(t, cfg.VideoSplitStrategies, cfg.GetVideoSplitStrategies(room))
+	assert.Equal(t, cfg.OnRecordFinished, cfg.GetOnRecordFinished(nil))
+
+	room = cfg.FindLiveRoomByUrl("https://live.bilibili.com/2")
+	assert.Equal(t, "foobar", cfg.GetOutPutPath(room))
+	assert.Equal(t, "{{ .HostName }}.flv", cfg.GetOutputTmpl(room))
+	assert.Equal(t, time.Hour, cfg.GetVideoSplitStrategies(room).MaxDuration)
+	assert.Equal(t, 1024, cfg.GetVideoSplitStrategies(room).MaxFileSize)
+	assert.False(t, cfg.GetOnRecordFinished(room).ConvertToMp4)
+	assert.Equal(t, RemuxerFFmpeg, cfg.GetOnRecordFinished(room).Remuxer)
+
+	// 房间的输出目录不存在
+	assert.Error(t, cfg.Verify())
+	room.OutPutPath = os.TempDir()
+	assert.NoError(t, cfg.Verify())
+	maxDur = time.Second
+	assert.Error(t, cfg.Verify())
+	maxDur = time.Hour
+	remuxer := "mp4box"
+	room.OnRecordFinished.Remuxer = &remuxer
+	assert.Error(t, cfg.Verify())
+	remuxer = RemuxerNative
+	assert.NoError(t, cfg.Verify())
+}
+
+func TestConfig_Copy(t *testing.T) {
+	c := NewConfig()
+	c.LiveRooms = []LiveRoom{{Url: "https://live.bilibili.com/1"}}
+	c.Cookies = map[string]string{"live.bilibili.com": "a=1"}
+	c.RefreshLiveRoomIndexCache()
+
+	n := c.Copy()
+	n.LiveRooms[0].IsListening = true
+	n.Cookies["live.bilibili.com"] = "a=2"
+	assert.NoError(t, n.RemoveLiveRoomByUrl("https://live.bilibili.com/1"))
+	assert.Empty(t, n.LiveRooms)
+	assert.Len(t, c.LiveRooms, 1)
+	assert.False(t, c.LiveRooms[0].IsListening)
+	assert.Equal(t, "a=1", c.Cookies["live.bilibili.com"])
+	assert.NotNil(t, c.FindLiveRoomByUrl("https://live.bilibili.com/1"))
 }
@@ -0,0 +1,76 @@
+package configs
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// ProxyDirect 用于按域名或房间设置不使用代理，覆盖上一级的代理
+const ProxyDirect = "direct"
+
+// Proxy 访问直播平台 api 与下载直播流使用的代理，支持 http、https 与 socks5。
+// 代理地址中可能包含用户名与密码，作为敏感字段处理。
+// 优先级为：房间的 proxy > domains 中直播间地址的域名 > url
+type Proxy struct {
+	// Url 全局代理，为空时直连
+	Url string `yaml:"url" secret:"true"`
+	// Domains 按直播间地址的域名设置代理，值为 direct 时直连
+	Domains map[string]string `yaml:"domains" secret:"true"`
+}
+
+// ParseProxyUrl 解析代理地址，只支持 http、https 与 socks5
+func ParseProxyUrl(proxy string) (*url.URL, error) {
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf(`the scheme of proxy "%s" is not supported, should be http, https or socks5`, proxy)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf(`the host of proxy "%s" is empty`, proxy)
+	}
+	return u, nil
+}
+
+func verifyProxy(proxy string) error {
+	if proxy == "" || proxy == ProxyDirect {
+		return nil
+	}
+	_, err := ParseProxyUrl(proxy)
+	return err
+}
+
+func (p *Proxy) verify() error {
+	if p.Url == ProxyDirect {
+		return fmt.Errorf("the global proxy can not be %s, leave it empty to connect directly", ProxyDirect)
+	}
+	if err := verifyProxy(p.Url); err != nil {
+		return err
+	}
+	for domain, proxy := range p.Domains {
+		if err := verifyProxy(proxy); err != nil {
+			return fmt.Errorf(`invalid proxy of domain "%s": %w`, domain, err)
+		}
+	}
+	return nil
+}
+
+// GetProxy 获取房间实际使用的代理地址，room 为 nil 时返回全局配置。
+// 返回空字符串表示未设置代理，按 HTTP_PROXY 等环境变量连接；返回 ProxyDirect 表示明确设置为直连，不使用环境变量中的代理
+func (c *Config) GetProxy(room *LiveRoom) string {
+	proxy := c.Proxy.Url
+	if room != nil {
+		if u, err := url.Parse(room.Url); err == nil {
+			if v, ok := c.Proxy.Domains[u.Host]; ok && v != "" {
+				proxy = v
+			}
+		}
+		if room.Proxy != "" {
+			proxy = room.Proxy
+		}
+	}
+	return proxy
+}
@@ -0,0 +1,37 @@
+package configs
+
+import (
+	"fmt"
+	"time"
+)
+
+// RateLimit 访问直播平台 api 的频率限制，所有直播间按域名共享
+type RateLimit struct {
+	// DefaultQPS 未在 domains 中设置的域名每秒最多请求数，0 表示不限制
+	DefaultQPS float64 `yaml:"default_qps"`
+	// Domains 按域名设置每秒最多请求数，同时匹配子域名，例如 bilibili.com 包括 api.live.bilibili.com，
+	// 匹配同一项的域名共用一个令牌桶
+	Domains map[string]float64 `yaml:"domains"`
+	// Backoff 被平台限流（HTTP 412、429 或平台的风控错误码）后暂停请求的时长，连续被限流时加倍，最长为 MaxBackoff，
+	// 0 表示使用默认值 30s 与 10m
+	Backoff    time.Duration `yaml:"backoff"`
+	MaxBackoff time.Duration `yaml:"max_backoff"`
+}
+
+func (r *RateLimit) verify() error {
+	if r.DefaultQPS < 0 {
+		return fmt.Errorf("default_qps can not < 0")
+	}
+	for domain, qps := range r.Domains {
+		if qps < 0 {
+			return fmt.Errorf(`the qps of domain "%s" can not < 0`, domain)
+		}
+	}
+	if r.Backoff < 0 || r.MaxBackoff < 0 {
+		return fmt.Errorf("backoff and max_backoff can not < 0")
+	}
+	if r.MaxBackoff > 0 && r.MaxBackoff < r.Backoff {
+		return fmt.Errorf("max_backoff can not < backoff")
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package configs
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// RecordFilter 开播后决定是否录制的规则，在开播与房间标题变化时检查，全部满足才录制
+type RecordFilter struct {
+	// TitleInclude 房间标题需匹配其中任一正则，为空时不限制
+	TitleInclude []string `yaml:"title_include,omitempty"`
+	// TitleExclude 房间标题匹配其中任一正则时不录制
+	TitleExclude []string `yaml:"title_exclude,omitempty"`
+	// Categories 允许录制的分区（不区分大小写），为空时不限制；平台未提供分区时不检查
+	Categories []string `yaml:"categories,omitempty"`
+	// MinOnline 开始录制所需的最少在线人数，0 表示不限制；平台未提供在线人数时不检查
+	MinOnline int64 `yaml:"min_online,omitempty"`
+}
+
+func (f *RecordFilter) verify() error {
+	for _, patterns := range [][]string{f.TitleInclude, f.TitleExclude} {
+		for _, pattern := range patterns {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return fmt.Errorf("invalid title pattern %q: %w", pattern, err)
+			}
+		}
+	}
+	if f.MinOnline < 0 {
+		return fmt.Errorf("min_online can not < 0")
+	}
+	return nil
+}
+
+func matchAny(patterns []string, s string) (string, bool) {
+	for _, pattern := range patterns {
+		if ok, err := regexp.MatchString(pattern, s); err == nil && ok {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// Check 检查直播间是否满足录制规则，满足时返回空字符串，否则返回不录制的原因
+func (f *RecordFilter) Check(title, category string, online int64) string {
+	if f == nil {
+		return ""
+	}
+	if len(f.TitleInclude) > 0 {
+		if _, ok := matchAny(f.TitleInclude, title); !ok {
+			return fmt.Sprintf("title %q does not match title_include", title)
+		}
+	}
+	if pattern, ok := matchAny(f.TitleExclude, title); ok {
+		return fmt.Sprintf("title %q matches title_exclude %q", title, pattern)
+	}
+	if len(f.Categories) > 0 && category != "" {
+		allowed := false
+		for _, c := range f.Categories {
+			if strings.EqualFold(strings.TrimSpace(c), category) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Sprintf("category %q is not in categories", category)
+		}
+	}
+	if f.MinOnline > 0 && online > 0 && online < f.MinOnline {
+		return fmt.Sprintf("online %d is less than min_online %d", online, f.MinOnline)
+	}
+	return ""
+}
@@ -0,0 +1,44 @@
+package configs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordFilterCheck(t *testing.T) {
+	var nilFilter *RecordFilter
+	assert.Empty(t, nilFilter.Check("any", "", 0))
+
+	f := &RecordFilter{
+		TitleInclude: []string{"唱", "(?i)karaoke"},
+		TitleExclude: []string{"回放"},
+		Categories:   []string{"唱见电台", " Music "},
+		MinOnline:    100,
+	}
+	assert.NoError(t, f.verify())
+	assert.Empty(t, f.Check("今晚唱歌", "唱见电台", 1000))
+	assert.Empty(t, f.Check("KARAOKE night", "music", 1000))
+	assert.Contains(t, f.Check("英雄联盟排位", "唱见电台", 1000), "title_include")
+	assert.Contains(t, f.Check("唱歌回放", "唱见电台", 1000), "title_exclude")
+	assert.Contains(t, f.Check("唱歌", "英雄联盟", 1000), "categories")
+	assert.Contains(t, f.Check("唱歌", "唱见电台", 10), "min_online")
+	// 平台未提供分区与在线人数时不检查
+	assert.Empty(t, f.Check("唱歌", "", 0))
+
+	f.TitleExclude = []string{"("}
+	assert.Error(t, f.verify())
+	f.TitleExclude = nil
+	f.MinOnline = -1
+	assert.Error(t, f.verify())
+}
+
+func TestConfig_GetRecordFilter(t *testing.T) {
+	cfg := NewConfig()
+	cfg.RecordFilter.TitleExclude = []string{"游戏"}
+	room := &LiveRoom{Url: "https://live.bilibili.com/1"}
+	assert.Equal(t, &cfg.RecordFilter, cfg.GetRecordFilter(nil))
+	assert.Equal(t, &cfg.RecordFilter, cfg.GetRecordFilter(room))
+	room.RecordFilter = &RecordFilter{Categories: []string{"唱见电台"}}
+	assert.Equal(t, room.RecordFilter, cfg.GetRecordFilter(room))
+}
@@ -0,0 +1,163 @@
+package configs
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// 录制窗口外监控器的行为
+const (
+	// ScheduleStopPolling 停止轮询直播间状态，直到下一个窗口开始
+	ScheduleStopPolling = "stop_polling"
+	// ScheduleLogOnly 继续轮询，但只记录开播日志，不录制也不发送通知
+	ScheduleLogOnly = "log_only"
+)
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+// Schedule 直播间的录制时间表，windows 与 cron 任一命中即处于录制窗口内
+type Schedule struct {
+	Windows []ScheduleWindow `yaml:"windows,omitempty"`
+	// Cron 为录制窗口开始时间的 cron 表达式（分 时 日 月 周），每个窗口持续 Duration
+	Cron     []string      `yaml:"cron,omitempty"`
+	Duration time.Duration `yaml:"duration,omitempty"`
+	// OutsideAction 窗口外的行为，stop_polling（默认）或 log_only
+	OutsideAction string `yaml:"outside_action,omitempty"`
+}
+
+// ScheduleWindow 按星期与每日时间段指定的录制窗口，End 不晚于 Start 时表示跨过零点
+type ScheduleWindow struct {
+	// Weekdays 为空时表示每天，例如 [mon, wed, fri]
+	Weekdays []string `yaml:"weekdays,omitempty"`
+	// Start、End 格式为 15:04
+	Start string `yaml:"start"`
+	End   string `yaml:"end"`
+}
+
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected format is 15:04", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func (w *ScheduleWindow) verify() error {
+	for _, day := range w.Weekdays {
+		if _, ok := weekdayNames[strings.ToLower(strings.TrimSpace(day))]; !ok {
+			return fmt.Errorf("invalid weekday %q", day)
+		}
+	}
+	if _, err := parseClock(w.Start); err != nil {
+		return err
+	}
+	if _, err := parseClock(w.End); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (w *ScheduleWindow) matchWeekday(day time.Weekday) bool {
+	if len(w.Weekdays) == 0 {
+		return true
+	}
+	for _, name := range w.Weekdays {
+		if weekdayNames[strings.ToLower(strings.TrimSpace(name))] == day {
+			return true
+		}
+	}
+	return false
+}
+
+// contains 判断 t 是否落在窗口内，跨零点的窗口属于开始那一天
+func (w *ScheduleWindow) contains(t time.Time) bool {
+	start, err := parseClock(w.Start)
+	if err != nil {
+		return false
+	}
+	end, err := parseClock(w.End)
+	if err != nil {
+		return false
+	}
+	if end <= start {
+		end += 24 * time.Hour
+	}
+	for _, offset := range []int{0, -1} {
+		day := time.Date(t.Year(), t.Month(), t.Day()+offset, 0, 0, 0, 0, t.Location())
+		if !w.matchWeekday(day.Weekday()) {
+			continue
+		}
+		begin := day.Add(start)
+		if !t.Before(begin) && t.Before(day.Add(end)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Schedule) verify() error {
+	for i := range s.Windows {
+		if err := s.Windows[i].verify(); err != nil {
+			return err
+		}
+	}
+	for _, spec := range s.Cron {
+		if _, err := cron.ParseStandard(spec); err != nil {
+			return fmt.Errorf("invalid cron expression %q: %w", spec, err)
+		}
+	}
+	if len(s.Cron) > 0 && s.Duration <= 0 {
+		return fmt.Errorf("duration must be set when using cron")
+	}
+	if len(s.Windows) == 0 && len(s.Cron) == 0 {
+		return fmt.Errorf("at least one window or cron expression is required")
+	}
+	switch s.OutsideAction {
+	case "", ScheduleStopPolling, ScheduleLogOnly:
+	default:
+		return fmt.Errorf("invalid outside_action %q", s.OutsideAction)
+	}
+	return nil
+}
+
+// InWindow 判断 t 是否处于录制窗口内，未设置时间表时总是返回 true
+func (s *Schedule) InWindow(t time.Time) bool {
+	if s == nil {
+		return true
+	}
+	for i := range s.Windows {
+		if s.Windows[i].contains(t) {
+			return true
+		}
+	}
+	for _, spec := range s.Cron {
+		sched, err := cron.ParseStandard(spec)
+		if err != nil {
+			continue
+		}
+		// 在 (t - Duration, t] 内有过触发，说明 t 落在该次触发开始的窗口内
+		if !sched.Next(t.Add(-s.Duration)).After(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetOutsideAction 获取窗口外的行为，默认停止轮询
+func (s *Schedule) GetOutsideAction() string {
+	if s == nil || s.OutsideAction == "" {
+		return ScheduleStopPolling
+	}
+	return s.OutsideAction
+}
@@ -0,0 +1,60 @@
+package configs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduleInWindow(t *testing.T) {
+	var nilSchedule *Schedule
+	assert.True(t, nilSchedule.InWindow(time.Now()))
+	assert.Equal(t, ScheduleStopPolling, nilSchedule.GetOutsideAction())
+
+	// 2024-01-05 为星期五
+	at := func(day, hour, min int) time.Time {
+		return time.Date(2024, 1, day, hour, min, 0, 0, time.Local)
+	}
+	s := &Schedule{
+		Windows: []ScheduleWindow{
+			{Weekdays: []string{"fri"}, Start: "22:00", End: "02:00"},
+			{Weekdays: []string{"Monday", "wed"}, Start: "20:00", End: "21:30"},
+		},
+	}
+	assert.NoError(t, s.verify())
+	assert.True(t, s.InWindow(at(5, 22, 0)))
+	assert.True(t, s.InWindow(at(6, 1, 59)))
+	assert.False(t, s.InWindow(at(6, 2, 0)))
+	assert.False(t, s.InWindow(at(5, 1, 0)))
+	assert.False(t, s.InWindow(at(5, 21, 59)))
+	assert.True(t, s.InWindow(at(1, 20, 30)))
+	assert.True(t, s.InWindow(at(3, 21, 29)))
+	assert.False(t, s.InWindow(at(2, 20, 30)))
+
+	// 每个工作日 19:30 开始，持续两小时
+	s = &Schedule{Cron: []string{"30 19 * * 1-5"}, Duration: 2 * time.Hour, OutsideAction: ScheduleLogOnly}
+	assert.NoError(t, s.verify())
+	assert.Equal(t, ScheduleLogOnly, s.GetOutsideAction())
+	assert.True(t, s.InWindow(at(5, 19, 30)))
+	assert.True(t, s.InWindow(at(5, 21, 29)))
+	assert.False(t, s.InWindow(at(5, 21, 30)))
+	assert.False(t, s.InWindow(at(5, 19, 29)))
+	assert.False(t, s.InWindow(at(6, 20, 0)))
+}
+
+func TestScheduleVerify(t *testing.T) {
+	assert.Error(t, (&Schedule{}).verify())
+	assert.Error(t, (&Schedule{Windows: []ScheduleWindow{{Weekdays: []string{"someday"}, Start: "20:00", End: "21:00"}}}).verify())
+	assert.Error(t, (&Schedule{Windows: []ScheduleWindow{{Start: "8pm", End: "21:00"}}}).verify())
+	assert.Error(t, (&Schedule{Cron: []string{"30 19 * * 1-5"}}).verify())
+	assert.Error(t, (&Schedule{Cron: []string{"every day"}, Duration: time.Hour}).verify())
+	assert.Error(t, (&Schedule{Cron: []string{"0 20 * * *"}, Duration: time.Hour, OutsideAction: "pause"}).verify())
+
+	cfg := NewConfig()
+	cfg.OutPutPath = t.TempDir()
+	cfg.LiveRooms = []LiveRoom{{Url: "https://live.bilibili.com/1", Schedule: &Schedule{}}}
+	assert.Error(t, cfg.Verify())
+	cfg.LiveRooms[0].Schedule.Windows = []ScheduleWindow{{Start: "20:00", End: "21:00"}}
+	assert.NoError(t, cfg.Verify())
+}
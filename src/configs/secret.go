@@ -0,0 +1,255 @@
+package configs
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// RedactedSecret api 返回配置时用于替换敏感信息，提交的配置中出现时保留原来的值
+const RedactedSecret = "******"
+
+// 敏感字段（带有 secret:"true" 标签）可以引用环境变量或文件，在加载配置时解析：
+// ${NAME} 替换为环境变量的值，可以出现在字符串中的任意位置；
+// file:/path/to/secret 替换为文件的内容（去掉末尾的换行），只能用于整个值。
+const fileRefPrefix = "file:"
+
+var envRefRegexp = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// secretRef 敏感字段引用的原文与加载时解析出的值
+type secretRef struct {
+	ref   string
+	value string
+}
+
+func isSecretRef(s string) bool {
+	return strings.HasPrefix(s, fileRefPrefix) || envRefRegexp.MatchString(s)
+}
+
+func resolveSecretRef(s string) (string, error) {
+	if strings.HasPrefix(s, fileRefPrefix) {
+		b, err := os.ReadFile(strings.TrimPrefix(s, fileRefPrefix))
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(b), "\r\n"), nil
+	}
+	var err error
+	value := envRefRegexp.ReplaceAllStringFunc(s, func(match string) string {
+		name := envRefRegexp.FindStringSubmatch(match)[1]
+		v, ok := os.LookupEnv(name)
+		if !ok && err == nil {
+			err = fmt.Errorf("environment variable %s is not set", name)
+		}
+		return v
+	})
+	return value, err
+}
+
+// elemKey 返回数组元素的标识，为第一个带有 secret:"key" 标签且不为空的字段，例如 name=a；没有标识时返回空字符串
+func elemKey(v reflect.Value) string {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return ""
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Tag.Get("secret") != "key" || v.Field(i).Kind() != reflect.String || v.Field(i).String() == "" {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		return name + "=" + v.Field(i).String()
+	}
+	return ""
+}
+
+// walkSecrets 遍历 v 中的敏感字段，path 为以 yaml 字段名组成的路径，例如 notify.telegram.botToken、cookies.live.bilibili.com，
+// 数组元素有标识时以标识表示，例如 rpc.auth.tokens[name=a].token，否则以下标表示。fn 返回字段的新值
+func walkSecrets(v reflect.Value, path string, fn func(path, value string) (string, error)) error {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			return walkSecrets(v.Elem(), path, fn)
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			elemPath := fmt.Sprintf("%s[%d]", path, i)
+			if key := elemKey(v.Index(i)); key != "" {
+				elemPath = fmt.Sprintf("%s[%s]", path, key)
+			}
+			if err := walkSecrets(v.Index(i), elemPath, fn); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+			if !field.IsExported() || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = strings.ToLower(field.Name)
+			}
+			if path != "" {
+				name = path + "." + name
+			}
+			fv := v.Field(i)
+			if field.Tag.Get("secret") != "true" {
+				if err := walkSecrets(fv, name, fn); err != nil {
+					return err
+				}
+				continue
+			}
+			switch fv.Kind() {
+			case reflect.String:
+				value, err := fn(name, fv.String())
+				if err != nil {
+					return err
+				}
+				if value != fv.String() {
+					fv.SetString(value)
+				}
+			case reflect.Map:
+				for _, key := range fv.MapKeys() {
+					old := fv.MapIndex(key).String()
+					value, err := fn(name+"."+key.String(), old)
+					if err != nil {
+						return err
+					}
+					if value != old {
+						fv.SetMapIndex(key, reflect.ValueOf(value))
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// resolveSecrets 解析敏感字段中的引用，并记录引用的原文，保存配置时写回原文
+func (c *Config) resolveSecrets() error {
+	c.secretRefs = make(map[string]secretRef)
+	return walkSecrets(reflect.ValueOf(c), "", func(path, value string) (string, error) {
+		if !isSecretRef(value) {
+			return value, nil
+		}
+		resolved, err := resolveSecretRef(value)
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve %s: %w", path, err)
+		}
+		c.secretRefs[path] = secretRef{ref: value, value: resolved}
+		return resolved, nil
+	})
+}
+
+// clone 通过 yaml 复制一份配置，用于输出时替换敏感字段而不影响正在使用的配置
+func (c *Config) clone() (*Config, error) {
+	b, err := yaml.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	ret := new(Config)
+	if err := yaml.Unmarshal(b, ret); err != nil {
+		return nil, err
+	}
+	ret.File = c.File
+	for i := range ret.LiveRooms {
+		ret.LiveRooms[i].LiveId = c.LiveRooms[i].LiveId
+	}
+	return ret, nil
+}
+
+// withSecretRefs 返回将敏感字段还原为引用原文的配置，值已被修改的字段不再使用引用
+func (c *Config) withSecretRefs() (*Config, error) {
+	ret, err := c.clone()
+	if err != nil {
+		return nil, err
+	}
+	err = walkSecrets(reflect.ValueOf(ret), "", func(path, value string) (string, error) {
+		if ref, ok := c.secretRefs[path]; ok && ref.value == value {
+			return ref.ref, nil
+		}
+		return value, nil
+	})
+	return ret, err
+}
+
+// Redacted 返回隐藏了敏感信息的配置，用于 api 的返回：引用显示为原文，其他非空的值显示为 RedactedSecret
+func (c *Config) Redacted() (*Config, error) {
+	ret, err := c.clone()
+	if err != nil {
+		return nil, err
+	}
+	err = walkSecrets(reflect.ValueOf(ret), "", func(path, value string) (string, error) {
+		if ref, ok := c.secretRefs[path]; ok && ref.value == value {
+			return ref.ref, nil
+		}
+		if value == "" {
+			return value, nil
+		}
+		return RedactedSecret, nil
+	})
+	return ret, err
+}
+
+// indexPathRegexp 匹配以下标表示的数组元素
+var indexPathRegexp = regexp.MustCompile(`\[\d+\]`)
+
+// secretPaths 返回 c 中敏感字段的值，以及出现多次（数组元素的标识重复）的路径
+func (c *Config) secretPaths() (map[string]string, map[string]bool) {
+	values := make(map[string]string)
+	duplicated := make(map[string]bool)
+	walkSecrets(reflect.ValueOf(c), "", func(path, value string) (string, error) {
+		if _, ok := values[path]; ok {
+			duplicated[path] = true
+		}
+		values[path] = value
+		return value, nil
+	})
+	return values, duplicated
+}
+
+// RestoreRedacted 将提交的配置中值为 RedactedSecret 的敏感字段还原为 old 中同一字段的值。
+// 数组元素按标识（token 的 name、用户的 username、webhook 的 name 或 url）匹配，
+// 没有标识或标识重复、无法准确匹配时返回错误
+func (c *Config) RestoreRedacted(old *Config) error {
+	values, oldDuplicated := old.secretPaths()
+	_, duplicated := c.secretPaths()
+	if c.secretRefs == nil {
+		c.secretRefs = make(map[string]secretRef)
+	}
+	return walkSecrets(reflect.ValueOf(c), "", func(path, value string) (string, error) {
+		if value != RedactedSecret {
+			return value, nil
+		}
+		if indexPathRegexp.MatchString(path) {
+			return "", fmt.Errorf("%s is redacted, but it has no name to match the previous value", path)
+		}
+		if duplicated[path] || oldDuplicated[path] {
+			return "", fmt.Errorf("%s is redacted, but the name is duplicated", path)
+		}
+		oldValue, ok := values[path]
+		if !ok {
+			return "", fmt.Errorf("%s is redacted, but there is no previous value", path)
+		}
+		if ref, ok := old.secretRefs[path]; ok {
+			c.secretRefs[path] = ref
+		}
+		return oldValue, nil
+	})
+}
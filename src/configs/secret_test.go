@@ -0,0 +1,126 @@
+package configs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+const secretConfig = `
+notify:
+  telegram:
+    botToken: ${TEST_TG_TOKEN}
+  email:
+    senderPassword: plain-password
+  webhook:
+    endpoints:
+    - name: a
+      url: https://example.com
+      headers:
+        Authorization: Bearer ${TEST_WEBHOOK_TOKEN}
+cookies:
+  live.douyin.com: %s
+`
+
+func TestSecrets(t *testing.T) {
+	dir := t.TempDir()
+	cookieFile := filepath.Join(dir, "cookie")
+	assert.NoError(t, os.WriteFile(cookieFile, []byte("a=b\n"), 0600))
+	t.Setenv("TEST_TG_TOKEN", "tg-token")
+	t.Setenv("TEST_WEBHOOK_TOKEN", "webhook-token")
+
+	content := []byte(secretConfigWithCookie("file:" + cookieFile))
+	cfg, err := NewConfigWithBytes(content)
+	assert.NoError(t, err)
+	assert.Equal(t, "tg-token", cfg.Notify.Telegram.BotToken)
+	assert.Equal(t, "Bearer webhook-token", cfg.Notify.Webhook.Endpoints[0].Headers["Authorization"])
+	assert.Equal(t, "a=b", cfg.Cookies["live.douyin.com"])
+
+	// 保存时写回引用
+	cfg.File = filepath.Join(dir, "config.yml")
+	assert.NoError(t, cfg.Marshal())
+	b, err := os.ReadFile(cfg.File)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), "${TEST_TG_TOKEN}")
+	assert.Contains(t, string(b), "file:"+cookieFile)
+	assert.NotContains(t, string(b), "tg-token")
+
+	// api 返回的配置隐藏明文
+	redacted, err := cfg.Redacted()
+	assert.NoError(t, err)
+	assert.Equal(t, "${TEST_TG_TOKEN}", redacted.Notify.Telegram.BotToken)
+	assert.Equal(t, RedactedSecret, redacted.Notify.Email.SenderPassword)
+	assert.Equal(t, "", redacted.Notify.Ntfy.Token)
+	assert.Equal(t, "tg-token", cfg.Notify.Telegram.BotToken)
+	assert.Equal(t, "plain-password", cfg.Notify.Email.SenderPassword)
+
+	// 提交隐藏后的配置时保留原来的值
+	b, err = yaml.Marshal(redacted)
+	assert.NoError(t, err)
+	submitted, err := NewConfigWithBytes(b)
+	assert.NoError(t, err)
+	assert.NoError(t, submitted.RestoreRedacted(cfg))
+	assert.Equal(t, "plain-password", submitted.Notify.Email.SenderPassword)
+	assert.Equal(t, "tg-token", submitted.Notify.Telegram.BotToken)
+	assert.Equal(t, "a=b", submitted.Cookies["live.douyin.com"])
+
+	// 修改过的值不再写回引用
+	cfg.Notify.Telegram.BotToken = "new-token"
+	out, err := cfg.withSecretRefs()
+	assert.NoError(t, err)
+	assert.Equal(t, "new-token", out.Notify.Telegram.BotToken)
+
+	// 引用无法解析时加载失败
+	_, err = NewConfigWithBytes([]byte(secretConfigWithCookie("${TEST_NOT_SET}")))
+	assert.ErrorContains(t, err, "TEST_NOT_SET")
+	_, err = NewConfigWithBytes([]byte(secretConfigWithCookie("file:" + filepath.Join(dir, "missing"))))
+	assert.Error(t, err)
+
+	// 隐藏的值在原来的配置中不存在
+	submitted, err = NewConfigWithBytes([]byte("cookies:\n  live.douyin.com: \"******\"\n"))
+	assert.NoError(t, err)
+	assert.Error(t, submitted.RestoreRedacted(NewConfig()))
+}
+
+func TestRestoreRedacted_Slices(t *testing.T) {
+	old := NewConfig()
+	old.RPC.Auth.Tokens = []AuthToken{{Name: "a", Token: "token-a"}, {Name: "b", Token: "token-b"}, {Token: "token-c"}}
+	old.Notify.Webhook.Endpoints = []WebhookEndpoint{
+		{Name: "a", URL: "https://a.example.com", Headers: map[string]string{"Authorization": "a"}},
+		{URL: "https://b.example.com", Headers: map[string]string{"Authorization": "b"}},
+	}
+
+	// 调整顺序、删除元素后仍按标识还原
+	submitted := NewConfig()
+	submitted.RPC.Auth.Tokens = []AuthToken{{Name: "b", Token: RedactedSecret}, {Name: "a", Token: RedactedSecret}}
+	submitted.Notify.Webhook.Endpoints = []WebhookEndpoint{
+		{URL: "https://b.example.com", Headers: map[string]string{"Authorization": RedactedSecret}},
+	}
+	assert.NoError(t, submitted.RestoreRedacted(old))
+	assert.Equal(t, "token-b", submitted.RPC.Auth.Tokens[0].Token)
+	assert.Equal(t, "token-a", submitted.RPC.Auth.Tokens[1].Token)
+	assert.Equal(t, "b", submitted.Notify.Webhook.Endpoints[0].Headers["Authorization"])
+
+	// 没有标识的元素无法匹配
+	submitted = NewConfig()
+	submitted.RPC.Auth.Tokens = []AuthToken{{Token: RedactedSecret}}
+	assert.ErrorContains(t, submitted.RestoreRedacted(old), "rpc.auth.tokens[0].token")
+
+	// 标识重复时无法匹配
+	submitted = NewConfig()
+	submitted.RPC.Auth.Tokens = []AuthToken{{Name: "a", Token: RedactedSecret}, {Name: "a", Token: "new"}}
+	assert.Error(t, submitted.RestoreRedacted(old))
+
+	// 原来的配置中没有同名的元素
+	submitted = NewConfig()
+	submitted.RPC.Auth.Tokens = []AuthToken{{Name: "d", Token: RedactedSecret}}
+	assert.ErrorContains(t, submitted.RestoreRedacted(old), "rpc.auth.tokens[name=d].token")
+}
+
+func secretConfigWithCookie(cookie string) string {
+	return fmt.Sprintf(secretConfig, cookie)
+}
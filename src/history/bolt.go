@@ -0,0 +1,174 @@
+package history
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/bililive-go/bililive-go/src/instance"
+)
+
+const (
+	dbFileName = "recordings.db"
+)
+
+var (
+	recordsBucket = []byte("records")
+)
+
+// NewStore 创建基于 BoltDB 的录制历史存储，数据库文件位于 AppDataPath 下
+func NewStore(ctx context.Context) Store {
+	inst := instance.GetInstance(ctx)
+	s := &boltStore{
+		path: filepath.Join(inst.Config.AppDataPath, dbFileName),
+	}
+	inst.RecordHistory = s
+	return s
+}
+
+type boltStore struct {
+	path string
+	db   *bolt.DB
+}
+
+func itob(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
+
+func (s *boltStore) Start(ctx context.Context) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), os.ModePerm); err != nil {
+		return err
+	}
+	db, err := bolt.Open(s.path, 0644, &bolt.Options{Timeout: 3 * time.Second})
+	if err != nil {
+		return err
+	}
+	if err := db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(recordsBucket)
+		if err != nil {
+			return err
+		}
+		// 上次运行时未正常结束的录制，遍历时不能修改 bucket，先收集再更新
+		interrupted := make(map[string]*Record)
+		if err := b.ForEach(func(k, v []byte) error {
+			record := new(Record)
+			if err := json.Unmarshal(v, record); err != nil {
+				return nil
+			}
+			if record.InProgress() {
+				interrupted[string(k)] = record
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		for k, record := range interrupted {
+			record.EndTime = time.Now()
+			record.ExitReason = ExitReasonInterrupted
+			data, err := json.Marshal(record)
+			if err != nil {
+				return err
+			}
+			if err := b.Put([]byte(k), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		db.Close()
+		return err
+	}
+	s.db = db
+	return nil
+}
+
+func (s *boltStore) Close(ctx context.Context) {
+	if s.db != nil {
+		s.db.Close()
+	}
+}
+
+func (s *boltStore) Add(record *Record) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(recordsBucket)
+		id, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		record.ID = id
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		return b.Put(itob(id), data)
+	})
+}
+
+func (s *boltStore) Update(record *Record) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(recordsBucket)
+		if b.Get(itob(record.ID)) == nil {
+			return ErrRecordNotExist
+		}
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		return b.Put(itob(record.ID), data)
+	})
+}
+
+func (s *boltStore) Get(id uint64) (*Record, error) {
+	record := new(Record)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(recordsBucket).Get(itob(id))
+		if v == nil {
+			return ErrRecordNotExist
+		}
+		return json.Unmarshal(v, record)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+func (s *boltStore) Delete(id uint64) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(recordsBucket).Delete(itob(id))
+	})
+}
+
+func (s *boltStore) Query(filter Filter) ([]*Record, error) {
+	records := make([]*Record, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(recordsBucket).ForEach(func(k, v []byte) error {
+			record := new(Record)
+			if err := json.Unmarshal(v, record); err != nil {
+				return nil
+			}
+			if filter.match(record) {
+				records = append(records, record)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].StartTime.After(records[j].StartTime)
+	})
+	if filter.Limit > 0 && len(records) > filter.Limit {
+		records = records[:filter.Limit]
+	}
+	return records, nil
+}
@@ -0,0 +1,93 @@
+package history
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bililive-go/bililive-go/src/configs"
+	"github.com/bililive-go/bililive-go/src/instance"
+)
+
+func newTestStore(t *testing.T, dir string) (context.Context, Store) {
+	cfg := configs.NewConfig()
+	cfg.AppDataPath = dir
+	ctx := context.WithValue(context.Background(), instance.Key, &instance.Instance{
+		Config: cfg,
+	})
+	s := NewStore(ctx)
+	assert.NoError(t, s.Start(ctx))
+	return ctx, s
+}
+
+func TestStoreAddAndQuery(t *testing.T) {
+	ctx, s := newTestStore(t, t.TempDir())
+	defer s.Close(ctx)
+	assert.Equal(t, s, GetStore(ctx))
+
+	base := time.Date(2024, 1, 1, 20, 0, 0, 0, time.Local)
+	records := []*Record{
+		{LiveID: "a", LiveUrl: "https://live.bilibili.com/1", Platform: "哔哩哔哩", StartTime: base},
+		{LiveID: "a", LiveUrl: "https://live.bilibili.com/1", Platform: "哔哩哔哩", StartTime: base.Add(24 * time.Hour)},
+		{LiveID: "b", LiveUrl: "https://www.douyu.com/2", Platform: "斗鱼", StartTime: base.Add(48 * time.Hour)},
+	}
+	for _, r := range records {
+		assert.NoError(t, s.Add(r))
+		assert.NotZero(t, r.ID)
+	}
+
+	all, err := s.Query(Filter{})
+	assert.NoError(t, err)
+	assert.Len(t, all, 3)
+	assert.Equal(t, records[2].ID, all[0].ID)
+
+	byLive, err := s.Query(Filter{LiveID: "a"})
+	assert.NoError(t, err)
+	assert.Len(t, byLive, 2)
+
+	byPlatform, err := s.Query(Filter{Platform: "www.douyu.com"})
+	assert.NoError(t, err)
+	assert.Len(t, byPlatform, 1)
+	byPlatform, err = s.Query(Filter{Platform: "哔哩哔哩"})
+	assert.NoError(t, err)
+	assert.Len(t, byPlatform, 2)
+
+	byDate, err := s.Query(Filter{From: base.Add(time.Hour), To: base.Add(47 * time.Hour)})
+	assert.NoError(t, err)
+	assert.Len(t, byDate, 1)
+	assert.Equal(t, records[1].ID, byDate[0].ID)
+
+	limited, err := s.Query(Filter{Limit: 1})
+	assert.NoError(t, err)
+	assert.Len(t, limited, 1)
+
+	records[0].EndTime = base.Add(time.Hour)
+	records[0].ExitReason = ExitReasonFinished
+	assert.NoError(t, s.Update(records[0]))
+	got, err := s.Get(records[0].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, ExitReasonFinished, got.ExitReason)
+	assert.False(t, got.InProgress())
+
+	assert.NoError(t, s.Delete(records[0].ID))
+	_, err = s.Get(records[0].ID)
+	assert.Equal(t, ErrRecordNotExist, err)
+	assert.Equal(t, ErrRecordNotExist, s.Update(records[0]))
+}
+
+func TestStoreMarksInterruptedRecords(t *testing.T) {
+	dir := t.TempDir()
+	ctx, s := newTestStore(t, dir)
+	record := &Record{LiveID: "a", StartTime: time.Now()}
+	assert.NoError(t, s.Add(record))
+	s.Close(ctx)
+
+	ctx, s = newTestStore(t, dir)
+	defer s.Close(ctx)
+	got, err := s.Get(record.ID)
+	assert.NoError(t, err)
+	assert.False(t, got.InProgress())
+	assert.Equal(t, ExitReasonInterrupted, got.ExitReason)
+}
@@ -0,0 +1,120 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/bililive-go/bililive-go/src/instance"
+	"github.com/bililive-go/bililive-go/src/interfaces"
+	"github.com/bililive-go/bililive-go/src/types"
+)
+
+// 录制结束原因
+const (
+	ExitReasonFinished    = "finished"    // 直播流正常结束
+	ExitReasonStopped     = "stopped"     // 录制器被主动关闭
+	ExitReasonInterrupted = "interrupted" // 程序异常退出，录制未正常收尾
+	ExitReasonSplit       = "split"       // 达到分段条件，在同一次录制中切换到了新文件
+	ExitReasonError       = "error"       // 录制出错，错误信息记录在 Error 中
+)
+
+// 后处理结果
+const (
+	PostProcessNone    = ""
+	PostProcessSuccess = "success"
+	PostProcessFailed  = "failed"
+)
+
+var (
+	ErrRecordNotExist = errors.New("record is not exist")
+)
+
+// File 一个录制会话产生的单个文件
+type File struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// Record 一次录制会话的记录
+type Record struct {
+	ID        uint64       `json:"id"`
+	LiveID    types.LiveID `json:"live_id"`
+	LiveUrl   string       `json:"live_url"`
+	Platform  string       `json:"platform"`
+	HostName  string       `json:"host_name"`
+	RoomName  string       `json:"room_name"`
+	StartTime time.Time    `json:"start_time"`
+	EndTime   time.Time    `json:"end_time,omitzero"`
+	Files     []File       `json:"files"`
+	// Source 录制所用直播流的 CDN 域名
+	Source           string `json:"source,omitempty"`
+	OutputFiles      []File `json:"output_files,omitempty"`
+	TotalBytes       int64  `json:"total_bytes"`
+	ExitReason       string `json:"exit_reason"`
+	Error            string `json:"error,omitempty"`
+	PostProcess      string `json:"post_process"`
+	PostProcessError string `json:"post_process_error,omitempty"`
+}
+
+// InProgress 录制是否仍在进行
+func (r *Record) InProgress() bool {
+	return r.EndTime.IsZero()
+}
+
+// Filter 查询条件，零值字段表示不过滤
+type Filter struct {
+	LiveID types.LiveID
+	// Platform 可以是平台中文名，也可以是直播间域名
+	Platform string
+	From, To time.Time
+	Limit    int
+}
+
+func (f Filter) matchPlatform(r *Record) bool {
+	if r.Platform == f.Platform {
+		return true
+	}
+	u, err := url.Parse(r.LiveUrl)
+	return err == nil && u.Host == f.Platform
+}
+
+func (f Filter) match(r *Record) bool {
+	if f.LiveID != "" && r.LiveID != f.LiveID {
+		return false
+	}
+	if f.Platform != "" && !f.matchPlatform(r) {
+		return false
+	}
+	if !f.From.IsZero() && r.StartTime.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && r.StartTime.After(f.To) {
+		return false
+	}
+	return true
+}
+
+// Store 录制历史存储
+type Store interface {
+	interfaces.Module
+	// Add 新增一条记录，并为其分配 ID
+	Add(record *Record) error
+	// Update 按 ID 覆盖已有记录
+	Update(record *Record) error
+	Get(id uint64) (*Record, error)
+	Delete(id uint64) error
+	// Query 按开始时间倒序返回符合条件的记录
+	Query(filter Filter) ([]*Record, error)
+}
+
+// GetStore 获取当前实例中的录制历史存储，未启用时返回 nil
+func GetStore(ctx context.Context) Store {
+	inst := instance.GetInstance(ctx)
+	if inst == nil || inst.RecordHistory == nil {
+		return nil
+	}
+	store, _ := inst.RecordHistory.(Store)
+	return store
+}
@@ -21,4 +21,102 @@ type Instance struct {
 	EventDispatcher interfaces.Module
 	ListenerManager interfaces.Module
 	RecorderManager interfaces.Module
+	RecordHistory   interfaces.Module
+	WebhookNotifier interfaces.Module
+	StorageManager  interfaces.Module
+	JobQueue        interfaces.Module
+	Uploader        interfaces.Module
+	ConfigWatcher   interfaces.Module
+
+	// configLock 保护重新加载配置时对 Config 的替换
+	configLock sync.RWMutex
+	// configUpdateLock 保证 api 对配置的修改与重新加载配置串行执行
+	configUpdateLock sync.Mutex
+	// livesLock 保护 Lives，api、重新加载配置与监控指标会并发读写
+	livesLock sync.RWMutex
+}
+
+// GetConfig 获取当前的配置，在后台协程中读取可能被重新加载的配置时使用
+func (inst *Instance) GetConfig() *configs.Config {
+	inst.configLock.RLock()
+	defer inst.configLock.RUnlock()
+	return inst.Config
+}
+
+// SetConfig 替换当前的配置
+func (inst *Instance) SetConfig(cfg *configs.Config) {
+	inst.configLock.Lock()
+	defer inst.configLock.Unlock()
+	inst.Config = cfg
+}
+
+// UpdateConfig 复制当前的配置交给 fn 修改，成功后替换当前配置并返回新配置。
+// 已发布的配置不会被修改，读取 GetConfig 得到的配置时无需加锁
+func (inst *Instance) UpdateConfig(fn func(cfg *configs.Config) error) (*configs.Config, error) {
+	inst.configUpdateLock.Lock()
+	defer inst.configUpdateLock.Unlock()
+	cfg := inst.GetConfig().Copy()
+	if err := fn(cfg); err != nil {
+		return nil, err
+	}
+	inst.SetConfig(cfg)
+	configs.SetCurrentConfig(cfg)
+	return cfg, nil
+}
+
+// LockConfigUpdate 阻止其他对配置的修改，重新加载配置时使用，返回解锁函数
+func (inst *Instance) LockConfigUpdate() (unlock func()) {
+	inst.configUpdateLock.Lock()
+	return inst.configUpdateLock.Unlock
+}
+
+// GetLive 获取直播间
+func (inst *Instance) GetLive(id types.LiveID) (live.Live, bool) {
+	inst.livesLock.RLock()
+	defer inst.livesLock.RUnlock()
+	l, ok := inst.Lives[id]
+	return l, ok
+}
+
+// GetLives 获取所有直播间的副本
+func (inst *Instance) GetLives() []live.Live {
+	inst.livesLock.RLock()
+	defer inst.livesLock.RUnlock()
+	lives := make([]live.Live, 0, len(inst.Lives))
+	for _, l := range inst.Lives {
+		lives = append(lives, l)
+	}
+	return lives
+}
+
+// AddLive 添加直播间，已存在相同 id 的直播间时返回 false
+func (inst *Instance) AddLive(l live.Live) bool {
+	inst.livesLock.Lock()
+	defer inst.livesLock.Unlock()
+	if _, ok := inst.Lives[l.GetLiveId()]; ok {
+		return false
+	}
+	inst.setLive(l)
+	return true
+}
+
+// SetLive 添加或替换直播间
+func (inst *Instance) SetLive(l live.Live) {
+	inst.livesLock.Lock()
+	defer inst.livesLock.Unlock()
+	inst.setLive(l)
+}
+
+// RemoveLive 删除直播间
+func (inst *Instance) RemoveLive(id types.LiveID) {
+	inst.livesLock.Lock()
+	defer inst.livesLock.Unlock()
+	delete(inst.Lives, id)
+}
+
+func (inst *Instance) setLive(l live.Live) {
+	if inst.Lives == nil {
+		inst.Lives = make(map[types.LiveID]live.Live)
+	}
+	inst.Lives[l.GetLiveId()] = l
 }
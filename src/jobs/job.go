@@ -0,0 +1,82 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/bililive-go/bililive-go/src/pkg/events"
+	"github.com/bililive-go/bililive-go/src/types"
+)
+
+// JobFinished 一个后处理任务执行成功、最终失败或被取消，对象为 *Job
+const JobFinished events.EventType = "JobFinished"
+
+// Status 任务状态
+type Status string
+
+const (
+	StatusPending   Status = "pending"   // 等待执行，或等待下一次重试
+	StatusRunning   Status = "running"   // 正在执行
+	StatusSucceeded Status = "succeeded" // 所有步骤执行成功
+	StatusFailed    Status = "failed"    // 重试次数用尽
+	StatusCanceled  Status = "canceled"  // 被手动取消
+)
+
+var (
+	ErrJobNotExist      = errors.New("job is not exist")
+	ErrJobNotRetryable  = errors.New("only failed or canceled jobs can be retried")
+	ErrJobNotCancelable = errors.New("only pending or running jobs can be canceled")
+)
+
+// Job 一个录制文件的后处理任务，按顺序执行 Steps 中的步骤
+type Job struct {
+	ID       uint64       `json:"id"`
+	LiveID   types.LiveID `json:"live_id"`
+	LiveUrl  string       `json:"live_url"`
+	HostName string       `json:"host_name"`
+	RoomName string       `json:"room_name"`
+	// RecordID 对应的录制历史，0 表示没有录制历史
+	RecordID uint64 `json:"record_id,omitempty"`
+	// File 录制得到的原始文件
+	File string `json:"file"`
+	// Files 下一个步骤的输入文件，每个步骤执行后替换为该步骤的输出；全部完成后即为最终的输出文件
+	Files []string `json:"files"`
+	Steps []string `json:"steps"`
+	// Step 下一个要执行的步骤在 Steps 中的下标，重试时从这一步继续
+	Step       int       `json:"step"`
+	Status     Status    `json:"status"`
+	Attempts   int       `json:"attempts"`
+	Error      string    `json:"error,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	NextRunAt  time.Time `json:"next_run_at,omitzero"`
+	FinishedAt time.Time `json:"finished_at,omitzero"`
+}
+
+// Finished 任务是否已经结束，不会再被执行
+func (j *Job) Finished() bool {
+	return j.Status == StatusSucceeded || j.Status == StatusFailed || j.Status == StatusCanceled
+}
+
+// StepFunc 执行任务的一个步骤，输入文件为 job.Files，返回该步骤的输出文件。
+// 出错时返回的非 nil 文件列表同样会被保存，重试时作为这一步的输入；ctx 在任务被取消或程序退出时取消。
+// job 只读，不能修改
+type StepFunc func(ctx context.Context, job *Job) (files []string, err error)
+
+// Filter 查询条件，零值字段表示不过滤
+type Filter struct {
+	Status Status
+	LiveID types.LiveID
+	Limit  int
+}
+
+func (f Filter) match(j *Job) bool {
+	if f.Status != "" && j.Status != f.Status {
+		return false
+	}
+	if f.LiveID != "" && j.LiveID != f.LiveID {
+		return false
+	}
+	return true
+}
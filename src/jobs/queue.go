@@ -0,0 +1,476 @@
+package jobs
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/bililive-go/bililive-go/src/instance"
+	"github.com/bililive-go/bililive-go/src/pkg/events"
+)
+
+const (
+	dbFileName = "jobs.db"
+	// maxBackoffShift 重试等待时间最多翻倍的次数
+	maxBackoffShift = 10
+)
+
+var (
+	jobsBucket = []byte("jobs")
+	// pendingBucket 未完成的任务（pending、running）的索引，调度时不需要读取所有历史任务
+	pendingBucket = []byte("pending")
+)
+
+// runningJob 正在执行的任务
+type runningJob struct {
+	cancel   context.CancelFunc
+	canceled bool
+}
+
+// Queue 持久化的后处理任务队列。任务保存在 AppDataPath 下的 BoltDB 中，
+// 同时执行的任务数不超过 jobs.workers，失败后按指数退避重试，程序重启后继续执行未完成的任务
+type Queue struct {
+	inst   *instance.Instance
+	path   string
+	db     *bolt.DB
+	ed     events.Dispatcher
+	logger *logrus.Entry
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	wake   chan struct{}
+
+	lock    sync.Mutex
+	steps   map[string]StepFunc
+	running map[uint64]*runningJob
+}
+
+func NewQueue(ctx context.Context) *Queue {
+	inst := instance.GetInstance(ctx)
+	q := &Queue{
+		inst:    inst,
+		path:    filepath.Join(inst.Config.AppDataPath, dbFileName),
+		wake:    make(chan struct{}, 1),
+		steps:   make(map[string]StepFunc),
+		running: make(map[uint64]*runningJob),
+	}
+	inst.JobQueue = q
+	return q
+}
+
+// GetQueue 获取当前实例中的任务队列，未启用时返回 nil
+func GetQueue(ctx context.Context) *Queue {
+	inst := instance.GetInstance(ctx)
+	if inst == nil || inst.JobQueue == nil {
+		return nil
+	}
+	q, _ := inst.JobQueue.(*Queue)
+	return q
+}
+
+func itob(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
+
+// RegisterStep 注册一个步骤，需要在 Start 之前完成，否则重启后恢复的任务可能找不到对应的步骤
+func (q *Queue) RegisterStep(name string, fn StepFunc) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.steps[name] = fn
+}
+
+func (q *Queue) Start(ctx context.Context) error {
+	q.logger = q.inst.Logger.WithField("module", "jobs")
+	q.ed, _ = q.inst.EventDispatcher.(events.Dispatcher)
+	if err := os.MkdirAll(filepath.Dir(q.path), os.ModePerm); err != nil {
+		return err
+	}
+	db, err := bolt.Open(q.path, 0644, &bolt.Options{Timeout: 3 * time.Second})
+	if err != nil {
+		return err
+	}
+	q.db = db
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(jobsBucket)
+		if err != nil {
+			return err
+		}
+		// 重建未完成任务的索引，兼容没有索引的旧数据
+		if tx.Bucket(pendingBucket) != nil {
+			if err := tx.DeleteBucket(pendingBucket); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.CreateBucket(pendingBucket); err != nil {
+			return err
+		}
+		var unfinished []*Job
+		err = b.ForEach(func(k, v []byte) error {
+			job := new(Job)
+			if err := json.Unmarshal(v, job); err == nil && !job.Finished() {
+				unfinished = append(unfinished, job)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, job := range unfinished {
+			// 上次运行时被中断的任务，从中断的步骤重新执行
+			if job.Status == StatusRunning {
+				job.Status = StatusPending
+				job.UpdatedAt = time.Now()
+			}
+			if err := putJob(tx, job); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return err
+	}
+	q.ctx, q.cancel = context.WithCancel(ctx)
+	q.wg.Add(1)
+	go q.run()
+	return nil
+}
+
+// Close 中断正在执行的任务并等待其退出，被中断的任务在下次启动后重新执行，不计入重试次数
+func (q *Queue) Close(ctx context.Context) {
+	if q.cancel != nil {
+		q.cancel()
+	}
+	q.wg.Wait()
+	if q.db != nil {
+		q.db.Close()
+	}
+}
+
+// notify 唤醒调度
+func (q *Queue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *Queue) run() {
+	defer q.wg.Done()
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		timer.Stop()
+		if wait := q.schedule(); wait > 0 {
+			timer.Reset(wait)
+		}
+		select {
+		case <-q.ctx.Done():
+			return
+		case <-q.wake:
+		case <-timer.C:
+		}
+	}
+}
+
+// schedule 在空闲的 worker 上按创建顺序启动到期的任务，返回距离下一个等待重试的任务到期的时间，没有时返回 0
+func (q *Queue) schedule() time.Duration {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	workers := max(q.inst.GetConfig().Jobs.Workers, 1)
+	now := time.Now()
+	var (
+		wait time.Duration
+		due  []*Job
+	)
+	err := q.db.View(func(tx *bolt.Tx) error {
+		jobs := tx.Bucket(jobsBucket)
+		return tx.Bucket(pendingBucket).ForEach(func(k, _ []byte) error {
+			job := new(Job)
+			if err := json.Unmarshal(jobs.Get(k), job); err != nil || job.Status != StatusPending {
+				return nil
+			}
+			if d := job.NextRunAt.Sub(now); d > 0 {
+				if wait == 0 || d < wait {
+					wait = d
+				}
+				return nil
+			}
+			due = append(due, job)
+			return nil
+		})
+	})
+	if err != nil {
+		q.logger.WithError(err).Error("failed to load jobs")
+		return 0
+	}
+	for _, job := range due {
+		if len(q.running) >= workers {
+			break
+		}
+		job.Status = StatusRunning
+		job.Attempts++
+		job.NextRunAt = time.Time{}
+		job.UpdatedAt = now
+		if err := q.put(job); err != nil {
+			q.logger.WithError(err).Error("failed to update job")
+			continue
+		}
+		ctx, cancel := context.WithCancel(q.ctx)
+		q.running[job.ID] = &runningJob{cancel: cancel}
+		q.wg.Add(1)
+		go q.execute(ctx, job)
+	}
+	return wait
+}
+
+// execute 从 job.Step 开始依次执行剩余的步骤，每个步骤完成后保存进度
+func (q *Queue) execute(ctx context.Context, job *Job) {
+	defer q.wg.Done()
+	logger := q.logger.WithFields(logrus.Fields{"job": job.ID, "file": job.File})
+	var err error
+	for job.Step < len(job.Steps) {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		name := job.Steps[job.Step]
+		q.lock.Lock()
+		fn := q.steps[name]
+		q.lock.Unlock()
+		if fn == nil {
+			err = fmt.Errorf("unknown step: %s", name)
+			break
+		}
+		logger.Debugf("running step %s, attempt %d", name, job.Attempts)
+		var files []string
+		files, err = fn(ctx, job)
+		if files != nil {
+			job.Files = files
+		}
+		if err != nil {
+			err = fmt.Errorf("%s: %w", name, err)
+			break
+		}
+		job.Step++
+		q.lock.Lock()
+		job.UpdatedAt = time.Now()
+		if err := q.put(job); err != nil {
+			logger.WithError(err).Warn("failed to save job progress")
+		}
+		q.lock.Unlock()
+	}
+	q.finish(job, err, logger)
+}
+
+// backoff 第 attempts 次执行失败后到下一次重试的等待时间
+func backoff(base time.Duration, attempts int) time.Duration {
+	return base << min(max(attempts-1, 0), maxBackoffShift)
+}
+
+func (q *Queue) finish(job *Job, err error, logger *logrus.Entry) {
+	q.lock.Lock()
+	r := q.running[job.ID]
+	delete(q.running, job.ID)
+	r.cancel()
+	now := time.Now()
+	job.UpdatedAt = now
+	job.Error = ""
+	switch {
+	case err == nil:
+		job.Status = StatusSucceeded
+		job.FinishedAt = now
+	case r.canceled:
+		job.Status = StatusCanceled
+		job.Error = err.Error()
+		job.FinishedAt = now
+	case q.ctx.Err() != nil:
+		// 程序退出导致的中断
+		job.Status = StatusPending
+		job.Attempts--
+	default:
+		job.Error = err.Error()
+		cfg := q.inst.GetConfig().Jobs
+		if job.Attempts >= max(cfg.MaxAttempts, 1) {
+			job.Status = StatusFailed
+			job.FinishedAt = now
+		} else {
+			job.Status = StatusPending
+			job.NextRunAt = now.Add(backoff(cfg.RetryBackoff, job.Attempts))
+		}
+	}
+	if err := q.put(job); err != nil {
+		logger.WithError(err).Error("failed to update job")
+	}
+	q.lock.Unlock()
+
+	switch job.Status {
+	case StatusSucceeded:
+		logger.Infof("job succeeded, output files: %v", job.Files)
+	case StatusPending:
+		if !job.NextRunAt.IsZero() {
+			logger.WithError(err).Warnf("job failed, will retry at %s", job.NextRunAt.Format(time.DateTime))
+		}
+	default:
+		logger.WithError(err).Warnf("job %s", job.Status)
+	}
+	if job.Finished() && q.ed != nil {
+		q.ed.DispatchEvent(events.NewEvent(JobFinished, job))
+	}
+	q.notify()
+}
+
+func (q *Queue) put(job *Job) error {
+	return q.db.Update(func(tx *bolt.Tx) error {
+		return putJob(tx, job)
+	})
+}
+
+// putJob 保存任务，并更新未完成任务的索引
+func putJob(tx *bolt.Tx, job *Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	key := itob(job.ID)
+	if err := tx.Bucket(jobsBucket).Put(key, data); err != nil {
+		return err
+	}
+	if job.Finished() {
+		return tx.Bucket(pendingBucket).Delete(key)
+	}
+	return tx.Bucket(pendingBucket).Put(key, []byte{})
+}
+
+func (q *Queue) get(id uint64) (*Job, error) {
+	job := new(Job)
+	err := q.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(jobsBucket).Get(itob(id))
+		if v == nil {
+			return ErrJobNotExist
+		}
+		return json.Unmarshal(v, job)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// Enqueue 新增一个任务并为其分配 ID，job.Files 为空时以 job.File 作为第一个步骤的输入
+func (q *Queue) Enqueue(job *Job) error {
+	if len(job.Files) == 0 {
+		job.Files = []string{job.File}
+	}
+	now := time.Now()
+	job.Status = StatusPending
+	job.Step, job.Attempts, job.Error = 0, 0, ""
+	job.CreatedAt, job.UpdatedAt = now, now
+	q.lock.Lock()
+	err := q.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(jobsBucket)
+		id, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		job.ID = id
+		return putJob(tx, job)
+	})
+	q.lock.Unlock()
+	if err != nil {
+		return err
+	}
+	q.notify()
+	return nil
+}
+
+func (q *Queue) Get(id uint64) (*Job, error) {
+	return q.get(id)
+}
+
+// List 按创建时间倒序返回符合条件的任务
+func (q *Queue) List(filter Filter) ([]*Job, error) {
+	jobs := make([]*Job, 0)
+	err := q.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(jobsBucket).Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			job := new(Job)
+			if err := json.Unmarshal(v, job); err != nil || !filter.match(job) {
+				continue
+			}
+			jobs = append(jobs, job)
+			if filter.Limit > 0 && len(jobs) >= filter.Limit {
+				break
+			}
+		}
+		return nil
+	})
+	return jobs, err
+}
+
+// Retry 重新执行失败或被取消的任务，从出错的步骤继续，并重置重试次数
+func (q *Queue) Retry(id uint64) (*Job, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	job, err := q.get(id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != StatusFailed && job.Status != StatusCanceled {
+		return nil, ErrJobNotRetryable
+	}
+	job.Status = StatusPending
+	job.Attempts = 0
+	job.Error = ""
+	job.NextRunAt, job.FinishedAt = time.Time{}, time.Time{}
+	job.UpdatedAt = time.Now()
+	if err := q.put(job); err != nil {
+		return nil, err
+	}
+	q.notify()
+	return job, nil
+}
+
+// Cancel 取消等待中或正在执行的任务。正在执行的任务会中断当前步骤，返回时其状态可能仍为 running
+func (q *Queue) Cancel(id uint64) (*Job, error) {
+	q.lock.Lock()
+	job, err := q.get(id)
+	if err != nil {
+		q.lock.Unlock()
+		return nil, err
+	}
+	if r, ok := q.running[id]; ok {
+		r.canceled = true
+		r.cancel()
+		q.lock.Unlock()
+		return job, nil
+	}
+	if job.Status != StatusPending {
+		q.lock.Unlock()
+		return nil, ErrJobNotCancelable
+	}
+	now := time.Now()
+	job.Status = StatusCanceled
+	job.NextRunAt = time.Time{}
+	job.UpdatedAt, job.FinishedAt = now, now
+	err = q.put(job)
+	q.lock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if q.ed != nil {
+		q.ed.DispatchEvent(events.NewEvent(JobFinished, job))
+	}
+	return job, nil
+}
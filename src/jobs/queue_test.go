@@ -0,0 +1,190 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/bililive-go/bililive-go/src/configs"
+	"github.com/bililive-go/bililive-go/src/instance"
+	"github.com/bililive-go/bililive-go/src/interfaces"
+)
+
+func newTestQueue(t *testing.T, dir string, register func(q *Queue)) (context.Context, *Queue) {
+	cfg := configs.NewConfig()
+	cfg.AppDataPath = dir
+	cfg.Jobs = configs.Jobs{Workers: 1, MaxAttempts: 3, RetryBackoff: 10 * time.Millisecond}
+	ctx := context.WithValue(context.Background(), instance.Key, &instance.Instance{
+		Config: cfg,
+		Logger: &interfaces.Logger{Logger: logrus.New()},
+	})
+	q := NewQueue(ctx)
+	register(q)
+	assert.NoError(t, q.Start(ctx))
+	return ctx, q
+}
+
+func waitStatus(t *testing.T, q *Queue, id uint64, status Status) *Job {
+	var job *Job
+	assert.Eventually(t, func() bool {
+		var err error
+		job, err = q.Get(id)
+		return err == nil && job.Status == status
+	}, 5*time.Second, 5*time.Millisecond)
+	return job
+}
+
+func TestQueueRunsStepsInOrder(t *testing.T) {
+	var running, maxRunning atomic.Int32
+	ctx, q := newTestQueue(t, t.TempDir(), func(q *Queue) {
+		q.RegisterStep("fix", func(ctx context.Context, job *Job) ([]string, error) {
+			n := running.Add(1)
+			defer running.Add(-1)
+			if n > maxRunning.Load() {
+				maxRunning.Store(n)
+			}
+			time.Sleep(10 * time.Millisecond)
+			return append(job.Files, job.File+".part2"), nil
+		})
+		q.RegisterStep("convert", func(ctx context.Context, job *Job) ([]string, error) {
+			var ret []string
+			for _, f := range job.Files {
+				ret = append(ret, f+".mp4")
+			}
+			return ret, nil
+		})
+	})
+	defer q.Close(ctx)
+	assert.Equal(t, q, GetQueue(ctx))
+
+	var ids []uint64
+	for _, file := range []string{"a.flv", "b.flv", "c.flv"} {
+		job := &Job{LiveID: "live", File: file, Steps: []string{"fix", "convert"}}
+		assert.NoError(t, q.Enqueue(job))
+		ids = append(ids, job.ID)
+	}
+	for _, id := range ids {
+		waitStatus(t, q, id, StatusSucceeded)
+	}
+	job, err := q.Get(ids[0])
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a.flv.mp4", "a.flv.part2.mp4"}, job.Files)
+	assert.Equal(t, 2, job.Step)
+	assert.Equal(t, 1, job.Attempts)
+	assert.False(t, job.FinishedAt.IsZero())
+	// workers 为 1 时任务依次执行
+	assert.EqualValues(t, 1, maxRunning.Load())
+
+	list, err := q.List(Filter{Limit: 2})
+	assert.NoError(t, err)
+	if assert.Len(t, list, 2) {
+		assert.Equal(t, ids[2], list[0].ID)
+	}
+	list, err = q.List(Filter{Status: StatusFailed})
+	assert.NoError(t, err)
+	assert.Empty(t, list)
+}
+
+func TestQueueRetry(t *testing.T) {
+	var calls atomic.Int32
+	var healthy atomic.Bool
+	ctx, q := newTestQueue(t, t.TempDir(), func(q *Queue) {
+		q.RegisterStep("upload", func(ctx context.Context, job *Job) ([]string, error) {
+			calls.Add(1)
+			if !healthy.Load() {
+				return nil, errors.New("connection refused")
+			}
+			return nil, nil
+		})
+	})
+	defer q.Close(ctx)
+
+	job := &Job{File: "a.flv", Steps: []string{"upload"}}
+	assert.NoError(t, q.Enqueue(job))
+	failed := waitStatus(t, q, job.ID, StatusFailed)
+	assert.EqualValues(t, 3, calls.Load())
+	assert.Equal(t, 3, failed.Attempts)
+	assert.Equal(t, "upload: connection refused", failed.Error)
+	// 两次重试之间的等待时间按指数增长：10ms、20ms
+	assert.GreaterOrEqual(t, failed.FinishedAt.Sub(failed.CreatedAt), 30*time.Millisecond)
+
+	_, err := q.Retry(failed.ID)
+	assert.NoError(t, err)
+	healthy.Store(true)
+	waitStatus(t, q, job.ID, StatusSucceeded)
+	_, err = q.Retry(job.ID)
+	assert.ErrorIs(t, err, ErrJobNotRetryable)
+	_, err = q.Retry(100)
+	assert.ErrorIs(t, err, ErrJobNotExist)
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, time.Second, backoff(time.Second, 1))
+	assert.Equal(t, 4*time.Second, backoff(time.Second, 3))
+	assert.Equal(t, 1024*time.Second, backoff(time.Second, 100))
+}
+
+func TestQueueCancelAndResume(t *testing.T) {
+	dir := t.TempDir()
+	started := make(chan struct{}, 4)
+	block := func(ctx context.Context, job *Job) ([]string, error) {
+		started <- struct{}{}
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	ctx, q := newTestQueue(t, dir, func(q *Queue) {
+		q.RegisterStep("block", block)
+	})
+	first := &Job{File: "a.flv", Steps: []string{"block"}}
+	second := &Job{File: "b.flv", Steps: []string{"block"}}
+	third := &Job{File: "c.flv", Steps: []string{"block"}}
+	for _, job := range []*Job{first, second, third} {
+		assert.NoError(t, q.Enqueue(job))
+	}
+	<-started
+
+	// 取消正在执行的任务，下一个任务开始执行
+	_, err := q.Cancel(first.ID)
+	assert.NoError(t, err)
+	canceled := waitStatus(t, q, first.ID, StatusCanceled)
+	assert.Equal(t, "block: context canceled", canceled.Error)
+	<-started
+	_, err = q.Cancel(first.ID)
+	assert.ErrorIs(t, err, ErrJobNotCancelable)
+
+	// 取消等待中的任务
+	_, err = q.Cancel(third.ID)
+	assert.NoError(t, err)
+	waitStatus(t, q, third.ID, StatusCanceled)
+
+	// 程序退出时正在执行的任务重新变为等待中，不计入重试次数
+	q.Close(ctx)
+
+	var calls atomic.Int32
+	ctx, q = newTestQueue(t, dir, func(q *Queue) {
+		q.RegisterStep("block", func(ctx context.Context, job *Job) ([]string, error) {
+			calls.Add(1)
+			return []string{job.File + ".mp4"}, nil
+		})
+	})
+	defer q.Close(ctx)
+	resumed := waitStatus(t, q, second.ID, StatusSucceeded)
+	assert.Equal(t, 1, resumed.Attempts)
+	assert.Equal(t, []string{"b.flv.mp4"}, resumed.Files)
+	assert.EqualValues(t, 1, calls.Load())
+	job, err := q.Get(third.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusCanceled, job.Status)
+
+	// 完成的任务从未完成任务的索引中移除
+	assert.NoError(t, q.db.View(func(tx *bolt.Tx) error {
+		assert.Zero(t, tx.Bucket(pendingBucket).Stats().KeyN)
+		return nil
+	}))
+}
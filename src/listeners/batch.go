@@ -0,0 +1,158 @@
+package listeners
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/bililive-go/bililive-go/src/instance"
+	"github.com/bililive-go/bililive-go/src/interfaces"
+	"github.com/bililive-go/bililive-go/src/live"
+	"github.com/bililive-go/bililive-go/src/pkg/ratelimit"
+	"github.com/bililive-go/bililive-go/src/types"
+)
+
+// for test
+var batchKickDelay = time.Second
+
+// batchGroup 同一平台中支持批量查询的直播间，每个轮询周期用一次请求获取所有直播间的信息，
+// 再由各自的 listener 分发事件；批量查询失败或结果中缺少的直播间单独调用 GetInfo，被平台限流时除外
+type batchGroup struct {
+	platform string
+	inst     *instance.Instance
+	logger   *interfaces.Logger
+
+	lock      sync.Mutex
+	listeners map[types.LiveID]*listener
+	// throttled 批量查询是否被平台限流，只在 run 中读写
+	throttled bool
+
+	// kick 有新的直播间加入时尽快轮询一次，代替单独轮询时启动后的第一次查询
+	kick chan struct{}
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newBatchGroup(inst *instance.Instance, platform string) *batchGroup {
+	g := &batchGroup{
+		platform:  platform,
+		inst:      inst,
+		logger:    inst.Logger,
+		listeners: make(map[types.LiveID]*listener),
+		kick:      make(chan struct{}, 1),
+		stop:      make(chan struct{}),
+	}
+	g.wg.Add(1)
+	go g.run()
+	return g
+}
+
+func (g *batchGroup) add(l *listener) {
+	g.lock.Lock()
+	g.listeners[l.Live.GetLiveId()] = l
+	g.lock.Unlock()
+	select {
+	case g.kick <- struct{}{}:
+	default:
+	}
+}
+
+func (g *batchGroup) remove(l *listener) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if g.listeners[l.Live.GetLiveId()] == l {
+		delete(g.listeners, l.Live.GetLiveId())
+	}
+}
+
+func (g *batchGroup) close() {
+	close(g.stop)
+	g.wg.Wait()
+}
+
+func (g *batchGroup) run() {
+	defer g.wg.Done()
+	interval := g.inst.GetConfig().Interval
+	ticker := newTicker(interval)
+	defer func() { ticker.Stop() }()
+
+	for {
+		select {
+		case <-g.stop:
+			return
+		case <-g.kick:
+			// 等待同时加入的其他直播间，例如启动时
+			select {
+			case <-g.stop:
+				return
+			case <-time.After(batchKickDelay):
+			}
+			g.refresh()
+		case <-ticker.C:
+			g.refresh()
+			// 重新加载配置后使用新的轮询间隔
+			if newInterval := g.inst.GetConfig().Interval; newInterval != interval {
+				ticker.Stop()
+				interval = newInterval
+				ticker = newTicker(interval)
+			}
+		}
+	}
+}
+
+// refresh 批量获取需要轮询的直播间的信息并更新各 listener 的状态
+func (g *batchGroup) refresh() {
+	g.lock.Lock()
+	members := make([]*listener, 0, len(g.listeners))
+	for _, l := range g.listeners {
+		members = append(members, l)
+	}
+	g.lock.Unlock()
+
+	polling := make([]*listener, 0, len(members))
+	lives := make([]live.Live, 0, len(members))
+	for _, l := range members {
+		if l.checkSchedule() {
+			polling = append(polling, l)
+			lives = append(lives, l.Live)
+		}
+	}
+	if len(polling) == 0 {
+		return
+	}
+	var infos map[types.LiveID]*live.Info
+	if provider, ok := live.GetBatchInfoProvider(lives[0]); ok {
+		var err error
+		infos, err = live.BatchGetInfo(provider, lives)
+		var throttledErr *ratelimit.ThrottledError
+		switch {
+		case errors.As(err, &throttledErr):
+			// 平台限流期间单独获取同样会被限流，跳过本次轮询，只在进入限流状态时记录一次
+			if !g.throttled {
+				g.throttled = true
+				g.logger.WithField("platform", g.platform).
+					Warnf("polling is throttled by %s until %s", throttledErr.Domain, throttledErr.Until.Format(time.DateTime))
+			}
+			return
+		case err != nil:
+			g.logger.WithError(err).WithField("platform", g.platform).
+				Warn("failed to batch load room info, fall back to loading rooms one by one")
+		case g.throttled:
+			g.throttled = false
+			g.logger.WithField("platform", g.platform).Info("polling is no longer throttled")
+		}
+	}
+	for _, l := range polling {
+		select {
+		case <-l.stop:
+			// 轮询期间被移除
+			continue
+		default:
+		}
+		if info, ok := infos[l.Live.GetLiveId()]; ok {
+			l.update(info, nil)
+		} else {
+			l.refresh()
+		}
+	}
+}
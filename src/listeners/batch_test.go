@@ -0,0 +1,107 @@
+package listeners
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	gomock "go.uber.org/mock/gomock"
+
+	"github.com/bililive-go/bililive-go/src/configs"
+	"github.com/bililive-go/bililive-go/src/instance"
+	livepkg "github.com/bililive-go/bililive-go/src/live"
+	livemock "github.com/bililive-go/bililive-go/src/live/mock"
+	"github.com/bililive-go/bililive-go/src/log"
+	"github.com/bililive-go/bililive-go/src/pkg/events"
+	evtmock "github.com/bililive-go/bililive-go/src/pkg/events/mock"
+	"github.com/bililive-go/bililive-go/src/pkg/ratelimit"
+	"github.com/bililive-go/bililive-go/src/types"
+)
+
+// batchLive 支持批量查询的直播间
+type batchLive struct {
+	*livemock.MockLive
+	batchGetInfo func(lives []livepkg.Live) (map[types.LiveID]*livepkg.Info, error)
+}
+
+func (b *batchLive) BatchGetInfo(lives []livepkg.Live) (map[types.LiveID]*livepkg.Info, error) {
+	return b.batchGetInfo(lives)
+}
+
+func TestBatchGroupRefresh(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ed := evtmock.NewMockDispatcher(ctrl)
+	inst := &instance.Instance{
+		EventDispatcher: ed,
+		Config:          configs.NewConfig(),
+	}
+	ctx := context.WithValue(context.Background(), instance.Key, inst)
+	log.New(ctx)
+
+	var requested [][]livepkg.Live
+	results := make(map[types.LiveID]*livepkg.Info)
+	var batchErr error
+	newBatchLive := func(id types.LiveID) *batchLive {
+		l := &batchLive{MockLive: livemock.NewMockLive(ctrl)}
+		l.EXPECT().GetLiveId().Return(id).AnyTimes()
+		l.EXPECT().GetRawUrl().Return("https://live.bilibili.com/" + string(id)).AnyTimes()
+		l.EXPECT().GetPlatformCNName().Return("platform").AnyTimes()
+		l.batchGetInfo = func(lives []livepkg.Live) (map[types.LiveID]*livepkg.Info, error) {
+			requested = append(requested, lives)
+			return results, batchErr
+		}
+		return l
+	}
+	a, b := newBatchLive("a"), newBatchLive("b")
+
+	m := NewManager(ctx).(*manager)
+	la, lb := NewListener(ctx, a).(*listener), NewListener(ctx, b).(*listener)
+	m.attachBatch(ctx, la)
+	m.attachBatch(ctx, lb)
+	assert.Same(t, la.batch, lb.batch)
+	g := la.batch
+	defer g.close()
+	g.listeners[a.GetLiveId()] = la
+	g.listeners[b.GetLiveId()] = lb
+
+	// a 的信息来自批量查询，b 不在结果中，单独获取
+	results[a.GetLiveId()] = &livepkg.Info{Status: true}
+	b.EXPECT().GetInfo().Return(&livepkg.Info{Status: true}, nil)
+	for _, l := range []*batchLive{a, b} {
+		l.EXPECT().SetLastStartTime(gomock.Any())
+		ed.EXPECT().DispatchEvent(events.NewEvent(LiveStart, l))
+	}
+	g.refresh()
+	assert.Len(t, requested, 1)
+	assert.Len(t, requested[0], 2)
+	assert.True(t, la.status.roomStatus)
+	assert.True(t, lb.status.roomStatus)
+
+	// 批量查询失败时全部单独获取
+	batchErr = errors.New("batch error")
+	for _, l := range []*batchLive{a, b} {
+		l.EXPECT().GetInfo().Return(&livepkg.Info{Status: false}, nil)
+		ed.EXPECT().DispatchEvent(events.NewEvent(LiveEnd, l))
+	}
+	g.refresh()
+	assert.Len(t, requested, 2)
+	assert.False(t, la.status.roomStatus)
+	assert.False(t, lb.status.roomStatus)
+
+	// 被平台限流时不再单独获取
+	batchErr = &ratelimit.ThrottledError{Domain: "api.live.bilibili.com", Until: time.Now().Add(time.Minute)}
+	g.refresh()
+	g.refresh()
+	assert.Len(t, requested, 4)
+	assert.True(t, g.throttled)
+
+	// 限流结束后恢复
+	batchErr = nil
+	results[b.GetLiveId()] = &livepkg.Info{Status: false}
+	results[a.GetLiveId()] = &livepkg.Info{Status: false}
+	g.refresh()
+	assert.False(t, g.throttled)
+}
@@ -11,4 +11,10 @@ const (
 	LiveEnd                  events.EventType = "LiveEnd"
 	RoomNameChanged          events.EventType = "RoomNameChanged"
 	RoomInitializingFinished events.EventType = "RoomInitializingFinished"
+	// ScheduleWindowEnd 直播间的录制窗口结束，正在进行的录制需要停止
+	ScheduleWindowEnd events.EventType = "ScheduleWindowEnd"
+	// RecordFilterRejected 直播不满足录制规则，正在进行的录制需要停止；开播时不满足也会在 LiveStart 之外分发
+	RecordFilterRejected events.EventType = "RecordFilterRejected"
+	// RecordFilterAccepted 直播中标题等变化后重新满足录制规则，需要开始录制
+	RecordFilterAccepted events.EventType = "RecordFilterAccepted"
 )
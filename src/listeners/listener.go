@@ -3,6 +3,7 @@ package listeners
 
 import (
 	"context"
+	"errors"
 	"sync/atomic"
 	"time"
 
@@ -16,6 +17,7 @@ import (
 	"github.com/bililive-go/bililive-go/src/live/system"
 	"github.com/bililive-go/bililive-go/src/notify"
 	"github.com/bililive-go/bililive-go/src/pkg/events"
+	"github.com/bililive-go/bililive-go/src/pkg/ratelimit"
 )
 
 const (
@@ -25,9 +27,21 @@ const (
 	stopped
 )
 
+// 录制时间表的状态
+const (
+	scheduleUnknown uint8 = iota
+	scheduleInside
+	scheduleOutside
+)
+
+// for test
+var now = time.Now
+
 type Listener interface {
 	Start() error
 	Close()
+	// RejectReason 直播中但不满足录制规则时返回原因，否则返回空字符串
+	RejectReason() string
 }
 
 func NewListener(ctx context.Context, live live.Live) Listener {
@@ -35,7 +49,7 @@ func NewListener(ctx context.Context, live live.Live) Listener {
 	return &listener{
 		Live:   live,
 		status: status{},
-		config: inst.Config,
+		inst:   inst,
 		stop:   make(chan struct{}),
 		ed:     inst.EventDispatcher.(events.Dispatcher),
 		logger: inst.Logger,
@@ -47,12 +61,23 @@ type listener struct {
 	Live   live.Live
 	status status
 
-	config *configs.Config
+	// inst 配置可能被重新加载，每次使用时从 inst 读取
+	inst   *instance.Instance
 	ed     events.Dispatcher
 	logger *interfaces.Logger
 
 	state uint32
 	stop  chan struct{}
+
+	scheduleState uint8
+	// logOnly 处于录制窗口外且只记录日志，此时不分发直播状态事件也不发送通知
+	logOnly bool
+	// rejectReason 当前直播不满足录制规则的原因，会被 API 并发读取
+	rejectReason atomic.Value
+	// throttled 平台 api 被限流，轮询暂停
+	throttled bool
+	// batch 平台支持批量查询时不单独轮询，由 batch 统一获取直播间信息
+	batch *batchGroup
 }
 
 func (l *listener) Start() error {
@@ -62,31 +87,61 @@ func (l *listener) Start() error {
 	defer atomic.CompareAndSwapUint32(&l.state, pending, running)
 
 	l.ed.DispatchEvent(events.NewEvent(ListenStart, l.Live))
-	l.refresh()
+	if l.batch != nil {
+		// 由平台的 batchGroup 统一轮询
+		l.batch.add(l)
+		return nil
+	}
+	l.tick()
 	go l.run()
 	return nil
 }
 
+func (l *listener) RejectReason() string {
+	reason, _ := l.rejectReason.Load().(string)
+	return reason
+}
+
 func (l *listener) Close() {
 	if !atomic.CompareAndSwapUint32(&l.state, running, stopped) {
 		return
 	}
 	l.ed.DispatchEvent(events.NewEvent(ListenStop, l.Live))
+	if l.batch != nil {
+		l.batch.remove(l)
+	}
 	close(l.stop)
 }
 
-// sendLiveNotification 发送直播状态变更通知
-func (l *listener) sendLiveNotification(hostName, status string) {
+// sendLiveNotification 发送直播状态变更通知，hostName 为已从缓存中补全的主播名
+func (l *listener) sendLiveNotification(info *live.Info, hostName, status string) {
 	// 创建context用于日志记录
 	ctx := context.Background()
+	notifyInfo := *info
+	notifyInfo.Live = l.Live
+	notifyInfo.HostName = hostName
 	// 发送通知
-	if err := notify.SendNotification(ctx, hostName, l.Live.GetPlatformCNName(), l.Live.GetRawUrl(), status); err != nil {
+	if err := notify.SendLiveNotification(ctx, &notifyInfo, status); err != nil {
 		l.logger.WithError(err).WithField("host", hostName).Error("failed to send notification")
 	}
 }
 
 func (l *listener) refresh() {
-	info, err := l.Live.GetInfo()
+	l.update(l.Live.GetInfo())
+}
+
+// update 根据获取到的直播间信息更新状态并分发事件
+func (l *listener) update(info *live.Info, err error) {
+	var throttledErr *ratelimit.ThrottledError
+	if errors.As(err, &throttledErr) {
+		// 平台限流期间的请求没有发出，只在进入限流状态时记录一次
+		if !l.throttled {
+			l.throttled = true
+			l.logger.WithField("url", l.Live.GetRawUrl()).
+				Warnf("polling is throttled by %s until %s", throttledErr.Domain, throttledErr.Until.Format(time.DateTime))
+		}
+		return
+	}
 	if err != nil {
 		l.logger.
 			WithError(err).
@@ -94,6 +149,10 @@ func (l *listener) refresh() {
 			Error("failed to load room info")
 		return
 	}
+	if l.throttled {
+		l.throttled = false
+		l.logger.WithField("url", l.Live.GetRawUrl()).Info("polling is no longer throttled")
+	}
 
 	// 尝试从缓存中获取主播姓名，以防API调用失败
 	hostName := info.HostName
@@ -117,6 +176,8 @@ func (l *listener) refresh() {
 	defer func() { l.status = latestStatus }()
 
 	isStatusChanged := true
+	// rejectedAtStart 开播时不满足录制规则，LiveStart 之外再分发 RecordFilterRejected
+	rejectedAtStart := false
 	switch l.status.Diff(latestStatus) {
 	case 0:
 		isStatusChanged = false
@@ -124,23 +185,54 @@ func (l *listener) refresh() {
 		l.Live.SetLastStartTime(time.Now())
 		evtTyp = LiveStart
 		logInfo = "Live Start"
+		if !l.logOnly {
+			// 是否开始录制由 recorders.Manager 根据 RejectReason 决定
+			if reason := l.checkFilter(info); reason != "" {
+				rejectedAtStart = true
+				logInfo = "Live Start (rejected by record filter: " + reason + ")"
+			}
+		}
 		// 发送开播提醒和录像通知
-		l.sendLiveNotification(hostName, consts.LiveStatusStart)
+		if !l.logOnly {
+			l.sendLiveNotification(info, hostName, consts.LiveStatusStart)
+		}
 
 	case statusToFalseEvt:
+		l.rejectReason.Store("")
 		evtTyp = LiveEnd
 		logInfo = "Live end"
 		// 发送结束直播提醒和录像通知
-		l.sendLiveNotification(hostName, consts.LiveStatusStop)
-	case roomNameChangedEvt:
-		if !l.config.VideoSplitStrategies.OnRoomNameChanged {
-			return
+		if !l.logOnly {
+			l.sendLiveNotification(info, hostName, consts.LiveStatusStop)
 		}
+	case roomNameChangedEvt:
+		// 是否按房间名分段由 recorders.Manager 决定，这里只根据录制规则开始或停止录制
 		evtTyp = RoomNameChanged
 		logInfo = "Room name was changed"
+		if l.logOnly {
+			break
+		}
+		rejected := l.RejectReason() != ""
+		switch reason := l.checkFilter(info); {
+		case reason == "" && rejected:
+			evtTyp = RecordFilterAccepted
+			logInfo = "Room name was changed (accepted by record filter)"
+		case reason != "" && !rejected:
+			evtTyp = RecordFilterRejected
+			logInfo = "Room name was changed (rejected by record filter: " + reason + ")"
+		case reason != "":
+			// 仍然不满足录制规则，没有正在进行的录制需要处理
+			l.logger.WithFields(fields).Info("Room name was changed (still rejected by record filter: " + reason + ")")
+			isStatusChanged = false
+		}
 	}
-	if isStatusChanged {
+	if isStatusChanged && l.logOnly {
+		l.logger.WithFields(fields).Info(logInfo + " (outside schedule window, ignored)")
+	} else if isStatusChanged {
 		l.ed.DispatchEvent(events.NewEvent(evtTyp, l.Live))
+		if rejectedAtStart {
+			l.ed.DispatchEvent(events.NewEvent(RecordFilterRejected, l.Live))
+		}
 		l.logger.WithFields(fields).Info(logInfo)
 	}
 
@@ -157,21 +249,73 @@ func (l *listener) refresh() {
 	}
 }
 
-func (l *listener) run() {
-	ticker := jitterbug.New(
-		time.Duration(l.config.Interval)*time.Second,
+// checkFilter 检查直播是否满足录制规则并记录结果，满足时返回空字符串
+func (l *listener) checkFilter(info *live.Info) string {
+	room := l.inst.GetConfig().FindLiveRoomByUrl(l.Live.GetRawUrl())
+	reason := l.inst.GetConfig().GetRecordFilter(room).Check(info.RoomName, info.Category, info.Online)
+	l.rejectReason.Store(reason)
+	return reason
+}
+
+// checkSchedule 根据直播间的录制时间表更新窗口状态，返回本次是否需要轮询
+func (l *listener) checkSchedule() bool {
+	schedule := l.inst.GetConfig().FindLiveRoomByUrl(l.Live.GetRawUrl()).GetSchedule()
+	logger := l.logger.WithField("url", l.Live.GetRawUrl())
+	if schedule.InWindow(now()) {
+		if l.scheduleState == scheduleOutside {
+			logger.Info("Schedule window start")
+			// 重置直播状态，窗口开始时若已经开播也能触发 LiveStart
+			l.status = status{}
+		}
+		l.scheduleState, l.logOnly = scheduleInside, false
+		return true
+	}
+	switch l.scheduleState {
+	case scheduleInside:
+		logger.Info("Schedule window end")
+		l.ed.DispatchEvent(events.NewEvent(ScheduleWindowEnd, l.Live))
+		l.status = status{}
+		l.rejectReason.Store("")
+	case scheduleUnknown:
+		logger.Info("Outside schedule window")
+	}
+	l.scheduleState = scheduleOutside
+	l.logOnly = schedule.GetOutsideAction() == configs.ScheduleLogOnly
+	return l.logOnly
+}
+
+func (l *listener) tick() {
+	if l.checkSchedule() {
+		l.refresh()
+	}
+}
+
+func newTicker(interval int) *jitterbug.Ticker {
+	return jitterbug.New(
+		time.Duration(interval)*time.Second,
 		jitterbug.Norm{
 			Stdev: time.Second * 3,
 		},
 	)
-	defer ticker.Stop()
+}
+
+func (l *listener) run() {
+	interval := l.inst.GetConfig().Interval
+	ticker := newTicker(interval)
+	defer func() { ticker.Stop() }()
 
 	for {
 		select {
 		case <-l.stop:
 			return
 		case <-ticker.C:
-			l.refresh()
+			l.tick()
+			// 重新加载配置后使用新的轮询间隔
+			if newInterval := l.inst.GetConfig().Interval; newInterval != interval {
+				ticker.Stop()
+				interval = newInterval
+				ticker = newTicker(interval)
+			}
 		}
 	}
 }
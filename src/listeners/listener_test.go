@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/bluele/gcache"
 	"github.com/stretchr/testify/assert"
@@ -49,10 +50,11 @@ func TestRefresh(t *testing.T) {
 	l.refresh()
 	assert.True(t, l.status.roomStatus)
 
-	// true -> true, roomName change
+	// true -> true, roomName change，是否分段由 recorders.Manager 决定
 	live.EXPECT().GetInfo().Return(&livepkg.Info{Status: true, RoomName: "a"}, nil)
 	live.EXPECT().GetRawUrl().Return("").AnyTimes()                 // 添加对GetRawUrl方法的期望调用
 	live.EXPECT().GetPlatformCNName().Return("platform").AnyTimes() // 添加对GetPlatformCNName方法的期望调用
+	ed.EXPECT().DispatchEvent(events.NewEvent(RoomNameChanged, live))
 	l.refresh()
 
 	// true -> true, roomName change
@@ -72,6 +74,61 @@ func TestRefresh(t *testing.T) {
 	assert.False(t, l.status.roomStatus)
 }
 
+func TestRefreshWithRecordFilter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ed := evtmock.NewMockDispatcher(ctrl)
+	cfg := configs.NewConfig()
+	cfg.RecordFilter = configs.RecordFilter{TitleExclude: []string{"游戏"}}
+	cfg.LiveRooms = []configs.LiveRoom{{
+		Url:          "https://live.bilibili.com/1",
+		RecordFilter: &configs.RecordFilter{TitleInclude: []string{"唱"}},
+	}}
+	ctx := context.WithValue(context.Background(), instance.Key, &instance.Instance{
+		EventDispatcher: ed,
+		Config:          cfg,
+	})
+	log.New(ctx)
+	live := livemock.NewMockLive(ctrl)
+	live.EXPECT().GetRawUrl().Return("https://live.bilibili.com/1").AnyTimes()
+	live.EXPECT().GetPlatformCNName().Return("platform").AnyTimes()
+	live.EXPECT().SetLastStartTime(gomock.Any()).AnyTimes()
+	l := NewListener(ctx, live).(*listener)
+
+	// 开播时不满足房间的录制规则，仍然分发 LiveStart，由 RejectReason 决定不开始录制
+	live.EXPECT().GetInfo().Return(&livepkg.Info{Status: true, RoomName: "打游戏"}, nil)
+	ed.EXPECT().DispatchEvent(events.NewEvent(LiveStart, live))
+	ed.EXPECT().DispatchEvent(events.NewEvent(RecordFilterRejected, live))
+	l.refresh()
+	assert.Contains(t, l.RejectReason(), "title_include")
+
+	// 仍然不满足，不分发事件
+	live.EXPECT().GetInfo().Return(&livepkg.Info{Status: true, RoomName: "继续打游戏"}, nil)
+	l.refresh()
+
+	// 改为唱歌后开始录制
+	live.EXPECT().GetInfo().Return(&livepkg.Info{Status: true, RoomName: "唱歌"}, nil)
+	ed.EXPECT().DispatchEvent(events.NewEvent(RecordFilterAccepted, live))
+	l.refresh()
+	assert.Empty(t, l.RejectReason())
+
+	// 满足时标题变化照常分发 RoomNameChanged
+	live.EXPECT().GetInfo().Return(&livepkg.Info{Status: true, RoomName: "唱歌 2"}, nil)
+	ed.EXPECT().DispatchEvent(events.NewEvent(RoomNameChanged, live))
+	l.refresh()
+
+	// 再次不满足时停止录制
+	live.EXPECT().GetInfo().Return(&livepkg.Info{Status: true, RoomName: "聊天"}, nil)
+	ed.EXPECT().DispatchEvent(events.NewEvent(RecordFilterRejected, live))
+	l.refresh()
+	assert.NotEmpty(t, l.RejectReason())
+
+	live.EXPECT().GetInfo().Return(&livepkg.Info{Status: false}, nil)
+	ed.EXPECT().DispatchEvent(events.NewEvent(LiveEnd, live))
+	l.refresh()
+	assert.Empty(t, l.RejectReason())
+}
+
 func TestRefreshWithError(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -117,3 +174,54 @@ func TestListenerStartAndClose(t *testing.T) {
 	l.Close()
 	l.Close()
 }
+
+func TestRefreshWithSchedule(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ed := evtmock.NewMockDispatcher(ctrl)
+	cfg := configs.NewConfig()
+	schedule := &configs.Schedule{
+		Windows:       []configs.ScheduleWindow{{Start: "20:00", End: "22:00"}},
+		OutsideAction: configs.ScheduleLogOnly,
+	}
+	cfg.LiveRooms = []configs.LiveRoom{{Url: "https://live.bilibili.com/1", Schedule: schedule}}
+	ctx := context.WithValue(context.Background(), instance.Key, &instance.Instance{
+		EventDispatcher: ed,
+		Config:          cfg,
+	})
+	log.New(ctx)
+	backup := now
+	defer func() { now = backup }()
+	setNow := func(hour int) {
+		now = func() time.Time { return time.Date(2024, 1, 1, hour, 0, 0, 0, time.Local) }
+	}
+	live := livemock.NewMockLive(ctrl)
+	live.EXPECT().GetRawUrl().Return("https://live.bilibili.com/1").AnyTimes()
+	live.EXPECT().GetPlatformCNName().Return("platform").AnyTimes()
+	live.EXPECT().SetLastStartTime(gomock.Any()).AnyTimes()
+	l := NewListener(ctx, live).(*listener)
+
+	// 窗口外开播，只记录日志
+	setNow(19)
+	live.EXPECT().GetInfo().Return(&livepkg.Info{Status: true}, nil)
+	l.tick()
+	assert.True(t, l.status.roomStatus)
+
+	// 窗口开始时已经在直播，触发 LiveStart
+	setNow(20)
+	live.EXPECT().GetInfo().Return(&livepkg.Info{Status: true}, nil)
+	ed.EXPECT().DispatchEvent(events.NewEvent(LiveStart, live))
+	l.tick()
+
+	// 窗口结束，停止录制
+	setNow(22)
+	ed.EXPECT().DispatchEvent(events.NewEvent(ScheduleWindowEnd, live))
+	live.EXPECT().GetInfo().Return(&livepkg.Info{Status: true}, nil)
+	l.tick()
+
+	// 窗口外停止轮询，不会调用 GetInfo
+	schedule.OutsideAction = configs.ScheduleStopPolling
+	setNow(23)
+	l.tick()
+	assert.False(t, l.logOnly)
+}
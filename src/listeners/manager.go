@@ -4,6 +4,7 @@ import (
 	"context"
 	"sync"
 
+	"github.com/bililive-go/bililive-go/src/configs"
 	"github.com/bililive-go/bililive-go/src/instance"
 	"github.com/bililive-go/bililive-go/src/interfaces"
 	"github.com/bililive-go/bililive-go/src/live"
@@ -16,7 +17,8 @@ var newListener = NewListener
 
 func NewManager(ctx context.Context) Manager {
 	lm := &manager{
-		savers: make(map[types.LiveID]Listener),
+		savers:  make(map[types.LiveID]Listener),
+		batches: make(map[string]*batchGroup),
 	}
 	instance.GetInstance(ctx).ListenerManager = lm
 	return lm
@@ -33,6 +35,26 @@ type Manager interface {
 type manager struct {
 	lock   sync.RWMutex
 	savers map[types.LiveID]Listener
+	// batches 支持批量查询的平台，按平台名索引
+	batches map[string]*batchGroup
+}
+
+// attachBatch 平台支持批量查询时，listener 交由平台的 batchGroup 统一轮询，需要持有 lock
+func (m *manager) attachBatch(ctx context.Context, l Listener) {
+	ll, ok := l.(*listener)
+	if !ok {
+		return
+	}
+	if _, ok := live.GetBatchInfoProvider(ll.Live); !ok {
+		return
+	}
+	platform := ll.Live.GetPlatformCNName()
+	g, ok := m.batches[platform]
+	if !ok {
+		g = newBatchGroup(instance.GetInstance(ctx), platform)
+		m.batches[platform] = g
+	}
+	ll.batch = g
 }
 
 func (m *manager) registryListener(ctx context.Context, ed events.Dispatcher) {
@@ -46,17 +68,23 @@ func (m *manager) registryListener(ctx context.Context, ed events.Dispatcher) {
 		}
 		inst := instance.GetInstance(ctx)
 		logger := inst.Logger
-		inst.Lives[live.GetLiveId()] = live
+		inst.SetLive(live)
 
-		room, err := inst.Config.GetLiveRoomByUrl(live.GetRawUrl())
+		cfg, err := inst.UpdateConfig(func(cfg *configs.Config) error {
+			room, err := cfg.GetLiveRoomByUrl(live.GetRawUrl())
+			if err != nil {
+				return err
+			}
+			room.LiveId = live.GetLiveId()
+			return nil
+		})
 		if err != nil {
 			logger.WithFields(map[string]any{
 				"room": live.GetRawUrl(),
 			}).Error(err)
 			panic(err)
 		}
-		room.LiveId = live.GetLiveId()
-		if room.IsListening {
+		if cfg.FindLiveRoomByUrl(live.GetRawUrl()).IsListening {
 			if err := m.replaceListener(ctx, initializingLive, live); err != nil {
 				logger.WithFields(map[string]any{
 					"url": live.GetRawUrl(),
@@ -68,7 +96,7 @@ func (m *manager) registryListener(ctx context.Context, ed events.Dispatcher) {
 
 func (m *manager) Start(ctx context.Context) error {
 	inst := instance.GetInstance(ctx)
-	if inst.Config.RPC.Enable || len(inst.Lives) > 0 {
+	if inst.GetConfig().RPC.Enable || len(inst.GetLives()) > 0 {
 		inst.WaitGroup.Add(1)
 	}
 	m.registryListener(ctx, inst.EventDispatcher.(events.Dispatcher))
@@ -77,11 +105,17 @@ func (m *manager) Start(ctx context.Context) error {
 
 func (m *manager) Close(ctx context.Context) {
 	m.lock.Lock()
-	defer m.lock.Unlock()
 	for id, listener := range m.savers {
 		listener.Close()
 		delete(m.savers, id)
 	}
+	batches := m.batches
+	m.batches = make(map[string]*batchGroup)
+	m.lock.Unlock()
+	// 在锁外等待批量轮询结束，轮询中分发的事件可能会调用 manager
+	for _, g := range batches {
+		g.close()
+	}
 	inst := instance.GetInstance(ctx)
 	inst.WaitGroup.Done()
 }
@@ -94,6 +128,7 @@ func (m *manager) AddListener(ctx context.Context, live live.Live) error {
 		return ErrListenerExist
 	}
 	listener := newListener(ctx, live)
+	m.attachBatch(ctx, listener)
 	m.savers[live.GetLiveId()] = listener
 	return listener.Start()
 }
@@ -120,6 +155,7 @@ func (m *manager) replaceListener(ctx context.Context, oldLive live.Live, newLiv
 	}
 	oldListener.Close()
 	newListener := newListener(ctx, newLive)
+	m.attachBatch(ctx, newListener)
 	if oldLiveId == newLive.GetLiveId() {
 		m.savers[oldLiveId] = newListener
 	} else {
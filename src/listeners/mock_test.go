@@ -54,6 +54,20 @@ func (mr *MockListenerMockRecorder) Close() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockListener)(nil).Close))
 }
 
+// RejectReason mocks base method.
+func (m *MockListener) RejectReason() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RejectReason")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// RejectReason indicates an expected call of RejectReason.
+func (mr *MockListenerMockRecorder) RejectReason() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RejectReason", reflect.TypeOf((*MockListener)(nil).RejectReason))
+}
+
 // Start mocks base method.
 func (m *MockListener) Start() error {
 	m.ctrl.T.Helper()
@@ -6,30 +6,42 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/hr3lxphr6j/requests"
 	"github.com/tidwall/gjson"
 
 	"github.com/bililive-go/bililive-go/src/live"
 	"github.com/bililive-go/bililive-go/src/live/internal"
+	"github.com/bililive-go/bililive-go/src/pkg/ratelimit"
 	"github.com/bililive-go/bililive-go/src/pkg/utils"
+	"github.com/bililive-go/bililive-go/src/types"
 )
 
 const (
 	domain = "live.bilibili.com"
 	cnName = "哔哩哔哩"
+	// apiHost 接口的域名，被风控拦截时仍然返回 HTTP 200，错误码在响应内容中
+	apiHost = "api.live.bilibili.com"
 
 	roomInitUrl     = "https://api.live.bilibili.com/room/v1/Room/room_init"
 	roomApiUrl      = "https://api.live.bilibili.com/room/v1/Room/get_info"
 	userApiUrl      = "https://api.live.bilibili.com/live_user/v1/UserInfo/get_anchor_in_room"
+	statusApiUrl    = "https://api.live.bilibili.com/room/v1/Room/get_status_info_by_uids"
 	liveApiUrlv2    = "https://api.live.bilibili.com/xlive/web-room/v2/index/getRoomPlayInfo"
 	appLiveApiUrlv2 = "https://api.live.bilibili.com/xlive/app-room/v2/index/getRoomPlayInfo"
 	biliAppAgent    = "Bilibili Freedoooooom/MarkII BiliDroid/5.49.0 os/android model/MuMu mobi_app/android build/5490400 channel/dw090 innerVer/5490400 osVer/6.0.1 network/2"
 	biliWebAgent    = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_12_6) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/59.0.3071.115 Safari/537.36"
+
+	// 风控拦截请求时接口返回的错误码
+	codeRequestIntercepted = -412
+	codeRiskControl        = -352
 )
 
 func init() {
 	live.Register(domain, new(builder))
+	ratelimit.Default.RegisterBodyThrottling(apiHost)
 }
 
 type builder struct{}
@@ -42,7 +54,23 @@ func (b *builder) Build(url *url.URL) (live.Live, error) {
 
 type Live struct {
 	internal.BaseLive
+	// idLock 保护 realID 与 uid，它们在第一次请求时解析，监控、录制与弹幕协程可能同时请求
+	idLock sync.Mutex
 	realID string
+	// uid 主播的 uid，用于批量查询直播间状态
+	uid string
+}
+
+// getRealID 返回直播间的长号与主播的 uid，只在第一次调用时解析
+func (l *Live) getRealID() (realID, uid string, err error) {
+	l.idLock.Lock()
+	defer l.idLock.Unlock()
+	if l.realID == "" {
+		if err := l.parseRealId(); err != nil {
+			return "", "", err
+		}
+	}
+	return l.realID, l.uid, nil
 }
 
 func (l *Live) parseRealId() error {
@@ -50,12 +78,12 @@ func (l *Live) parseRealId() error {
 	if len(paths) < 2 {
 		return live.ErrRoomUrlIncorrect
 	}
-	cookies := l.Options.Cookies.Cookies(l.Url)
+	cookies := l.GetOptions().Cookies.Cookies(l.Url)
 	cookieKVs := make(map[string]string)
 	for _, item := range cookies {
 		cookieKVs[item.Name] = item.Value
 	}
-	resp, err := l.RequestSession.Get(roomInitUrl, live.CommonUserAgent, requests.Query("id", paths[1]), requests.Cookies(cookieKVs))
+	resp, err := l.RequestSession().Get(roomInitUrl, live.CommonUserAgent, requests.Query("id", paths[1]), requests.Cookies(cookieKVs))
 	if err != nil {
 		return err
 	}
@@ -63,29 +91,29 @@ func (l *Live) parseRealId() error {
 		return live.ErrRoomNotExist
 	}
 	body, err := resp.Bytes()
-	if err != nil || gjson.GetBytes(body, "code").Int() != 0 {
+	if err != nil || checkCode(resp, body) != 0 {
 		return live.ErrRoomNotExist
 	}
 	l.realID = gjson.GetBytes(body, "data.room_id").String()
+	l.uid = gjson.GetBytes(body, "data.uid").String()
 	return nil
 }
 
 func (l *Live) GetInfo() (info *live.Info, err error) {
 	// Parse the short id from URL to full id
-	if l.realID == "" {
-		if err := l.parseRealId(); err != nil {
-			return nil, err
-		}
+	realID, _, err := l.getRealID()
+	if err != nil {
+		return nil, err
 	}
-	cookies := l.Options.Cookies.Cookies(l.Url)
+	cookies := l.GetOptions().Cookies.Cookies(l.Url)
 	cookieKVs := make(map[string]string)
 	for _, item := range cookies {
 		cookieKVs[item.Name] = item.Value
 	}
-	resp, err := l.RequestSession.Get(
+	resp, err := l.RequestSession().Get(
 		roomApiUrl,
 		live.CommonUserAgent,
-		requests.Query("room_id", l.realID),
+		requests.Query("room_id", realID),
 		requests.Query("from", "room"),
 		requests.Cookies(cookieKVs),
 	)
@@ -99,18 +127,22 @@ func (l *Live) GetInfo() (info *live.Info, err error) {
 	if err != nil {
 		return nil, err
 	}
-	if gjson.GetBytes(body, "code").Int() != 0 {
+	if checkCode(resp, body) != 0 {
 		return nil, live.ErrRoomNotExist
 	}
 
 	info = &live.Info{
-		Live:      l,
-		RoomName:  gjson.GetBytes(body, "data.title").String(),
-		Status:    gjson.GetBytes(body, "data.live_status").Int() == 1,
-		AudioOnly: l.Options.AudioOnly,
+		Live:          l,
+		RoomName:      gjson.GetBytes(body, "data.title").String(),
+		Status:        gjson.GetBytes(body, "data.live_status").Int() == 1,
+		AudioOnly:     l.GetOptions().AudioOnly,
+		Category:      gjson.GetBytes(body, "data.area_name").String(),
+		CoverUrl:      gjson.GetBytes(body, "data.user_cover").String(),
+		Online:        gjson.GetBytes(body, "data.online").Int(),
+		LiveStartTime: parseLiveTime(gjson.GetBytes(body, "data.live_time").String()),
 	}
 
-	resp, err = l.RequestSession.Get(userApiUrl, live.CommonUserAgent, requests.Query("roomid", l.realID))
+	resp, err = l.RequestSession().Get(userApiUrl, live.CommonUserAgent, requests.Query("roomid", realID))
 	if err != nil {
 		return nil, err
 	}
@@ -121,30 +153,118 @@ func (l *Live) GetInfo() (info *live.Info, err error) {
 	if err != nil {
 		return nil, err
 	}
-	if gjson.GetBytes(body, "code").Int() != 0 {
+	if checkCode(resp, body) != 0 {
 		return nil, live.ErrInternalError
 	}
 
 	info.HostName = gjson.GetBytes(body, "data.info.uname").String()
+	info.AvatarUrl = gjson.GetBytes(body, "data.info.face").String()
 	return info, nil
 }
 
-func (l *Live) GetStreamInfos() (infos []*live.StreamUrlInfo, err error) {
-	if l.realID == "" {
-		if err := l.parseRealId(); err != nil {
-			return nil, err
+// BatchGetInfo 通过主播的 uid 一次获取多个直播间的信息。
+// 只查询与 l 使用同一 session 的直播间，设置了不同代理的直播间不在结果中，由调用者单独获取
+func (l *Live) BatchGetInfo(lives []live.Live) (map[types.LiveID]*live.Info, error) {
+	rooms := make(map[string]*Live, len(lives))
+	uids := make([]int64, 0, len(lives))
+	for _, item := range lives {
+		room, ok := item.(*Live)
+		if !ok || room.RequestSession() != l.RequestSession() {
+			continue
+		}
+		_, uidStr, err := room.getRealID()
+		if err != nil {
+			continue
+		}
+		uid, err := strconv.ParseInt(uidStr, 10, 64)
+		if err != nil {
+			continue
+		}
+		rooms[uidStr] = room
+		uids = append(uids, uid)
+	}
+	infos := make(map[types.LiveID]*live.Info, len(rooms))
+	if len(uids) == 0 {
+		return infos, nil
+	}
+	resp, err := l.RequestSession().Post(statusApiUrl, live.CommonUserAgent, requests.JSON(map[string]any{"uids": uids}))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, live.ErrInternalError
+	}
+	body, err := resp.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	if checkCode(resp, body) != 0 {
+		return nil, live.ErrInternalError
+	}
+	gjson.GetBytes(body, "data").ForEach(func(key, value gjson.Result) bool {
+		room, ok := rooms[key.String()]
+		if !ok {
+			return true
 		}
+		info := &live.Info{
+			Live:      room,
+			HostName:  value.Get("uname").String(),
+			RoomName:  value.Get("title").String(),
+			Status:    value.Get("live_status").Int() == 1,
+			AudioOnly: room.GetOptions().AudioOnly,
+			Category:  value.Get("area_v2_name").String(),
+			CoverUrl:  value.Get("cover_from_user").String(),
+			AvatarUrl: value.Get("face").String(),
+			Online:    value.Get("online").Int(),
+		}
+		// 与 get_info 不同，这里的 live_time 为时间戳，未开播时为 0
+		if t := value.Get("live_time").Int(); t > 0 {
+			info.LiveStartTime = time.Unix(t, 0)
+		}
+		infos[room.GetLiveId()] = info
+		return true
+	})
+	return infos, nil
+}
+
+// checkCode 返回接口的错误码，被风控拦截时通知限流器暂停请求该域名，否则重置退避时长
+func checkCode(resp *requests.Response, body []byte) int64 {
+	code := gjson.GetBytes(body, "code").Int()
+	if code == codeRequestIntercepted || code == codeRiskControl {
+		ratelimit.Default.Throttle(resp.Request.URL.Hostname())
+	} else {
+		ratelimit.Default.Reset(resp.Request.URL.Hostname())
+	}
+	return code
+}
+
+// cst 哔哩哔哩接口返回的时间所在的时区
+var cst = time.FixedZone("CST", 8*60*60)
+
+// parseLiveTime 解析 get_info 中的 live_time，未开播时为 0000-00-00 00:00:00，返回零值
+func parseLiveTime(s string) time.Time {
+	t, err := time.ParseInLocation(time.DateTime, s, cst)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+func (l *Live) GetStreamInfos() (infos []*live.StreamUrlInfo, err error) {
+	realID, _, err := l.getRealID()
+	if err != nil {
+		return nil, err
 	}
-	cookies := l.Options.Cookies.Cookies(l.Url)
+	cookies := l.GetOptions().Cookies.Cookies(l.Url)
 	cookieKVs := make(map[string]string)
 	for _, item := range cookies {
 		cookieKVs[item.Name] = item.Value
 	}
 	apiUrl := liveApiUrlv2
-	query := fmt.Sprintf("?room_id=%s&protocol=0,1&format=0,1,2&codec=0,1&qn=10000&platform=web&ptype=8&dolby=5&panorama=1", l.realID)
+	query := fmt.Sprintf("?room_id=%s&protocol=0,1&format=0,1,2&codec=0,1&qn=10000&platform=web&ptype=8&dolby=5&panorama=1", realID)
 	agent := live.CommonUserAgent
 	// for audio only use android api
-	if l.Options.AudioOnly {
+	if l.GetOptions().AudioOnly {
 		params := map[string]string{"appkey": "iVGUTjsxvpLeuDCf",
 			"build":       "6310200",
 			"codec":       "0,1",
@@ -155,8 +275,8 @@ func (l *Live) GetStreamInfos() (infos []*live.StreamUrlInfo, err error) {
 			"only_audio":  "1",
 			"platform":    "android",
 			"protocol":    "0,1",
-			"room_id":     l.realID,
-			"qn":          strconv.Itoa(l.Options.Quality),
+			"room_id":     realID,
+			"qn":          strconv.Itoa(l.GetOptions().Quality),
 		}
 		values := url.Values{}
 		for key, value := range params {
@@ -166,7 +286,7 @@ func (l *Live) GetStreamInfos() (infos []*live.StreamUrlInfo, err error) {
 		apiUrl = appLiveApiUrlv2
 		agent = requests.UserAgent(biliAppAgent)
 	}
-	resp, err := l.RequestSession.Get(apiUrl+query, agent, requests.Cookies(cookieKVs))
+	resp, err := l.RequestSession().Get(apiUrl+query, agent, requests.Cookies(cookieKVs))
 	if err != nil {
 		return nil, err
 	}
@@ -180,7 +300,7 @@ func (l *Live) GetStreamInfos() (infos []*live.StreamUrlInfo, err error) {
 	urlStrings := make([]string, 0, 4)
 	addr := ""
 
-	if l.Options.Quality == 0 && gjson.GetBytes(body, "data.playurl_info.playurl.stream.1.format.1.codec.#").Int() > 1 {
+	if l.GetOptions().Quality == 0 && gjson.GetBytes(body, "data.playurl_info.playurl.stream.1.format.1.codec.#").Int() > 1 {
 		addr = "data.playurl_info.playurl.stream.1.format.1.codec.1" // hevc m3u8
 	} else {
 		addr = "data.playurl_info.playurl.stream.0.format.0.codec.0" // avc flv
@@ -209,7 +329,7 @@ func (l *Live) GetPlatformCNName() string {
 func (l *Live) getHeadersForDownloader() map[string]string {
 	agent := biliWebAgent
 	referer := l.GetRawUrl()
-	if l.Options.AudioOnly {
+	if l.GetOptions().AudioOnly {
 		agent = biliAppAgent
 		referer = ""
 	}
@@ -0,0 +1,71 @@
+package bilibili
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/hr3lxphr6j/requests"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bililive-go/bililive-go/src/live"
+	"github.com/bililive-go/bililive-go/src/live/internal"
+)
+
+func TestParseLiveTime(t *testing.T) {
+	assert.Equal(t, int64(1704110400), parseLiveTime("2024-01-01 20:00:00").Unix())
+	assert.True(t, parseLiveTime("0000-00-00 00:00:00").IsZero())
+	assert.True(t, parseLiveTime("").IsZero())
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestBatchGetInfo(t *testing.T) {
+	var uids []int64
+	session := requests.NewSession(&http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		var body struct {
+			Uids []int64 `json:"uids"`
+		}
+		assert.NoError(t, json.NewDecoder(req.Body).Decode(&body))
+		uids = body.Uids
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body: io.NopCloser(strings.NewReader(`{"code":0,"data":{
+				"1":{"uid":1,"title":"a","uname":"host a","live_status":1,"live_time":1704110400,"online":10,"area_v2_name":"唱见电台"},
+				"2":{"uid":2,"title":"b","uname":"host b","live_status":0,"live_time":0}}}`)),
+			Request: req,
+		}, nil
+	})})
+	newLive := func(id, uid string) *Live {
+		u, _ := url.Parse("https://live.bilibili.com/" + id)
+		l := &Live{BaseLive: internal.NewBaseLive(u), realID: id, uid: uid}
+		l.SetRequestSession(session)
+		return l
+	}
+	a, b := newLive("100", "1"), newLive("200", "2")
+	// 使用其他 session 的直播间不在结果中
+	other := newLive("300", "3")
+	other.SetRequestSession(requests.NewSession(http.DefaultClient))
+
+	infos, err := a.BatchGetInfo([]live.Live{a, b, other})
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 2}, uids)
+	assert.Len(t, infos, 2)
+	infoA := infos[a.GetLiveId()]
+	assert.True(t, infoA.Status)
+	assert.Equal(t, "a", infoA.RoomName)
+	assert.Equal(t, "host a", infoA.HostName)
+	assert.Equal(t, "唱见电台", infoA.Category)
+	assert.Equal(t, int64(10), infoA.Online)
+	assert.Equal(t, int64(1704110400), infoA.LiveStartTime.Unix())
+	assert.Same(t, a, infoA.Live)
+	assert.False(t, infos[b.GetLiveId()].Status)
+	assert.True(t, infos[b.GetLiveId()].LiveStartTime.IsZero())
+}
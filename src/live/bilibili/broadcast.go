@@ -0,0 +1,312 @@
+package bilibili
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+
+	"github.com/bililive-go/bililive-go/src/configs"
+	"github.com/bililive-go/bililive-go/src/instance"
+	"github.com/bililive-go/bililive-go/src/pkg/danmaku"
+)
+
+// 弹幕服务器数据包协议版本
+const (
+	protoVerJson      uint16 = 0
+	protoVerHeartbeat uint16 = 1
+	protoVerZlib      uint16 = 2
+	protoVerBrotli    uint16 = 3
+)
+
+// 弹幕服务器数据包操作码
+const (
+	opHeartbeat      uint32 = 2
+	opHeartbeatReply uint32 = 3
+	opMessage        uint32 = 5
+	opAuth           uint32 = 7
+	opAuthReply      uint32 = 8
+)
+
+const packetHeaderLen = 16
+
+var (
+	ErrInvalidPacket = errors.New("invalid broadcast packet")
+	ErrAuthFailed    = errors.New("broadcast auth failed")
+)
+
+// for test
+var (
+	heartbeatInterval = 30 * time.Second
+	reconnectInterval = 5 * time.Second
+)
+
+// packet 弹幕服务器的数据包，头部为 16 字节的大端序整数：
+// 包长度(4) 头部长度(2) 协议版本(2) 操作码(4) 序列号(4)
+type packet struct {
+	protoVer uint16
+	op       uint32
+	body     []byte
+}
+
+func encodePacket(op uint32, protoVer uint16, body []byte) []byte {
+	buf := make([]byte, packetHeaderLen+len(body))
+	binary.BigEndian.PutUint32(buf[0:], uint32(len(buf)))
+	binary.BigEndian.PutUint16(buf[4:], packetHeaderLen)
+	binary.BigEndian.PutUint16(buf[6:], protoVer)
+	binary.BigEndian.PutUint32(buf[8:], op)
+	binary.BigEndian.PutUint32(buf[12:], 1)
+	copy(buf[packetHeaderLen:], body)
+	return buf
+}
+
+// decodePackets 解析一个 WebSocket 帧中的全部数据包，压缩包会被递归展开
+func decodePackets(data []byte) ([]packet, error) {
+	var packets []packet
+	for len(data) > 0 {
+		if len(data) < packetHeaderLen {
+			return nil, ErrInvalidPacket
+		}
+		packetLen := binary.BigEndian.Uint32(data[0:])
+		headerLen := binary.BigEndian.Uint16(data[4:])
+		if packetLen < uint32(headerLen) || uint32(len(data)) < packetLen || headerLen < packetHeaderLen {
+			return nil, ErrInvalidPacket
+		}
+		p := packet{
+			protoVer: binary.BigEndian.Uint16(data[6:]),
+			op:       binary.BigEndian.Uint32(data[8:]),
+			body:     data[headerLen:packetLen],
+		}
+		data = data[packetLen:]
+
+		var r io.Reader
+		switch {
+		case p.op == opMessage && p.protoVer == protoVerZlib:
+			zr, err := zlib.NewReader(bytes.NewReader(p.body))
+			if err != nil {
+				return nil, err
+			}
+			r = zr
+		case p.op == opMessage && p.protoVer == protoVerBrotli:
+			r = brotli.NewReader(bytes.NewReader(p.body))
+		default:
+			packets = append(packets, p)
+			continue
+		}
+		inflated, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		inner, err := decodePackets(inflated)
+		if err != nil {
+			return nil, err
+		}
+		packets = append(packets, inner...)
+	}
+	return packets, nil
+}
+
+// broadcastAuth 连接弹幕服务器所需的认证信息
+type broadcastAuth struct {
+	roomID  int64
+	uid     int64
+	buvid   string
+	token   string
+	servers []string
+	header  http.Header
+	// proxy 与访问 api 使用相同的代理
+	proxy string
+}
+
+// broadcastClient 哔哩哔哩直播弹幕服务器客户端
+type broadcastClient struct {
+	// getAuth 每次连接前获取认证信息，token 有时效性
+	getAuth func() (*broadcastAuth, error)
+}
+
+func (c *broadcastClient) getLogger(ctx context.Context) *logrus.Entry {
+	if inst := instance.GetInstance(ctx); inst != nil && inst.Logger != nil {
+		return inst.Logger.WithField("module", "danmaku")
+	}
+	return logrus.WithField("module", "danmaku")
+}
+
+func (c *broadcastClient) Run(ctx context.Context, handler danmaku.Handler) error {
+	serverIndex := 0
+	for {
+		err := c.connect(ctx, serverIndex, handler)
+		if ctx.Err() != nil {
+			return nil
+		}
+		c.getLogger(ctx).WithError(err).Debug("danmaku connection closed, will reconnect")
+		serverIndex++
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(reconnectInterval):
+		}
+	}
+}
+
+func (c *broadcastClient) connect(ctx context.Context, serverIndex int, handler danmaku.Handler) error {
+	auth, err := c.getAuth()
+	if err != nil {
+		return err
+	}
+	if len(auth.servers) == 0 {
+		return fmt.Errorf("no danmaku server available")
+	}
+	server := auth.servers[serverIndex%len(auth.servers)]
+	dialer := *websocket.DefaultDialer
+	switch auth.proxy {
+	case "":
+	case configs.ProxyDirect:
+		dialer.Proxy = nil
+	default:
+		u, err := configs.ParseProxyUrl(auth.proxy)
+		if err != nil {
+			return err
+		}
+		dialer.Proxy = http.ProxyURL(u)
+	}
+	conn, _, err := dialer.DialContext(ctx, server, auth.header)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	authBody, err := json.Marshal(map[string]any{
+		"uid":      auth.uid,
+		"roomid":   auth.roomID,
+		"protover": protoVerBrotli,
+		"buvid":    auth.buvid,
+		"platform": "web",
+		"type":     2,
+		"key":      auth.token,
+	})
+	if err != nil {
+		return err
+	}
+	if err = conn.WriteMessage(websocket.BinaryMessage, encodePacket(opAuth, protoVerHeartbeat, authBody)); err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		// ctx 结束时关闭连接，让阻塞中的 ReadMessage 返回
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	authed := false
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		packets, err := decodePackets(data)
+		if err != nil {
+			c.getLogger(ctx).WithError(err).Debug("failed to decode danmaku packet")
+			continue
+		}
+		for _, p := range packets {
+			switch p.op {
+			case opAuthReply:
+				if code := gjson.GetBytes(p.body, "code").Int(); code != 0 {
+					return fmt.Errorf("%w, code: %d", ErrAuthFailed, code)
+				}
+				if !authed {
+					authed = true
+					go c.heartbeat(conn, heartbeatInterval, done)
+				}
+			case opMessage:
+				if msg := parseBroadcastMessage(p.body); msg != nil {
+					handler(msg)
+				}
+			}
+		}
+	}
+}
+
+func (c *broadcastClient) heartbeat(conn *websocket.Conn, interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := conn.WriteMessage(websocket.BinaryMessage, encodePacket(opHeartbeat, protoVerHeartbeat, nil)); err != nil {
+			return
+		}
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// parseBroadcastMessage 将弹幕服务器推送的命令转换为弹幕消息，不关心的命令返回 nil
+func parseBroadcastMessage(body []byte) *danmaku.Message {
+	data := gjson.ParseBytes(body)
+	// 部分命令带有后缀，例如 DANMU_MSG:4:0:2:2:2:0
+	cmd, _, _ := strings.Cut(data.Get("cmd").String(), ":")
+	now := time.Now()
+	switch cmd {
+	case "DANMU_MSG":
+		info := data.Get("info")
+		return &danmaku.Message{
+			Type:     danmaku.TypeComment,
+			Time:     now,
+			UserID:   info.Get("2.0").Int(),
+			UserName: info.Get("2.1").String(),
+			Content:  info.Get("1").String(),
+			Mode:     int(info.Get("0.1").Int()),
+			FontSize: int(info.Get("0.2").Int()),
+			Color:    int(info.Get("0.3").Int()),
+		}
+	case "SEND_GIFT":
+		return &danmaku.Message{
+			Type:      danmaku.TypeGift,
+			Time:      now,
+			UserID:    data.Get("data.uid").Int(),
+			UserName:  data.Get("data.uname").String(),
+			GiftName:  data.Get("data.giftName").String(),
+			GiftCount: int(data.Get("data.num").Int()),
+		}
+	case "SUPER_CHAT_MESSAGE":
+		return &danmaku.Message{
+			Type:     danmaku.TypeSuperChat,
+			Time:     now,
+			UserID:   data.Get("data.uid").Int(),
+			UserName: data.Get("data.user_info.uname").String(),
+			Content:  data.Get("data.message").String(),
+			Price:    data.Get("data.price").Float(),
+			Duration: int(data.Get("data.time").Int()),
+		}
+	case "GUARD_BUY":
+		return &danmaku.Message{
+			Type:       danmaku.TypeGuard,
+			Time:       now,
+			UserID:     data.Get("data.uid").Int(),
+			UserName:   data.Get("data.username").String(),
+			GiftName:   data.Get("data.gift_name").String(),
+			GiftCount:  int(data.Get("data.num").Int()),
+			GuardLevel: int(data.Get("data.guard_level").Int()),
+		}
+	}
+	return nil
+}
@@ -0,0 +1,286 @@
+package bilibili
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+
+	"github.com/bililive-go/bililive-go/src/pkg/danmaku"
+)
+
+// 以下为从弹幕服务器抓取的消息体（已精简无关字段）
+const (
+	capturedDanmuMsg  = `{"cmd":"DANMU_MSG","info":[[0,1,25,16777215,1704110400000,1704110400,0,"3c2b4e5f",0,0,0,"",0,"{}","{}",{}],"晚上好",[10001,"观众A",0,0,0,10000,1,""],[],[0,0,9868950,">50000",0],["",""],0,0,null,{"ts":1704110400,"ct":"D1B3D8F3"},0,0,null,null,0,105]}`
+	capturedDanmuMsg2 = `{"cmd":"DANMU_MSG:4:0:2:2:2:0","info":[[0,4,18,65280,1704110401000,1704110401,0,"5d6e7f80",0,0,0,"",0,"{}","{}",{}],"<底部弹幕>",[10002,"观众B",0,0,0,10000,1,""],[]]}`
+	capturedGift      = `{"cmd":"SEND_GIFT","data":{"action":"投喂","giftId":1,"giftName":"辣条","num":10,"price":100,"timestamp":1704110402,"uid":10003,"uname":"观众C"}}`
+	capturedSuperChat = `{"cmd":"SUPER_CHAT_MESSAGE","data":{"id":1,"message":"主播加油","price":30,"start_time":1704110403,"time":60,"uid":10004,"user_info":{"uname":"观众D"}}}`
+	capturedGuard     = `{"cmd":"GUARD_BUY","data":{"uid":10005,"username":"观众E","guard_level":3,"num":1,"price":198000,"gift_id":10003,"gift_name":"舰长","start_time":1704110404}}`
+	capturedInteract  = `{"cmd":"INTERACT_WORD","data":{"uid":10006,"uname":"观众F","msg_type":1}}`
+)
+
+func zlibCompress(t *testing.T, data []byte) []byte {
+	buf := new(bytes.Buffer)
+	w := zlib.NewWriter(buf)
+	_, err := w.Write(data)
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func brotliCompress(t *testing.T, data []byte) []byte {
+	buf := new(bytes.Buffer)
+	w := brotli.NewWriter(buf)
+	_, err := w.Write(data)
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func messagePackets(bodies ...string) []byte {
+	var data []byte
+	for _, body := range bodies {
+		data = append(data, encodePacket(opMessage, protoVerJson, []byte(body))...)
+	}
+	return data
+}
+
+func TestDecodePackets(t *testing.T) {
+	frame := append(encodePacket(opHeartbeatReply, protoVerHeartbeat, []byte{0, 0, 0, 1}), messagePackets(capturedGift)...)
+	packets, err := decodePackets(frame)
+	assert.NoError(t, err)
+	assert.Len(t, packets, 2)
+	assert.Equal(t, opHeartbeatReply, packets[0].op)
+	assert.Equal(t, capturedGift, string(packets[1].body))
+
+	packets, err = decodePackets(encodePacket(opMessage, protoVerZlib, zlibCompress(t, messagePackets(capturedDanmuMsg, capturedGuard))))
+	assert.NoError(t, err)
+	assert.Len(t, packets, 2)
+	assert.Equal(t, capturedGuard, string(packets[1].body))
+
+	_, err = decodePackets(frame[:30])
+	assert.Equal(t, ErrInvalidPacket, err)
+}
+
+func TestParseBroadcastMessage(t *testing.T) {
+	msg := parseBroadcastMessage([]byte(capturedDanmuMsg2))
+	assert.Equal(t, danmaku.TypeComment, msg.Type)
+	assert.Equal(t, int64(10002), msg.UserID)
+	assert.Equal(t, "观众B", msg.UserName)
+	assert.Equal(t, "<底部弹幕>", msg.Content)
+	assert.Equal(t, 4, msg.Mode)
+	assert.Equal(t, 18, msg.FontSize)
+	assert.Equal(t, 65280, msg.Color)
+
+	msg = parseBroadcastMessage([]byte(capturedSuperChat))
+	assert.Equal(t, danmaku.TypeSuperChat, msg.Type)
+	assert.Equal(t, 30.0, msg.Price)
+	assert.Equal(t, 60, msg.Duration)
+	assert.Equal(t, "观众D", msg.UserName)
+
+	msg = parseBroadcastMessage([]byte(capturedGuard))
+	assert.Equal(t, danmaku.TypeGuard, msg.Type)
+	assert.Equal(t, 3, msg.GuardLevel)
+
+	assert.Nil(t, parseBroadcastMessage([]byte(capturedInteract)))
+}
+
+// broadcastServer 模拟弹幕服务器，认证成功后回放抓取的数据帧
+type broadcastServer struct {
+	t           *testing.T
+	connections atomic.Int32
+	heartbeats  atomic.Int32
+}
+
+func (s *broadcastServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{
+		CheckOrigin: func(r *http.Request) bool { return true },
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	n := s.connections.Add(1)
+
+	_, data, err := conn.ReadMessage()
+	assert.NoError(s.t, err)
+	packets, err := decodePackets(data)
+	assert.NoError(s.t, err)
+	assert.Len(s.t, packets, 1)
+	assert.Equal(s.t, opAuth, packets[0].op)
+	assert.Equal(s.t, int64(7734200), gjson.GetBytes(packets[0].body, "roomid").Int())
+	assert.Equal(s.t, "test-token", gjson.GetBytes(packets[0].body, "key").String())
+	assert.Equal(s.t, "https://live.bilibili.com", r.Header.Get("Origin"))
+
+	frames := [][]byte{encodePacket(opAuthReply, protoVerHeartbeat, []byte(`{"code":0}`))}
+	if n == 1 {
+		frames = append(frames,
+			encodePacket(opMessage, protoVerBrotli, brotliCompress(s.t, messagePackets(capturedDanmuMsg, capturedDanmuMsg2))),
+			encodePacket(opHeartbeatReply, protoVerHeartbeat, []byte{0, 0, 0x10, 0}),
+			encodePacket(opMessage, protoVerZlib, zlibCompress(s.t, messagePackets(capturedGift, capturedSuperChat))),
+			messagePackets(capturedInteract, capturedGuard),
+		)
+	} else {
+		frames = append(frames, messagePackets(capturedDanmuMsg))
+	}
+	for _, frame := range frames {
+		assert.NoError(s.t, conn.WriteMessage(websocket.BinaryMessage, frame))
+	}
+	// 等待客户端心跳后断开，第一次连接断开后客户端应当重连
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if packets, err := decodePackets(data); err == nil && len(packets) > 0 && packets[0].op == opHeartbeat {
+			s.heartbeats.Add(1)
+			if n == 1 {
+				return
+			}
+		}
+	}
+}
+
+func TestBroadcastClient(t *testing.T) {
+	backupHeartbeat, backupReconnect := heartbeatInterval, reconnectInterval
+	heartbeatInterval, reconnectInterval = 50*time.Millisecond, 10*time.Millisecond
+	defer func() { heartbeatInterval, reconnectInterval = backupHeartbeat, backupReconnect }()
+
+	s := &broadcastServer{t: t}
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	client := &broadcastClient{getAuth: func() (*broadcastAuth, error) {
+		header := http.Header{}
+		header.Set("Origin", "https://"+domain)
+		return &broadcastAuth{
+			roomID:  7734200,
+			token:   "test-token",
+			servers: []string{"ws" + strings.TrimPrefix(server.URL, "http")},
+			header:  header,
+		}, nil
+	}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var (
+		lock     sync.Mutex
+		messages []*danmaku.Message
+	)
+	done := make(chan error, 1)
+	go func() {
+		done <- client.Run(ctx, func(msg *danmaku.Message) {
+			lock.Lock()
+			defer lock.Unlock()
+			messages = append(messages, msg)
+			if len(messages) == 6 {
+				cancel()
+			}
+		})
+	}()
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		cancel()
+		<-done
+		t.Fatal("client did not receive all messages")
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	types := make([]danmaku.MessageType, 0, len(messages))
+	for _, msg := range messages {
+		types = append(types, msg.Type)
+	}
+	assert.Equal(t, []danmaku.MessageType{
+		danmaku.TypeComment, danmaku.TypeComment, danmaku.TypeGift, danmaku.TypeSuperChat, danmaku.TypeGuard,
+		danmaku.TypeComment,
+	}, types)
+	assert.Equal(t, "晚上好", messages[0].Content)
+	assert.Equal(t, "观众A", messages[0].UserName)
+	assert.Equal(t, "辣条", messages[2].GiftName)
+	assert.Equal(t, 10, messages[2].GiftCount)
+	assert.Equal(t, int32(2), s.connections.Load())
+	assert.GreaterOrEqual(t, s.heartbeats.Load(), int32(1))
+}
+
+// connectProxy 只支持 CONNECT 的 http 代理，记录经过的连接数
+type connectProxy struct {
+	connections atomic.Int32
+}
+
+func (p *connectProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodConnect {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	target, err := net.Dial("tcp", r.Host)
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	defer target.Close()
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	p.connections.Add(1)
+	conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+	go io.Copy(target, conn)
+	io.Copy(conn, target)
+}
+
+func TestBroadcastClientProxy(t *testing.T) {
+	server := httptest.NewServer(&broadcastServer{t: t})
+	defer server.Close()
+	p := &connectProxy{}
+	proxy := httptest.NewServer(p)
+	defer proxy.Close()
+
+	client := &broadcastClient{getAuth: func() (*broadcastAuth, error) {
+		header := http.Header{}
+		header.Set("Origin", "https://"+domain)
+		return &broadcastAuth{
+			roomID:  7734200,
+			token:   "test-token",
+			servers: []string{"ws" + strings.TrimPrefix(server.URL, "http")},
+			header:  header,
+			proxy:   proxy.URL,
+		}, nil
+	}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	received := make(chan struct{})
+	go client.Run(ctx, func(msg *danmaku.Message) {
+		select {
+		case <-received:
+		default:
+			close(received)
+		}
+	})
+	select {
+	case <-received:
+	case <-ctx.Done():
+		t.Fatal("client did not receive messages through the proxy")
+	}
+	cancel()
+	assert.Equal(t, int32(1), p.connections.Load())
+}
@@ -0,0 +1,78 @@
+package bilibili
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/hr3lxphr6j/requests"
+	"github.com/tidwall/gjson"
+
+	"github.com/bililive-go/bililive-go/src/live"
+	"github.com/bililive-go/bililive-go/src/pkg/danmaku"
+)
+
+const (
+	danmuInfoApiUrl      = "https://api.live.bilibili.com/xlive/web-room/v1/index/getDanmuInfo"
+	defaultDanmakuServer = "wss://broadcastlv.chat.bilibili.com/sub"
+)
+
+func (l *Live) NewDanmakuClient() (danmaku.Client, error) {
+	return &broadcastClient{getAuth: l.getBroadcastAuth}, nil
+}
+
+func (l *Live) getBroadcastAuth() (*broadcastAuth, error) {
+	realID, _, err := l.getRealID()
+	if err != nil {
+		return nil, err
+	}
+	roomID, err := strconv.ParseInt(realID, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	cookies := l.GetOptions().Cookies.Cookies(l.Url)
+	cookieKVs := make(map[string]string)
+	for _, item := range cookies {
+		cookieKVs[item.Name] = item.Value
+	}
+	uid, _ := strconv.ParseInt(cookieKVs["DedeUserID"], 10, 64)
+	header := http.Header{}
+	header.Set("User-Agent", biliWebAgent)
+	header.Set("Origin", "https://"+domain)
+	if len(cookies) > 0 {
+		req := &http.Request{Header: http.Header{}}
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		header.Set("Cookie", req.Header.Get("Cookie"))
+	}
+	auth := &broadcastAuth{
+		roomID: roomID,
+		uid:    uid,
+		buvid:  cookieKVs["buvid3"],
+		header: header,
+		proxy:  l.GetProxy(),
+	}
+
+	resp, err := l.RequestSession().Get(
+		danmuInfoApiUrl,
+		live.CommonUserAgent,
+		requests.Query("id", realID),
+		requests.Query("type", "0"),
+		requests.Cookies(cookieKVs),
+	)
+	if err == nil && resp.StatusCode == http.StatusOK {
+		if body, err := resp.Bytes(); err == nil && checkCode(resp, body) == 0 {
+			auth.token = gjson.GetBytes(body, "data.token").String()
+			gjson.GetBytes(body, "data.host_list").ForEach(func(_, value gjson.Result) bool {
+				auth.servers = append(auth.servers, fmt.Sprintf("wss://%s:%d/sub", value.Get("host").String(), value.Get("wss_port").Int()))
+				return true
+			})
+		}
+	}
+	// 获取弹幕服务器信息失败时，使用默认服务器匿名连接
+	if len(auth.servers) == 0 {
+		auth.servers = []string{defaultDanmakuServer}
+	}
+	return auth, nil
+}
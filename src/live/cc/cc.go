@@ -38,7 +38,7 @@ type Live struct {
 }
 
 func (l *Live) getData() (*gjson.Result, error) {
-	resp, err := l.RequestSession.Get(l.Url.String(), live.CommonUserAgent)
+	resp, err := l.RequestSession().Get(l.Url.String(), live.CommonUserAgent)
 	if err != nil {
 		return nil, err
 	}
@@ -93,7 +93,7 @@ func (l *Live) GetStreamUrls() (us []*url.URL, err error) {
 	if err != nil {
 		return nil, err
 	}
-	resp, err := l.RequestSession.Get(fmt.Sprintf("%s%s", apiUrl, ccid), live.CommonUserAgent)
+	resp, err := l.RequestSession().Get(fmt.Sprintf("%s%s", apiUrl, ccid), live.CommonUserAgent)
 	if err != nil {
 		return nil, err
 	}
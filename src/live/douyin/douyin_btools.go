@@ -46,9 +46,10 @@ func NewBtoolsLive(live *Live) btoolsLive {
 
 type btoolsLive struct {
 	*Live
-	roomId   string
-	hostName string
-	roomName string
+	roomId    string
+	hostName  string
+	roomName  string
+	avatarUrl string
 }
 
 func (l *btoolsLive) updateChannelInfo() (err error) {
@@ -63,6 +64,7 @@ func (l *btoolsLive) updateChannelInfo() (err error) {
 	}
 	l.hostName = channelInfo.Owner
 	l.roomName = channelInfo.Title
+	l.avatarUrl = channelInfo.Avatar
 	l.roomId = channelInfo.Id
 	return
 }
@@ -169,6 +171,7 @@ func (l *btoolsLive) GetInfo() (info *live.Info, err error) {
 	ret.Status = liveInfo.Living
 	ret.HostName = liveInfo.Owner
 	ret.RoomName = liveInfo.Title
+	ret.AvatarUrl = l.avatarUrl
 
 	return ret, nil
 }
@@ -115,7 +115,7 @@ func (l *Live) loadCryptoJS() {
 		"https://cdn.bootcdn.net/ajax/libs/crypto-js/3.1.9-1/crypto-js.min.js"}
 
 	for _, url := range cdnUrls {
-		resp, err = l.RequestSession.Get(url)
+		resp, err = l.RequestSession().Get(url)
 		if err != nil || resp.StatusCode != http.StatusOK {
 			continue
 		}
@@ -150,7 +150,7 @@ func (l *Live) fetchRoomID() error {
 		return nil
 	}
 	var body []byte
-	resp, err := l.RequestSession.Get(l.Url.String(), live.CommonUserAgent)
+	resp, err := l.RequestSession().Get(l.Url.String(), live.CommonUserAgent)
 	if err != nil {
 		return errors.New("request failed. error: " + err.Error())
 	}
@@ -203,7 +203,7 @@ func (l *Live) GetInfo() (info *live.Info, err error) {
 		}
 
 	}
-	resp, err := l.RequestSession.Get(fmt.Sprintf("%s/%s", liveInfoUrl, l.roomID), live.CommonUserAgent)
+	resp, err := l.RequestSession().Get(fmt.Sprintf("%s/%s", liveInfoUrl, l.roomID), live.CommonUserAgent)
 	if err != nil {
 		return nil, err
 	}
@@ -220,12 +220,19 @@ func (l *Live) GetInfo() (info *live.Info, err error) {
 		RoomName:     gjson.GetBytes(body, "room.room_name").String(),
 		Status:       gjson.GetBytes(body, "room.show_status").Int() == 1 && gjson.GetBytes(body, "room.videoLoop").Int() == 0,
 		CustomLiveId: "douyu/" + l.roomID,
+		Category:     gjson.GetBytes(body, "room.second_lvl_name").String(),
+		CoverUrl:     gjson.GetBytes(body, "room.room_pic").String(),
+		AvatarUrl:    gjson.GetBytes(body, "room.avatar.big").String(),
+		Online:       gjson.GetBytes(body, "room.room_biz_all.hot").Int(),
+	}
+	if showTime := gjson.GetBytes(body, "room.show_time").Int(); showTime > 0 && info.Status {
+		info.LiveStartTime = time.Unix(showTime, 0)
 	}
 	return info, nil
 }
 
 func (l *Live) getSignParams() (map[string]string, error) {
-	resp, err := l.RequestSession.Get(liveEncUrl, live.CommonUserAgent, requests.Query("rids", l.roomID))
+	resp, err := l.RequestSession().Get(liveEncUrl, live.CommonUserAgent, requests.Query("rids", l.roomID))
 	if err != nil {
 		return nil, err
 	}
@@ -315,7 +322,7 @@ func (l *Live) GetStreamUrls() (us []*url.URL, err error) {
 	if err != nil {
 		return nil, err
 	}
-	resp, err := l.RequestSession.Post(
+	resp, err := l.RequestSession().Post(
 		fmt.Sprintf("%s/%s", liveAPIUrl, l.roomID),
 		requests.Form(params),
 		requests.Header("origin", "https://www.douyu.com"),
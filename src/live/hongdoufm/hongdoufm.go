@@ -86,7 +86,7 @@ func (l *Live) getRoomInfo() ([]byte, error) {
 		}
 	}
 
-	resp, err := l.RequestSession.Get(roomInitUrl + l.roomID)
+	resp, err := l.RequestSession().Get(roomInitUrl + l.roomID)
 	if err != nil {
 		return nil, err
 	}
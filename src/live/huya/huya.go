@@ -49,7 +49,7 @@ var GetStreamInfosMethodList = []GetStreamInfosMethod{
 }
 
 func (l *Live) GetHtmlBody() (htmlBody string, err error) {
-	html, err := l.RequestSession.Get(l.Url.String(), live.CommonUserAgent)
+	html, err := l.RequestSession().Get(l.Url.String(), live.CommonUserAgent)
 	if err != nil {
 		return
 	}
@@ -41,16 +41,23 @@ func GetInfo_ForLol(l *Live, body string) (info *live.Info, err error) {
 	}
 
 	info = &live.Info{
-		Live:     l,
-		HostName: hostName,
-		RoomName: roomName,
-		Status:   status == "true",
+		Live:      l,
+		HostName:  hostName,
+		RoomName:  roomName,
+		Status:    status == "true",
+		Category:  strFilter.Do(utils.Match1(`"gameFullName":"([^"]*)"`, body)),
+		CoverUrl:  strFilter.Do(utils.Match1(`"screenshot":"([^"]*)"`, body)),
+		AvatarUrl: strFilter.Do(utils.Match1(`"avatar180":"([^"]*)"`, body)),
+	}
+	info.Online, _ = strconv.ParseInt(utils.Match1(`"totalCount":(\d+)`, body), 10, 64)
+	if startTime, _ := strconv.ParseInt(utils.Match1(`"startTime":(\d+)`, body), 10, 64); startTime > 0 && info.Status {
+		info.LiveStartTime = time.Unix(startTime, 0)
 	}
 	return info, nil
 }
 
 func GetStreamInfos_ForLol(l *Live) (infos []*live.StreamUrlInfo, err error) {
-	resp, err := l.RequestSession.Get(l.Url.String(), requests.UserAgent(uaForLol))
+	resp, err := l.RequestSession().Get(l.Url.String(), requests.UserAgent(uaForLol))
 	if err != nil {
 		return nil, err
 	}
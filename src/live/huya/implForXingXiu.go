@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/bililive-go/bililive-go/src/live"
 	"github.com/bililive-go/bililive-go/src/pkg/utils"
@@ -40,10 +41,17 @@ func GetInfo_ForXingXiu(l *Live, body string) (info *live.Info, err error) {
 	}
 
 	info = &live.Info{
-		Live:     l,
-		HostName: hostName,
-		RoomName: roomName,
-		Status:   status == "ON",
+		Live:      l,
+		HostName:  hostName,
+		RoomName:  roomName,
+		Status:    status == "ON",
+		Category:  res.Get("data.liveData.gameFullName").String(),
+		CoverUrl:  res.Get("data.liveData.screenshot").String(),
+		AvatarUrl: res.Get("data.liveData.avatar180").String(),
+		Online:    res.Get("data.liveData.totalCount").Int(),
+	}
+	if startTime := res.Get("data.liveData.startTime").Int(); startTime > 0 && info.Status {
+		info.LiveStartTime = time.Unix(startTime, 0)
 	}
 	return info, nil
 }
@@ -108,7 +116,7 @@ func (l *Live) getJsonFromBody(htmlBody string) (result *gjson.Result, err error
 	headers["xweb_xhr"] = "1"
 	headers["referer"] = "https://servicewechat.com/wx74767bf0b684f7d3/301/page-frame.html"
 	headers["accept-language"] = "zh-CN,zh;q=0.9"
-	resp, err := l.RequestSession.Get("https://mp.huya.com/cache.php", requests.Headers(headers), requests.Queries(params), requests.UserAgent(uaForXingXiu))
+	resp, err := l.RequestSession().Get("https://mp.huya.com/cache.php", requests.Headers(headers), requests.Queries(params), requests.UserAgent(uaForXingXiu))
 	if err != nil {
 		return nil, err
 	}
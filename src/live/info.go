@@ -2,6 +2,7 @@ package live
 
 import (
 	"encoding/json"
+	"time"
 
 	"github.com/bililive-go/bililive-go/src/types"
 )
@@ -14,6 +15,16 @@ type Info struct {
 	Initializing         bool
 	CustomLiveId         string
 	AudioOnly            bool
+	// RejectReason 直播中但不满足录制规则（record_filter）的原因
+	RejectReason string
+
+	// 以下为平台接口返回的可选信息，平台不支持时为零值
+	Category  string // 分区/分类
+	CoverUrl  string // 直播间封面
+	AvatarUrl string // 主播头像
+	Online    int64  // 在线人数（部分平台为人气值）
+	// LiveStartTime 平台记录的本场直播开始时间
+	LiveStartTime time.Time
 }
 
 type InfoCookie struct {
@@ -32,11 +43,18 @@ func (i *Info) MarshalJSON() ([]byte, error) {
 		Status            bool         `json:"status"`
 		Listening         bool         `json:"listening"`
 		Recording         bool         `json:"recording"`
+		RejectReason      string       `json:"reject_reason,omitempty"`
 		Initializing      bool         `json:"initializing"`
 		LastStartTime     string       `json:"last_start_time,omitempty"`
 		LastStartTimeUnix int64        `json:"last_start_time_unix,omitempty"`
 		AudioOnly         bool         `json:"audio_only"`
 		NickName          string       `json:"nick_name"`
+		Category          string       `json:"category,omitempty"`
+		CoverUrl          string       `json:"cover_url,omitempty"`
+		AvatarUrl         string       `json:"avatar_url,omitempty"`
+		Online            int64        `json:"online,omitempty"`
+		LiveStartTime     string       `json:"live_start_time,omitempty"`
+		LiveStartTimeUnix int64        `json:"live_start_time_unix,omitempty"`
 	}{
 		Id:             i.Live.GetLiveId(),
 		LiveUrl:        i.Live.GetRawUrl(),
@@ -46,13 +64,22 @@ func (i *Info) MarshalJSON() ([]byte, error) {
 		Status:         i.Status,
 		Listening:      i.Listening,
 		Recording:      i.Recording,
+		RejectReason:   i.RejectReason,
 		Initializing:   i.Initializing,
 		AudioOnly:      i.AudioOnly,
 		NickName:       i.Live.GetOptions().NickName,
+		Category:       i.Category,
+		CoverUrl:       i.CoverUrl,
+		AvatarUrl:      i.AvatarUrl,
+		Online:         i.Online,
 	}
 	if !i.Live.GetLastStartTime().IsZero() {
 		t.LastStartTime = i.Live.GetLastStartTime().Format("2006-01-02 15:04:05")
 		t.LastStartTimeUnix = i.Live.GetLastStartTime().Unix()
 	}
+	if !i.LiveStartTime.IsZero() {
+		t.LiveStartTime = i.LiveStartTime.Format("2006-01-02 15:04:05")
+		t.LiveStartTimeUnix = i.LiveStartTime.Unix()
+	}
 	return json.Marshal(t)
 }
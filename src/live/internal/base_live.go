@@ -3,23 +3,35 @@ package internal
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"net/url"
+	"sync"
 	"time"
 
 	"github.com/bililive-go/bililive-go/src/configs"
 	"github.com/bililive-go/bililive-go/src/instance"
 	"github.com/bililive-go/bililive-go/src/live"
+	"github.com/bililive-go/bililive-go/src/pkg/ratelimit"
 	"github.com/bililive-go/bililive-go/src/pkg/utils"
 	"github.com/bililive-go/bililive-go/src/types"
 	"github.com/hr3lxphr6j/requests"
 )
 
 type BaseLive struct {
-	Url            *url.URL
-	LastStartTime  time.Time
-	LiveId         types.LiveID
-	Options        *live.Options
-	RequestSession *requests.Session
+	Url           *url.URL
+	LastStartTime time.Time
+	LiveId        types.LiveID
+	// state 重新加载配置时会被替换，与轮询并发访问；复制 BaseLive 时共享
+	state *baseLiveState
+}
+
+// baseLiveState 直播间的选项与访问平台 api 使用的 session
+type baseLiveState struct {
+	lock    sync.RWMutex
+	options *live.Options
+	session *requests.Session
+	// proxy 当前 session 使用的代理，与 configs.Config.GetProxy 的返回值相同
+	proxy string
 }
 
 func genLiveId(url *url.URL) types.LiveID {
@@ -31,33 +43,63 @@ func genLiveIdByString(value string) types.LiveID {
 }
 
 func NewBaseLive(url *url.URL) BaseLive {
-	requestSession := requests.DefaultSession
+	requestSession, _ := newRequestSession("")
+	return BaseLive{
+		Url:    url,
+		LiveId: genLiveId(url),
+		state:  &baseLiveState{options: live.MustNewOptions(), session: requestSession},
+	}
+}
+
+// defaultSession 直连时所有直播间共用的 session
+var defaultSession = requests.NewSession(&http.Client{Transport: ratelimit.NewTransport(nil)})
+
+// newRequestSession 创建通过 proxy 访问平台 api 的 session，proxy 为空时使用环境变量中的代理，为 configs.ProxyDirect 时直连。
+// 所有 session 的请求都经过 ratelimit.Default，按域名共享请求频率限制
+func newRequestSession(proxy string) (*requests.Session, error) {
 	config := configs.GetCurrentConfig()
 	if config != nil && config.Debug {
 		client, _ := utils.CreateConnCounterClient()
-		requestSession = requests.NewSession(client)
+		if err := utils.SetProxy(client.Transport.(*http.Transport), proxy); err != nil {
+			return nil, err
+		}
+		client.Transport = ratelimit.NewTransport(client.Transport)
+		return requests.NewSession(client), nil
 	}
-	return BaseLive{
-		Url:            url,
-		LiveId:         genLiveId(url),
-		RequestSession: requestSession,
+	if proxy == "" {
+		return defaultSession, nil
 	}
+	client, err := utils.NewProxyClient(proxy)
+	if err != nil {
+		return nil, err
+	}
+	client.Transport = ratelimit.NewTransport(client.Transport)
+	return requests.NewSession(client), nil
 }
 
 func (a *BaseLive) UpdateLiveOptionsbyConfig(ctx context.Context, room *configs.LiveRoom) (err error) {
-	inst := instance.GetInstance(ctx)
+	cfg := instance.GetInstance(ctx).GetConfig()
 	url, err := url.Parse(room.Url)
 	if err != nil {
 		return
 	}
+	session, proxy := a.RequestSession(), cfg.GetProxy(room)
+	if proxy != a.GetProxy() {
+		if session, err = newRequestSession(proxy); err != nil {
+			return err
+		}
+	}
 	opts := make([]live.Option, 0)
-	if v, ok := inst.Config.Cookies[url.Host]; ok {
+	if v, ok := cfg.Cookies[url.Host]; ok {
 		opts = append(opts, live.WithKVStringCookies(url, v))
 	}
 	opts = append(opts, live.WithQuality(room.Quality))
 	opts = append(opts, live.WithAudioOnly(room.AudioOnly))
 	opts = append(opts, live.WithNickName(room.NickName))
-	a.Options = live.MustNewOptions(opts...)
+	options := live.MustNewOptions(opts...)
+	a.state.lock.Lock()
+	a.state.options, a.state.session, a.state.proxy = options, session, proxy
+	a.state.lock.Unlock()
 	return
 }
 
@@ -81,8 +123,31 @@ func (a *BaseLive) SetLastStartTime(time time.Time) {
 	a.LastStartTime = time
 }
 
+// GetProxy 返回访问平台 api 使用的代理地址，与 configs.Config.GetProxy 的返回值相同
+func (a *BaseLive) GetProxy() string {
+	a.state.lock.RLock()
+	defer a.state.lock.RUnlock()
+	return a.state.proxy
+}
+
+// RequestSession 返回访问平台 api 使用的 session，代理变化后会被替换
+func (a *BaseLive) RequestSession() *requests.Session {
+	a.state.lock.RLock()
+	defer a.state.lock.RUnlock()
+	return a.state.session
+}
+
+// SetRequestSession 替换访问平台 api 使用的 session
+func (a *BaseLive) SetRequestSession(session *requests.Session) {
+	a.state.lock.Lock()
+	defer a.state.lock.Unlock()
+	a.state.session = session
+}
+
 func (a *BaseLive) GetOptions() *live.Options {
-	return a.Options
+	a.state.lock.RLock()
+	defer a.state.lock.RUnlock()
+	return a.state.options
 }
 
 // TODO: remove this method
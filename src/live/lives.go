@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/bililive-go/bililive-go/src/configs"
+	"github.com/bililive-go/bililive-go/src/pkg/danmaku"
 	"github.com/bililive-go/bililive-go/src/types"
 	"github.com/bluele/gcache"
 )
@@ -131,6 +132,59 @@ type Live interface {
 	GetOptions() *Options
 }
 
+// DanmakuProvider 支持录制弹幕的直播间实现此接口
+type DanmakuProvider interface {
+	NewDanmakuClient() (danmaku.Client, error)
+}
+
+// GetDanmakuProvider 获取直播间的弹幕客户端提供者，不支持弹幕时返回 false
+func GetDanmakuProvider(live Live) (DanmakuProvider, bool) {
+	if w, ok := live.(*WrappedLive); ok {
+		live = w.Live
+	}
+	provider, ok := live.(DanmakuProvider)
+	return provider, ok
+}
+
+// BatchInfoProvider 可以一次请求获取多个直播间信息的平台实现此接口
+type BatchInfoProvider interface {
+	// BatchGetInfo 获取 lives 的信息，lives 均为同一平台的直播间，结果按 LiveID 索引；
+	// 结果中缺少的直播间由调用者单独调用 GetInfo
+	BatchGetInfo(lives []Live) (map[types.LiveID]*Info, error)
+}
+
+// GetBatchInfoProvider 获取直播间所属平台的批量查询实现，平台不支持时返回 false
+func GetBatchInfoProvider(live Live) (BatchInfoProvider, bool) {
+	if w, ok := live.(*WrappedLive); ok {
+		live = w.Live
+	}
+	provider, ok := live.(BatchInfoProvider)
+	return provider, ok
+}
+
+// BatchGetInfo 通过 provider 获取 lives 的信息，lives 可以是 WrappedLive，获取成功的直播间会更新缓存
+func BatchGetInfo(provider BatchInfoProvider, lives []Live) (map[types.LiveID]*Info, error) {
+	unwrapped := make([]Live, len(lives))
+	for i, l := range lives {
+		if w, ok := l.(*WrappedLive); ok {
+			l = w.Live
+		}
+		unwrapped[i] = l
+	}
+	infos, err := provider.BatchGetInfo(unwrapped)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range lives {
+		if w, ok := l.(*WrappedLive); ok && w.cache != nil {
+			if info, ok := infos[l.GetLiveId()]; ok {
+				w.cache.Set(w, info)
+			}
+		}
+	}
+	return infos, nil
+}
+
 type WrappedLive struct {
 	Live
 	cache gcache.Cache
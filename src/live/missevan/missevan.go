@@ -50,7 +50,7 @@ func (l *Live) getRoomInfo() ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	resp, err := l.RequestSession.Get(roomInitUrl + roomid)
+	resp, err := l.RequestSession().Get(roomInitUrl + roomid)
 	if err != nil {
 		return nil, err
 	}
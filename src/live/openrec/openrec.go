@@ -32,7 +32,7 @@ func (b *builder) Build(url *url.URL) (live.Live, error) {
 }
 
 func (l *Live) GetInfo() (info *live.Info, err error) {
-	resp, err := l.RequestSession.Get(l.Url.String(), live.CommonUserAgent)
+	resp, err := l.RequestSession().Get(l.Url.String(), live.CommonUserAgent)
 	if err != nil {
 		return nil, err
 	}
@@ -69,7 +69,7 @@ func (l *Live) GetInfo() (info *live.Info, err error) {
 }
 
 func (l *Live) GetStreamUrls() (us []*url.URL, err error) {
-	resp, err := l.RequestSession.Get(l.Url.String(), live.CommonUserAgent)
+	resp, err := l.RequestSession().Get(l.Url.String(), live.CommonUserAgent)
 	if err != nil {
 		return nil, err
 	}
@@ -40,7 +40,7 @@ type Live struct {
 }
 
 func (l *Live) GetInfo() (info *live.Info, err error) {
-	cookies := l.Options.Cookies.Cookies(l.Url)
+	cookies := l.GetOptions().Cookies.Cookies(l.Url)
 	cookieKVs := make(map[string]string)
 	for _, item := range cookies {
 		cookieKVs[item.Name] = item.Value
@@ -56,7 +56,7 @@ func (l *Live) GetInfo() (info *live.Info, err error) {
 		"Referer":         "https://www.xiaohongshu.com/hina/livestream/" + roomId,
 	}
 
-	resp, err := l.RequestSession.Get(
+	resp, err := l.RequestSession().Get(
 		roomApiUrl,
 		requests.Query("room_id", roomId),
 		requests.Cookies(cookieKVs),
@@ -78,7 +78,7 @@ func (l *Live) GetInfo() (info *live.Info, err error) {
 	}
 
 	homeUrl := fmt.Sprintf("%s/%s", roomUrl, roomId)
-	response, err := l.RequestSession.Get(homeUrl,
+	response, err := l.RequestSession().Get(homeUrl,
 		requests.Cookies(cookieKVs),
 		requests.Headers(headers),
 	)
@@ -38,7 +38,7 @@ type Live struct {
 }
 
 func (l *Live) requestRoomInfo() ([]byte, error) {
-	resp, err := l.RequestSession.Get(fmt.Sprintf(apiUrl, strings.Split(l.Url.Path, "/")[1]), live.CommonUserAgent)
+	resp, err := l.RequestSession().Get(fmt.Sprintf(apiUrl, strings.Split(l.Url.Path, "/")[1]), live.CommonUserAgent)
 	if err != nil {
 		return nil, err
 	}
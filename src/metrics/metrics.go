@@ -13,6 +13,7 @@ import (
 	"github.com/bililive-go/bililive-go/src/interfaces"
 	"github.com/bililive-go/bililive-go/src/listeners"
 	"github.com/bililive-go/bililive-go/src/live"
+	"github.com/bililive-go/bililive-go/src/pkg/ratelimit"
 	"github.com/bililive-go/bililive-go/src/recorders"
 	"github.com/bililive-go/bililive-go/src/types"
 )
@@ -30,12 +31,42 @@ var (
 		[]string{"live_id", "live_url", "live_host_name", "live_room_name", "start_time"},
 		nil,
 	)
+	liveOnline = prometheus.NewDesc(
+		prometheus.BuildFQName("bgo", "live", "online"),
+		"live online viewers",
+		[]string{"live_id", "live_url", "live_host_name", "live_room_name", "live_category"},
+		nil,
+	)
 	recorderTotalBytes = prometheus.NewDesc(
 		prometheus.BuildFQName("bgo", "recorder", "total_bytes"),
 		"recorder total bytes",
 		[]string{"live_id", "live_url", "live_host_name", "live_room_name"},
 		nil,
 	)
+	rateLimitQPS = prometheus.NewDesc(
+		prometheus.BuildFQName("bgo", "ratelimit", "qps"),
+		"max requests per second to the domain, 0 means unlimited",
+		[]string{"domain"},
+		nil,
+	)
+	rateLimitThrottled = prometheus.NewDesc(
+		prometheus.BuildFQName("bgo", "ratelimit", "throttled"),
+		"whether requests to the domain are paused after being throttled by the platform",
+		[]string{"domain"},
+		nil,
+	)
+	rateLimitBackoffSeconds = prometheus.NewDesc(
+		prometheus.BuildFQName("bgo", "ratelimit", "backoff_seconds"),
+		"duration of the latest backoff of the domain",
+		[]string{"domain"},
+		nil,
+	)
+	rateLimitThrottledTotal = prometheus.NewDesc(
+		prometheus.BuildFQName("bgo", "ratelimit", "throttled_total"),
+		"times that the domain is throttled by the platform",
+		[]string{"domain"},
+		nil,
+	)
 )
 
 type collector struct {
@@ -57,7 +88,8 @@ func bool2float64(b bool) float64 {
 
 func (c collector) Collect(ch chan<- prometheus.Metric) {
 	wg := sync.WaitGroup{}
-	for id, l := range c.inst.Lives {
+	for _, l := range c.inst.GetLives() {
+		id := l.GetLiveId()
 		wg.Add(1)
 		go func(id types.LiveID, l live.Live) {
 			defer wg.Done()
@@ -72,6 +104,13 @@ func (c collector) Collect(ch chan<- prometheus.Metric) {
 				string(id), l.GetRawUrl(), info.HostName, info.RoomName, fmt.Sprintf("%v", listening),
 			)
 
+			if info.Status && info.Online > 0 {
+				ch <- prometheus.MustNewConstMetric(
+					liveOnline, prometheus.GaugeValue, float64(info.Online),
+					string(id), l.GetRawUrl(), info.HostName, info.RoomName, info.Category,
+				)
+			}
+
 			if info.Status && listening {
 				ch <- prometheus.MustNewConstMetric(
 					liveDurationSeconds, prometheus.CounterValue, time.Since(l.GetLastStartTime()).Seconds(),
@@ -90,12 +129,24 @@ func (c collector) Collect(ch chan<- prometheus.Metric) {
 		}(id, l)
 	}
 	wg.Wait()
+
+	for _, s := range ratelimit.Default.Status() {
+		ch <- prometheus.MustNewConstMetric(rateLimitQPS, prometheus.GaugeValue, s.QPS, s.Domain)
+		ch <- prometheus.MustNewConstMetric(rateLimitThrottled, prometheus.GaugeValue, bool2float64(s.Throttled), s.Domain)
+		ch <- prometheus.MustNewConstMetric(rateLimitBackoffSeconds, prometheus.GaugeValue, s.Backoff.Seconds(), s.Domain)
+		ch <- prometheus.MustNewConstMetric(rateLimitThrottledTotal, prometheus.CounterValue, float64(s.ThrottledTotal), s.Domain)
+	}
 }
 
 func (collector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- liveStatus
 	ch <- liveDurationSeconds
+	ch <- liveOnline
 	ch <- recorderTotalBytes
+	ch <- rateLimitQPS
+	ch <- rateLimitThrottled
+	ch <- rateLimitBackoffSeconds
+	ch <- rateLimitThrottledTotal
 }
 
 func (c *collector) Start(_ context.Context) error {
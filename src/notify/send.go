@@ -3,10 +3,12 @@ package notify
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/bililive-go/bililive-go/src/configs"
 	"github.com/bililive-go/bililive-go/src/consts"
 	"github.com/bililive-go/bililive-go/src/instance"
+	"github.com/bililive-go/bililive-go/src/live"
 	"github.com/bililive-go/bililive-go/src/notify/email"
 	"github.com/bililive-go/bililive-go/src/notify/ntfy"
 	"github.com/bililive-go/bililive-go/src/notify/telegram"
@@ -16,6 +18,33 @@ import (
 // 检测用户是否开启了telegram和email通知服务，然后分别发送通知
 // 参数: ctx(context上下文), hostName(主播姓名), platform(直播平台), liveURL(直播地址), status(直播状态: consts.LiveStatusStart/consts.LiveStatusStop)
 func SendNotification(ctx context.Context, hostName, platform, liveURL, status string) error {
+	return sendNotification(ctx, hostName, platform, liveURL, status, "")
+}
+
+// SendLiveNotification 发送直播状态通知，并附带平台提供的直播间标题、分区、在线人数与封面
+func SendLiveNotification(ctx context.Context, info *live.Info, status string) error {
+	return sendNotification(ctx, info.HostName, info.Live.GetPlatformCNName(), info.Live.GetRawUrl(), status, liveDetail(info))
+}
+
+// liveDetail 返回附加在通知内容之后的直播间信息，每项一行，平台未提供的项省略
+func liveDetail(info *live.Info) string {
+	var b strings.Builder
+	if info.RoomName != "" {
+		fmt.Fprintf(&b, "\n直播间：%s", info.RoomName)
+	}
+	if info.Category != "" {
+		fmt.Fprintf(&b, "\n分区：%s", info.Category)
+	}
+	if info.Online > 0 {
+		fmt.Fprintf(&b, "\n在线人数：%d", info.Online)
+	}
+	if info.CoverUrl != "" {
+		fmt.Fprintf(&b, "\n封面：%s", info.CoverUrl)
+	}
+	return b.String()
+}
+
+func sendNotification(ctx context.Context, hostName, platform, liveURL, status, detail string) error {
 	// 获取当前配置
 	cfg := configs.GetCurrentConfig()
 	if cfg == nil {
@@ -43,7 +72,7 @@ func SendNotification(ctx context.Context, hostName, platform, liveURL, status s
 	hostInfo := fmt.Sprintf("%s,%s", hostName, messageStatus)
 
 	// 构造Telegram消息内容 (包含所有信息)
-	telegramMessage := fmt.Sprintf("主播：%s\n平台：%s\n直播地址：%s", hostInfo, platform, liveURL)
+	telegramMessage := fmt.Sprintf("主播：%s\n平台：%s\n直播地址：%s%s", hostInfo, platform, liveURL, detail)
 
 	// 检查是否开启了Telegram通知服务
 	if cfg.Notify.Telegram.Enable {
@@ -68,7 +97,7 @@ func SendNotification(ctx context.Context, hostName, platform, liveURL, status s
 
 	// 构造邮件主题和内容
 	emailSubject := fmt.Sprintf("%s - %s", hostInfo, platform)
-	emailBody := fmt.Sprintf("主播：%s\n平台：%s\n直播地址：%s", hostInfo, platform, liveURL)
+	emailBody := fmt.Sprintf("主播：%s\n平台：%s\n直播地址：%s%s", hostInfo, platform, liveURL, detail)
 
 	// 检查是否开启了Email通知服务
 	if cfg.Notify.Email.Enable {
@@ -85,7 +114,7 @@ func SendNotification(ctx context.Context, hostName, platform, liveURL, status s
 	}
 
 	// 构造ntfy消息内容
-	ntfyMessage := fmt.Sprintf("平台：%s,%s", platform, messageStatus)
+	ntfyMessage := fmt.Sprintf("平台：%s,%s%s", platform, messageStatus, detail)
 
 	// 检查是否开启了Ntfy通知服务
 	if cfg.Notify.Ntfy.Enable {
@@ -4,7 +4,10 @@ import (
 	"context"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
+
 	"github.com/bililive-go/bililive-go/src/consts"
+	"github.com/bililive-go/bililive-go/src/live"
 )
 
 // TestSendTestNotification 测试SendTestNotification函数
@@ -79,3 +82,13 @@ func TestSendNotificationUnknown(t *testing.T) {
 
 	// 如果没有panic，则测试通过
 }
+
+func TestLiveDetail(t *testing.T) {
+	assert.Equal(t, "", liveDetail(&live.Info{}))
+	assert.Equal(t, "\n直播间：标题\n分区：虚拟主播\n在线人数：100\n封面：https://example.com/cover.jpg", liveDetail(&live.Info{
+		RoomName: "标题",
+		Category: "虚拟主播",
+		Online:   100,
+		CoverUrl: "https://example.com/cover.jpg",
+	}))
+}
@@ -0,0 +1,344 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/bluele/gcache"
+	"github.com/sirupsen/logrus"
+
+	"github.com/bililive-go/bililive-go/src/configs"
+	"github.com/bililive-go/bililive-go/src/instance"
+	"github.com/bililive-go/bililive-go/src/listeners"
+	"github.com/bililive-go/bililive-go/src/live"
+	"github.com/bililive-go/bililive-go/src/pkg/events"
+	"github.com/bililive-go/bililive-go/src/pkg/utils"
+	"github.com/bililive-go/bililive-go/src/recorders"
+)
+
+const (
+	defaultMaxRetries = 3
+	defaultTimeout    = 10 * time.Second
+	maxDeliveryLogs   = 200
+)
+
+// for test
+var retryInterval = time.Second
+
+// Events 支持推送的事件
+var Events = []events.EventType{
+	listeners.LiveStart,
+	listeners.LiveEnd,
+	listeners.RoomNameChanged,
+	listeners.RecordFilterRejected,
+	listeners.RecordFilterAccepted,
+	recorders.RecorderStart,
+	recorders.RecorderStop,
+	recorders.PostProcessFinished,
+}
+
+// Payload 推送的事件内容，也是 body 模板的数据
+type Payload struct {
+	Event         string    `json:"event"`
+	Time          time.Time `json:"time"`
+	LiveID        string    `json:"live_id"`
+	Platform      string    `json:"platform"`
+	LiveUrl       string    `json:"live_url"`
+	HostName      string    `json:"host_name"`
+	RoomName      string    `json:"room_name"`
+	Category      string    `json:"category,omitempty"`
+	CoverUrl      string    `json:"cover_url,omitempty"`
+	AvatarUrl     string    `json:"avatar_url,omitempty"`
+	Online        int64     `json:"online,omitempty"`
+	LiveStartTime time.Time `json:"live_start_time,omitzero"`
+	File          string    `json:"file,omitempty"`
+	OutputFiles   []string  `json:"output_files,omitempty"`
+	Error         string    `json:"error,omitempty"`
+}
+
+// Delivery 一次推送的结果
+type Delivery struct {
+	ID         uint64    `json:"id"`
+	Endpoint   string    `json:"endpoint"`
+	Event      string    `json:"event"`
+	Time       time.Time `json:"time"`
+	Attempts   int       `json:"attempts"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Success    bool      `json:"success"`
+	Error      string    `json:"error,omitempty"`
+}
+
+type endpoint struct {
+	configs.WebhookEndpoint
+	tmpl       *template.Template
+	events     map[events.EventType]bool
+	maxRetries int
+}
+
+func (e *endpoint) accept(typ events.EventType) bool {
+	return len(e.events) == 0 || e.events[typ]
+}
+
+// Notifier 订阅事件并推送到配置的回调地址
+type Notifier struct {
+	hc        *http.Client
+	cache     gcache.Cache
+	ed        events.Dispatcher
+	logger    *logrus.Entry
+	endpoints []*endpoint
+	listeners map[events.EventType]*events.EventListener
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	lock       sync.RWMutex
+	deliveries []*Delivery
+	lastID     uint64
+	// closed Close 之后不再开始新的推送，与 wg.Add 一起在 lock 中读写
+	closed bool
+}
+
+func NewNotifier(ctx context.Context) *Notifier {
+	inst := instance.GetInstance(ctx)
+	n := &Notifier{
+		hc:        new(http.Client),
+		cache:     inst.Cache,
+		listeners: make(map[events.EventType]*events.EventListener),
+	}
+	inst.WebhookNotifier = n
+	return n
+}
+
+// GetNotifier 获取当前实例中的 webhook 推送模块，未启用时返回 nil
+func GetNotifier(ctx context.Context) *Notifier {
+	inst := instance.GetInstance(ctx)
+	if inst == nil || inst.WebhookNotifier == nil {
+		return nil
+	}
+	n, _ := inst.WebhookNotifier.(*Notifier)
+	return n
+}
+
+func (n *Notifier) Start(ctx context.Context) error {
+	inst := instance.GetInstance(ctx)
+	cfg := inst.GetConfig().Notify.Webhook
+	n.logger = inst.Logger.WithField("module", "webhook")
+	if !cfg.Enable {
+		return nil
+	}
+	for _, c := range cfg.Endpoints {
+		e := &endpoint{WebhookEndpoint: c, events: make(map[events.EventType]bool)}
+		if e.Name == "" {
+			e.Name = e.URL
+		}
+		if e.Method == "" {
+			e.Method = http.MethodPost
+		}
+		e.Method = strings.ToUpper(e.Method)
+		e.maxRetries = defaultMaxRetries
+		if e.MaxRetries != nil {
+			e.maxRetries = *e.MaxRetries
+		}
+		if e.Timeout <= 0 {
+			e.Timeout = defaultTimeout
+		}
+		for _, typ := range e.Events {
+			e.events[events.EventType(typ)] = true
+		}
+		if e.Body != "" {
+			tmpl, err := template.New(e.Name).Funcs(utils.GetFuncMap(inst.GetConfig())).Parse(e.Body)
+			if err != nil {
+				return fmt.Errorf("failed to parse body template of webhook %s: %w", e.Name, err)
+			}
+			e.tmpl = tmpl
+		}
+		n.endpoints = append(n.endpoints, e)
+	}
+
+	n.ctx, n.cancel = context.WithCancel(ctx)
+	n.ed = inst.EventDispatcher.(events.Dispatcher)
+	for _, typ := range Events {
+		listener := events.NewEventListener(n.handleEvent)
+		n.listeners[typ] = listener
+		n.ed.AddEventListener(typ, listener)
+	}
+	return nil
+}
+
+// Close 取消订阅，并等待进行中的推送结束
+func (n *Notifier) Close(ctx context.Context) {
+	n.lock.Lock()
+	n.closed = true
+	n.lock.Unlock()
+	if n.ed != nil {
+		for typ, listener := range n.listeners {
+			n.ed.RemoveEventListener(typ, listener)
+		}
+	}
+	if n.cancel != nil {
+		n.cancel()
+	}
+	n.wg.Wait()
+}
+
+// Deliveries 返回最近的推送记录，最新的在前
+func (n *Notifier) Deliveries() []*Delivery {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	ret := make([]*Delivery, 0, len(n.deliveries))
+	for i := len(n.deliveries) - 1; i >= 0; i-- {
+		d := *n.deliveries[i]
+		ret = append(ret, &d)
+	}
+	return ret
+}
+
+func (n *Notifier) newPayload(event *events.Event) *Payload {
+	payload := &Payload{Event: string(event.Type), Time: time.Now()}
+	var l live.Live
+	switch obj := event.Object.(type) {
+	case live.Live:
+		l = obj
+	case *recorders.PostProcessResult:
+		l = obj.Live
+		payload.File = obj.File
+		payload.OutputFiles = obj.OutputFiles
+		if obj.Err != nil {
+			payload.Error = obj.Err.Error()
+		}
+	default:
+		return payload
+	}
+	payload.LiveID = string(l.GetLiveId())
+	payload.Platform = l.GetPlatformCNName()
+	payload.LiveUrl = l.GetRawUrl()
+	if n.cache != nil {
+		if obj, err := n.cache.Get(l); err == nil {
+			info := obj.(*live.Info)
+			payload.HostName = info.HostName
+			payload.RoomName = info.RoomName
+			payload.Category = info.Category
+			payload.CoverUrl = info.CoverUrl
+			payload.AvatarUrl = info.AvatarUrl
+			payload.Online = info.Online
+			payload.LiveStartTime = info.LiveStartTime
+		}
+	}
+	return payload
+}
+
+func (n *Notifier) handleEvent(event *events.Event) {
+	targets := make([]*endpoint, 0, len(n.endpoints))
+	for _, e := range n.endpoints {
+		if e.accept(event.Type) {
+			targets = append(targets, e)
+		}
+	}
+	if len(targets) == 0 {
+		return
+	}
+	payload := n.newPayload(event)
+
+	// 事件在分发协程中处理，可能与 Close 同时进行
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	if n.closed {
+		return
+	}
+	for _, e := range targets {
+		n.wg.Add(1)
+		go func(e *endpoint) {
+			defer n.wg.Done()
+			n.deliver(e, payload)
+		}(e)
+	}
+}
+
+func (e *endpoint) renderBody(payload *Payload) ([]byte, error) {
+	if e.tmpl == nil {
+		return json.Marshal(payload)
+	}
+	buf := new(bytes.Buffer)
+	if err := e.tmpl.Execute(buf, payload); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// deliver 推送一个事件，失败时按指数退避重试
+func (n *Notifier) deliver(e *endpoint, payload *Payload) {
+	d := &Delivery{Endpoint: e.Name, Event: payload.Event, Time: payload.Time}
+	defer n.addDelivery(d)
+
+	body, err := e.renderBody(payload)
+	if err != nil {
+		d.Error = err.Error()
+		n.logger.WithError(err).Errorf("failed to render body of webhook %s", e.Name)
+		return
+	}
+	interval := retryInterval
+	for {
+		d.Attempts++
+		d.StatusCode, err = n.send(e, body)
+		if err == nil {
+			d.Success, d.Error = true, ""
+			return
+		}
+		d.Error = err.Error()
+		if d.Attempts > e.maxRetries {
+			n.logger.WithError(err).Warnf("failed to deliver %s to webhook %s", payload.Event, e.Name)
+			return
+		}
+		select {
+		case <-n.ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+		interval *= 2
+	}
+}
+
+func (n *Notifier) send(e *endpoint, body []byte) (int, error) {
+	ctx, cancel := context.WithTimeout(n.ctx, e.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, e.Method, e.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	if e.tmpl == nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range e.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := n.hc.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+func (n *Notifier) addDelivery(d *Delivery) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.lastID++
+	d.ID = n.lastID
+	n.deliveries = append(n.deliveries, d)
+	if len(n.deliveries) > maxDeliveryLogs {
+		n.deliveries = n.deliveries[len(n.deliveries)-maxDeliveryLogs:]
+	}
+}
@@ -0,0 +1,193 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bluele/gcache"
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+	gomock "go.uber.org/mock/gomock"
+
+	"github.com/bililive-go/bililive-go/src/configs"
+	"github.com/bililive-go/bililive-go/src/instance"
+	"github.com/bililive-go/bililive-go/src/listeners"
+	livepkg "github.com/bililive-go/bililive-go/src/live"
+	livemock "github.com/bililive-go/bililive-go/src/live/mock"
+	"github.com/bililive-go/bililive-go/src/log"
+	"github.com/bililive-go/bililive-go/src/pkg/events"
+	"github.com/bililive-go/bililive-go/src/recorders"
+	"github.com/bililive-go/bililive-go/src/types"
+)
+
+type request struct {
+	method string
+	header http.Header
+	body   string
+}
+
+// recordServer 记录收到的请求，前 failCount 次请求返回 500
+type recordServer struct {
+	lock      sync.Mutex
+	failCount int
+	requests  []request
+}
+
+func (s *recordServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.requests = append(s.requests, request{method: r.Method, header: r.Header, body: string(body)})
+	if s.failCount > 0 {
+		s.failCount--
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (s *recordServer) getRequests() []request {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]request(nil), s.requests...)
+}
+
+func TestNotifier(t *testing.T) {
+	backup := retryInterval
+	retryInterval = 10 * time.Millisecond
+	defer func() { retryInterval = backup }()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	jsonServer := &recordServer{failCount: 1}
+	js := httptest.NewServer(jsonServer)
+	defer js.Close()
+	tmplServer := new(recordServer)
+	ts := httptest.NewServer(tmplServer)
+	defer ts.Close()
+	failServer := &recordServer{failCount: 100}
+	fs := httptest.NewServer(failServer)
+	defer fs.Close()
+	noRetryServer := &recordServer{failCount: 100}
+	nrs := httptest.NewServer(noRetryServer)
+	defer nrs.Close()
+	maxRetries, noRetry := 2, 0
+
+	cfg := configs.NewConfig()
+	cfg.Notify.Webhook = configs.Webhook{
+		Enable: true,
+		Endpoints: []configs.WebhookEndpoint{
+			{Name: "json", URL: js.URL},
+			{
+				Name:    "template",
+				URL:     ts.URL,
+				Method:  "put",
+				Headers: map[string]string{"Authorization": "Bearer token"},
+				Body:    `{{ .HostName }} {{ .Event }}{{ range .OutputFiles }} {{ . | base }}{{ end }}`,
+				Events:  []string{string(recorders.PostProcessFinished)},
+			},
+			{Name: "fail", URL: fs.URL, MaxRetries: &maxRetries, Events: []string{string(listeners.LiveStart)}},
+			{Name: "no-retry", URL: nrs.URL, MaxRetries: &noRetry, Events: []string{string(listeners.LiveStart)}},
+		},
+	}
+	cache := gcache.New(4).LRU().Build()
+	inst := &instance.Instance{Config: cfg, Cache: cache}
+	ctx := context.WithValue(context.Background(), instance.Key, inst)
+	log.New(ctx)
+	ed := events.NewDispatcher(ctx)
+
+	live := livemock.NewMockLive(ctrl)
+	live.EXPECT().GetLiveId().Return(types.LiveID("id")).AnyTimes()
+	live.EXPECT().GetPlatformCNName().Return("哔哩哔哩").AnyTimes()
+	live.EXPECT().GetRawUrl().Return("https://live.bilibili.com/1").AnyTimes()
+	cache.Set(live, &livepkg.Info{HostName: "host", RoomName: "room", Category: "虚拟主播", Online: 100})
+
+	n := NewNotifier(ctx)
+	assert.Equal(t, n, GetNotifier(ctx))
+	assert.NoError(t, n.Start(ctx))
+
+	ed.DispatchEvent(events.NewEvent(listeners.LiveStart, live))
+	ed.DispatchEvent(events.NewEvent(recorders.PostProcessFinished, &recorders.PostProcessResult{
+		Live:        live,
+		File:        "/a/b.flv",
+		OutputFiles: []string{"/a/b.mp4"},
+		Err:         errors.New("failed"),
+	}))
+	assert.Eventually(t, func() bool { return len(n.Deliveries()) == 5 }, 3*time.Second, 10*time.Millisecond)
+	n.Close(ctx)
+
+	// 第一次返回 500，重试后成功
+	reqs := jsonServer.getRequests()
+	assert.Len(t, reqs, 3)
+	assert.Equal(t, http.MethodPost, reqs[0].method)
+	assert.Equal(t, "application/json", reqs[0].header.Get("Content-Type"))
+	for _, req := range reqs {
+		switch gjson.Get(req.body, "event").String() {
+		case string(listeners.LiveStart):
+			assert.Equal(t, "host", gjson.Get(req.body, "host_name").String())
+			assert.Equal(t, "id", gjson.Get(req.body, "live_id").String())
+			assert.Equal(t, "虚拟主播", gjson.Get(req.body, "category").String())
+			assert.Equal(t, int64(100), gjson.Get(req.body, "online").Int())
+			assert.False(t, gjson.Get(req.body, "live_start_time").Exists())
+		case string(recorders.PostProcessFinished):
+			assert.Equal(t, "/a/b.flv", gjson.Get(req.body, "file").String())
+			assert.Equal(t, "failed", gjson.Get(req.body, "error").String())
+		default:
+			t.Errorf("unexpected request: %s", req.body)
+		}
+	}
+
+	reqs = tmplServer.getRequests()
+	assert.Len(t, reqs, 1)
+	assert.Equal(t, http.MethodPut, reqs[0].method)
+	assert.Equal(t, "Bearer token", reqs[0].header.Get("Authorization"))
+	assert.Equal(t, "host PostProcessFinished b.mp4", reqs[0].body)
+
+	assert.Len(t, failServer.getRequests(), 3)
+	// max_retries 为 0 时不重试
+	assert.Len(t, noRetryServer.getRequests(), 1)
+
+	deliveries := n.Deliveries()
+	byEndpoint := make(map[string][]*Delivery)
+	for _, d := range deliveries {
+		byEndpoint[d.Endpoint] = append(byEndpoint[d.Endpoint], d)
+	}
+	assert.Len(t, byEndpoint["json"], 2)
+	assert.Equal(t, 3, byEndpoint["json"][0].Attempts+byEndpoint["json"][1].Attempts)
+	assert.True(t, byEndpoint["template"][0].Success)
+	assert.False(t, byEndpoint["fail"][0].Success)
+	assert.Equal(t, 3, byEndpoint["fail"][0].Attempts)
+	assert.Equal(t, http.StatusInternalServerError, byEndpoint["fail"][0].StatusCode)
+	assert.Greater(t, deliveries[0].ID, deliveries[len(deliveries)-1].ID)
+
+	// Close 之后到达的事件不再推送
+	n.handleEvent(events.NewEvent(listeners.LiveStart, live))
+	n.wg.Wait()
+	assert.Len(t, n.Deliveries(), 5)
+	assert.Len(t, noRetryServer.getRequests(), 1)
+}
+
+func TestEvents(t *testing.T) {
+	names := make([]string, 0, len(Events))
+	for _, typ := range Events {
+		names = append(names, string(typ))
+	}
+	assert.Equal(t, configs.WebhookEvents, names)
+}
+
+func TestNotifierWithInvalidTemplate(t *testing.T) {
+	cfg := configs.NewConfig()
+	cfg.Notify.Webhook = configs.Webhook{
+		Enable:    true,
+		Endpoints: []configs.WebhookEndpoint{{URL: "http://127.0.0.1", Body: "{{ .HostName "}},
+	}
+	ctx := context.WithValue(context.Background(), instance.Key, &instance.Instance{Config: cfg})
+	log.New(ctx)
+	events.NewDispatcher(ctx)
+	assert.Error(t, NewNotifier(ctx).Start(ctx))
+}
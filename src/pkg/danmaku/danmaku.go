@@ -0,0 +1,49 @@
+package danmaku
+
+import (
+	"context"
+	"time"
+)
+
+// MessageType 弹幕消息类型
+type MessageType string
+
+const (
+	TypeComment   MessageType = "comment"    // 普通弹幕
+	TypeGift      MessageType = "gift"       // 礼物
+	TypeSuperChat MessageType = "super_chat" // 醒目留言
+	TypeGuard     MessageType = "guard"      // 上舰
+)
+
+// Message 平台无关的弹幕消息
+type Message struct {
+	Type MessageType `json:"type"`
+	// Time 收到消息的本地时间，用于与视频对齐
+	Time     time.Time `json:"time"`
+	UserID   int64     `json:"uid"`
+	UserName string    `json:"user"`
+	Content  string    `json:"content,omitempty"`
+
+	// 普通弹幕的显示属性
+	Mode     int `json:"mode,omitempty"`
+	FontSize int `json:"font_size,omitempty"`
+	Color    int `json:"color,omitempty"`
+
+	// 礼物、上舰
+	GiftName   string `json:"gift_name,omitempty"`
+	GiftCount  int    `json:"gift_count,omitempty"`
+	GuardLevel int    `json:"guard_level,omitempty"`
+
+	// 醒目留言的金额（元）与持续时间（秒）
+	Price    float64 `json:"price,omitempty"`
+	Duration int     `json:"duration,omitempty"`
+}
+
+// Handler 处理收到的弹幕消息，可能在任意协程中被调用
+type Handler func(msg *Message)
+
+// Client 弹幕服务器客户端
+type Client interface {
+	// Run 连接弹幕服务器并持续接收消息，断线后自动重连，直到 ctx 结束
+	Run(ctx context.Context, handler Handler) error
+}
@@ -0,0 +1,195 @@
+package danmaku
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 弹幕文件格式
+const (
+	FormatXML   = "xml"
+	FormatJSONL = "jsonl"
+)
+
+// Meta 弹幕文件对应的录制信息
+type Meta struct {
+	RoomID   string
+	HostName string
+	RoomName string
+	// StartTime 视频文件开始录制的时间，弹幕的时间偏移以此为基准
+	StartTime time.Time
+}
+
+// Writer 将弹幕按时间偏移写入文件
+type Writer interface {
+	Write(msg *Message) error
+	Close() error
+}
+
+// IsValidFormat 判断弹幕文件格式是否受支持
+func IsValidFormat(format string) bool {
+	return format == FormatXML || format == FormatJSONL
+}
+
+// FileName 根据视频文件名生成同名的弹幕文件名
+func FileName(videoFile, format string) string {
+	return strings.TrimSuffix(videoFile, filepath.Ext(videoFile)) + "." + format
+}
+
+// NewWriter 创建弹幕文件，文件已存在时会被覆盖
+func NewWriter(format, file string, meta Meta) (Writer, error) {
+	if !IsValidFormat(format) {
+		return nil, fmt.Errorf("unsupported danmaku format: %s", format)
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		return nil, err
+	}
+	w := &writer{
+		file: f,
+		buf:  bufio.NewWriter(f),
+		meta: meta,
+	}
+	if format == FormatXML {
+		w.encoder = new(xmlEncoder)
+	} else {
+		w.encoder = new(jsonlEncoder)
+	}
+	if err := w.encoder.header(w.buf, meta); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := w.buf.Flush(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+type encoder interface {
+	header(w *bufio.Writer, meta Meta) error
+	message(w *bufio.Writer, offset float64, msg *Message) error
+	footer(w *bufio.Writer) error
+}
+
+type writer struct {
+	lock    sync.Mutex
+	file    *os.File
+	buf     *bufio.Writer
+	meta    Meta
+	encoder encoder
+	closed  bool
+}
+
+func (w *writer) Write(msg *Message) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.closed {
+		return os.ErrClosed
+	}
+	offset := msg.Time.Sub(w.meta.StartTime).Seconds()
+	if offset < 0 {
+		offset = 0
+	}
+	if err := w.encoder.message(w.buf, offset, msg); err != nil {
+		return err
+	}
+	// 每条消息都落盘，避免程序异常退出时丢失弹幕
+	return w.buf.Flush()
+}
+
+func (w *writer) Close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	err := w.encoder.footer(w.buf)
+	if flushErr := w.buf.Flush(); err == nil {
+		err = flushErr
+	}
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// xmlEncoder 输出与 BililiveRecorder 兼容的 XML 弹幕文件
+type xmlEncoder struct{}
+
+func escape(s string) string {
+	buf := new(bytes.Buffer)
+	xml.EscapeText(buf, []byte(s))
+	return buf.String()
+}
+
+func (e *xmlEncoder) header(w *bufio.Writer, meta Meta) error {
+	_, err := fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?>
+<i>
+<chatserver>chat.bilibili.com</chatserver><chatid>0</chatid><mission>0</mission><maxlimit>1000</maxlimit><state>0</state><real_name>0</real_name><source>0</source>
+<BililiveRecorder version="bililive-go" />
+<BililiveRecorderRecordInfo roomid="%s" name="%s" title="%s" start_time="%s" />
+`, escape(meta.RoomID), escape(meta.HostName), escape(meta.RoomName), meta.StartTime.Format(time.RFC3339Nano))
+	return err
+}
+
+func (e *xmlEncoder) message(w *bufio.Writer, offset float64, msg *Message) (err error) {
+	user := escape(msg.UserName)
+	switch msg.Type {
+	case TypeComment:
+		_, err = fmt.Fprintf(w, "<d p=\"%.3f,%d,%d,%d,%d,0,%d,0\" user=\"%s\">%s</d>\n",
+			offset, msg.Mode, msg.FontSize, msg.Color, msg.Time.UnixMilli(), msg.UserID, user, escape(msg.Content))
+	case TypeGift:
+		_, err = fmt.Fprintf(w, "<gift ts=\"%.3f\" user=\"%s\" uid=\"%d\" giftname=\"%s\" giftcount=\"%d\" />\n",
+			offset, user, msg.UserID, escape(msg.GiftName), msg.GiftCount)
+	case TypeSuperChat:
+		_, err = fmt.Fprintf(w, "<sc ts=\"%.3f\" user=\"%s\" uid=\"%d\" price=\"%g\" time=\"%d\">%s</sc>\n",
+			offset, user, msg.UserID, msg.Price, msg.Duration, escape(msg.Content))
+	case TypeGuard:
+		_, err = fmt.Fprintf(w, "<guard ts=\"%.3f\" user=\"%s\" uid=\"%d\" level=\"%d\" count=\"%d\" />\n",
+			offset, user, msg.UserID, msg.GuardLevel, msg.GiftCount)
+	}
+	return
+}
+
+func (e *xmlEncoder) footer(w *bufio.Writer) error {
+	_, err := w.WriteString("</i>\n")
+	return err
+}
+
+// jsonlEncoder 每行输出一条 JSON 格式的消息，offset 为相对视频开始的秒数
+type jsonlEncoder struct{}
+
+func (e *jsonlEncoder) header(w *bufio.Writer, meta Meta) error {
+	return nil
+}
+
+func (e *jsonlEncoder) message(w *bufio.Writer, offset float64, msg *Message) error {
+	b, err := json.Marshal(struct {
+		Offset float64 `json:"offset"`
+		*Message
+	}{
+		Offset:  float64(int64(offset*1000)) / 1000,
+		Message: msg,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(b); err != nil {
+		return err
+	}
+	return w.WriteByte('\n')
+}
+
+func (e *jsonlEncoder) footer(w *bufio.Writer) error {
+	return nil
+}
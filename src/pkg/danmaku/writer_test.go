@@ -0,0 +1,114 @@
+package danmaku
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testStartTime = time.Date(2024, 1, 1, 20, 0, 0, 0, time.Local)
+
+func testMessages() []*Message {
+	return []*Message{
+		{Type: TypeComment, Time: testStartTime.Add(-time.Second), UserID: 1, UserName: "a", Content: "早", Mode: 1, FontSize: 25, Color: 16777215},
+		{Type: TypeComment, Time: testStartTime.Add(1500 * time.Millisecond), UserID: 2, UserName: "<b>", Content: "1 < 2 & \"3\"", Mode: 1, FontSize: 25, Color: 255},
+		{Type: TypeGift, Time: testStartTime.Add(2 * time.Second), UserID: 3, UserName: "c", GiftName: "辣条", GiftCount: 10},
+		{Type: TypeSuperChat, Time: testStartTime.Add(3 * time.Second), UserID: 4, UserName: "d", Content: "sc", Price: 30, Duration: 60},
+		{Type: TypeGuard, Time: testStartTime.Add(4 * time.Second), UserID: 5, UserName: "e", GuardLevel: 3, GiftCount: 1},
+	}
+}
+
+func TestFileName(t *testing.T) {
+	assert.Equal(t, "/a/b/c.xml", FileName("/a/b/c.flv", FormatXML))
+	assert.Equal(t, "/a/b.c/d.jsonl", FileName("/a/b.c/d.ts", FormatJSONL))
+}
+
+func TestXMLWriter(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "out.xml")
+	w, err := NewWriter(FormatXML, file, Meta{RoomID: "1", HostName: "host", RoomName: "title & more", StartTime: testStartTime})
+	assert.NoError(t, err)
+	for _, msg := range testMessages() {
+		assert.NoError(t, w.Write(msg))
+	}
+	assert.NoError(t, w.Close())
+	assert.NoError(t, w.Close())
+	assert.Error(t, w.Write(testMessages()[0]))
+
+	b, err := os.ReadFile(file)
+	assert.NoError(t, err)
+	var doc struct {
+		Info struct {
+			Title string `xml:"title,attr"`
+		} `xml:"BililiveRecorderRecordInfo"`
+		Comments []struct {
+			P    string `xml:"p,attr"`
+			User string `xml:"user,attr"`
+			Text string `xml:",chardata"`
+		} `xml:"d"`
+		Gifts []struct {
+			Ts    string `xml:"ts,attr"`
+			Name  string `xml:"giftname,attr"`
+			Count int    `xml:"giftcount,attr"`
+		} `xml:"gift"`
+		SuperChats []struct {
+			Price string `xml:"price,attr"`
+			Text  string `xml:",chardata"`
+		} `xml:"sc"`
+		Guards []struct {
+			Level int `xml:"level,attr"`
+		} `xml:"guard"`
+	}
+	assert.NoError(t, xml.Unmarshal(b, &doc))
+	assert.Equal(t, "title & more", doc.Info.Title)
+	assert.Len(t, doc.Comments, 2)
+	assert.True(t, strings.HasPrefix(doc.Comments[0].P, "0.000,1,25,16777215,"))
+	assert.True(t, strings.HasPrefix(doc.Comments[1].P, "1.500,1,25,255,"))
+	assert.Equal(t, "<b>", doc.Comments[1].User)
+	assert.Equal(t, "1 < 2 & \"3\"", doc.Comments[1].Text)
+	assert.Len(t, doc.Gifts, 1)
+	assert.Equal(t, "2.000", doc.Gifts[0].Ts)
+	assert.Equal(t, "辣条", doc.Gifts[0].Name)
+	assert.Equal(t, 10, doc.Gifts[0].Count)
+	assert.Len(t, doc.SuperChats, 1)
+	assert.Equal(t, "30", doc.SuperChats[0].Price)
+	assert.Len(t, doc.Guards, 1)
+	assert.Equal(t, 3, doc.Guards[0].Level)
+}
+
+func TestJSONLWriter(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "out.jsonl")
+	w, err := NewWriter(FormatJSONL, file, Meta{StartTime: testStartTime})
+	assert.NoError(t, err)
+	for _, msg := range testMessages() {
+		assert.NoError(t, w.Write(msg))
+	}
+	assert.NoError(t, w.Close())
+
+	f, err := os.Open(file)
+	assert.NoError(t, err)
+	defer f.Close()
+	var lines []map[string]any
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := make(map[string]any)
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+		lines = append(lines, line)
+	}
+	assert.Len(t, lines, 5)
+	assert.Equal(t, 0.0, lines[0]["offset"])
+	assert.Equal(t, 1.5, lines[1]["offset"])
+	assert.Equal(t, string(TypeGift), lines[2]["type"])
+	assert.Equal(t, "辣条", lines[2]["gift_name"])
+}
+
+func TestNewWriterWithInvalidFormat(t *testing.T) {
+	_, err := NewWriter("srt", filepath.Join(t.TempDir(), "out.srt"), Meta{})
+	assert.Error(t, err)
+}
@@ -12,6 +12,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/bililive-go/bililive-go/src/configs"
 	"github.com/bililive-go/bililive-go/src/instance"
 	"github.com/bililive-go/bililive-go/src/live"
 	"github.com/bililive-go/bililive-go/src/pkg/parser"
@@ -21,9 +22,15 @@ import (
 const (
 	Name = "ffmpeg"
 
+	// stopTimeout 发送退出命令后等待 ffmpeg 退出的时长，超时后强制结束，例如卡在读取直播流时
+	stopTimeout = 10 * time.Second
+
 	userAgent = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_12_6) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/59.0.3071.115 Safari/537.36"
 )
 
+// statusTimeout 获取状态的最长等待时间，例如 ffmpeg 尚未启动或没有输出进度时；for test
+var statusTimeout = 5 * time.Second
+
 func init() {
 	parser.Register(Name, new(builder))
 }
@@ -35,12 +42,26 @@ func (b *builder) Build(cfg map[string]string) (parser.Parser, error) {
 	if debugFlag, ok := cfg["debug"]; ok && debugFlag != "" {
 		debug = true
 	}
+	maxFileSize, _ := strconv.Atoi(cfg["max_file_size"])
+	if proxy := cfg["proxy"]; proxy != "" && proxy != configs.ProxyDirect {
+		u, err := configs.ParseProxyUrl(proxy)
+		if err != nil {
+			return nil, err
+		}
+		// ffmpeg 的 -http_proxy 只支持 http 代理
+		if u.Scheme != "http" {
+			return nil, fmt.Errorf("ffmpeg does not support %s proxy, enable use_native_flv_parser or use_native_hls_parser instead", u.Scheme)
+		}
+	}
 	return &Parser{
 		debug:       debug,
 		closeOnce:   new(sync.Once),
+		exited:      make(chan struct{}),
 		statusReq:   make(chan struct{}, 1),
 		statusResp:  make(chan map[string]string, 1),
 		timeoutInUs: cfg["timeout_in_us"],
+		maxFileSize: maxFileSize,
+		proxy:       cfg["proxy"],
 	}, nil
 }
 
@@ -49,11 +70,15 @@ type Parser struct {
 	cmdStdIn    io.WriteCloser
 	cmdStdout   io.ReadCloser
 	closeOnce   *sync.Once
+	exited      chan struct{}
 	debug       bool
 	timeoutInUs string
+	maxFileSize int
+	proxy       string
 
 	statusReq  chan struct{}
 	statusResp chan map[string]string
+	statusLock sync.Mutex
 	cmdLock    sync.Mutex
 }
 
@@ -119,10 +144,28 @@ func (p *Parser) scheduler() {
 	}
 }
 
+// Status 获取 ffmpeg 输出的进度，超过 statusTimeout 没有结果时返回错误，调用者应视为没有进度
 func (p *Parser) Status() (map[string]string, error) {
-	// TODO: check parser is running
-	p.statusReq <- struct{}{}
-	return <-p.statusResp, nil
+	p.statusLock.Lock()
+	defer p.statusLock.Unlock()
+	// 丢弃上一次超时后才到达的结果
+	select {
+	case <-p.statusResp:
+	default:
+	}
+	select {
+	case p.statusReq <- struct{}{}:
+	default:
+		// ffmpeg 已经退出，上一次的请求没有被处理，statusResp 已关闭
+	}
+	select {
+	case status := <-p.statusResp:
+		return status, nil
+	case <-p.exited:
+		return nil, fmt.Errorf("ffmpeg has exited")
+	case <-time.After(statusTimeout):
+		return nil, fmt.Errorf("timeout waiting for ffmpeg status")
+	}
 }
 
 func (p *Parser) ParseLiveStream(ctx context.Context, streamUrlInfo *live.StreamUrlInfo, live live.Live, file string) (err error) {
@@ -155,6 +198,11 @@ func (p *Parser) ParseLiveStream(ctx context.Context, streamUrlInfo *live.Stream
 		"-user_agent", ffUserAgent,
 		"-referer", referer,
 		"-rw_timeout", p.timeoutInUs,
+	)
+	if p.proxy != "" && p.proxy != configs.ProxyDirect {
+		args = append(args, "-http_proxy", p.proxy)
+	}
+	args = append(args,
 		"-i", url.String(),
 		"-c", "copy",
 		// No need for `.ts` output: will cause audio error
@@ -170,12 +218,10 @@ func (p *Parser) ParseLiveStream(ctx context.Context, streamUrlInfo *live.Stream
 		args = append(args, "-headers", k+": "+v)
 	}
 
-	inst := instance.GetInstance(ctx)
-	MaxFileSize := inst.Config.VideoSplitStrategies.MaxFileSize
-	if MaxFileSize < 0 {
-		inst.Logger.Infof("Invalid MaxFileSize: %d", MaxFileSize)
-	} else if MaxFileSize > 0 {
-		args = append(args, "-fs", strconv.Itoa(MaxFileSize))
+	if p.maxFileSize < 0 {
+		instance.GetInstance(ctx).Logger.Infof("Invalid MaxFileSize: %d", p.maxFileSize)
+	} else if p.maxFileSize > 0 {
+		args = append(args, "-fs", strconv.Itoa(p.maxFileSize))
 	}
 
 	args = append(args, file)
@@ -185,6 +231,10 @@ func (p *Parser) ParseLiveStream(ctx context.Context, streamUrlInfo *live.Stream
 		p.cmdLock.Lock()
 		defer p.cmdLock.Unlock()
 		p.cmd = exec.Command(ffmpegPath, args...)
+		if p.proxy == configs.ProxyDirect {
+			// ffmpeg 会使用环境变量 http_proxy 中的代理，直连时通过 no_proxy 跳过
+			p.cmd.Env = append(os.Environ(), "no_proxy=*")
+		}
 		if p.cmdStdIn, err = p.cmd.StdinPipe(); err != nil {
 			return
 		}
@@ -207,6 +257,7 @@ func (p *Parser) ParseLiveStream(ctx context.Context, streamUrlInfo *live.Stream
 
 	go p.scheduler()
 	err = p.cmd.Wait()
+	close(p.exited)
 	if err != nil {
 		return err
 	}
@@ -222,6 +273,13 @@ func (p *Parser) Stop() (err error) {
 				if _, err = p.cmdStdIn.Write([]byte("q")); err != nil {
 					err = fmt.Errorf("error sending stop command to ffmpeg: %v", err)
 				}
+				go func(process *os.Process) {
+					select {
+					case <-p.exited:
+					case <-time.After(stopTimeout):
+						process.Kill()
+					}
+				}(p.cmd.Process)
 			} else if p.cmdStdIn == nil {
 				err = fmt.Errorf("p.cmdStdIn == nil")
 			} else if p.cmd.Process == nil {
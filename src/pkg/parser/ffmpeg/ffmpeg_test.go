@@ -0,0 +1,21 @@
+package ffmpeg
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatusTimeout(t *testing.T) {
+	backup := statusTimeout
+	statusTimeout = 10 * time.Millisecond
+	defer func() { statusTimeout = backup }()
+
+	p, err := new(builder).Build(map[string]string{})
+	assert.NoError(t, err)
+	// ffmpeg 尚未启动时不会一直等待
+	status, err := p.(*Parser).Status()
+	assert.Error(t, err)
+	assert.Nil(t, status)
+}
@@ -0,0 +1,391 @@
+package hls
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/bililive-go/bililive-go/src/instance"
+	"github.com/bililive-go/bililive-go/src/live"
+	"github.com/bililive-go/bililive-go/src/pkg/parser"
+	"github.com/bililive-go/bililive-go/src/pkg/utils"
+)
+
+const (
+	Name = "hls"
+
+	userAgent = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_12_6) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/59.0.3071.115 Safari/537.36"
+
+	defaultConcurrency    = 3
+	defaultTimeout        = time.Minute
+	defaultTargetDuration = 2 * time.Second
+	minPollInterval       = 100 * time.Millisecond
+	maxVariantDepth       = 3
+	segmentRetryCount     = 3
+	pendingSegmentCount   = 64
+)
+
+var (
+	ErrEncryptedStream = errors.New("encrypted hls stream is not supported")
+	ErrStreamStalled   = errors.New("no new hls segment")
+	ErrNoMediaPlaylist = errors.New("no media playlist found")
+)
+
+func init() {
+	parser.Register(Name, new(builder))
+}
+
+type builder struct{}
+
+func (b *builder) Build(cfg map[string]string) (parser.Parser, error) {
+	timeout := defaultTimeout
+	if us, err := strconv.Atoi(cfg["timeout_in_us"]); err == nil && us > 0 {
+		timeout = time.Duration(us) * time.Microsecond
+	}
+	concurrency := defaultConcurrency
+	if n, err := strconv.Atoi(cfg["hls_concurrency"]); err == nil && n > 0 {
+		concurrency = n
+	}
+	hc, err := utils.NewProxyClient(cfg["proxy"])
+	if err != nil {
+		return nil, err
+	}
+	hc.Timeout = timeout
+	return &Parser{
+		hc:        hc,
+		timeout:   timeout,
+		sem:       make(chan struct{}, concurrency),
+		stopCh:    make(chan struct{}),
+		closeOnce: new(sync.Once),
+	}, nil
+}
+
+type Parser struct {
+	hc *http.Client
+	// timeout 超过该时长没有新分片则认为直播流已中断
+	timeout time.Duration
+	// sem 限制同时下载的分片数量
+	sem     chan struct{}
+	headers map[string]string
+
+	stopCh    chan struct{}
+	closeOnce *sync.Once
+
+	playlistUrl        atomic.Value
+	totalSize          atomic.Int64
+	segmentCount       atomic.Int64
+	skippedCount       atomic.Int64
+	discontinuityCount atomic.Int64
+	lastSequence       atomic.Uint64
+}
+
+// segmentJob 一个待下载的分片，按入队顺序写入文件
+type segmentJob struct {
+	seg     *segment
+	mapData []byte
+	data    []byte
+	err     error
+	done    chan struct{}
+}
+
+func (p *Parser) ParseLiveStream(ctx context.Context, streamUrlInfo *live.StreamUrlInfo, live live.Live, file string) error {
+	p.headers = streamUrlInfo.HeadersForDownloader
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-p.stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	mediaUrl, pl, err := p.resolveMediaPlaylist(ctx, streamUrlInfo.Url)
+	if err != nil {
+		if p.isStopped() {
+			return nil
+		}
+		return err
+	}
+
+	f, err := os.OpenFile(file, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	jobs := make(chan *segmentJob, pendingSegmentCount)
+	writeErrCh := make(chan error, 1)
+	go func() {
+		err := p.writeSegments(ctx, f, jobs)
+		if err != nil {
+			cancel()
+		}
+		writeErrCh <- err
+	}()
+
+	err = p.poll(ctx, mediaUrl, pl, jobs)
+	close(jobs)
+	if writeErr := <-writeErrCh; writeErr != nil {
+		err = writeErr
+	}
+	if p.isStopped() {
+		return nil
+	}
+	return err
+}
+
+func (p *Parser) isStopped() bool {
+	select {
+	case <-p.stopCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// resolveMediaPlaylist 跟随多码率播放列表，直到拿到包含分片的媒体播放列表
+func (p *Parser) resolveMediaPlaylist(ctx context.Context, u *url.URL) (*url.URL, *playlist, error) {
+	for i := 0; i < maxVariantDepth; i++ {
+		pl, err := p.fetchPlaylist(ctx, u)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !pl.isMaster() {
+			return u, pl, nil
+		}
+		v := pl.bestVariant()
+		p.getLogger(ctx).Debugf("hls: follow variant %s, bandwidth: %d, resolution: %s", v.uri, v.bandwidth, v.resolution)
+		u = v.uri
+	}
+	return nil, nil, ErrNoMediaPlaylist
+}
+
+func (p *Parser) poll(ctx context.Context, mediaUrl *url.URL, pl *playlist, jobs chan<- *segmentJob) error {
+	p.playlistUrl.Store(mediaUrl.String())
+	var (
+		hasLast      bool
+		lastSeq      uint64
+		lastNewTime  = time.Now()
+		currentMap   string
+		pendingReset bool
+		newCount     int
+	)
+	for {
+		if pl != nil {
+			if pl.encrypted {
+				return ErrEncryptedStream
+			}
+			segments := pl.segments
+			if hasLast && len(segments) > 0 {
+				newLast := segments[len(segments)-1].sequence
+				// 媒体序号大幅回退，说明源站重置了播放列表
+				if newLast < lastSeq && lastSeq-newLast >= uint64(len(segments)) {
+					p.getLogger(ctx).Infof("hls: media sequence reset from %d to %d", lastSeq, newLast)
+					hasLast = false
+					pendingReset = true
+				}
+			}
+			newCount = 0
+			for _, seg := range segments {
+				if hasLast && seg.sequence <= lastSeq {
+					continue
+				}
+				if pendingReset {
+					seg.discontinuity = true
+					pendingReset = false
+				}
+				job := &segmentJob{seg: seg, done: make(chan struct{})}
+				if seg.mapUri != nil && seg.mapUri.String() != currentMap {
+					data, err := p.fetchWithRetry(ctx, seg.mapUri)
+					if err != nil {
+						return err
+					}
+					currentMap, job.mapData = seg.mapUri.String(), data
+				}
+				select {
+				case jobs <- job:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+				go p.download(ctx, job)
+				hasLast, lastSeq = true, seg.sequence
+				newCount++
+			}
+			if newCount > 0 {
+				lastNewTime = time.Now()
+			}
+			if pl.endList {
+				return nil
+			}
+		}
+		if time.Since(lastNewTime) > p.timeout {
+			return ErrStreamStalled
+		}
+
+		interval := defaultTargetDuration
+		if pl != nil && pl.targetDuration > 0 {
+			interval = time.Duration(pl.targetDuration * float64(time.Second))
+		}
+		if newCount == 0 {
+			// 列表未更新时缩短轮询间隔
+			interval /= 2
+		}
+		if interval < minPollInterval {
+			interval = minPollInterval
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+
+		var err error
+		if pl, err = p.fetchPlaylist(ctx, mediaUrl); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			p.getLogger(ctx).WithError(err).Debug("hls: failed to refresh playlist")
+			pl = nil
+		}
+	}
+}
+
+// download 并发下载分片，由 writeSegments 按顺序等待结果
+func (p *Parser) download(ctx context.Context, job *segmentJob) {
+	defer close(job.done)
+	select {
+	case p.sem <- struct{}{}:
+		defer func() { <-p.sem }()
+	case <-ctx.Done():
+		job.err = ctx.Err()
+		return
+	}
+	job.data, job.err = p.fetchWithRetry(ctx, job.seg.uri)
+}
+
+func (p *Parser) writeSegments(ctx context.Context, w io.Writer, jobs <-chan *segmentJob) error {
+	for job := range jobs {
+		select {
+		case <-job.done:
+		case <-ctx.Done():
+			return nil
+		}
+		if job.err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			p.skippedCount.Add(1)
+			p.getLogger(ctx).WithError(job.err).Warnf("hls: skip segment %d", job.seg.sequence)
+			continue
+		}
+		if job.seg.discontinuity {
+			p.discontinuityCount.Add(1)
+			p.getLogger(ctx).Debugf("hls: discontinuity at segment %d", job.seg.sequence)
+		}
+		if len(job.mapData) > 0 {
+			if _, err := w.Write(job.mapData); err != nil {
+				return err
+			}
+			p.totalSize.Add(int64(len(job.mapData)))
+		}
+		if _, err := w.Write(job.data); err != nil {
+			return err
+		}
+		p.totalSize.Add(int64(len(job.data)))
+		p.segmentCount.Add(1)
+		p.lastSequence.Store(job.seg.sequence)
+	}
+	return nil
+}
+
+func (p *Parser) newRequest(ctx context.Context, u *url.URL) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	for k, v := range p.headers {
+		req.Header.Set(k, v)
+	}
+	return req, nil
+}
+
+func (p *Parser) fetch(ctx context.Context, u *url.URL) ([]byte, error) {
+	req, err := p.newRequest(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, u)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (p *Parser) fetchWithRetry(ctx context.Context, u *url.URL) (data []byte, err error) {
+	for i := 0; i < segmentRetryCount; i++ {
+		if data, err = p.fetch(ctx, u); err == nil || ctx.Err() != nil {
+			return
+		}
+	}
+	return
+}
+
+func (p *Parser) fetchPlaylist(ctx context.Context, u *url.URL) (*playlist, error) {
+	req, err := p.newRequest(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, u)
+	}
+	// 以重定向后的地址作为相对路径的基准
+	return parsePlaylist(resp.Body, resp.Request.URL)
+}
+
+func (p *Parser) Status() (map[string]string, error) {
+	playlistUrl, _ := p.playlistUrl.Load().(string)
+	return map[string]string{
+		"parser":              Name,
+		"playlist_url":        playlistUrl,
+		"total_size":          strconv.FormatInt(p.totalSize.Load(), 10),
+		"segment_count":       strconv.FormatInt(p.segmentCount.Load(), 10),
+		"skipped_segments":    strconv.FormatInt(p.skippedCount.Load(), 10),
+		"discontinuity_count": strconv.FormatInt(p.discontinuityCount.Load(), 10),
+		"media_sequence":      strconv.FormatUint(p.lastSequence.Load(), 10),
+	}, nil
+}
+
+func (p *Parser) Stop() error {
+	p.closeOnce.Do(func() {
+		close(p.stopCh)
+	})
+	return nil
+}
+
+func (p *Parser) getLogger(ctx context.Context) *logrus.Entry {
+	if inst := instance.GetInstance(ctx); inst != nil && inst.Logger != nil {
+		return inst.Logger.WithField("parser", Name)
+	}
+	return logrus.WithField("parser", Name)
+}
@@ -0,0 +1,227 @@
+package hls
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bililive-go/bililive-go/src/live"
+)
+
+// liveServer 模拟一个滑动窗口的直播 HLS 源，每次请求播放列表前进一个分片
+type liveServer struct {
+	sync.Mutex
+	window        int
+	total         int
+	head          int
+	discontinuity int
+	// resetAt 大于 0 时，在输出到该分片后把媒体序号重置为 0
+	resetAt   int
+	resetDone bool
+	endless   bool
+}
+
+func (s *liveServer) playlist() string {
+	s.Lock()
+	defer s.Unlock()
+	if s.head < s.total || s.endless {
+		s.head++
+	}
+	first := s.head - s.window
+	if first < 0 {
+		first = 0
+	}
+	seqOffset := 0
+	if s.resetAt > 0 && s.head > s.resetAt {
+		// 重置后序号从 0 重新开始
+		seqOffset = s.resetAt
+		if first < s.resetAt {
+			first = s.resetAt
+		}
+	}
+	b := new(strings.Builder)
+	fmt.Fprintf(b, "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:0.1\n#EXT-X-MEDIA-SEQUENCE:%d\n", first-seqOffset)
+	for i := first; i < s.head; i++ {
+		if i == s.discontinuity && i > 0 {
+			b.WriteString("#EXT-X-DISCONTINUITY\n")
+		}
+		fmt.Fprintf(b, "#EXTINF:0.100,\nseg/%d.ts\n", i)
+	}
+	if s.head >= s.total && !s.endless {
+		b.WriteString("#EXT-X-ENDLIST\n")
+	}
+	return b.String()
+}
+
+func (s *liveServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/master.m3u8", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "#EXTM3U\n"+
+			"#EXT-X-STREAM-INF:BANDWIDTH=100000,RESOLUTION=640x360,CODECS=\"avc1.4d401e,mp4a.40.2\"\nlow.m3u8\n"+
+			"#EXT-X-STREAM-INF:BANDWIDTH=2000000,RESOLUTION=1920x1080\nhigh/live.m3u8\n")
+	})
+	mux.HandleFunc("/low.m3u8", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "should not use the low variant", http.StatusForbidden)
+	})
+	mux.HandleFunc("/high/live.m3u8", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Referer") != "https://example.com/room" {
+			http.Error(w, "missing referer", http.StatusForbidden)
+			return
+		}
+		fmt.Fprint(w, s.playlist())
+	})
+	mux.HandleFunc("/high/seg/", func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimSuffix(filepath.Base(r.URL.Path), ".ts")
+		fmt.Fprintf(w, "seg-%s|", name)
+	})
+	return mux
+}
+
+func newTestParser(t *testing.T) *Parser {
+	p, err := new(builder).Build(map[string]string{"timeout_in_us": "2000000"})
+	assert.NoError(t, err)
+	return p.(*Parser)
+}
+
+func newStreamInfo(t *testing.T, rawUrl string) *live.StreamUrlInfo {
+	u, err := url.Parse(rawUrl)
+	assert.NoError(t, err)
+	return &live.StreamUrlInfo{
+		Url:                  u,
+		HeadersForDownloader: map[string]string{"Referer": "https://example.com/room"},
+	}
+}
+
+func expectedContent(from, to int) string {
+	b := new(strings.Builder)
+	for i := from; i < to; i++ {
+		fmt.Fprintf(b, "seg-%d|", i)
+	}
+	return b.String()
+}
+
+func TestParseLiveStream(t *testing.T) {
+	s := &liveServer{window: 3, total: 12, discontinuity: 5}
+	server := httptest.NewServer(s.handler())
+	defer server.Close()
+
+	file := filepath.Join(t.TempDir(), "out.ts")
+	p := newTestParser(t)
+	err := p.ParseLiveStream(context.Background(), newStreamInfo(t, server.URL+"/master.m3u8"), nil, file)
+	assert.NoError(t, err)
+
+	b, err := os.ReadFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedContent(0, 12), string(b))
+
+	status, err := p.Status()
+	assert.NoError(t, err)
+	assert.Equal(t, Name, status["parser"])
+	assert.Equal(t, "12", status["segment_count"])
+	assert.Equal(t, "1", status["discontinuity_count"])
+	assert.Equal(t, "11", status["media_sequence"])
+	assert.Equal(t, fmt.Sprint(len(b)), status["total_size"])
+	assert.Equal(t, server.URL+"/high/live.m3u8", status["playlist_url"])
+}
+
+func TestParseLiveStreamWithSequenceReset(t *testing.T) {
+	s := &liveServer{window: 3, total: 10, resetAt: 6}
+	server := httptest.NewServer(s.handler())
+	defer server.Close()
+
+	file := filepath.Join(t.TempDir(), "out.ts")
+	p := newTestParser(t)
+	assert.NoError(t, p.ParseLiveStream(context.Background(), newStreamInfo(t, server.URL+"/high/live.m3u8"), nil, file))
+	b, err := os.ReadFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedContent(0, 10), string(b))
+	status, _ := p.Status()
+	assert.Equal(t, "1", status["discontinuity_count"])
+}
+
+func TestStop(t *testing.T) {
+	s := &liveServer{window: 3, endless: true}
+	server := httptest.NewServer(s.handler())
+	defer server.Close()
+
+	file := filepath.Join(t.TempDir(), "out.ts")
+	p := newTestParser(t)
+	done := make(chan error, 1)
+	go func() {
+		done <- p.ParseLiveStream(context.Background(), newStreamInfo(t, server.URL+"/high/live.m3u8"), nil, file)
+	}()
+	time.Sleep(500 * time.Millisecond)
+	assert.NoError(t, p.Stop())
+	assert.NoError(t, p.Stop())
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(3 * time.Second):
+		t.Fatal("parser did not stop")
+	}
+	b, err := os.ReadFile(file)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(b), expectedContent(0, 3)))
+}
+
+func TestStalledStream(t *testing.T) {
+	s := &liveServer{window: 3, total: 3}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 不带 ENDLIST 且不再更新的播放列表
+		fmt.Fprint(w, strings.ReplaceAll(s.playlist(), "#EXT-X-ENDLIST\n", ""))
+	}))
+	defer server.Close()
+
+	p, err := new(builder).Build(map[string]string{"timeout_in_us": "500000"})
+	assert.NoError(t, err)
+	err = p.ParseLiveStream(context.Background(), newStreamInfo(t, server.URL+"/live.m3u8"), nil, filepath.Join(t.TempDir(), "out.ts"))
+	assert.Equal(t, ErrStreamStalled, err)
+}
+
+func TestParsePlaylist(t *testing.T) {
+	base, _ := url.Parse("https://example.com/live/index.m3u8?token=1")
+	pl, err := parsePlaylist(strings.NewReader(`#EXTM3U
+#EXT-X-TARGETDURATION:4
+#EXT-X-MEDIA-SEQUENCE:100
+#EXT-X-MAP:URI="init.mp4"
+#EXTINF:4.000,
+100.m4s
+#EXT-X-DISCONTINUITY
+#EXTINF:3.500,title
+https://cdn.example.com/101.m4s
+#EXT-X-KEY:METHOD=AES-128,URI="key",IV=0x1
+#EXTINF:4.000,
+102.m4s
+`), base)
+	assert.NoError(t, err)
+	assert.False(t, pl.isMaster())
+	assert.False(t, pl.endList)
+	assert.True(t, pl.encrypted)
+	assert.Equal(t, 4.0, pl.targetDuration)
+	assert.Len(t, pl.segments, 3)
+	assert.Equal(t, uint64(100), pl.segments[0].sequence)
+	assert.Equal(t, "https://example.com/live/100.m4s", pl.segments[0].uri.String())
+	assert.Equal(t, "https://example.com/live/init.mp4", pl.segments[0].mapUri.String())
+	assert.True(t, pl.segments[1].discontinuity)
+	assert.Equal(t, 3.5, pl.segments[1].duration)
+	assert.Equal(t, "https://cdn.example.com/101.m4s", pl.segments[1].uri.String())
+	assert.Equal(t, uint64(102), pl.segments[2].sequence)
+
+	_, err = parsePlaylist(strings.NewReader("<html></html>"), base)
+	assert.Equal(t, ErrNotM3u8Playlist, err)
+
+	attrs := parseAttributes(`BANDWIDTH=1280000,CODECS="avc1.4d401e,mp4a.40.2",RESOLUTION=1280x720`)
+	assert.Equal(t, "1280000", attrs["BANDWIDTH"])
+	assert.Equal(t, "avc1.4d401e,mp4a.40.2", attrs["CODECS"])
+	assert.Equal(t, "1280x720", attrs["RESOLUTION"])
+}
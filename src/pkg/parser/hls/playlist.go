@@ -0,0 +1,175 @@
+package hls
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrNotM3u8Playlist = errors.New("not m3u8 playlist")
+)
+
+// variant 多码率播放列表中的一个子播放列表
+type variant struct {
+	uri        *url.URL
+	bandwidth  int
+	resolution string
+}
+
+type segment struct {
+	uri           *url.URL
+	sequence      uint64
+	duration      float64
+	discontinuity bool
+	// mapUri 为 fMP4 的初始化分片 (EXT-X-MAP)，TS 分片为 nil
+	mapUri *url.URL
+}
+
+type playlist struct {
+	variants       []*variant
+	segments       []*segment
+	targetDuration float64
+	mediaSequence  uint64
+	endList        bool
+	encrypted      bool
+}
+
+func (p *playlist) isMaster() bool {
+	return len(p.variants) > 0
+}
+
+// bestVariant 选择带宽最高的子播放列表
+func (p *playlist) bestVariant() *variant {
+	var best *variant
+	for _, v := range p.variants {
+		if best == nil || v.bandwidth > best.bandwidth {
+			best = v
+		}
+	}
+	return best
+}
+
+// parseAttributes 解析形如 KEY=VALUE,KEY2="VALUE,2" 的属性列表
+func parseAttributes(s string) map[string]string {
+	attrs := make(map[string]string)
+	for len(s) > 0 {
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			break
+		}
+		key := strings.TrimSpace(s[:eq])
+		s = s[eq+1:]
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			end := strings.IndexByte(s[1:], '"')
+			if end < 0 {
+				value, s = s[1:], ""
+			} else {
+				value, s = s[1:end+1], s[end+2:]
+			}
+			s = strings.TrimPrefix(s, ",")
+		} else if comma := strings.IndexByte(s, ','); comma >= 0 {
+			value, s = s[:comma], s[comma+1:]
+		} else {
+			value, s = s, ""
+		}
+		attrs[key] = value
+	}
+	return attrs
+}
+
+func parsePlaylist(r io.Reader, base *url.URL) (*playlist, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	p := new(playlist)
+	var (
+		headerChecked bool
+		nextVariant   *variant
+		nextDuration  float64
+		discontinuity bool
+		mapUri        *url.URL
+		sequence      uint64
+		sequenceSet   bool
+	)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if !headerChecked {
+			if !strings.HasPrefix(line, "#EXTM3U") {
+				return nil, ErrNotM3u8Playlist
+			}
+			headerChecked = true
+			continue
+		}
+		if !strings.HasPrefix(line, "#") {
+			u, err := base.Parse(line)
+			if err != nil {
+				return nil, err
+			}
+			if nextVariant != nil {
+				nextVariant.uri = u
+				p.variants = append(p.variants, nextVariant)
+				nextVariant = nil
+				continue
+			}
+			if !sequenceSet {
+				sequence = p.mediaSequence
+				sequenceSet = true
+			}
+			p.segments = append(p.segments, &segment{
+				uri:           u,
+				sequence:      sequence,
+				duration:      nextDuration,
+				discontinuity: discontinuity,
+				mapUri:        mapUri,
+			})
+			sequence++
+			nextDuration = 0
+			discontinuity = false
+			continue
+		}
+		tag, value, _ := strings.Cut(line, ":")
+		switch tag {
+		case "#EXT-X-STREAM-INF":
+			attrs := parseAttributes(value)
+			bandwidth, _ := strconv.Atoi(attrs["BANDWIDTH"])
+			nextVariant = &variant{bandwidth: bandwidth, resolution: attrs["RESOLUTION"]}
+		case "#EXT-X-TARGETDURATION":
+			p.targetDuration, _ = strconv.ParseFloat(value, 64)
+		case "#EXT-X-MEDIA-SEQUENCE":
+			p.mediaSequence, _ = strconv.ParseUint(value, 10, 64)
+		case "#EXTINF":
+			durationStr, _, _ := strings.Cut(value, ",")
+			nextDuration, _ = strconv.ParseFloat(durationStr, 64)
+		case "#EXT-X-DISCONTINUITY":
+			discontinuity = true
+		case "#EXT-X-MAP":
+			if uri, ok := parseAttributes(value)["URI"]; ok {
+				u, err := base.Parse(uri)
+				if err != nil {
+					return nil, err
+				}
+				mapUri = u
+			}
+		case "#EXT-X-KEY":
+			if method := parseAttributes(value)["METHOD"]; method != "" && method != "NONE" {
+				p.encrypted = true
+			}
+		case "#EXT-X-ENDLIST":
+			p.endList = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !headerChecked {
+		return nil, ErrNotM3u8Playlist
+	}
+	return p, nil
+}
@@ -0,0 +1,273 @@
+package flv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+var ErrInvalidAMF = errors.New("invalid amf0 data")
+
+// AMFProperty 对象或 ECMA 数组中的一项
+type AMFProperty struct {
+	Key   string
+	Value any
+}
+
+type (
+	// AMFObject AMF0 对象，保持属性顺序
+	AMFObject []AMFProperty
+	// AMFECMAArray AMF0 ECMA 数组，onMetaData 通常使用该类型
+	AMFECMAArray []AMFProperty
+	// AMFDate AMF0 日期，UnixMilli 为毫秒时间戳
+	AMFDate struct {
+		UnixMilli float64
+		TimeZone  int16
+	}
+	// AMFUndefined AMF0 undefined
+	AMFUndefined struct{}
+)
+
+// Get 获取 key 对应的值
+func (o AMFObject) Get(key string) (any, bool) {
+	return getProperty(o, key)
+}
+
+// Get 获取 key 对应的值
+func (a AMFECMAArray) Get(key string) (any, bool) {
+	return getProperty(a, key)
+}
+
+func getProperty(props []AMFProperty, key string) (any, bool) {
+	for _, p := range props {
+		if p.Key == key {
+			return p.Value, true
+		}
+	}
+	return nil, false
+}
+
+// DecodeAMF 解码 b 中的所有 AMF0 值，例如 script tag 的数据
+func DecodeAMF(b []byte) ([]any, error) {
+	r := bytes.NewReader(b)
+	var ret []any
+	for r.Len() > 0 {
+		v, err := decodeAMFValue(r)
+		if err != nil {
+			return ret, err
+		}
+		ret = append(ret, v)
+	}
+	return ret, nil
+}
+
+func readAMFString(r *bytes.Reader, long bool) (string, error) {
+	var l uint32
+	if long {
+		if err := binary.Read(r, binary.BigEndian, &l); err != nil {
+			return "", ErrInvalidAMF
+		}
+	} else {
+		var l16 uint16
+		if err := binary.Read(r, binary.BigEndian, &l16); err != nil {
+			return "", ErrInvalidAMF
+		}
+		l = uint32(l16)
+	}
+	if int64(l) > int64(r.Len()) {
+		return "", ErrInvalidAMF
+	}
+	b := make([]byte, l)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return "", ErrInvalidAMF
+	}
+	return string(b), nil
+}
+
+// readAMFProperties 读取对象的属性直到结束标记
+func readAMFProperties(r *bytes.Reader) ([]AMFProperty, error) {
+	var props []AMFProperty
+	for {
+		key, err := readAMFString(r, false)
+		if err != nil {
+			return nil, err
+		}
+		if key == "" {
+			marker, err := r.ReadByte()
+			if err != nil {
+				return nil, ErrInvalidAMF
+			}
+			if DataType(marker) == ObjectEndMarker {
+				return props, nil
+			}
+			r.UnreadByte()
+		}
+		v, err := decodeAMFValue(r)
+		if err != nil {
+			return nil, err
+		}
+		props = append(props, AMFProperty{Key: key, Value: v})
+	}
+}
+
+func decodeAMFValue(r *bytes.Reader) (any, error) {
+	marker, err := r.ReadByte()
+	if err != nil {
+		return nil, ErrInvalidAMF
+	}
+	switch DataType(marker) {
+	case Number:
+		var v float64
+		if err := binary.Read(r, binary.BigEndian, &v); err != nil {
+			return nil, ErrInvalidAMF
+		}
+		return v, nil
+	case Boolean:
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, ErrInvalidAMF
+		}
+		return b != 0, nil
+	case String:
+		return readAMFString(r, false)
+	case LongString:
+		return readAMFString(r, true)
+	case Object:
+		props, err := readAMFProperties(r)
+		return AMFObject(props), err
+	case ECMAArray:
+		// 数组长度只是参考值，以结束标记为准
+		if _, err := r.Seek(4, io.SeekCurrent); err != nil {
+			return nil, ErrInvalidAMF
+		}
+		props, err := readAMFProperties(r)
+		return AMFECMAArray(props), err
+	case StrictArray:
+		var n uint32
+		if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+			return nil, ErrInvalidAMF
+		}
+		ret := make([]any, 0, min(int(n), r.Len()))
+		for i := uint32(0); i < n; i++ {
+			v, err := decodeAMFValue(r)
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, v)
+		}
+		return ret, nil
+	case Date:
+		var d AMFDate
+		if err := binary.Read(r, binary.BigEndian, &d.UnixMilli); err != nil {
+			return nil, ErrInvalidAMF
+		}
+		if err := binary.Read(r, binary.BigEndian, &d.TimeZone); err != nil {
+			return nil, ErrInvalidAMF
+		}
+		return d, nil
+	case Null:
+		return nil, nil
+	case Undefined:
+		return AMFUndefined{}, nil
+	default:
+		return nil, fmt.Errorf("%w: unsupported type %d", ErrInvalidAMF, marker)
+	}
+}
+
+// EncodeAMF 将 values 依次编码为 AMF0
+func EncodeAMF(values ...any) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	for _, v := range values {
+		if err := encodeAMFValue(buf, v); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func writeAMFKey(buf *bytes.Buffer, key string) error {
+	if len(key) > math.MaxUint16 {
+		return fmt.Errorf("%w: key is too long", ErrInvalidAMF)
+	}
+	buf.Write(binary.BigEndian.AppendUint16(nil, uint16(len(key))))
+	buf.WriteString(key)
+	return nil
+}
+
+func writeAMFProperties(buf *bytes.Buffer, props []AMFProperty) error {
+	for _, p := range props {
+		if err := writeAMFKey(buf, p.Key); err != nil {
+			return err
+		}
+		if err := encodeAMFValue(buf, p.Value); err != nil {
+			return err
+		}
+	}
+	buf.Write([]byte{0, 0, byte(ObjectEndMarker)})
+	return nil
+}
+
+func encodeAMFValue(buf *bytes.Buffer, v any) error {
+	switch v := v.(type) {
+	case float64:
+		buf.WriteByte(byte(Number))
+		buf.Write(binary.BigEndian.AppendUint64(nil, math.Float64bits(v)))
+	case int:
+		return encodeAMFValue(buf, float64(v))
+	case int64:
+		return encodeAMFValue(buf, float64(v))
+	case uint32:
+		return encodeAMFValue(buf, float64(v))
+	case bool:
+		buf.WriteByte(byte(Boolean))
+		if v {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+	case string:
+		if len(v) > math.MaxUint16 {
+			buf.WriteByte(byte(LongString))
+			buf.Write(binary.BigEndian.AppendUint32(nil, uint32(len(v))))
+		} else {
+			buf.WriteByte(byte(String))
+			buf.Write(binary.BigEndian.AppendUint16(nil, uint16(len(v))))
+		}
+		buf.WriteString(v)
+	case AMFObject:
+		buf.WriteByte(byte(Object))
+		return writeAMFProperties(buf, v)
+	case AMFECMAArray:
+		buf.WriteByte(byte(ECMAArray))
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(len(v))))
+		return writeAMFProperties(buf, v)
+	case []any:
+		buf.WriteByte(byte(StrictArray))
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(len(v))))
+		for _, item := range v {
+			if err := encodeAMFValue(buf, item); err != nil {
+				return err
+			}
+		}
+	case []float64:
+		buf.WriteByte(byte(StrictArray))
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(len(v))))
+		for _, item := range v {
+			encodeAMFValue(buf, item)
+		}
+	case AMFDate:
+		buf.WriteByte(byte(Date))
+		buf.Write(binary.BigEndian.AppendUint64(nil, math.Float64bits(v.UnixMilli)))
+		buf.Write(binary.BigEndian.AppendUint16(nil, uint16(v.TimeZone)))
+	case nil:
+		buf.WriteByte(byte(Null))
+	case AMFUndefined:
+		buf.WriteByte(byte(Undefined))
+	default:
+		return fmt.Errorf("%w: unsupported value %T", ErrInvalidAMF, v)
+	}
+	return nil
+}
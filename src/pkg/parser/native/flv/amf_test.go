@@ -0,0 +1,38 @@
+package flv
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAMFRoundTrip(t *testing.T) {
+	values := []any{
+		"onMetaData",
+		AMFECMAArray{
+			{Key: "width", Value: 1920.0},
+			{Key: "stereo", Value: true},
+			{Key: "encoder", Value: "obs"},
+			{Key: "keyframes", Value: AMFObject{
+				{Key: "times", Value: []any{0.0, 1.5}},
+			}},
+			{Key: "date", Value: AMFDate{UnixMilli: 1700000000000, TimeZone: 8}},
+			{Key: "empty", Value: nil},
+			{Key: "undefined", Value: AMFUndefined{}},
+		},
+	}
+	b, err := EncodeAMF(values...)
+	assert.NoError(t, err)
+	got, err := DecodeAMF(b)
+	assert.NoError(t, err)
+	assert.Equal(t, values, got)
+
+	width, ok := got[1].(AMFECMAArray).Get("width")
+	assert.True(t, ok)
+	assert.Equal(t, 1920.0, width)
+
+	_, err = DecodeAMF(b[:len(b)-2])
+	assert.ErrorIs(t, err, ErrInvalidAMF)
+	_, err = EncodeAMF(struct{}{})
+	assert.ErrorIs(t, err, ErrInvalidAMF)
+}
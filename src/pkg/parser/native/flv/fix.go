@@ -0,0 +1,297 @@
+package flv
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// maxTimestampGap 同类 tag 的时间戳前后相差超过该值（毫秒）时视为时间戳跳变，重新计算偏移
+	maxTimestampGap = 3000
+	// 时间戳跳变后，新的时间戳接在上一帧之后的间隔（毫秒）
+	videoFrameDuration = 33
+	audioFrameDuration = 23
+	// duplicateWindow 检测重复 tag 时比较的最近 tag 数量
+	duplicateWindow = 128
+)
+
+var ErrNoMediaTag = errors.New("no media tag")
+
+// FixStats 修复过程的统计
+type FixStats struct {
+	Tags            int `json:"tags"`
+	Duplicates      int `json:"duplicates"`
+	OutOfOrder      int `json:"out_of_order"`
+	Discontinuities int `json:"discontinuities"`
+	Parts           int `json:"parts"`
+	// Truncated 输入的最后一个 tag 不完整，已丢弃
+	Truncated bool `json:"truncated"`
+}
+
+type tagKey struct {
+	typ       uint8
+	timestamp uint32
+	sum       uint32
+}
+
+// fixer 修复时间戳并在 sequence header 变化时分段。
+// 每一段的第一帧时间戳为 0；时间戳回退或跳变时接在上一帧之后继续；
+// 与最近的 tag 完全相同的 tag、以及小幅回退的乱序 tag 会被丢弃。
+type fixer struct {
+	header *Header
+	create func() (io.WriteCloser, error)
+	stats  FixStats
+
+	// 当前的 onMetaData 与 sequence header，每一段开头都会写入
+	metadata  *Tag
+	avcHeader *Tag
+	aacHeader *Tag
+
+	out    io.WriteCloser
+	w      *TagWriter
+	frames int
+
+	offset  int64
+	lastOut int64
+	last    map[uint8]int64
+
+	recent     map[tagKey]int
+	recentKeys []tagKey
+}
+
+// Fix 读取 r 中的 FLV 数据并写入修复后的分段，create 用于创建下一个分段的输出。
+// 输入不完整时丢弃最后一个 tag，不视为错误。
+func Fix(r io.Reader, create func() (io.WriteCloser, error)) (*FixStats, error) {
+	tr := NewTagReader(r)
+	header, err := tr.ReadHeader()
+	if err != nil {
+		return nil, err
+	}
+	f := &fixer{
+		header: header,
+		create: create,
+		recent: make(map[tagKey]int),
+	}
+	defer f.closePart()
+	for {
+		tag, err := tr.ReadTag()
+		if err == io.EOF {
+			break
+		}
+		if err == io.ErrUnexpectedEOF {
+			f.stats.Truncated = true
+			break
+		}
+		if err != nil {
+			return &f.stats, err
+		}
+		f.stats.Tags++
+		if err := f.handle(tag); err != nil {
+			return &f.stats, err
+		}
+	}
+	if err := f.closePart(); err != nil {
+		return &f.stats, err
+	}
+	if f.stats.Parts == 0 {
+		return &f.stats, ErrNoMediaTag
+	}
+	return &f.stats, nil
+}
+
+func (f *fixer) handle(tag *Tag) error {
+	switch {
+	case tag.IsScript():
+		// 只保留 onMetaData，在每一段的开头写入；原有的关键帧索引在修复后不再准确
+		f.metadata = stripGeneratedMetadata(tag)
+		return nil
+	case tag.IsAVCSeqHeader():
+		f.updateSeqHeader(&f.avcHeader, tag)
+		return nil
+	case tag.IsAACSeqHeader():
+		f.updateSeqHeader(&f.aacHeader, tag)
+		return nil
+	case !tag.IsVideo() && !tag.IsAudio():
+		return nil
+	}
+	// 缺少 sequence header 的帧无法解码
+	if (tag.IsAVC() && f.avcHeader == nil) || (tag.IsAAC() && f.aacHeader == nil) {
+		return nil
+	}
+	if f.isDuplicate(tag) {
+		f.stats.Duplicates++
+		return nil
+	}
+	if f.out == nil {
+		if err := f.openPart(tag); err != nil {
+			return err
+		}
+	}
+	if !f.rebase(tag) {
+		f.stats.OutOfOrder++
+		return nil
+	}
+	f.frames++
+	return f.w.WriteTag(tag)
+}
+
+// updateSeqHeader 更新 sequence header，内容变化且当前分段已经有数据时结束当前分段
+func (f *fixer) updateSeqHeader(current **Tag, tag *Tag) {
+	if *current != nil && bytes.Equal((*current).Data, tag.Data) {
+		return
+	}
+	if *current != nil && f.frames > 0 {
+		f.closePart()
+	}
+	*current = tag
+}
+
+func (f *fixer) isDuplicate(tag *Tag) bool {
+	key := tagKey{typ: tag.Type, timestamp: tag.Timestamp, sum: crc32.ChecksumIEEE(tag.Data)}
+	if f.recent[key] > 0 {
+		return true
+	}
+	f.recent[key]++
+	f.recentKeys = append(f.recentKeys, key)
+	if len(f.recentKeys) > duplicateWindow {
+		old := f.recentKeys[0]
+		f.recentKeys = f.recentKeys[1:]
+		if f.recent[old]--; f.recent[old] <= 0 {
+			delete(f.recent, old)
+		}
+	}
+	return false
+}
+
+// rebase 修正 tag 的时间戳，返回 false 表示应当丢弃的乱序 tag
+func (f *fixer) rebase(tag *Tag) bool {
+	ts := int64(tag.Timestamp) + f.offset
+	last, ok := f.last[tag.Type]
+	switch {
+	case !ok:
+		// 该类型在本段的第一帧，与另一类型差距过大时接在其后
+		if f.frames > 0 && (ts < f.lastOut-maxTimestampGap || ts > f.lastOut+maxTimestampGap) {
+			f.stats.Discontinuities++
+			ts = f.jump(tag)
+		}
+	case ts < last && last-ts <= maxTimestampGap:
+		return false
+	case ts < last || ts-last > maxTimestampGap:
+		f.stats.Discontinuities++
+		ts = f.jump(tag)
+	}
+	if ts < 0 {
+		ts = 0
+	}
+	f.last[tag.Type] = ts
+	if ts > f.lastOut {
+		f.lastOut = ts
+	}
+	tag.Timestamp = uint32(ts)
+	return true
+}
+
+// jump 时间戳跳变时调整偏移，使 tag 接在上一帧之后
+func (f *fixer) jump(tag *Tag) int64 {
+	duration := int64(audioFrameDuration)
+	if tag.IsVideo() {
+		duration = videoFrameDuration
+	}
+	ts := f.lastOut + duration
+	f.offset = ts - int64(tag.Timestamp)
+	return ts
+}
+
+// openPart 创建新的分段，写入文件头、onMetaData 与 sequence header，并以 first 为时间戳起点
+func (f *fixer) openPart(first *Tag) error {
+	out, err := f.create()
+	if err != nil {
+		return err
+	}
+	f.out, f.w = out, NewTagWriter(out)
+	f.frames = 0
+	f.offset = -int64(first.Timestamp)
+	f.lastOut = 0
+	f.last = make(map[uint8]int64)
+	f.stats.Parts++
+
+	if err := f.w.WriteHeader(f.header); err != nil {
+		return err
+	}
+	for _, tag := range []*Tag{f.metadata, f.avcHeader, f.aacHeader} {
+		if tag == nil {
+			continue
+		}
+		if err := f.w.WriteTag(&Tag{Type: tag.Type, StreamID: tag.StreamID, Data: tag.Data}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fixer) closePart() error {
+	if f.out == nil {
+		return nil
+	}
+	err := f.out.Close()
+	f.out, f.w = nil, nil
+	return err
+}
+
+// FixFile 修复 fileName 的时间戳，并在音视频参数变化时拆分为多个文件，返回修复后的文件列表。
+// 修复失败时保留原文件并返回 []string{fileName}；非 flv 文件直接返回。
+func FixFile(fileName string) (outputFiles []string, stats *FixStats, err error) {
+	outputFiles = []string{fileName}
+	ext := filepath.Ext(fileName)
+	if strings.ToLower(ext) != ".flv" {
+		return outputFiles, nil, nil
+	}
+	in, err := os.Open(fileName)
+	if err != nil {
+		return outputFiles, nil, err
+	}
+	defer in.Close()
+
+	base := strings.TrimSuffix(fileName, ext)
+	var parts []string
+	stats, err = Fix(in, func() (io.WriteCloser, error) {
+		name := fmt.Sprintf("%s.fix_p%03d%s", base, len(parts)+1, ext)
+		parts = append(parts, name)
+		return os.Create(name)
+	})
+	in.Close()
+	if err != nil {
+		for _, part := range parts {
+			os.Remove(part)
+		}
+		return outputFiles, stats, err
+	}
+
+	// 只有一个分段时直接替换原文件
+	if len(parts) == 1 {
+		if err = os.Rename(parts[0], fileName); err != nil {
+			os.Remove(parts[0])
+		}
+		return outputFiles, stats, err
+	}
+	// 否则去掉中间的 .fix_p，全部重命名成功后才删除原文件，失败时删除已修复的分段并保留原文件
+	names := make([]string, 0, len(parts))
+	for i, part := range parts {
+		name := strings.Replace(part, ".fix_p", "", 1)
+		if err = os.Rename(part, name); err != nil {
+			for _, file := range append(names, parts[i:]...) {
+				os.Remove(file)
+			}
+			return outputFiles, stats, err
+		}
+		names = append(names, name)
+	}
+	// 原文件删除失败时仍然使用修复后的文件
+	return names, stats, os.Remove(fileName)
+}
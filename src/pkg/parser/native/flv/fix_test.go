@@ -0,0 +1,242 @@
+package flv
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type nopWriteCloser struct {
+	*bytes.Buffer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+func avcSeqHeader(sps byte) *Tag {
+	return &Tag{Type: videoTag, Data: []byte{byte(KeyFrame)<<4 | byte(AVCCode), byte(AVCSeqHeader), 0, 0, 0, sps}}
+}
+
+func aacSeqHeader() *Tag {
+	return &Tag{Type: audioTag, Data: []byte{byte(AAC)<<4 | 0x0f, byte(AACSeqHeader), 0x12, 0x10}}
+}
+
+func videoFrame(ts uint32, key bool, payload byte) *Tag {
+	frameType := InterFrame
+	if key {
+		frameType = KeyFrame
+	}
+	return &Tag{Type: videoTag, Timestamp: ts, Data: []byte{byte(frameType)<<4 | byte(AVCCode), byte(AVCNALU), 0, 0, 0, payload}}
+}
+
+func audioFrame(ts uint32, payload byte) *Tag {
+	return &Tag{Type: audioTag, Timestamp: ts, Data: []byte{byte(AAC)<<4 | 0x0f, byte(AACRaw), payload}}
+}
+
+func buildFlv(t *testing.T, tags ...*Tag) []byte {
+	buf := new(bytes.Buffer)
+	w := NewTagWriter(buf)
+	assert.NoError(t, w.WriteHeader(&Header{HasVideo: true, HasAudio: true}))
+	for _, tag := range tags {
+		assert.NoError(t, w.WriteTag(tag))
+	}
+	assert.Equal(t, int64(buf.Len()), w.Size())
+	return buf.Bytes()
+}
+
+func readFlv(t *testing.T, b []byte) []*Tag {
+	r := NewTagReader(bytes.NewReader(b))
+	_, err := r.ReadHeader()
+	assert.NoError(t, err)
+	var tags []*Tag
+	for {
+		tag, err := r.ReadTag()
+		if err == io.EOF {
+			return tags
+		}
+		assert.NoError(t, err)
+		tags = append(tags, tag)
+	}
+}
+
+func timestamps(tags []*Tag, typ uint8) []uint32 {
+	var ret []uint32
+	for _, tag := range tags {
+		if tag.Type == typ && !tag.IsAVCSeqHeader() && !tag.IsAACSeqHeader() {
+			ret = append(ret, tag.Timestamp)
+		}
+	}
+	return ret
+}
+
+func fix(t *testing.T, b []byte) ([][]*Tag, *FixStats, error) {
+	var parts []*bytes.Buffer
+	stats, err := Fix(bytes.NewReader(b), func() (io.WriteCloser, error) {
+		buf := new(bytes.Buffer)
+		parts = append(parts, buf)
+		return nopWriteCloser{buf}, nil
+	})
+	ret := make([][]*Tag, 0, len(parts))
+	for _, part := range parts {
+		ret = append(ret, readFlv(t, part.Bytes()))
+	}
+	return ret, stats, err
+}
+
+func TestTagReadWrite(t *testing.T) {
+	tags := []*Tag{
+		{Type: scriptTag, Data: []byte{2, 0, 10}},
+		avcSeqHeader(1),
+		videoFrame(0x01020304, true, 1),
+	}
+	b := buildFlv(t, tags...)
+	got := readFlv(t, b)
+	assert.Equal(t, tags, got)
+	assert.True(t, got[1].IsAVCSeqHeader())
+	assert.False(t, got[1].IsKeyFrame())
+	assert.True(t, got[2].IsKeyFrame())
+
+	// 最后一个 tag 不完整
+	r := NewTagReader(bytes.NewReader(b[:len(b)-6]))
+	_, err := r.ReadHeader()
+	assert.NoError(t, err)
+	r.ReadTag()
+	r.ReadTag()
+	_, err = r.ReadTag()
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	_, err = NewTagReader(bytes.NewReader([]byte("not a flv file"))).ReadHeader()
+	assert.ErrorIs(t, err, ErrNotFlvStream)
+}
+
+func TestFixTimestamps(t *testing.T) {
+	b := buildFlv(t,
+		&Tag{Type: scriptTag, Timestamp: 5000, Data: []byte{2, 0, 10}},
+		avcSeqHeader(1),
+		aacSeqHeader(),
+		// 起始时间戳不为 0
+		videoFrame(5000, true, 1),
+		audioFrame(5010, 1),
+		videoFrame(5040, false, 2),
+		// 重复的 tag
+		videoFrame(5040, false, 2),
+		audioFrame(5030, 2),
+		// 乱序
+		videoFrame(5020, false, 3),
+		// 重复发送的 sequence header 不会分段
+		avcSeqHeader(1),
+		// 时间戳回退（例如推流端重连）
+		videoFrame(100, true, 4),
+		audioFrame(110, 3),
+		// 时间戳向前跳变
+		videoFrame(60000, false, 5),
+		audioFrame(60010, 4),
+	)
+	parts, stats, err := fix(t, b)
+	assert.NoError(t, err)
+	assert.Len(t, parts, 1)
+	assert.Equal(t, &FixStats{Tags: 14, Duplicates: 1, OutOfOrder: 1, Discontinuities: 2, Parts: 1}, stats)
+
+	tags := parts[0]
+	assert.True(t, tags[0].IsScript())
+	assert.Equal(t, uint32(0), tags[0].Timestamp)
+	assert.True(t, tags[1].IsAVCSeqHeader())
+	assert.True(t, tags[2].IsAACSeqHeader())
+	assert.Equal(t, []uint32{0, 40, 73, 116}, timestamps(tags, videoTag))
+	assert.Equal(t, []uint32{10, 30, 83, 126}, timestamps(tags, audioTag))
+}
+
+func TestFixSplitOnSeqHeaderChange(t *testing.T) {
+	b := buildFlv(t,
+		&Tag{Type: scriptTag, Data: []byte{2, 0, 10}},
+		// sequence header 之前的帧无法解码
+		videoFrame(0, false, 0),
+		avcSeqHeader(1),
+		aacSeqHeader(),
+		videoFrame(1000, true, 1),
+		audioFrame(1000, 1),
+		videoFrame(1040, false, 2),
+		// 分辨率变化
+		avcSeqHeader(2),
+		videoFrame(1080, true, 3),
+		audioFrame(1090, 2),
+	)
+	// 不完整的最后一个 tag
+	b = append(b, 9, 0, 0, 10)
+	parts, stats, err := fix(t, b)
+	assert.NoError(t, err)
+	assert.True(t, stats.Truncated)
+	assert.Len(t, parts, 2)
+
+	assert.Equal(t, []uint32{0, 40}, timestamps(parts[0], videoTag))
+	assert.Equal(t, byte(1), parts[0][1].Data[5])
+
+	// 新的分段以 onMetaData 和新的 sequence header 开头，时间戳从 0 开始
+	second := parts[1]
+	assert.True(t, second[0].IsScript())
+	assert.True(t, second[1].IsAVCSeqHeader())
+	assert.Equal(t, byte(2), second[1].Data[5])
+	assert.True(t, second[2].IsAACSeqHeader())
+	assert.Equal(t, []uint32{0}, timestamps(second, videoTag))
+	assert.Equal(t, []uint32{10}, timestamps(second, audioTag))
+
+	_, _, err = fix(t, buildFlv(t, avcSeqHeader(1)))
+	assert.ErrorIs(t, err, ErrNoMediaTag)
+}
+
+func TestFixFile(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "record.flv")
+	assert.NoError(t, os.WriteFile(file, buildFlv(t,
+		avcSeqHeader(1), videoFrame(500, true, 1), videoFrame(540, false, 2),
+	), 0644))
+	files, stats, err := FixFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, stats.Parts)
+	assert.Equal(t, []string{file}, files)
+	b, _ := os.ReadFile(file)
+	assert.Equal(t, []uint32{0, 40}, timestamps(readFlv(t, b), videoTag))
+
+	assert.NoError(t, os.WriteFile(file, buildFlv(t,
+		avcSeqHeader(1), videoFrame(0, true, 1), avcSeqHeader(2), videoFrame(40, true, 2),
+	), 0644))
+	files, _, err = FixFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "record001.flv"), filepath.Join(dir, "record002.flv")}, files)
+	assert.NoFileExists(t, file)
+	for _, f := range files {
+		assert.FileExists(t, f)
+	}
+
+	// 修复失败时保留原文件
+	broken := filepath.Join(dir, "broken.flv")
+	assert.NoError(t, os.WriteFile(broken, []byte("not a flv file"), 0644))
+	files, _, err = FixFile(broken)
+	assert.Error(t, err)
+	assert.Equal(t, []string{broken}, files)
+	assert.FileExists(t, broken)
+	entries, _ := os.ReadDir(dir)
+	assert.Len(t, entries, 3)
+
+	// 分段重命名失败时保留原文件，删除已修复的分段
+	splitDir := t.TempDir()
+	split := filepath.Join(splitDir, "split.flv")
+	assert.NoError(t, os.WriteFile(split, buildFlv(t,
+		avcSeqHeader(1), videoFrame(0, true, 1), avcSeqHeader(2), videoFrame(40, true, 2),
+	), 0644))
+	assert.NoError(t, os.MkdirAll(filepath.Join(splitDir, "split002.flv", "occupied"), 0755))
+	files, _, err = FixFile(split)
+	assert.Error(t, err)
+	assert.Equal(t, []string{split}, files)
+	assert.FileExists(t, split)
+	entries, _ = os.ReadDir(splitDir)
+	assert.Len(t, entries, 2)
+
+	files, stats, err = FixFile(filepath.Join(dir, "record.ts"))
+	assert.NoError(t, err)
+	assert.Nil(t, stats)
+	assert.Equal(t, []string{filepath.Join(dir, "record.ts")}, files)
+}
@@ -1,21 +1,17 @@
 package flv
 
 import (
-	"bytes"
 	"context"
-	"encoding/binary"
 	"errors"
-	"fmt"
 	"io"
 	"net/http"
-	"os"
-	"runtime/debug"
+	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 
-	"github.com/bililive-go/bililive-go/src/instance"
 	"github.com/bililive-go/bililive-go/src/live"
 	"github.com/bililive-go/bililive-go/src/pkg/parser"
-	"github.com/bililive-go/bililive-go/src/pkg/reader"
 	"github.com/bililive-go/bililive-go/src/pkg/utils"
 )
 
@@ -25,8 +21,6 @@ const (
 	audioTag  uint8 = 8
 	videoTag  uint8 = 9
 	scriptTag uint8 = 18
-
-	ioRetryCount int = 3
 )
 
 var (
@@ -47,11 +41,20 @@ func (b *builder) Build(cfg map[string]string) (parser.Parser, error) {
 	// if err != nil {
 	// 	timeout = time.Minute
 	// }
+	maxFileSize, _ := strconv.ParseInt(cfg["max_file_size"], 10, 64)
+	maxDuration, _ := time.ParseDuration(cfg["max_duration"])
+	hc, err := utils.NewProxyClient(cfg["proxy"])
+	if err != nil {
+		return nil, err
+	}
 	return &Parser{
-		Metadata:  Metadata{},
-		hc:        &http.Client{},
-		stopCh:    make(chan struct{}),
-		closeOnce: new(sync.Once),
+		Metadata:    Metadata{},
+		hc:          hc,
+		stopCh:      make(chan struct{}),
+		closeOnce:   new(sync.Once),
+		maxFileSize: maxFileSize,
+		maxDuration: maxDuration,
+		relay:       newRelay(),
 	}, nil
 }
 
@@ -62,20 +65,46 @@ type Metadata struct {
 type Parser struct {
 	Metadata Metadata
 
-	i              *reader.BufferedReader
-	o              io.Writer
-	avcHeaderCount uint8
-	tagCount       uint32
+	tagCount uint32
 
 	hc        *http.Client
 	stopCh    chan struct{}
 	closeOnce *sync.Once
+	totalSize atomic.Int64
+
+	// 分段策略，设置了 splitHandler 时生效
+	maxFileSize  int64
+	maxDuration  time.Duration
+	splitHandler parser.SplitHandler
+
+	relay *relay
+}
+
+// SetSplitHandler 设置后，文件大小或时长达到限制时在下一个关键帧处切换到 handler 返回的新文件
+func (p *Parser) SetSplitHandler(handler parser.SplitHandler) {
+	p.splitHandler = handler
+}
+
+// Relay 将正在录制的直播流写入 w，直到 ctx 结束、录制停止或写入失败
+func (p *Parser) Relay(ctx context.Context, w io.Writer) error {
+	return p.relay.Relay(ctx, w)
 }
 
 func (p *Parser) ParseLiveStream(ctx context.Context, streamUrlInfo *live.StreamUrlInfo, live live.Live, file string) error {
+	defer p.relay.close()
 	url := streamUrlInfo.Url
+	// Stop 时中断请求，避免卡在读取上
+	reqCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-p.stopCh:
+			cancel()
+		case <-reqCtx.Done():
+		}
+	}()
 	// init input
-	req, err := http.NewRequest("GET", url.String(), nil)
+	req, err := http.NewRequestWithContext(reqCtx, "GET", url.String(), nil)
 	if err != nil {
 		return err
 	}
@@ -89,93 +118,70 @@ func (p *Parser) ParseLiveStream(ctx context.Context, streamUrlInfo *live.Stream
 		return err
 	}
 	defer resp.Body.Close()
-	p.i = reader.New(resp.Body)
-	defer p.i.Free()
 
-	// init output
-	f, err := os.OpenFile(file, os.O_RDWR|os.O_CREATE, 0666)
-	if err != nil {
+	// start parse
+	err = p.doParse(resp.Body, file)
+	select {
+	case <-p.stopCh:
+		return nil
+	default:
 		return err
 	}
-	p.o = f
-	defer f.Close()
+}
 
-	// start parse
-	return p.doParse(ctx)
+func (p *Parser) Status() (map[string]string, error) {
+	return map[string]string{
+		"parser":        Name,
+		"total_size":    strconv.FormatInt(p.totalSize.Load(), 10),
+		"tag_count":     strconv.FormatUint(uint64(atomic.LoadUint32(&p.tagCount)), 10),
+		"relay_clients": strconv.Itoa(p.relay.clients()),
+	}, nil
 }
 
 func (p *Parser) Stop() error {
 	p.closeOnce.Do(func() {
 		close(p.stopCh)
+		p.relay.close()
 	})
 	return nil
 }
 
-func (p *Parser) doParse(ctx context.Context) error {
-	// header of flv
-	b, err := p.i.ReadN(9)
+func (p *Parser) doParse(r io.Reader, file string) error {
+	tr := NewTagReader(r)
+	header, err := tr.ReadHeader()
 	if err != nil {
 		return err
 	}
-	// signature
-	if !bytes.Equal(b[:4], flvSign) {
-		return ErrNotFlvStream
+	p.Metadata.HasVideo, p.Metadata.HasAudio = header.HasVideo, header.HasAudio
+	p.relay.setHeader(header)
+
+	m := &muxer{
+		header:      header,
+		maxSize:     p.maxFileSize,
+		maxDuration: p.maxDuration,
+		onSplit:     p.splitHandler,
+		written:     &p.totalSize,
 	}
-	// flag
-	p.Metadata.HasVideo = uint8(b[4])&(1<<2) != 0
-	p.Metadata.HasAudio = uint8(b[4])&1 != 0
-
-	// offset must be 9
-	if binary.BigEndian.Uint32(b[5:]) != 9 {
-		return ErrNotFlvStream
-	}
-
-	// write flv header
-	if err := p.doWrite(ctx, p.i.AllBytes()); err != nil {
+	defer m.Close()
+	if err := m.open(file); err != nil {
 		return err
 	}
-	p.i.Reset()
-
 	for {
 		select {
 		case <-p.stopCh:
-			return nil
+			return m.Close()
 		default:
-			if err := p.parseTag(ctx); err != nil {
-				return err
-			}
-		}
-	}
-}
-
-func (p *Parser) doCopy(ctx context.Context, n uint32) error {
-	if writtenCount, err := io.CopyN(p.o, p.i, int64(n)); err != nil || writtenCount != int64(writtenCount) {
-		utils.PrintStack()
-		if err == nil {
-			err = fmt.Errorf("doCopy(%d), %d bytes written", n, writtenCount)
 		}
-		return err
-	}
-	return nil
-}
-
-func (p *Parser) doWrite(ctx context.Context, b []byte) error {
-	inst := instance.GetInstance(ctx)
-	logger := inst.Logger
-	leftInputSize := len(b)
-	for retryLeft := ioRetryCount; retryLeft > 0 && leftInputSize > 0; retryLeft-- {
-		writtenCount, err := p.o.Write(b[len(b)-leftInputSize:])
-		leftInputSize -= writtenCount
+		tag, err := tr.ReadTag()
 		if err != nil {
-			logger.Debugf("%s", string(debug.Stack()))
 			return err
 		}
-		if leftInputSize != 0 {
-			logger.Debugf("doWrite() left %d bytes to write", leftInputSize)
+		atomic.AddUint32(&p.tagCount, 1)
+		// muxer 会修改 tag 的时间戳，转发一份副本
+		relayTag := *tag
+		p.relay.publish(&relayTag)
+		if err := m.WriteTag(tag); err != nil {
+			return err
 		}
 	}
-	if leftInputSize != 0 {
-		return fmt.Errorf("doWrite([%d]byte) tried %d times, but still has %d bytes to write", len(b), ioRetryCount, leftInputSize)
-	}
-	return nil
 }
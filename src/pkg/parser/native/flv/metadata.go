@@ -0,0 +1,149 @@
+package flv
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+const (
+	// maxIndexedKeyframes onMetaData 中最多记录的关键帧数量，超出时均匀抽取。
+	// 预留空间约为 18 字节 * maxIndexedKeyframes，需要小于 AMF0 字符串的最大长度，用于填充
+	maxIndexedKeyframes = 3000
+	// metadataPaddingKey 填充属性，使重写后的 onMetaData 与预留的大小一致
+	metadataPaddingKey = "padding"
+	// metadataPaddingOverhead 填充属性的 key 与字符串头部的长度
+	metadataPaddingOverhead = 2 + len(metadataPaddingKey) + 3
+)
+
+// 由 muxer 计算的属性，原始 onMetaData 中的同名属性会被忽略
+var generatedMetadataKeys = map[string]bool{
+	"duration":              true,
+	"filesize":              true,
+	"hasKeyframes":          true,
+	"keyframes":             true,
+	"lasttimestamp":         true,
+	"lastkeyframetimestamp": true,
+	"lastkeyframelocation":  true,
+	metadataPaddingKey:      true,
+}
+
+// keyframe 关键帧的时间（秒）与在文件中的位置
+type keyframe struct {
+	time     float64
+	position int64
+}
+
+// baseMetadata 从直播流的 script tag 中读取 onMetaData 的属性，去掉需要重新计算的属性
+func baseMetadata(tag *Tag) AMFECMAArray {
+	ret := AMFECMAArray{}
+	if tag == nil {
+		return ret
+	}
+	values, _ := DecodeAMF(tag.Data)
+	if len(values) < 2 {
+		return ret
+	}
+	if name, _ := values[0].(string); name != "onMetaData" {
+		return ret
+	}
+	var props []AMFProperty
+	switch v := values[1].(type) {
+	case AMFECMAArray:
+		props = v
+	case AMFObject:
+		props = v
+	}
+	for _, p := range props {
+		if !generatedMetadataKeys[p.Key] {
+			ret = append(ret, p)
+		}
+	}
+	return ret
+}
+
+// sampleKeyframes 关键帧数量超过 maxIndexedKeyframes 时均匀抽取
+func sampleKeyframes(keyframes []keyframe) []keyframe {
+	if len(keyframes) <= maxIndexedKeyframes {
+		return keyframes
+	}
+	ret := make([]keyframe, 0, maxIndexedKeyframes)
+	for i := 0; i < maxIndexedKeyframes; i++ {
+		ret = append(ret, keyframes[i*len(keyframes)/maxIndexedKeyframes])
+	}
+	return ret
+}
+
+// buildMetadata 生成包含时长、文件大小与关键帧索引的 onMetaData
+func buildMetadata(base AMFECMAArray, lastTimestamp uint32, fileSize int64, keyframes []keyframe) AMFECMAArray {
+	keyframes = sampleKeyframes(keyframes)
+	times := make([]float64, 0, len(keyframes))
+	positions := make([]float64, 0, len(keyframes))
+	for _, k := range keyframes {
+		times = append(times, k.time)
+		positions = append(positions, float64(k.position))
+	}
+	var lastKeyframe keyframe
+	if len(keyframes) > 0 {
+		lastKeyframe = keyframes[len(keyframes)-1]
+	}
+	ret := make(AMFECMAArray, 0, len(base)+8)
+	ret = append(ret, base...)
+	return append(ret,
+		AMFProperty{Key: "duration", Value: float64(lastTimestamp) / 1000},
+		AMFProperty{Key: "filesize", Value: float64(fileSize)},
+		AMFProperty{Key: "lasttimestamp", Value: float64(lastTimestamp) / 1000},
+		AMFProperty{Key: "lastkeyframetimestamp", Value: lastKeyframe.time},
+		AMFProperty{Key: "lastkeyframelocation", Value: float64(lastKeyframe.position)},
+		AMFProperty{Key: "hasKeyframes", Value: len(keyframes) > 0},
+		AMFProperty{Key: "keyframes", Value: AMFObject{
+			{Key: "times", Value: times},
+			{Key: "filepositions", Value: positions},
+		}},
+	)
+}
+
+// reservedMetadataSize 预留足够写入 maxIndexedKeyframes 个关键帧及填充属性的大小
+func reservedMetadataSize(base AMFECMAArray) (int, error) {
+	b, err := EncodeAMF("onMetaData", buildMetadata(base, 0, 0, make([]keyframe, maxIndexedKeyframes)))
+	if err != nil {
+		return 0, err
+	}
+	return len(b) + metadataPaddingOverhead, nil
+}
+
+// encodeMetadata 编码 onMetaData，并通过填充属性使数据大小恰好为 size
+func encodeMetadata(props AMFECMAArray, size int) ([]byte, error) {
+	b, err := EncodeAMF("onMetaData", props)
+	if err != nil {
+		return nil, err
+	}
+	n := size - len(b) - metadataPaddingOverhead
+	if n < 0 || n > math.MaxUint16 {
+		return nil, fmt.Errorf("metadata size %d does not fit in reserved %d bytes", len(b), size)
+	}
+	b, err = EncodeAMF("onMetaData", append(props, AMFProperty{Key: metadataPaddingKey, Value: strings.Repeat(" ", n)}))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) != size {
+		return nil, fmt.Errorf("metadata size %d does not match reserved %d bytes", len(b), size)
+	}
+	return b, nil
+}
+
+// stripGeneratedMetadata 去掉 onMetaData 中的时长与关键帧索引，用于重新封装后位置已经失效的情况
+func stripGeneratedMetadata(tag *Tag) *Tag {
+	values, _ := DecodeAMF(tag.Data)
+	if len(values) < 2 {
+		return tag
+	}
+	if name, _ := values[0].(string); name != "onMetaData" {
+		return tag
+	}
+	data, err := EncodeAMF("onMetaData", baseMetadata(tag))
+	if err != nil {
+		return tag
+	}
+	return &Tag{Type: tag.Type, Timestamp: tag.Timestamp, StreamID: tag.StreamID, Data: data}
+}
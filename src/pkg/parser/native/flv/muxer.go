@@ -0,0 +1,217 @@
+package flv
+
+import (
+	"bytes"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/bililive-go/bililive-go/src/pkg/parser"
+)
+
+// muxer 将直播流的 tag 写入文件，达到分段条件后在下一个视频关键帧处切换到新文件。
+// 新文件会重新写入 FLV 文件头、onMetaData 与 sequence header，时间戳从 0 开始，可以单独播放。
+// 文件开头预留 onMetaData 的空间，结束或切换文件时写入时长、文件大小与关键帧索引，使文件可以拖动播放。
+type muxer struct {
+	header *Header
+	// 最近的 onMetaData 与 sequence header，切换文件时重新写入
+	metadata  *Tag
+	avcHeader *Tag
+	aacHeader *Tag
+
+	file string
+	out  *os.File
+	w    *TagWriter
+
+	maxSize     int64
+	maxDuration time.Duration
+	onSplit     parser.SplitHandler
+	// written 所有文件已写入的字节数
+	written *atomic.Int64
+
+	// frames 当前文件中的音视频帧数
+	frames int
+	// baseTs 当前文件第一帧的原始时间戳
+	baseTs int64
+	// hasVideo 直播流中是否有视频帧，纯音频流在任意音频帧处切换
+	hasVideo bool
+	// pendingSplit 已达到分段条件，等待下一个关键帧
+	pendingSplit bool
+
+	// 当前文件预留的 onMetaData
+	metaBase   AMFECMAArray
+	metaSize   int
+	metaOffset int64
+	keyframes  []keyframe
+	lastTs     uint32
+}
+
+func (m *muxer) open(file string) error {
+	f, err := os.OpenFile(file, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	m.file, m.out, m.w = file, f, NewTagWriter(f)
+	m.frames, m.pendingSplit = 0, false
+	m.metaSize, m.keyframes, m.lastTs = 0, nil, 0
+	if err := m.write(func() error { return m.w.WriteHeader(m.header) }); err != nil {
+		return err
+	}
+	// 第一个文件在收到直播流的 onMetaData 或第一个音视频 tag 时才写入 onMetaData
+	if m.metadata == nil && m.avcHeader == nil && m.aacHeader == nil {
+		return nil
+	}
+	if err := m.writeMetadata(); err != nil {
+		return err
+	}
+	for _, tag := range []*Tag{m.avcHeader, m.aacHeader} {
+		if tag == nil {
+			continue
+		}
+		if err := m.writeTag(&Tag{Type: tag.Type, StreamID: tag.StreamID, Data: tag.Data}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeMetadata 写入预留了关键帧索引空间的 onMetaData
+func (m *muxer) writeMetadata() error {
+	m.metaBase = baseMetadata(m.metadata)
+	size, err := reservedMetadataSize(m.metaBase)
+	if err != nil {
+		return err
+	}
+	data, err := encodeMetadata(buildMetadata(m.metaBase, 0, 0, nil), size)
+	if err != nil {
+		return err
+	}
+	m.metaSize, m.metaOffset = size, m.w.Size()+tagHeaderSize
+	return m.writeTag(&Tag{Type: scriptTag, Data: data})
+}
+
+// finalize 用实际的时长、文件大小与关键帧索引覆盖预留的 onMetaData
+func (m *muxer) finalize() error {
+	if m.out == nil || m.metaSize == 0 {
+		return nil
+	}
+	data, err := encodeMetadata(buildMetadata(m.metaBase, m.lastTs, m.w.Size(), m.keyframes), m.metaSize)
+	if err != nil {
+		return err
+	}
+	_, err = m.out.WriteAt(data, m.metaOffset)
+	return err
+}
+
+func (m *muxer) write(fn func() error) error {
+	before := m.w.Size()
+	err := fn()
+	m.written.Add(m.w.Size() - before)
+	return err
+}
+
+func (m *muxer) writeTag(tag *Tag) error {
+	return m.write(func() error { return m.w.WriteTag(tag) })
+}
+
+// rebase 将时间戳转换为相对当前文件第一帧的时间戳
+func (m *muxer) rebase(tag *Tag) {
+	ts := int64(tag.Timestamp) - m.baseTs
+	if m.frames == 0 || ts < 0 {
+		ts = 0
+	}
+	tag.Timestamp = uint32(ts)
+}
+
+// split 关闭当前文件，并通过 onSplit 获取下一个文件名
+func (m *muxer) split() error {
+	finished := m.file
+	if err := m.Close(); err != nil {
+		return err
+	}
+	next, err := m.onSplit(finished)
+	if err != nil {
+		return err
+	}
+	return m.open(next)
+}
+
+// updateSeqHeader 缓存 sequence header；内容变化且当前文件已经有数据时切换到新文件
+func (m *muxer) updateSeqHeader(current **Tag, tag *Tag) error {
+	if *current != nil && bytes.Equal((*current).Data, tag.Data) {
+		return nil
+	}
+	*current = tag
+	if m.frames > 0 && m.onSplit != nil {
+		// 新文件开头会写入新的 sequence header
+		return m.split()
+	}
+	if m.metaSize == 0 {
+		if err := m.writeMetadata(); err != nil {
+			return err
+		}
+	}
+	m.rebase(tag)
+	return m.writeTag(tag)
+}
+
+func (m *muxer) WriteTag(tag *Tag) error {
+	switch {
+	case tag.IsAVCSeqHeader():
+		return m.updateSeqHeader(&m.avcHeader, tag)
+	case tag.IsAACSeqHeader():
+		return m.updateSeqHeader(&m.aacHeader, tag)
+	case tag.IsScript():
+		// 直播流中途的 script tag 不写入文件，只用于之后的分段
+		m.metadata = tag
+		if m.metaSize == 0 {
+			return m.writeMetadata()
+		}
+		return nil
+	}
+
+	if tag.IsVideo() {
+		m.hasVideo = true
+	}
+	if m.pendingSplit && (tag.IsKeyFrame() || (!m.hasVideo && tag.IsAudio())) {
+		if err := m.split(); err != nil {
+			return err
+		}
+	}
+	if m.metaSize == 0 {
+		if err := m.writeMetadata(); err != nil {
+			return err
+		}
+	}
+	if m.frames == 0 {
+		m.baseTs = int64(tag.Timestamp)
+	}
+	m.rebase(tag)
+	if tag.IsKeyFrame() {
+		m.keyframes = append(m.keyframes, keyframe{time: float64(tag.Timestamp) / 1000, position: m.w.Size()})
+	}
+	if err := m.writeTag(tag); err != nil {
+		return err
+	}
+	m.frames++
+	if tag.Timestamp > m.lastTs {
+		m.lastTs = tag.Timestamp
+	}
+	if m.onSplit != nil && !m.pendingSplit {
+		m.pendingSplit = (m.maxSize > 0 && m.w.Size() >= m.maxSize) ||
+			(m.maxDuration > 0 && time.Duration(tag.Timestamp)*time.Millisecond >= m.maxDuration)
+	}
+	return nil
+}
+
+func (m *muxer) Close() error {
+	if m.out == nil {
+		return nil
+	}
+	err := m.finalize()
+	if closeErr := m.out.Close(); err == nil {
+		err = closeErr
+	}
+	m.out = nil
+	return err
+}
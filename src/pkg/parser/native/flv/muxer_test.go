@@ -0,0 +1,258 @@
+package flv
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bililive-go/bililive-go/src/live"
+	"github.com/bililive-go/bililive-go/src/pkg/parser"
+)
+
+// splitRecorder 记录分段回调，并按序号生成新的文件名
+type splitRecorder struct {
+	dir      string
+	finished []string
+}
+
+func (s *splitRecorder) handler(finished string) (string, error) {
+	s.finished = append(s.finished, finished)
+	return filepath.Join(s.dir, fmt.Sprintf("%d.flv", len(s.finished))), nil
+}
+
+func readFlvFile(t *testing.T, file string) []*Tag {
+	b, err := os.ReadFile(file)
+	assert.NoError(t, err)
+	return readFlv(t, b)
+}
+
+// liveStream 生成 n 秒的直播流，每秒一个关键帧，每 200ms 一帧视频和一帧音频
+func liveStream(start uint32, seconds int) []*Tag {
+	tags := []*Tag{
+		{Type: scriptTag, Timestamp: start, Data: []byte{2, 0, 10}},
+		avcSeqHeader(1),
+		aacSeqHeader(),
+	}
+	for i := 0; i < seconds*5; i++ {
+		ts := start + uint32(i*200)
+		tags = append(tags, videoFrame(ts, i%5 == 0, byte(i)), audioFrame(ts+10, byte(i)))
+	}
+	return tags
+}
+
+func writeTags(t *testing.T, m *muxer, tags []*Tag) {
+	for _, tag := range tags {
+		cp := *tag
+		assert.NoError(t, m.WriteTag(&cp))
+	}
+	assert.NoError(t, m.Close())
+}
+
+// assertPlayable 检查文件以 onMetaData 与 sequence header 开头，第一帧是时间戳为 0 的关键帧
+func assertPlayable(t *testing.T, tags []*Tag) {
+	if !assert.True(t, len(tags) > 4) {
+		return
+	}
+	assert.True(t, tags[0].IsScript())
+	assert.True(t, tags[1].IsAVCSeqHeader())
+	assert.True(t, tags[2].IsAACSeqHeader())
+	assert.True(t, tags[3].IsKeyFrame())
+	assert.Equal(t, uint32(0), tags[3].Timestamp)
+}
+
+func newTestMuxer(t *testing.T, s *splitRecorder) *muxer {
+	m := &muxer{
+		header:  &Header{HasVideo: true, HasAudio: true},
+		onSplit: s.handler,
+		written: new(atomic.Int64),
+	}
+	assert.NoError(t, m.open(filepath.Join(s.dir, "0.flv")))
+	return m
+}
+
+func TestMuxerSplitByDuration(t *testing.T) {
+	s := &splitRecorder{dir: t.TempDir()}
+	m := newTestMuxer(t, s)
+	m.maxDuration = 2 * time.Second
+	writeTags(t, m, liveStream(100000, 5))
+
+	// 在达到 2s 后的下一个关键帧（3s）处切换
+	assert.Equal(t, []string{filepath.Join(s.dir, "0.flv")}, s.finished)
+	first := readFlvFile(t, filepath.Join(s.dir, "0.flv"))
+	assertPlayable(t, first)
+	assert.Len(t, timestamps(first, videoTag), 15)
+	assert.Equal(t, uint32(2800), timestamps(first, videoTag)[14])
+	second := readFlvFile(t, filepath.Join(s.dir, "1.flv"))
+	assertPlayable(t, second)
+	assert.Len(t, timestamps(second, videoTag), 10)
+	assert.Equal(t, uint32(1800), timestamps(second, videoTag)[9])
+
+	var total int64
+	for i := 0; i < 2; i++ {
+		stat, err := os.Stat(filepath.Join(s.dir, fmt.Sprintf("%d.flv", i)))
+		assert.NoError(t, err)
+		total += stat.Size()
+	}
+	assert.Equal(t, total, m.written.Load())
+}
+
+func TestMuxerSplitBySize(t *testing.T) {
+	s := &splitRecorder{dir: t.TempDir()}
+	m := newTestMuxer(t, s)
+	m.maxSize = 100
+	writeTags(t, m, liveStream(0, 3))
+	assert.Len(t, s.finished, 2)
+	for i := 0; i < 3; i++ {
+		tags := readFlvFile(t, filepath.Join(s.dir, fmt.Sprintf("%d.flv", i)))
+		assertPlayable(t, tags)
+		assert.Len(t, timestamps(tags, videoTag), 5)
+	}
+
+	// 没有设置回调时不分段
+	dir := t.TempDir()
+	m = &muxer{header: &Header{HasVideo: true, HasAudio: true}, maxSize: 100, written: new(atomic.Int64)}
+	assert.NoError(t, m.open(filepath.Join(dir, "0.flv")))
+	writeTags(t, m, liveStream(0, 3))
+	assert.Len(t, timestamps(readFlvFile(t, filepath.Join(dir, "0.flv")), videoTag), 15)
+}
+
+func TestMuxerSplitOnSeqHeaderChange(t *testing.T) {
+	s := &splitRecorder{dir: t.TempDir()}
+	m := newTestMuxer(t, s)
+	tags := liveStream(0, 1)
+	tags = append(tags, avcSeqHeader(1), avcSeqHeader(2), videoFrame(1000, true, 10), audioFrame(1010, 10))
+	writeTags(t, m, tags)
+	assert.Len(t, s.finished, 1)
+
+	first := readFlvFile(t, filepath.Join(s.dir, "0.flv"))
+	assert.Equal(t, 1, countSeqHeaders(first))
+	second := readFlvFile(t, filepath.Join(s.dir, "1.flv"))
+	assertPlayable(t, second)
+	assert.Equal(t, byte(2), second[1].Data[5])
+	assert.Equal(t, []uint32{10}, timestamps(second, audioTag))
+}
+
+func countSeqHeaders(tags []*Tag) int {
+	n := 0
+	for _, tag := range tags {
+		if tag.IsAVCSeqHeader() {
+			n++
+		}
+	}
+	return n
+}
+
+func TestParseLiveStreamSplit(t *testing.T) {
+	stream := buildFlv(t, liveStream(0, 4)...)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(stream)
+	}))
+	defer server.Close()
+
+	p, err := parser.New(Name, map[string]string{"max_duration": "1s"})
+	assert.NoError(t, err)
+	s := &splitRecorder{dir: t.TempDir()}
+	p.(parser.SplittableParser).SetSplitHandler(s.handler)
+	u, _ := url.Parse(server.URL + "/live.flv")
+	err = p.ParseLiveStream(context.Background(), &live.StreamUrlInfo{Url: u}, nil, filepath.Join(s.dir, "0.flv"))
+	assert.ErrorIs(t, err, io.EOF)
+	// 1s 时标记分段，在 2s 的关键帧处切换
+	assert.Len(t, s.finished, 1)
+	for i := 0; i < 2; i++ {
+		assertPlayable(t, readFlvFile(t, filepath.Join(s.dir, fmt.Sprintf("%d.flv", i))))
+	}
+	status, _ := p.(parser.StatusParser).Status()
+	assert.Equal(t, "43", status["tag_count"])
+}
+
+// decodeMetadata 解码文件开头的 onMetaData
+func decodeMetadata(t *testing.T, tag *Tag) AMFECMAArray {
+	values, err := DecodeAMF(tag.Data)
+	assert.NoError(t, err)
+	if !assert.Len(t, values, 2) {
+		return nil
+	}
+	assert.Equal(t, "onMetaData", values[0])
+	return values[1].(AMFECMAArray)
+}
+
+func TestMuxerMetadata(t *testing.T) {
+	s := &splitRecorder{dir: t.TempDir()}
+	m := newTestMuxer(t, s)
+	m.maxDuration = 2 * time.Second
+	tags := liveStream(0, 5)
+	tags[0].Data, _ = EncodeAMF("onMetaData", AMFECMAArray{
+		{Key: "width", Value: 1920.0},
+		{Key: "duration", Value: 0.0},
+	})
+	// 中途的 script tag 不写入文件
+	tags = append(tags, &Tag{Type: scriptTag, Timestamp: 5000, Data: tags[0].Data})
+	writeTags(t, m, tags)
+
+	for i, want := range []struct {
+		duration  float64
+		keyframes int
+	}{{2.81, 3}, {1.81, 2}} {
+		file := filepath.Join(s.dir, fmt.Sprintf("%d.flv", i))
+		b, err := os.ReadFile(file)
+		assert.NoError(t, err)
+		tags := readFlv(t, b)
+		assertPlayable(t, tags)
+		for _, tag := range tags[1:] {
+			assert.False(t, tag.IsScript())
+		}
+
+		meta := decodeMetadata(t, tags[0])
+		width, _ := meta.Get("width")
+		assert.Equal(t, 1920.0, width)
+		duration, _ := meta.Get("duration")
+		assert.Equal(t, want.duration, duration)
+		fileSize, _ := meta.Get("filesize")
+		assert.Equal(t, float64(len(b)), fileSize)
+
+		v, _ := meta.Get("keyframes")
+		index := v.(AMFObject)
+		times, _ := index.Get("times")
+		positions, _ := index.Get("filepositions")
+		if !assert.Len(t, positions, want.keyframes) {
+			continue
+		}
+		for j, pos := range positions.([]any) {
+			// 关键帧索引指向关键帧 tag 的开头
+			r := NewTagReader(bytes.NewReader(b[int(pos.(float64)):]))
+			tag, err := r.ReadTag()
+			assert.NoError(t, err)
+			assert.True(t, tag.IsKeyFrame())
+			assert.Equal(t, float64(tag.Timestamp)/1000, times.([]any)[j])
+		}
+	}
+}
+
+func TestEncodeMetadataSize(t *testing.T) {
+	base := AMFECMAArray{{Key: "width", Value: 1280.0}}
+	size, err := reservedMetadataSize(base)
+	assert.NoError(t, err)
+	keyframes := make([]keyframe, maxIndexedKeyframes*2+1)
+	for i := range keyframes {
+		keyframes[i] = keyframe{time: float64(i), position: int64(i) * 1000}
+	}
+	for _, n := range []int{0, 1, maxIndexedKeyframes, len(keyframes)} {
+		b, err := encodeMetadata(buildMetadata(base, 123456, 1<<40, keyframes[:n]), size)
+		assert.NoError(t, err)
+		assert.Len(t, b, size)
+	}
+	sampled := sampleKeyframes(keyframes)
+	assert.Equal(t, maxIndexedKeyframes, len(sampled))
+	assert.Equal(t, keyframes[0], sampled[0])
+}
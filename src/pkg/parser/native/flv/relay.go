@@ -0,0 +1,189 @@
+package flv
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+)
+
+// relayBufferSize 每个观看者最多积压的 tag 数，超过后断开该观看者，不阻塞录制
+const relayBufferSize = 1024
+
+var ErrRelayTooSlow = errors.New("relay client is too slow")
+
+// relay 将正在录制的直播流转发给观看者，不重新请求直播源。
+// 新的观看者先收到 FLV 文件头、onMetaData 与 sequence header，之后从下一个关键帧开始接收，时间戳从 0 开始
+type relay struct {
+	lock sync.Mutex
+	// ready 收到直播流的文件头后关闭
+	ready  chan struct{}
+	done   chan struct{}
+	header *Header
+	// 最近的 onMetaData 与 sequence header
+	metadata  *Tag
+	avcHeader *Tag
+	aacHeader *Tag
+	hasVideo  bool
+
+	subscribers map[*relaySubscriber]struct{}
+	closeOnce   sync.Once
+}
+
+type relaySubscriber struct {
+	ch  chan *Tag
+	err error
+}
+
+func newRelay() *relay {
+	return &relay{
+		ready:       make(chan struct{}),
+		done:        make(chan struct{}),
+		subscribers: make(map[*relaySubscriber]struct{}),
+	}
+}
+
+func (r *relay) setHeader(header *Header) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.header == nil {
+		close(r.ready)
+	}
+	r.header = header
+}
+
+// publish 转发一个 tag，tag 的内容之后不能再被修改
+func (r *relay) publish(tag *Tag) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	switch {
+	case tag.IsAVCSeqHeader():
+		r.avcHeader = tag
+	case tag.IsAACSeqHeader():
+		r.aacHeader = tag
+	case tag.IsScript():
+		r.metadata = tag
+	case tag.IsVideo():
+		r.hasVideo = true
+	}
+	for s := range r.subscribers {
+		select {
+		case s.ch <- tag:
+		default:
+			s.err = ErrRelayTooSlow
+			r.unsubscribeLocked(s)
+		}
+	}
+}
+
+func (r *relay) unsubscribeLocked(s *relaySubscriber) {
+	if _, ok := r.subscribers[s]; ok {
+		delete(r.subscribers, s)
+		close(s.ch)
+	}
+}
+
+func (r *relay) close() {
+	r.closeOnce.Do(func() {
+		r.lock.Lock()
+		defer r.lock.Unlock()
+		close(r.done)
+		for s := range r.subscribers {
+			r.unsubscribeLocked(s)
+		}
+	})
+}
+
+// clients 当前的观看者数
+func (r *relay) clients() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return len(r.subscribers)
+}
+
+// subscribe 注册观看者，返回需要先发送的 tag
+func (r *relay) subscribe() (*relaySubscriber, []*Tag, *Header, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	select {
+	case <-r.done:
+		return nil, nil, nil, false
+	default:
+	}
+	s := &relaySubscriber{ch: make(chan *Tag, relayBufferSize)}
+	r.subscribers[s] = struct{}{}
+	initial := make([]*Tag, 0, 3)
+	for _, tag := range []*Tag{r.metadata, r.avcHeader, r.aacHeader} {
+		if tag != nil {
+			initial = append(initial, &Tag{Type: tag.Type, StreamID: tag.StreamID, Data: tag.Data})
+		}
+	}
+	return s, initial, r.header, r.hasVideo || r.header.HasVideo
+}
+
+// Relay 将直播流写入 w，直到 ctx 结束、录制停止或写入失败；录制停止时返回 nil
+func (r *relay) Relay(ctx context.Context, w io.Writer) error {
+	select {
+	case <-r.ready:
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	s, initial, header, hasVideo := r.subscribe()
+	if s == nil {
+		return nil
+	}
+	defer func() {
+		r.lock.Lock()
+		r.unsubscribeLocked(s)
+		r.lock.Unlock()
+	}()
+
+	tw := NewTagWriter(w)
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	for _, tag := range initial {
+		if err := tw.WriteTag(tag); err != nil {
+			return err
+		}
+	}
+	started := false
+	var baseTs uint32
+	for {
+		var tag *Tag
+		var ok bool
+		select {
+		case tag, ok = <-s.ch:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if !ok {
+			r.lock.Lock()
+			err := s.err
+			r.lock.Unlock()
+			return err
+		}
+		if tag.IsVideo() {
+			hasVideo = true
+		}
+		isHeader := tag.IsAVCSeqHeader() || tag.IsAACSeqHeader() || tag.IsScript()
+		if !started {
+			// sequence header 与 onMetaData 在开始前也需要转发，其他帧等到关键帧（纯音频流为任意音频帧）
+			if !isHeader && !tag.IsKeyFrame() && (hasVideo || !tag.IsAudio()) {
+				continue
+			}
+			if !isHeader {
+				started, baseTs = true, tag.Timestamp
+			}
+		}
+		out := &Tag{Type: tag.Type, StreamID: tag.StreamID, Data: tag.Data}
+		if started && tag.Timestamp > baseTs {
+			out.Timestamp = tag.Timestamp - baseTs
+		}
+		if err := tw.WriteTag(out); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package flv
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func startRelay(r *relay, w io.Writer) chan error {
+	done := make(chan error, 1)
+	go func() { done <- r.Relay(context.Background(), w) }()
+	return done
+}
+
+func waitClients(t *testing.T, r *relay, n int) {
+	assert.Eventually(t, func() bool { return r.clients() == n }, time.Second, time.Millisecond)
+}
+
+func TestRelay(t *testing.T) {
+	r := newRelay()
+	// 收到直播流之前就结束时不输出任何内容
+	closed := newRelay()
+	buf := new(bytes.Buffer)
+	done := startRelay(closed, buf)
+	closed.close()
+	assert.NoError(t, <-done)
+	assert.Zero(t, buf.Len())
+
+	r.setHeader(&Header{HasVideo: true, HasAudio: true})
+	stream := liveStream(1000, 2)
+	// 观看者在第一个 GOP 的中间加入
+	for _, tag := range stream[:8] {
+		r.publish(tag)
+	}
+	buf = new(bytes.Buffer)
+	done = startRelay(r, buf)
+	waitClients(t, r, 1)
+	for _, tag := range stream[8:] {
+		r.publish(tag)
+	}
+	r.close()
+	assert.NoError(t, <-done)
+	assert.Equal(t, 0, r.clients())
+
+	tags := readFlv(t, buf.Bytes())
+	assertPlayable(t, tags)
+	// 从第二个关键帧（原始时间戳 2000）开始，之后是 5 帧视频和 5 帧音频
+	if assert.Len(t, tags, 3+10) {
+		assert.Equal(t, stream[13].Data, tags[3].Data)
+		assert.Equal(t, uint32(10), tags[4].Timestamp)
+		assert.Equal(t, uint32(810), tags[12].Timestamp)
+	}
+}
+
+// blockingWriter 在 release 关闭之前阻塞写入
+type blockingWriter struct {
+	release chan struct{}
+}
+
+func (w *blockingWriter) Write(b []byte) (int, error) {
+	<-w.release
+	return len(b), nil
+}
+
+func TestRelayTooSlow(t *testing.T) {
+	r := newRelay()
+	r.setHeader(&Header{HasVideo: true, HasAudio: true})
+	w := &blockingWriter{release: make(chan struct{})}
+	done := startRelay(r, w)
+	waitClients(t, r, 1)
+	// 观看者来不及接收时断开，录制不会被阻塞
+	for i := 0; i <= relayBufferSize; i++ {
+		r.publish(audioFrame(uint32(i), 0))
+	}
+	assert.Equal(t, 0, r.clients())
+	close(w.release)
+	assert.ErrorIs(t, <-done, ErrRelayTooSlow)
+}
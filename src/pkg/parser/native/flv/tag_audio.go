@@ -1,7 +1,5 @@
 package flv
 
-import "context"
-
 type (
 	SoundFormat   uint8
 	SoundRate     uint8
@@ -46,38 +44,3 @@ const (
 	AACSeqHeader AACPacketType = 0
 	AACRaw       AACPacketType = 1
 )
-
-func (p *Parser) parseAudioTag(ctx context.Context, length, timestamp uint32) (*AudioTagHeader, error) {
-	b, err := p.i.ReadByte()
-	l := length - 1
-	if err != nil {
-		return nil, err
-	}
-	tag := new(AudioTagHeader)
-
-	tag.SoundFormat = SoundFormat(b >> 4 & 15)
-	tag.SoundRate = SoundRate(b >> 2 & 3)
-	tag.SoundSize = SoundSize(b >> 1 & 1)
-	tag.SoundType = SoundType(b & 1)
-
-	if tag.SoundFormat == AAC {
-		b, err := p.i.ReadByte()
-		l -= 1
-		if err != nil {
-			return nil, err
-		}
-		tag.AACPacketType = AACPacketType(b)
-	}
-
-	// write tag header && audio tag header & AACPacketType
-	if err := p.doWrite(ctx, p.i.AllBytes()); err != nil {
-		return nil, err
-	}
-	p.i.Reset()
-	// write body
-	if err := p.doCopy(ctx, l); err != nil {
-		return nil, err
-	}
-
-	return tag, nil
-}
@@ -0,0 +1,169 @@
+package flv
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+const (
+	headerSize    = 9
+	tagHeaderSize = 11
+	prevSizeSize  = 4
+)
+
+// Header FLV 文件头
+type Header struct {
+	HasVideo, HasAudio bool
+}
+
+// Tag 一个完整的 FLV tag，Data 不包含 tag header 与 PreviousTagSize
+type Tag struct {
+	Type      uint8
+	Timestamp uint32
+	StreamID  uint32
+	Data      []byte
+}
+
+func (t *Tag) IsVideo() bool  { return t.Type == videoTag }
+func (t *Tag) IsAudio() bool  { return t.Type == audioTag }
+func (t *Tag) IsScript() bool { return t.Type == scriptTag }
+
+// IsAVC 是否为 H.264 视频 tag
+func (t *Tag) IsAVC() bool {
+	return t.IsVideo() && len(t.Data) >= 2 && CodeID(t.Data[0]&15) == AVCCode
+}
+
+// IsAAC 是否为 AAC 音频 tag
+func (t *Tag) IsAAC() bool {
+	return t.IsAudio() && len(t.Data) >= 2 && SoundFormat(t.Data[0]>>4&15) == AAC
+}
+
+// IsAVCSeqHeader 是否为 AVC sequence header（SPS/PPS）
+func (t *Tag) IsAVCSeqHeader() bool {
+	return t.IsAVC() && AVCPacketType(t.Data[1]) == AVCSeqHeader
+}
+
+// IsAACSeqHeader 是否为 AAC sequence header（AudioSpecificConfig）
+func (t *Tag) IsAACSeqHeader() bool {
+	return t.IsAAC() && AACPacketType(t.Data[1]) == AACSeqHeader
+}
+
+// IsKeyFrame 是否为视频关键帧（不包括 sequence header）
+func (t *Tag) IsKeyFrame() bool {
+	return t.IsVideo() && len(t.Data) >= 1 && FrameType(t.Data[0]>>4&15) == KeyFrame && !t.IsAVCSeqHeader()
+}
+
+// TagReader 按 tag 读取 FLV 数据
+type TagReader struct {
+	r   *bufio.Reader
+	buf [tagHeaderSize]byte
+}
+
+func NewTagReader(r io.Reader) *TagReader {
+	return &TagReader{r: bufio.NewReader(r)}
+}
+
+// ReadHeader 读取 FLV 文件头及第一个 PreviousTagSize
+func (r *TagReader) ReadHeader() (*Header, error) {
+	b := make([]byte, headerSize+prevSizeSize)
+	if _, err := io.ReadFull(r.r, b); err != nil {
+		return nil, ErrNotFlvStream
+	}
+	if !bytes.Equal(b[:4], flvSign) || binary.BigEndian.Uint32(b[5:9]) != headerSize {
+		return nil, ErrNotFlvStream
+	}
+	return &Header{
+		HasVideo: b[4]&(1<<2) != 0,
+		HasAudio: b[4]&1 != 0,
+	}, nil
+}
+
+// ReadTag 读取下一个 tag。数据正常结束时返回 io.EOF，tag 不完整时返回 io.ErrUnexpectedEOF；
+// 缺少最后的 PreviousTagSize 不视为错误。
+func (r *TagReader) ReadTag() (*Tag, error) {
+	b := r.buf[:]
+	if n, err := io.ReadFull(r.r, b); err != nil {
+		if n == 0 && errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	tag := &Tag{
+		Type:      b[0] & 0x1f,
+		Timestamp: uint32(b[4])<<16 | uint32(b[5])<<8 | uint32(b[6]) | uint32(b[7])<<24,
+		StreamID:  uint32(b[8])<<16 | uint32(b[9])<<8 | uint32(b[10]),
+	}
+	switch tag.Type {
+	case audioTag, videoTag, scriptTag:
+	default:
+		return nil, ErrUnknownTag
+	}
+	tag.Data = make([]byte, uint32(b[1])<<16|uint32(b[2])<<8|uint32(b[3]))
+	if _, err := io.ReadFull(r.r, tag.Data); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if _, err := io.ReadFull(r.r, b[:prevSizeSize]); err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+	return tag, nil
+}
+
+// TagWriter 按 tag 写入 FLV 数据，并维护 PreviousTagSize
+type TagWriter struct {
+	w    io.Writer
+	size int64
+}
+
+func NewTagWriter(w io.Writer) *TagWriter {
+	return &TagWriter{w: w}
+}
+
+func (w *TagWriter) write(b []byte) error {
+	n, err := w.w.Write(b)
+	w.size += int64(n)
+	if err == nil && n != len(b) {
+		err = io.ErrShortWrite
+	}
+	return err
+}
+
+// WriteHeader 写入 FLV 文件头及第一个 PreviousTagSize
+func (w *TagWriter) WriteHeader(h *Header) error {
+	b := make([]byte, headerSize+prevSizeSize)
+	copy(b, flvSign)
+	if h.HasVideo {
+		b[4] |= 1 << 2
+	}
+	if h.HasAudio {
+		b[4] |= 1
+	}
+	binary.BigEndian.PutUint32(b[5:9], headerSize)
+	return w.write(b)
+}
+
+// WriteTag 写入一个 tag 及其 PreviousTagSize
+func (w *TagWriter) WriteTag(t *Tag) error {
+	l := len(t.Data)
+	b := make([]byte, tagHeaderSize, tagHeaderSize+l+prevSizeSize)
+	b[0] = t.Type
+	b[1], b[2], b[3] = byte(l>>16), byte(l>>8), byte(l)
+	b[4], b[5], b[6], b[7] = byte(t.Timestamp>>16), byte(t.Timestamp>>8), byte(t.Timestamp), byte(t.Timestamp>>24)
+	b[8], b[9], b[10] = byte(t.StreamID>>16), byte(t.StreamID>>8), byte(t.StreamID)
+	b = append(b, t.Data...)
+	b = binary.BigEndian.AppendUint32(b, uint32(tagHeaderSize+l))
+	return w.write(b)
+}
+
+// Size 已写入的字节数
+func (w *TagWriter) Size() int64 {
+	return w.size
+}
@@ -1,7 +1,5 @@
 package flv
 
-import "context"
-
 type DataType uint8
 
 const (
@@ -18,17 +16,3 @@ const (
 	Date            DataType = 11
 	LongString      DataType = 12
 )
-
-func (p *Parser) parseScriptTag(ctx context.Context, length uint32) error {
-	// TODO: parse script tag content
-	// write tag header
-	if err := p.doWrite(ctx, p.i.AllBytes()); err != nil {
-		return err
-	}
-	p.i.Reset()
-	// write body
-	if err := p.doCopy(ctx, length); err != nil {
-		return err
-	}
-	return nil
-}
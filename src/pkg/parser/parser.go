@@ -4,6 +4,7 @@ package parser
 import (
 	"context"
 	"errors"
+	"io"
 
 	"github.com/bililive-go/bililive-go/src/live"
 )
@@ -22,6 +23,22 @@ type StatusParser interface {
 	Status() (map[string]string, error)
 }
 
+// SplitHandler 在上一个文件写入完成并关闭后调用，返回接下来写入的文件名
+type SplitHandler func(finishedFile string) (nextFile string, err error)
+
+// SplittableParser 可以按分段策略在同一个直播流中切换到新文件的 parser
+type SplittableParser interface {
+	Parser
+	SetSplitHandler(handler SplitHandler)
+}
+
+// RelayParser 可以将正在录制的直播流转发给其他客户端的 parser
+type RelayParser interface {
+	Parser
+	// Relay 将直播流写入 w，直到 ctx 结束、录制停止或写入失败
+	Relay(ctx context.Context, w io.Writer) error
+}
+
 var m = make(map[string]Builder)
 
 func Register(name string, b Builder) {
@@ -0,0 +1,235 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bililive-go/bililive-go/src/configs"
+)
+
+// 配置中未设置时使用的退避时长
+const (
+	defaultBackoff    = 30 * time.Second
+	defaultMaxBackoff = 10 * time.Minute
+)
+
+// Default 所有直播间共享的限流器，使用当前配置中的 rate_limit
+var Default = New(func() *configs.RateLimit {
+	if cfg := configs.GetCurrentConfig(); cfg != nil {
+		return &cfg.RateLimit
+	}
+	return nil
+})
+
+// ThrottledError 域名被平台限流，正处于退避中，请求没有发出
+type ThrottledError struct {
+	Domain string
+	Until  time.Time
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("requests to %s are throttled until %s", e.Domain, e.Until.Format(time.DateTime))
+}
+
+// Status 一个域名当前的限流状态
+type Status struct {
+	Domain string
+	QPS    float64
+	// Throttled 是否处于退避中，Until 为退避结束的时间
+	Throttled bool
+	Until     time.Time
+	// Backoff 最近一次退避的时长，请求成功后重置为 0
+	Backoff time.Duration
+	// ThrottledTotal 被平台限流的次数
+	ThrottledTotal int64
+}
+
+// domain 一个令牌桶与它的退避状态
+type domain struct {
+	name   string
+	qps    float64
+	tokens float64
+	last   time.Time
+
+	backoff        time.Duration
+	until          time.Time
+	throttledTotal int64
+}
+
+// reserve 取出一个令牌，返回需要等待的时长
+func (d *domain) reserve(now time.Time) time.Duration {
+	if d.qps <= 0 {
+		return 0
+	}
+	// 允许的突发请求数与每秒请求数相同，至少为 1
+	burst := math.Max(1, d.qps)
+	if d.last.IsZero() {
+		d.tokens = burst
+	} else {
+		d.tokens = math.Min(burst, d.tokens+now.Sub(d.last).Seconds()*d.qps)
+	}
+	d.last = now
+	d.tokens--
+	if d.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-d.tokens / d.qps * float64(time.Second))
+}
+
+// Limiter 按域名限制请求频率，被平台限流后指数退避，退避期间的请求直接返回 ThrottledError
+type Limiter struct {
+	config func() *configs.RateLimit
+
+	lock    sync.Mutex
+	cfg     *configs.RateLimit
+	domains map[string]*domain
+	// bodyThrottling 在响应内容中返回限流错误的 host
+	bodyThrottling map[string]bool
+
+	// for test
+	now func() time.Time
+}
+
+// New 创建限流器，每次请求时通过 config 读取配置，配置变化后立即生效
+func New(config func() *configs.RateLimit) *Limiter {
+	return &Limiter{
+		config:         config,
+		domains:        make(map[string]*domain),
+		bodyThrottling: make(map[string]bool),
+		now:            time.Now,
+	}
+}
+
+// match 返回 host 所属的域名与每秒请求数，domains 中最长的匹配项优先，没有匹配时使用 host 本身
+func match(cfg *configs.RateLimit, host string) (string, float64) {
+	if cfg == nil {
+		return host, 0
+	}
+	name, qps := host, cfg.DefaultQPS
+	matched := ""
+	for d, v := range cfg.Domains {
+		if (host == d || strings.HasSuffix(host, "."+d)) && len(d) > len(matched) {
+			matched, name, qps = d, d, v
+		}
+	}
+	return name, qps
+}
+
+// getDomain 获取 host 对应的令牌桶，需要持有 lock
+func (l *Limiter) getDomain(host string) *domain {
+	if cfg := l.config(); cfg != l.cfg {
+		// 配置被重新加载，更新已有令牌桶的速率，退避状态保持不变
+		l.cfg = cfg
+		for _, d := range l.domains {
+			_, d.qps = match(cfg, d.name)
+		}
+	}
+	name, qps := match(l.cfg, host)
+	d, ok := l.domains[name]
+	if !ok {
+		d = &domain{name: name, qps: qps}
+		l.domains[name] = d
+	}
+	return d
+}
+
+// Wait 等待 host 的令牌，host 处于退避中时返回 *ThrottledError
+func (l *Limiter) Wait(ctx context.Context, host string) error {
+	l.lock.Lock()
+	d := l.getDomain(host)
+	now := l.now()
+	if now.Before(d.until) {
+		l.lock.Unlock()
+		return &ThrottledError{Domain: d.name, Until: d.until}
+	}
+	delay := d.reserve(now)
+	l.lock.Unlock()
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Throttle 记录 host 被平台限流，返回退避结束的时间；连续被限流时退避时长加倍
+func (l *Limiter) Throttle(host string) time.Time {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	d := l.getDomain(host)
+	backoff, maxBackoff := defaultBackoff, defaultMaxBackoff
+	if l.cfg != nil && l.cfg.Backoff > 0 {
+		backoff = l.cfg.Backoff
+	}
+	if l.cfg != nil && l.cfg.MaxBackoff > 0 {
+		maxBackoff = l.cfg.MaxBackoff
+	}
+	now := l.now()
+	if now.Before(d.until) {
+		// 退避开始前已经发出的请求，不重复计算
+		return d.until
+	}
+	if d.backoff > 0 {
+		backoff = min(d.backoff*2, maxBackoff)
+	}
+	d.backoff = backoff
+	d.until = now.Add(backoff)
+	d.throttledTotal++
+	return d.until
+}
+
+// Reset 请求成功后重置 host 的退避时长
+func (l *Limiter) Reset(host string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if d := l.getDomain(host); !l.now().Before(d.until) {
+		d.backoff = 0
+	}
+}
+
+// RegisterBodyThrottling 声明 host 被限流时仍然返回 HTTP 200，在响应内容中返回错误码。
+// Transport 不会在这些 host 的请求成功时调用 Reset，由调用方检查响应内容后调用 Throttle 或 Reset
+func (l *Limiter) RegisterBodyThrottling(host string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.bodyThrottling[host] = true
+}
+
+func (l *Limiter) isBodyThrottling(host string) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.bodyThrottling[host]
+}
+
+// Status 返回所有域名当前的限流状态，按域名排序
+func (l *Limiter) Status() []Status {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	now := l.now()
+	ret := make([]Status, 0, len(l.domains))
+	for _, d := range l.domains {
+		s := Status{
+			Domain:         d.name,
+			QPS:            d.qps,
+			Throttled:      now.Before(d.until),
+			Backoff:        d.backoff,
+			ThrottledTotal: d.throttledTotal,
+		}
+		if s.Throttled {
+			s.Until = d.until
+		}
+		ret = append(ret, s)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Domain < ret[j].Domain })
+	return ret
+}
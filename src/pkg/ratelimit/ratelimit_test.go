@@ -0,0 +1,152 @@
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bililive-go/bililive-go/src/configs"
+)
+
+func newTestLimiter(cfg *configs.RateLimit) (*Limiter, *time.Time) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := New(func() *configs.RateLimit { return cfg })
+	l.now = func() time.Time { return now }
+	return l, &now
+}
+
+func TestLimiterReserve(t *testing.T) {
+	l, now := newTestLimiter(&configs.RateLimit{
+		DefaultQPS: 1,
+		Domains:    map[string]float64{"bilibili.com": 2},
+	})
+	// 子域名共用一个令牌桶，可以突发 2 个请求
+	d := l.getDomain("api.live.bilibili.com")
+	assert.Same(t, d, l.getDomain("live.bilibili.com"))
+	assert.Equal(t, "bilibili.com", d.name)
+	assert.Zero(t, d.reserve(*now))
+	assert.Zero(t, d.reserve(*now))
+	assert.Equal(t, 500*time.Millisecond, d.reserve(*now))
+	*now = now.Add(2 * time.Second)
+	assert.Zero(t, d.reserve(*now))
+
+	other := l.getDomain("www.douyu.com")
+	assert.Equal(t, "www.douyu.com", other.name)
+	assert.Equal(t, 1.0, other.qps)
+	assert.Zero(t, other.reserve(*now))
+	assert.Equal(t, time.Second, other.reserve(*now))
+}
+
+func TestLimiterThrottle(t *testing.T) {
+	l, now := newTestLimiter(&configs.RateLimit{Backoff: time.Minute, MaxBackoff: 3 * time.Minute})
+	ctx := context.Background()
+	host := "api.live.bilibili.com"
+	assert.NoError(t, l.Wait(ctx, host))
+
+	until := l.Throttle(host)
+	assert.Equal(t, now.Add(time.Minute), until)
+	var throttled *ThrottledError
+	assert.True(t, errors.As(l.Wait(ctx, host), &throttled))
+	assert.Equal(t, until, throttled.Until)
+	// 退避期间再次被限流不会延长退避
+	assert.Equal(t, until, l.Throttle(host))
+	// 其他域名不受影响
+	assert.NoError(t, l.Wait(ctx, "www.douyu.com"))
+
+	// 连续被限流时退避时长加倍，最长为 max_backoff
+	*now = until
+	assert.Equal(t, now.Add(2*time.Minute), l.Throttle(host))
+	*now = now.Add(2 * time.Minute)
+	assert.Equal(t, now.Add(3*time.Minute), l.Throttle(host))
+
+	status := l.Status()
+	assert.Len(t, status, 2)
+	assert.Equal(t, host, status[0].Domain)
+	assert.True(t, status[0].Throttled)
+	assert.Equal(t, int64(3), status[0].ThrottledTotal)
+
+	// 请求成功后重置
+	*now = now.Add(3 * time.Minute)
+	l.Reset(host)
+	assert.Equal(t, now.Add(time.Minute), l.Throttle(host))
+}
+
+func TestTransport(t *testing.T) {
+	code := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(code)
+	}))
+	defer server.Close()
+	l, _ := newTestLimiter(nil)
+	client := &http.Client{Transport: &Transport{Limiter: l}}
+
+	resp, err := client.Get(server.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	code = http.StatusPreconditionFailed
+	resp, err = client.Get(server.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	// 退避期间请求不会发出
+	code = http.StatusOK
+	_, err = client.Get(server.URL)
+	var throttled *ThrottledError
+	assert.True(t, errors.As(err, &throttled))
+	u, _ := url.Parse(server.URL)
+	assert.Equal(t, u.Hostname(), throttled.Domain)
+	assert.Equal(t, defaultBackoff, l.Status()[0].Backoff)
+}
+
+func TestTransportBodyThrottling(t *testing.T) {
+	code := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"code":%d}`, code)
+	}))
+	defer server.Close()
+	l, now := newTestLimiter(&configs.RateLimit{Backoff: time.Minute, MaxBackoff: 10 * time.Minute})
+	u, _ := url.Parse(server.URL)
+	host := u.Hostname()
+	l.RegisterBodyThrottling(host)
+	client := &http.Client{Transport: &Transport{Limiter: l}}
+	// get 模拟平台的请求：检查响应内容中的错误码后调用 Throttle 或 Reset
+	get := func() error {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		var body struct{ Code int }
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			return err
+		}
+		if body.Code == -412 {
+			l.Throttle(host)
+		} else {
+			l.Reset(host)
+		}
+		return nil
+	}
+
+	// 连续被响应内容中的错误码限流时退避时长加倍，HTTP 200 不会重置退避
+	code = -412
+	for _, backoff := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute} {
+		assert.NoError(t, get())
+		assert.Equal(t, backoff, l.Status()[0].Backoff)
+		*now = l.Status()[0].Until
+	}
+
+	// 调用方确认请求成功后重置
+	code = 0
+	assert.NoError(t, get())
+	assert.Zero(t, l.Status()[0].Backoff)
+}
@@ -0,0 +1,43 @@
+package ratelimit
+
+import (
+	"net/http"
+)
+
+// Transport 在发出请求前等待 Limiter 的令牌，响应为 HTTP 412 或 429 时通知 Limiter 退避，
+// 其他响应重置退避时长，通过 RegisterBodyThrottling 声明的 host 除外
+type Transport struct {
+	Base    http.RoundTripper
+	Limiter *Limiter
+}
+
+// NewTransport 使用 Default 限流 base 发出的请求，base 为 nil 时使用 http.DefaultTransport
+func NewTransport(base http.RoundTripper) *Transport {
+	return &Transport{Base: base, Limiter: Default}
+}
+
+// IsThrottledStatus 平台用于限流的 HTTP 状态码
+func IsThrottledStatus(code int) bool {
+	return code == http.StatusPreconditionFailed || code == http.StatusTooManyRequests
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Hostname()
+	if err := t.Limiter.Wait(req.Context(), host); err != nil {
+		return nil, err
+	}
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if IsThrottledStatus(resp.StatusCode) {
+		t.Limiter.Throttle(host)
+	} else if !t.Limiter.isBodyThrottling(host) {
+		t.Limiter.Reset(host)
+	}
+	return resp, nil
+}
@@ -0,0 +1,158 @@
+package remux
+
+import (
+	"encoding/binary"
+)
+
+// 视频轨道与音频轨道的 track ID
+const (
+	videoTrackID = 1
+	audioTrackID = 2
+)
+
+// movieTimescale mvhd/tkhd/elst 使用的时间单位（毫秒）
+const movieTimescale = 1000
+
+// buf 用于拼接 box 内容
+type buf []byte
+
+func (b buf) u8(v uint8) buf   { return append(b, v) }
+func (b buf) u16(v uint16) buf { return binary.BigEndian.AppendUint16(b, v) }
+func (b buf) u32(v uint32) buf { return binary.BigEndian.AppendUint32(b, v) }
+func (b buf) u64(v uint64) buf { return binary.BigEndian.AppendUint64(b, v) }
+func (b buf) str(s string) buf { return append(b, s...) }
+func (b buf) zeros(n int) buf  { return append(b, make([]byte, n)...) }
+
+func box(typ string, children ...[]byte) []byte {
+	size := 8
+	for _, c := range children {
+		size += len(c)
+	}
+	b := make(buf, 0, size).u32(uint32(size)).str(typ)
+	for _, c := range children {
+		b = append(b, c...)
+	}
+	return b
+}
+
+func fullBox(typ string, version uint8, flags uint32, children ...[]byte) []byte {
+	header := buf{}.u32(uint32(version)<<24 | flags)
+	return box(typ, append([][]byte{header}, children...)...)
+}
+
+func ftyp(major string, compatible ...string) []byte {
+	b := buf{}.str(major).u32(0x200)
+	for _, c := range compatible {
+		b = b.str(c)
+	}
+	return box("ftyp", b)
+}
+
+// matrix 单位变换矩阵
+func matrix(b buf) buf {
+	return b.u32(0x00010000).u32(0).u32(0).
+		u32(0).u32(0x00010000).u32(0).
+		u32(0).u32(0).u32(0x40000000)
+}
+
+func mvhd(duration uint64, nextTrackID uint32) []byte {
+	b := buf{}.u64(0).u64(0). // creation_time, modification_time
+					u32(movieTimescale).u64(duration).
+					u32(0x00010000).u16(0x0100).zeros(10)
+	b = matrix(b).zeros(24).u32(nextTrackID)
+	return fullBox("mvhd", 1, 0, b)
+}
+
+func tkhd(t *track, id uint32, duration uint64) []byte {
+	b := buf{}.u64(0).u64(0).u32(id).u32(0).u64(duration).zeros(8).
+		u16(0).u16(0) // layer, alternate_group
+	if t.video {
+		b = b.u16(0)
+	} else {
+		b = b.u16(0x0100)
+	}
+	b = matrix(b.u16(0)).u32(uint32(t.width) << 16).u32(uint32(t.height) << 16)
+	// track_enabled | track_in_movie
+	return fullBox("tkhd", 1, 3, b)
+}
+
+// editEntry elst 中的一项，mediaTime 为 -1 表示空白
+type editEntry struct {
+	duration  uint64
+	mediaTime int64
+}
+
+func edts(entries []editEntry) []byte {
+	b := buf{}.u32(uint32(len(entries)))
+	for _, e := range entries {
+		b = b.u64(e.duration).u64(uint64(e.mediaTime)).u16(1).u16(0)
+	}
+	return box("edts", fullBox("elst", 1, 0, b))
+}
+
+func mdia(t *track, duration uint64, stbl []byte) []byte {
+	// language: und
+	mdhd := fullBox("mdhd", 1, 0, buf{}.u64(0).u64(0).u32(t.timescale).u64(duration).u16(0x55c4).u16(0))
+	handler, name, header := "soun", "SoundHandler", fullBox("smhd", 0, 0, buf{}.u16(0).u16(0))
+	if t.video {
+		handler, name, header = "vide", "VideoHandler", fullBox("vmhd", 0, 1, buf{}.zeros(8))
+	}
+	hdlr := fullBox("hdlr", 0, 0, buf{}.u32(0).str(handler).zeros(12).str(name).u8(0))
+	dinf := box("dinf", fullBox("dref", 0, 0, buf{}.u32(1), fullBox("url ", 0, 1)))
+	return box("mdia", mdhd, hdlr, box("minf", header, dinf, stbl))
+}
+
+func stsd(t *track) []byte {
+	var entry []byte
+	if t.video {
+		b := buf{}.zeros(6).u16(1). // reserved, data_reference_index
+						zeros(16).u16(t.width).u16(t.height).
+						u32(0x00480000).u32(0x00480000).u32(0).u16(1).
+						zeros(32).u16(0x0018).u16(0xffff)
+		configBox := "avcC"
+		if t.codec == CodecHEVC {
+			configBox = "hvcC"
+		}
+		entry = box(t.codec, b, box(configBox, t.config))
+	} else {
+		b := buf{}.zeros(6).u16(1).zeros(8).
+			u16(t.channels).u16(16).u16(0).u16(0).
+			u32(min(t.sampleRate, 0xffff) << 16)
+		entry = box(t.codec, b, esds(t.config))
+	}
+	return fullBox("stsd", 0, 0, buf{}.u32(1), entry)
+}
+
+// descriptor MPEG-4 描述符，长度使用可变长度编码
+func descriptor(tag uint8, payload ...[]byte) []byte {
+	size := 0
+	for _, p := range payload {
+		size += len(p)
+	}
+	b := buf{}.u8(tag)
+	for shift := 21; shift > 0; shift -= 7 {
+		if size>>shift > 0 {
+			b = b.u8(uint8(size>>shift&0x7f) | 0x80)
+		}
+	}
+	b = b.u8(uint8(size & 0x7f))
+	for _, p := range payload {
+		b = append(b, p...)
+	}
+	return b
+}
+
+func esds(asc []byte) []byte {
+	decoderConfig := descriptor(0x04,
+		// objectTypeIndication: MPEG-4 Audio, streamType: AudioStream
+		buf{}.u8(0x40).u8(0x15).zeros(3).u32(0).u32(0),
+		descriptor(0x05, asc),
+	)
+	es := descriptor(0x03, buf{}.u16(0).u8(0), decoderConfig, descriptor(0x06, []byte{0x02}))
+	return fullBox("esds", 0, 0, es)
+}
+
+// trex fragmented mp4 中轨道的默认值
+func trex(id uint32) []byte {
+	return fullBox("trex", 0, 0, buf{}.u32(id).u32(1).u32(0).u32(0).u32(0))
+}
@@ -0,0 +1,254 @@
+package remux
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var errInvalidBitstream = errors.New("invalid bitstream")
+
+// bitReader 按位读取数据，用于解析 SPS 与 AudioSpecificConfig
+type bitReader struct {
+	b   []byte
+	pos int
+}
+
+func (r *bitReader) bit() (uint32, error) {
+	if r.pos >= len(r.b)*8 {
+		return 0, errInvalidBitstream
+	}
+	v := uint32(r.b[r.pos/8]>>(7-r.pos%8)) & 1
+	r.pos++
+	return v, nil
+}
+
+func (r *bitReader) bits(n int) (uint32, error) {
+	var v uint32
+	for i := 0; i < n; i++ {
+		b, err := r.bit()
+		if err != nil {
+			return 0, err
+		}
+		v = v<<1 | b
+	}
+	return v, nil
+}
+
+// ue 读取无符号指数哥伦布编码
+func (r *bitReader) ue() (uint32, error) {
+	zeros := 0
+	for {
+		b, err := r.bit()
+		if err != nil {
+			return 0, err
+		}
+		if b == 1 {
+			break
+		}
+		zeros++
+		if zeros > 31 {
+			return 0, errInvalidBitstream
+		}
+	}
+	v, err := r.bits(zeros)
+	return (1<<zeros - 1) + v, err
+}
+
+// se 读取有符号指数哥伦布编码
+func (r *bitReader) se() (int32, error) {
+	v, err := r.ue()
+	if v%2 == 1 {
+		return int32(v/2 + 1), err
+	}
+	return -int32(v / 2), err
+}
+
+// unescapeRBSP 去掉 NAL 中的防竞争字节 0x03
+func unescapeRBSP(b []byte) []byte {
+	ret := make([]byte, 0, len(b))
+	zeros := 0
+	for _, c := range b {
+		if zeros >= 2 && c == 3 {
+			zeros = 0
+			continue
+		}
+		ret = append(ret, c)
+		if c == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+	}
+	return ret
+}
+
+// avcConfigSPS 返回 AVCDecoderConfigurationRecord 中的第一个 SPS
+func avcConfigSPS(record []byte) ([]byte, error) {
+	if len(record) < 8 || record[5]&0x1f == 0 {
+		return nil, errInvalidBitstream
+	}
+	n := int(binary.BigEndian.Uint16(record[6:8]))
+	if len(record) < 8+n {
+		return nil, errInvalidBitstream
+	}
+	return record[8 : 8+n], nil
+}
+
+// parseAVCSPS 从 H.264 SPS 中解析出画面的宽高（已去掉裁剪区域）
+func parseAVCSPS(nal []byte) (width, height int, err error) {
+	if len(nal) < 4 {
+		return 0, 0, errInvalidBitstream
+	}
+	r := &bitReader{b: unescapeRBSP(nal[1:])}
+	profile, _ := r.bits(8)
+	r.bits(16) // constraint_set flags 与 level_idc
+	r.ue()     // seq_parameter_set_id
+	chromaFormat := uint32(1)
+	separateColourPlane := uint32(0)
+	switch profile {
+	case 100, 110, 122, 244, 44, 83, 86, 118, 128, 138, 139, 134, 135:
+		if chromaFormat, err = r.ue(); err != nil {
+			return 0, 0, err
+		}
+		if chromaFormat == 3 {
+			separateColourPlane, _ = r.bit()
+		}
+		r.ue()  // bit_depth_luma_minus8
+		r.ue()  // bit_depth_chroma_minus8
+		r.bit() // qpprime_y_zero_transform_bypass_flag
+		present, _ := r.bit()
+		if present == 1 {
+			lists := 8
+			if chromaFormat == 3 {
+				lists = 12
+			}
+			for i := 0; i < lists; i++ {
+				if listPresent, _ := r.bit(); listPresent == 1 {
+					size := 16
+					if i >= 6 {
+						size = 64
+					}
+					if err := skipScalingList(r, size); err != nil {
+						return 0, 0, err
+					}
+				}
+			}
+		}
+	}
+	r.ue() // log2_max_frame_num_minus4
+	pocType, err := r.ue()
+	if err != nil {
+		return 0, 0, err
+	}
+	switch pocType {
+	case 0:
+		r.ue() // log2_max_pic_order_cnt_lsb_minus4
+	case 1:
+		r.bit() // delta_pic_order_always_zero_flag
+		r.se()  // offset_for_non_ref_pic
+		r.se()  // offset_for_top_to_bottom_field
+		n, err := r.ue()
+		if err != nil {
+			return 0, 0, err
+		}
+		for i := uint32(0); i < n; i++ {
+			if _, err := r.se(); err != nil {
+				return 0, 0, err
+			}
+		}
+	}
+	r.ue()  // max_num_ref_frames
+	r.bit() // gaps_in_frame_num_value_allowed_flag
+	widthInMbs, _ := r.ue()
+	heightInMapUnits, _ := r.ue()
+	frameMbsOnly, err := r.bit()
+	if err != nil {
+		return 0, 0, err
+	}
+	if frameMbsOnly == 0 {
+		r.bit() // mb_adaptive_frame_field_flag
+	}
+	r.bit() // direct_8x8_inference_flag
+	var cropLeft, cropRight, cropTop, cropBottom uint32
+	cropping, err := r.bit()
+	if err != nil {
+		return 0, 0, err
+	}
+	if cropping == 1 {
+		cropLeft, _ = r.ue()
+		cropRight, _ = r.ue()
+		cropTop, _ = r.ue()
+		if cropBottom, err = r.ue(); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	cropUnitX, cropUnitY := uint32(1), 2-frameMbsOnly
+	if separateColourPlane == 0 {
+		switch chromaFormat {
+		case 1:
+			cropUnitX, cropUnitY = 2, 2*(2-frameMbsOnly)
+		case 2:
+			cropUnitX = 2
+		}
+	}
+	width = int((widthInMbs+1)*16 - cropUnitX*(cropLeft+cropRight))
+	height = int((2-frameMbsOnly)*(heightInMapUnits+1)*16 - cropUnitY*(cropTop+cropBottom))
+	if width <= 0 || height <= 0 {
+		return 0, 0, errInvalidBitstream
+	}
+	return width, height, nil
+}
+
+func skipScalingList(r *bitReader, size int) error {
+	last, next := int32(8), int32(8)
+	for i := 0; i < size; i++ {
+		if next != 0 {
+			delta, err := r.se()
+			if err != nil {
+				return err
+			}
+			next = (last + delta + 256) % 256
+		}
+		if next != 0 {
+			last = next
+		}
+	}
+	return nil
+}
+
+var aacSampleRates = []uint32{96000, 88200, 64000, 48000, 44100, 32000, 24000, 22050, 16000, 12000, 11025, 8000, 7350}
+
+// parseAudioSpecificConfig 从 AAC 的 AudioSpecificConfig 中解析采样率与声道数
+func parseAudioSpecificConfig(b []byte) (sampleRate uint32, channels uint16, err error) {
+	r := &bitReader{b: b}
+	objectType, err := r.bits(5)
+	if err != nil {
+		return 0, 0, err
+	}
+	if objectType == 31 {
+		r.bits(6)
+	}
+	index, err := r.bits(4)
+	if err != nil {
+		return 0, 0, err
+	}
+	if index == 15 {
+		if sampleRate, err = r.bits(24); err != nil {
+			return 0, 0, err
+		}
+	} else if int(index) < len(aacSampleRates) {
+		sampleRate = aacSampleRates[index]
+	} else {
+		return 0, 0, errInvalidBitstream
+	}
+	channelConfig, err := r.bits(4)
+	if err != nil {
+		return 0, 0, err
+	}
+	channels = uint16(channelConfig)
+	if channelConfig == 7 {
+		channels = 8
+	}
+	return sampleRate, channels, nil
+}
@@ -0,0 +1,248 @@
+package remux
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/bililive-go/bililive-go/src/pkg/parser/native/flv"
+)
+
+// MP4 中的编码格式（sample entry 类型）
+const (
+	CodecAVC  = "avc1"
+	CodecHEVC = "hvc1"
+	CodecAAC  = "mp4a"
+)
+
+// FLV 视频编码 ID，12 为国内 CDN 普遍使用的 HEVC 扩展
+const (
+	flvCodecAVC  = 7
+	flvCodecHEVC = 12
+)
+
+// Enhanced RTMP 的视频 packet type
+const (
+	exPacketSequenceStart = 0
+	exPacketCodedFrames   = 1
+	exPacketCodedFramesX  = 3
+)
+
+// track 一路音频或视频，config 为 avcC/hvcC 的内容或 AudioSpecificConfig
+type track struct {
+	video     bool
+	codec     string
+	config    []byte
+	timescale uint32
+
+	width, height uint16
+	sampleRate    uint32
+	channels      uint16
+}
+
+// sample 一帧数据，时间戳单位为毫秒，dts 从 0 开始
+type sample struct {
+	dts  int64
+	cts  int32
+	key  bool
+	data []byte
+}
+
+// demuxer 从 FLV 中读取音视频帧。只使用第一次出现的编码参数，
+// 中途参数变化的流应先经过 fix_flv_at_first 分段
+type demuxer struct {
+	r     *flv.TagReader
+	video *track
+	audio *track
+	// metadata 中的宽高，用于无法从码流解析宽高的情况
+	metaWidth, metaHeight float64
+	baseTs                int64
+	lastDts               map[*track]int64
+}
+
+func newDemuxer(r io.Reader) (*demuxer, error) {
+	d := &demuxer{r: flv.NewTagReader(r), baseTs: -1, lastDts: make(map[*track]int64)}
+	if _, err := d.r.ReadHeader(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// next 返回下一帧及其所属的轨道，读完时返回 io.EOF
+func (d *demuxer) next() (*track, *sample, error) {
+	for {
+		tag, err := d.r.ReadTag()
+		if err != nil {
+			if err == io.ErrUnexpectedEOF {
+				// 录制中断导致的不完整 tag，忽略即可
+				err = io.EOF
+			}
+			return nil, nil, err
+		}
+		var t *track
+		var s *sample
+		switch {
+		case tag.IsScript():
+			d.readMetadata(tag)
+		case tag.IsVideo():
+			t, s = d.readVideo(tag)
+		case tag.IsAudio():
+			t, s = d.readAudio(tag)
+		}
+		if s == nil {
+			continue
+		}
+		d.rebase(t, s, tag.Timestamp)
+		return t, s, nil
+	}
+}
+
+// rebase 将时间戳转换为从 0 开始并保证单调递增
+func (d *demuxer) rebase(t *track, s *sample, ts uint32) {
+	if d.baseTs < 0 {
+		d.baseTs = int64(ts)
+	}
+	s.dts = max(int64(ts)-d.baseTs, 0)
+	if last, ok := d.lastDts[t]; ok && s.dts < last {
+		s.dts = last
+	}
+	d.lastDts[t] = s.dts
+}
+
+func (d *demuxer) readMetadata(tag *flv.Tag) {
+	values, _ := flv.DecodeAMF(tag.Data)
+	if len(values) < 2 {
+		return
+	}
+	var props []flv.AMFProperty
+	switch v := values[1].(type) {
+	case flv.AMFECMAArray:
+		props = v
+	case flv.AMFObject:
+		props = v
+	}
+	for _, p := range props {
+		v, _ := p.Value.(float64)
+		switch p.Key {
+		case "width":
+			d.metaWidth = v
+		case "height":
+			d.metaHeight = v
+		}
+	}
+}
+
+func (d *demuxer) readVideo(tag *flv.Tag) (*track, *sample) {
+	b := tag.Data
+	if len(b) < 5 {
+		return nil, nil
+	}
+	var (
+		codec      string
+		packetType byte
+		cts        int32
+		payload    []byte
+	)
+	frameType := b[0] >> 4 & 7
+	if b[0]&0x80 != 0 {
+		// Enhanced RTMP：低 4 位为 packet type，之后是 FourCC
+		switch string(b[1:5]) {
+		case CodecAVC:
+			codec = CodecAVC
+		case CodecHEVC:
+			codec = CodecHEVC
+		default:
+			return nil, nil
+		}
+		switch b[0] & 15 {
+		case exPacketSequenceStart:
+			packetType, payload = 0, b[5:]
+		case exPacketCodedFrames:
+			if len(b) < 8 {
+				return nil, nil
+			}
+			packetType, cts, payload = 1, si24(b[5:8]), b[8:]
+		case exPacketCodedFramesX:
+			packetType, payload = 1, b[5:]
+		default:
+			return nil, nil
+		}
+	} else {
+		switch b[0] & 15 {
+		case flvCodecAVC:
+			codec = CodecAVC
+		case flvCodecHEVC:
+			codec = CodecHEVC
+		default:
+			return nil, nil
+		}
+		packetType, cts, payload = b[1], si24(b[2:5]), b[5:]
+	}
+	// 视频信息帧/命令帧不包含图像数据
+	if frameType == 5 {
+		return nil, nil
+	}
+
+	switch packetType {
+	case 0:
+		if d.video == nil && len(payload) > 0 {
+			d.video = d.newVideoTrack(codec, payload)
+		}
+		return nil, nil
+	case 1:
+		if d.video == nil || d.video.codec != codec || len(payload) == 0 {
+			return nil, nil
+		}
+		return d.video, &sample{cts: max(cts, 0), key: frameType == 1, data: payload}
+	}
+	return nil, nil
+}
+
+func (d *demuxer) newVideoTrack(codec string, config []byte) *track {
+	t := &track{video: true, codec: codec, config: append([]byte(nil), config...), timescale: 1000}
+	width, height := int(d.metaWidth), int(d.metaHeight)
+	if codec == CodecAVC {
+		if sps, err := avcConfigSPS(config); err == nil {
+			if w, h, err := parseAVCSPS(sps); err == nil {
+				width, height = w, h
+			}
+		}
+	}
+	t.width, t.height = uint16(width), uint16(height)
+	return t
+}
+
+func (d *demuxer) readAudio(tag *flv.Tag) (*track, *sample) {
+	b := tag.Data
+	if !tag.IsAAC() {
+		return nil, nil
+	}
+	if flv.AACPacketType(b[1]) == flv.AACSeqHeader {
+		if d.audio != nil {
+			return nil, nil
+		}
+		sampleRate, channels, err := parseAudioSpecificConfig(b[2:])
+		if err != nil || sampleRate == 0 {
+			return nil, nil
+		}
+		d.audio = &track{
+			codec:      CodecAAC,
+			config:     append([]byte(nil), b[2:]...),
+			timescale:  sampleRate,
+			sampleRate: sampleRate,
+			channels:   channels,
+		}
+		return nil, nil
+	}
+	if d.audio == nil || len(b) <= 2 {
+		return nil, nil
+	}
+	return d.audio, &sample{key: true, data: b[2:]}
+}
+
+func si24(b []byte) int32 {
+	v := int32(binary.BigEndian.Uint32(append([]byte{0}, b[:3]...)))
+	if v&0x800000 != 0 {
+		v -= 1 << 24
+	}
+	return v
+}
@@ -0,0 +1,224 @@
+package remux
+
+import (
+	"flag"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bililive-go/bililive-go/src/pkg/parser/native/flv"
+)
+
+// go test ./pkg/remux -run TestFixtures -update 重新生成 testdata 中的 FLV 文件
+var updateFixtures = flag.Bool("update", false, "regenerate flv fixtures in testdata")
+
+// FLV tag 类型
+const (
+	audioTagType  = 8
+	videoTagType  = 9
+	scriptTagType = 18
+)
+
+// bitWriter 用于生成 SPS
+type bitWriter struct {
+	b []byte
+	n int
+}
+
+func (w *bitWriter) bits(v uint32, n int) {
+	for i := n - 1; i >= 0; i-- {
+		if w.n%8 == 0 {
+			w.b = append(w.b, 0)
+		}
+		w.b[len(w.b)-1] |= byte(v>>i&1) << (7 - w.n%8)
+		w.n++
+	}
+}
+
+func (w *bitWriter) ue(v uint32) {
+	v++
+	n := 0
+	for x := v; x > 1; x >>= 1 {
+		n++
+	}
+	w.bits(0, n)
+	w.bits(v, n+1)
+}
+
+// escapeRBSP 插入防竞争字节
+func escapeRBSP(b []byte) []byte {
+	var ret []byte
+	zeros := 0
+	for _, c := range b {
+		if zeros >= 2 && c <= 3 {
+			ret = append(ret, 3)
+			zeros = 0
+		}
+		ret = append(ret, c)
+		if c == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+	}
+	return ret
+}
+
+// highProfileSPS 生成 High Profile、4:2:0 的 SPS，高度不是 16 的倍数时通过裁剪实现
+func highProfileSPS(width, height uint32) []byte {
+	w := &bitWriter{}
+	w.bits(100, 8) // profile_idc
+	w.bits(0, 8)
+	w.bits(40, 8) // level_idc
+	w.ue(0)       // seq_parameter_set_id
+	w.ue(1)       // chroma_format_idc
+	w.ue(0)
+	w.ue(0)
+	w.bits(0, 1)
+	w.bits(0, 1) // seq_scaling_matrix_present_flag
+	w.ue(0)      // log2_max_frame_num_minus4
+	w.ue(0)      // pic_order_cnt_type
+	w.ue(2)      // log2_max_pic_order_cnt_lsb_minus4
+	w.ue(4)      // max_num_ref_frames
+	w.bits(0, 1)
+	mbWidth, mbHeight := (width+15)/16, (height+15)/16
+	w.ue(mbWidth - 1)
+	w.ue(mbHeight - 1)
+	w.bits(1, 1) // frame_mbs_only_flag
+	w.bits(1, 1) // direct_8x8_inference_flag
+	cropRight, cropBottom := (mbWidth*16-width)/2, (mbHeight*16-height)/2
+	if cropRight > 0 || cropBottom > 0 {
+		w.bits(1, 1)
+		w.ue(0)
+		w.ue(cropRight)
+		w.ue(0)
+		w.ue(cropBottom)
+	} else {
+		w.bits(0, 1)
+	}
+	w.bits(0, 1) // vui_parameters_present_flag
+	w.bits(1, 1) // rbsp_stop_one_bit
+	return append([]byte{0x67}, escapeRBSP(w.b)...)
+}
+
+func avcDecoderConfig(sps []byte) []byte {
+	pps := []byte{0x68, 0xee, 0x3c, 0x80}
+	b := []byte{1, sps[1], sps[2], sps[3], 0xff, 0xe1, byte(len(sps) >> 8), byte(len(sps))}
+	b = append(b, sps...)
+	return append(append(b, 1, byte(len(pps)>>8), byte(len(pps))), pps...)
+}
+
+// fixtureVideo 生成 FLV 视频 tag，codec 为 FLV 的 CodecID
+func fixtureVideo(ts uint32, codec byte, key bool, packetType byte, cts uint32, payload []byte) *flv.Tag {
+	frameType := byte(2)
+	if key {
+		frameType = 1
+	}
+	data := []byte{frameType<<4 | codec, packetType, byte(cts >> 16), byte(cts >> 8), byte(cts)}
+	return &flv.Tag{Type: videoTagType, Timestamp: ts, Data: append(data, payload...)}
+}
+
+func fixtureAudio(ts uint32, packetType byte, payload []byte) *flv.Tag {
+	return &flv.Tag{Type: audioTagType, Timestamp: ts, Data: append([]byte{0xaf, packetType}, payload...)}
+}
+
+// nalu 生成长度前缀格式的 NAL 单元
+func nalu(header byte, size int) []byte {
+	b := []byte{0, 0, byte(size >> 8), byte(size), header}
+	for i := 1; i < size; i++ {
+		b = append(b, byte(i))
+	}
+	return b
+}
+
+// interleave 按时间戳合并音视频 tag
+func interleave(video, audio []*flv.Tag) []*flv.Tag {
+	var ret []*flv.Tag
+	for len(video) > 0 || len(audio) > 0 {
+		if len(audio) == 0 || (len(video) > 0 && video[0].Timestamp <= audio[0].Timestamp) {
+			ret, video = append(ret, video[0]), video[1:]
+		} else {
+			ret, audio = append(ret, audio[0]), audio[1:]
+		}
+	}
+	return ret
+}
+
+// aacFrames 生成 seconds 秒的 AAC 帧，每帧 1024 个采样
+func aacFrames(start uint32, sampleRate float64, seconds float64) []*flv.Tag {
+	var tags []*flv.Tag
+	for i := 0; float64(i)*1024/sampleRate < seconds; i++ {
+		ts := start + uint32(math.Round(float64(i)*1024*1000/sampleRate))
+		tags = append(tags, fixtureAudio(ts, 1, []byte{0x21, 0x10, byte(i)}))
+	}
+	return tags
+}
+
+func videoFrames(start uint32, codec byte, fps, gop, count int) []*flv.Tag {
+	var tags []*flv.Tag
+	for i := 0; i < count; i++ {
+		ts := start + uint32(math.Round(float64(i)*1000/float64(fps)))
+		key := i%gop == 0
+		header := byte(0x01)
+		if key {
+			header = 0x05
+		}
+		// 模拟 B 帧：每 3 帧中最后一帧的显示时间与解码时间相同
+		cts := uint32(1000 / fps)
+		if i%3 == 2 {
+			cts = 0
+		}
+		tags = append(tags, fixtureVideo(ts, codec, key, 1, cts, nalu(header, 64)))
+	}
+	return tags
+}
+
+func writeFixture(t *testing.T, name string, header *flv.Header, metadata flv.AMFECMAArray, tags []*flv.Tag) {
+	f, err := os.Create(filepath.Join("testdata", name))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer f.Close()
+	w := flv.NewTagWriter(f)
+	assert.NoError(t, w.WriteHeader(header))
+	data, err := flv.EncodeAMF("onMetaData", metadata)
+	assert.NoError(t, err)
+	assert.NoError(t, w.WriteTag(&flv.Tag{Type: scriptTagType, Timestamp: tags[0].Timestamp, Data: data}))
+	for _, tag := range tags {
+		assert.NoError(t, w.WriteTag(tag))
+	}
+}
+
+func TestFixtures(t *testing.T) {
+	if !*updateFixtures {
+		t.Skip("run with -update to regenerate fixtures")
+	}
+	assert.NoError(t, os.MkdirAll("testdata", 0755))
+
+	// 1920x1080 H.264 30fps 3s + 44.1kHz 双声道 AAC，时间戳从 10s 开始，音频晚 20ms
+	tags := []*flv.Tag{
+		fixtureVideo(10000, 7, true, 0, 0, avcDecoderConfig(highProfileSPS(1920, 1080))),
+		fixtureAudio(10000, 0, []byte{0x12, 0x10}),
+	}
+	tags = append(tags, interleave(videoFrames(10000, 7, 30, 30, 90), aacFrames(10020, 44100, 3))...)
+	writeFixture(t, "avc_aac.flv", &flv.Header{HasVideo: true, HasAudio: true},
+		flv.AMFECMAArray{{Key: "width", Value: 1920.0}, {Key: "height", Value: 1080.0}}, tags)
+
+	// 1280x720 HEVC（CodecID 12）25fps 2s + 48kHz 单声道 AAC
+	// 不包含参数集的 HEVCDecoderConfigurationRecord
+	hvcc := []byte{1, 1, 0x60, 0, 0, 0, 0x90, 0, 0, 0, 0, 0, 93, 0xf0, 0, 0xfc, 0xfd, 0xf8, 0xf8, 0, 0, 0x0f, 0}
+	tags = []*flv.Tag{
+		fixtureVideo(0, 12, true, 0, 0, hvcc),
+		fixtureAudio(0, 0, []byte{0x11, 0x88}),
+	}
+	tags = append(tags, interleave(videoFrames(0, 12, 25, 25, 50), aacFrames(0, 48000, 2))...)
+	writeFixture(t, "hevc_aac.flv", &flv.Header{HasVideo: true, HasAudio: true},
+		flv.AMFECMAArray{{Key: "width", Value: 1280.0}, {Key: "height", Value: 720.0}}, tags)
+
+	// 48kHz 双声道纯音频 1s
+	tags = append([]*flv.Tag{fixtureAudio(500, 0, []byte{0x11, 0x90})}, aacFrames(500, 48000, 1)...)
+	writeFixture(t, "audio_only.flv", &flv.Header{HasAudio: true}, flv.AMFECMAArray{}, tags)
+}
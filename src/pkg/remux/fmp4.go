@@ -0,0 +1,216 @@
+package remux
+
+import (
+	"io"
+	"time"
+)
+
+// trun 中每一帧的 sample_flags
+const (
+	syncSampleFlags    = 0x02000000 // sample_depends_on = 2
+	nonSyncSampleFlags = 0x01010000 // sample_depends_on = 1, sample_is_non_sync_sample
+)
+
+// fragTrack fragmented mp4 中一路轨道尚未输出的帧，id 为 0 表示写入 moov 之后才出现，不会输出
+type fragTrack struct {
+	*track
+	id      uint32
+	pending []*sample
+	// end 已输出部分的结束时间（轨道 timescale）
+	end int64
+}
+
+// fmp4Writer 输出 fragmented mp4：ftyp、moov，之后每个片段为 moof+mdat。
+// 视频在片段时长达到 fragmentDuration 后的下一个关键帧处开始新的片段，纯音频在任意帧处切分
+type fmp4Writer struct {
+	w                io.Writer
+	fragmentDuration time.Duration
+	tracks           map[*track]*fragTrack
+	order            []*fragTrack
+	headerWritten    bool
+	// fragmentStart 当前片段第一帧的时间（毫秒），-1 表示当前片段为空
+	fragmentStart int64
+	fragments     int
+}
+
+func newFmp4Writer(w io.Writer, fragmentDuration time.Duration) *fmp4Writer {
+	return &fmp4Writer{w: w, fragmentDuration: fragmentDuration, tracks: make(map[*track]*fragTrack), fragmentStart: -1}
+}
+
+func (f *fmp4Writer) trackOf(t *track) *fragTrack {
+	ft, ok := f.tracks[t]
+	if !ok {
+		ft = &fragTrack{track: t}
+		f.tracks[t] = ft
+	}
+	return ft
+}
+
+// writeHeader 写入 ftyp 与 moov，只包含此时已经出现的轨道
+func (f *fmp4Writer) writeHeader(video, audio *track) error {
+	f.headerWritten = true
+	var traks, trexes [][]byte
+	for _, item := range []struct {
+		t  *track
+		id uint32
+	}{{video, videoTrackID}, {audio, audioTrackID}} {
+		if item.t == nil {
+			continue
+		}
+		ft := f.trackOf(item.t)
+		ft.id = item.id
+		f.order = append(f.order, ft)
+		stbl := box("stbl",
+			stsd(item.t),
+			fullBox("stts", 0, 0, buf{}.u32(0)),
+			fullBox("stsc", 0, 0, buf{}.u32(0)),
+			fullBox("stsz", 0, 0, buf{}.u32(0).u32(0)),
+			fullBox("stco", 0, 0, buf{}.u32(0)),
+		)
+		traks = append(traks, box("trak", tkhd(item.t, item.id, 0), mdia(item.t, 0, stbl)))
+		trexes = append(trexes, trex(item.id))
+	}
+	if len(f.order) == 0 {
+		return ErrNoTrack
+	}
+	nextID := f.order[len(f.order)-1].id + 1
+	moov := box("moov", append(append([][]byte{mvhd(0, nextID)}, traks...), box("mvex", trexes...))...)
+	_, err := f.w.Write(append(ftyp("iso5", "iso5", "iso6", "mp41"), moov...))
+	return err
+}
+
+func (f *fmp4Writer) writeSample(video, audio, t *track, s *sample) error {
+	boundary := s.key && (t.video || video == nil)
+	if boundary && f.fragmentStart >= 0 && time.Duration(s.dts-f.fragmentStart)*time.Millisecond >= f.fragmentDuration {
+		if err := f.flush(video, audio, t, s); err != nil {
+			return err
+		}
+	}
+	ft := f.trackOf(t)
+	if f.headerWritten && ft.id == 0 {
+		return nil
+	}
+	if f.fragmentStart < 0 {
+		f.fragmentStart = s.dts
+	}
+	ft.pending = append(ft.pending, s)
+	return nil
+}
+
+// trafPart 一个片段中一路轨道的数据
+type trafPart struct {
+	ft        *fragTrack
+	samples   []*sample
+	durations []uint32
+	base      int64
+}
+
+// flush 输出当前片段。next 为触发切分的帧，其所在轨道的最后一帧时长可以直接计算；
+// 其他轨道的最后一帧留到下一个片段。next 为 nil 时输出全部剩余的帧
+func (f *fmp4Writer) flush(video, audio, nextTrack *track, next *sample) error {
+	if !f.headerWritten {
+		if err := f.writeHeader(video, audio); err != nil {
+			return err
+		}
+	}
+	var parts []*trafPart
+	fragmentStart := int64(-1)
+	for _, ft := range f.order {
+		samples := ft.pending
+		ft.pending = nil
+		if next != nil && ft.track != nextTrack && len(samples) > 0 {
+			ft.pending = []*sample{samples[len(samples)-1]}
+			samples = samples[:len(samples)-1]
+		}
+		if len(ft.pending) > 0 && (fragmentStart < 0 || ft.pending[0].dts < fragmentStart) {
+			fragmentStart = ft.pending[0].dts
+		}
+		if len(samples) == 0 {
+			continue
+		}
+		part := &trafPart{ft: ft, samples: samples, base: scaleTime(samples[0].dts, ft.timescale)}
+		for i, s := range samples {
+			start := scaleTime(s.dts, ft.timescale)
+			var d int64
+			switch {
+			case i+1 < len(samples):
+				d = scaleTime(samples[i+1].dts, ft.timescale) - start
+			case ft.track == nextTrack:
+				d = scaleTime(next.dts, ft.timescale) - start
+			case i > 0:
+				d = int64(part.durations[i-1])
+			default:
+				d = int64(defaultDuration(ft.track))
+			}
+			part.durations = append(part.durations, uint32(d))
+			ft.end = start + d
+		}
+		parts = append(parts, part)
+	}
+	if next != nil && (fragmentStart < 0 || next.dts < fragmentStart) {
+		fragmentStart = next.dts
+	}
+	f.fragmentStart = fragmentStart
+	if len(parts) == 0 {
+		return nil
+	}
+	f.fragments++
+
+	moof := f.moof(parts, 0)
+	moof = f.moof(parts, len(moof)+8)
+	mdatSize := 8
+	for _, p := range parts {
+		for _, s := range p.samples {
+			mdatSize += len(s.data)
+		}
+	}
+	out := append(moof, buf{}.u32(uint32(mdatSize)).str("mdat")...)
+	if _, err := f.w.Write(out); err != nil {
+		return err
+	}
+	for _, p := range parts {
+		for _, s := range p.samples {
+			if _, err := f.w.Write(s.data); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// moof 生成片段头，dataOffset 为第一帧数据相对 moof 开头的偏移
+func (f *fmp4Writer) moof(parts []*trafPart, dataOffset int) []byte {
+	children := [][]byte{fullBox("mfhd", 0, 0, buf{}.u32(uint32(f.fragments)))}
+	for _, p := range parts {
+		trun := buf{}.u32(uint32(len(p.samples))).u32(uint32(dataOffset))
+		for i, s := range p.samples {
+			flags := uint32(syncSampleFlags)
+			if !s.key {
+				flags = nonSyncSampleFlags
+			}
+			trun = trun.u32(p.durations[i]).u32(uint32(len(s.data))).u32(flags).
+				u32(uint32(scaleTime(int64(s.cts), p.ft.timescale)))
+			dataOffset += len(s.data)
+		}
+		children = append(children, box("traf",
+			// default-base-is-moof
+			fullBox("tfhd", 0, 0x020000, buf{}.u32(p.ft.id)),
+			fullBox("tfdt", 1, 0, buf{}.u64(uint64(p.base))),
+			// data-offset, sample-duration, sample-size, sample-flags, sample-composition-time-offset
+			fullBox("trun", 0, 0x000f01, trun),
+		))
+	}
+	return box("moof", children...)
+}
+
+// close 输出剩余的帧，返回文件时长
+func (f *fmp4Writer) close(video, audio *track) (time.Duration, error) {
+	if err := f.flush(video, audio, nil, nil); err != nil {
+		return 0, err
+	}
+	var duration time.Duration
+	for _, ft := range f.order {
+		duration = max(duration, time.Duration(ft.end)*time.Second/time.Duration(ft.timescale))
+	}
+	return duration, nil
+}
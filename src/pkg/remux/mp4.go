@@ -0,0 +1,251 @@
+package remux
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"time"
+)
+
+// trackTable 记录一路轨道的 sample 表，在写完 mdat 后生成 moov
+type trackTable struct {
+	*track
+	id uint32
+	// startDts 第一帧相对文件开头的时间（毫秒）
+	startDts     int64
+	dts          []int64
+	cts          []int32
+	sizes        []uint32
+	syncs        []uint32
+	chunkOffsets []int64
+	chunkSamples []uint32
+}
+
+func (t *trackTable) add(s *sample, offset int64, newChunk bool) {
+	if len(t.dts) == 0 {
+		t.startDts = s.dts
+	}
+	t.dts = append(t.dts, scaleTime(s.dts-t.startDts, t.timescale))
+	t.cts = append(t.cts, int32(scaleTime(int64(s.cts), t.timescale)))
+	t.sizes = append(t.sizes, uint32(len(s.data)))
+	if s.key {
+		t.syncs = append(t.syncs, uint32(len(t.sizes)))
+	}
+	if newChunk || len(t.chunkOffsets) == 0 {
+		t.chunkOffsets = append(t.chunkOffsets, offset)
+		t.chunkSamples = append(t.chunkSamples, 0)
+	}
+	t.chunkSamples[len(t.chunkSamples)-1]++
+}
+
+// durations 每帧的时长，最后一帧使用前一帧的时长
+func (t *trackTable) durations() []uint32 {
+	ret := make([]uint32, len(t.dts))
+	for i := 0; i+1 < len(t.dts); i++ {
+		ret[i] = uint32(t.dts[i+1] - t.dts[i])
+	}
+	if n := len(ret); n > 1 {
+		ret[n-1] = ret[n-2]
+	} else if n == 1 {
+		ret[0] = defaultDuration(t.track)
+	}
+	return ret
+}
+
+// defaultDuration 无法根据时间戳计算时长时使用的默认帧时长
+func defaultDuration(t *track) uint32 {
+	if t.video {
+		return uint32(scaleTime(33, t.timescale))
+	}
+	// AAC 每帧 1024 个采样
+	return 1024
+}
+
+// scaleTime 将毫秒转换为 timescale 下的时间
+func scaleTime(ms int64, timescale uint32) int64 {
+	return int64(math.Round(float64(ms) * float64(timescale) / 1000))
+}
+
+func (t *trackTable) stbl() []byte {
+	durations := t.durations()
+	stts := buf{}
+	var entries uint32
+	for i := 0; i < len(durations); {
+		j := i
+		for j < len(durations) && durations[j] == durations[i] {
+			j++
+		}
+		stts = stts.u32(uint32(j - i)).u32(durations[i])
+		entries++
+		i = j
+	}
+	children := [][]byte{
+		stsd(t.track),
+		fullBox("stts", 0, 0, buf{}.u32(entries), stts),
+	}
+
+	hasCts := false
+	for _, c := range t.cts {
+		if c != 0 {
+			hasCts = true
+			break
+		}
+	}
+	if hasCts {
+		ctts := buf{}
+		entries = 0
+		for i := 0; i < len(t.cts); {
+			j := i
+			for j < len(t.cts) && t.cts[j] == t.cts[i] {
+				j++
+			}
+			ctts = ctts.u32(uint32(j - i)).u32(uint32(t.cts[i]))
+			entries++
+			i = j
+		}
+		children = append(children, fullBox("ctts", 0, 0, buf{}.u32(entries), ctts))
+	}
+
+	// 音频每一帧都是同步帧，省略 stss
+	if t.video {
+		stss := buf{}.u32(uint32(len(t.syncs)))
+		for _, s := range t.syncs {
+			stss = stss.u32(s)
+		}
+		children = append(children, fullBox("stss", 0, 0, stss))
+	}
+
+	stsc := buf{}
+	entries = 0
+	for i, n := range t.chunkSamples {
+		if i > 0 && n == t.chunkSamples[i-1] {
+			continue
+		}
+		stsc = stsc.u32(uint32(i + 1)).u32(n).u32(1)
+		entries++
+	}
+	children = append(children, fullBox("stsc", 0, 0, buf{}.u32(entries), stsc))
+
+	stsz := buf{}.u32(0).u32(uint32(len(t.sizes)))
+	for _, s := range t.sizes {
+		stsz = stsz.u32(s)
+	}
+	children = append(children, fullBox("stsz", 0, 0, stsz))
+
+	large := len(t.chunkOffsets) > 0 && t.chunkOffsets[len(t.chunkOffsets)-1] > math.MaxUint32
+	stco := buf{}.u32(uint32(len(t.chunkOffsets)))
+	for _, o := range t.chunkOffsets {
+		if large {
+			stco = stco.u64(uint64(o))
+		} else {
+			stco = stco.u32(uint32(o))
+		}
+	}
+	if large {
+		children = append(children, fullBox("co64", 0, 0, stco))
+	} else {
+		children = append(children, fullBox("stco", 0, 0, stco))
+	}
+	return box("stbl", children...)
+}
+
+// mediaDuration 轨道的时长，单位为轨道的 timescale
+func (t *trackTable) mediaDuration() uint64 {
+	var d uint64
+	for _, v := range t.durations() {
+		d += uint64(v)
+	}
+	return d
+}
+
+func (t *trackTable) trak() (trak []byte, duration uint64) {
+	media := t.mediaDuration()
+	mediaMs := uint64(math.Round(float64(media) * movieTimescale / float64(t.timescale)))
+	// 轨道晚于文件开头的部分使用空白编辑，B 帧的显示时间偏移由 media_time 抵消
+	var edits []editEntry
+	if t.startDts > 0 {
+		edits = append(edits, editEntry{duration: uint64(t.startDts), mediaTime: -1})
+	}
+	if t.startDts > 0 || (len(t.cts) > 0 && t.cts[0] != 0) {
+		edits = append(edits, editEntry{duration: mediaMs, mediaTime: int64(t.cts[0])})
+	}
+	duration = uint64(t.startDts) + mediaMs
+	children := [][]byte{tkhd(t.track, t.id, duration)}
+	if len(edits) > 0 {
+		children = append(children, edts(edits))
+	}
+	children = append(children, mdia(t.track, media, t.stbl()))
+	return box("trak", children...), duration
+}
+
+// mp4Writer 输出普通的 mp4：ftyp、mdat、moov
+type mp4Writer struct {
+	w         io.WriteSeeker
+	offset    int64
+	mdatStart int64
+	tables    map[*track]*trackTable
+	last      *track
+}
+
+func newMp4Writer(w io.WriteSeeker) (*mp4Writer, error) {
+	m := &mp4Writer{w: w, tables: make(map[*track]*trackTable)}
+	if err := m.write(ftyp("isom", "isom", "iso2", "avc1", "mp41")); err != nil {
+		return nil, err
+	}
+	// 使用 64 位长度的 mdat，写完后回填
+	m.mdatStart = m.offset
+	if err := m.write(buf{}.u32(1).str("mdat").u64(0)); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *mp4Writer) write(b []byte) error {
+	n, err := m.w.Write(b)
+	m.offset += int64(n)
+	return err
+}
+
+func (m *mp4Writer) writeSample(t *track, s *sample) error {
+	table, ok := m.tables[t]
+	if !ok {
+		table = &trackTable{track: t}
+		m.tables[t] = table
+	}
+	table.add(s, m.offset, m.last != t)
+	m.last = t
+	return m.write(s.data)
+}
+
+func (m *mp4Writer) close(video, audio *track) (time.Duration, error) {
+	mdatSize := m.offset - m.mdatStart
+	if _, err := m.w.Seek(m.mdatStart+8, io.SeekStart); err != nil {
+		return 0, err
+	}
+	if _, err := m.w.Write(binary.BigEndian.AppendUint64(nil, uint64(mdatSize))); err != nil {
+		return 0, err
+	}
+	if _, err := m.w.Seek(m.offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	var traks [][]byte
+	var duration uint64
+	nextID := uint32(1)
+	for _, item := range []struct {
+		t  *track
+		id uint32
+	}{{video, videoTrackID}, {audio, audioTrackID}} {
+		table, ok := m.tables[item.t]
+		if item.t == nil || !ok {
+			continue
+		}
+		table.id = item.id
+		trak, d := table.trak()
+		traks = append(traks, trak)
+		duration = max(duration, d)
+		nextID = item.id + 1
+	}
+	moov := box("moov", append([][]byte{mvhd(duration, nextID)}, traks...)...)
+	return time.Duration(duration) * time.Millisecond, m.write(moov)
+}
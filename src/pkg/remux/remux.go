@@ -0,0 +1,129 @@
+package remux
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// ErrNoTrack FLV 中没有可以转封装的 H.264/HEVC 视频或 AAC 音频
+var ErrNoTrack = errors.New("no supported audio or video track")
+
+// defaultFragmentDuration fragmented mp4 每个片段的默认最短时长
+const defaultFragmentDuration = time.Second
+
+// Options 转封装选项
+type Options struct {
+	// Fragmented 输出 fragmented mp4，moov 位于文件开头，之后是若干 moof+mdat 片段
+	Fragmented bool
+	// FragmentDuration 每个片段的最短时长，默认为 1s；有视频时在关键帧处切分
+	FragmentDuration time.Duration
+}
+
+// Stats 转封装的结果
+type Stats struct {
+	VideoCodec   string        `json:"video_codec,omitempty"`
+	AudioCodec   string        `json:"audio_codec,omitempty"`
+	VideoSamples int           `json:"video_samples"`
+	AudioSamples int           `json:"audio_samples"`
+	Duration     time.Duration `json:"duration"`
+	Fragments    int           `json:"fragments,omitempty"`
+}
+
+// mp4Muxer 普通 mp4 与 fragmented mp4 的共同接口
+type mp4Muxer interface {
+	writeSample(video, audio, t *track, s *sample) error
+	close(video, audio *track) (time.Duration, error)
+}
+
+// progressiveMuxer 适配 mp4Writer
+type progressiveMuxer struct{ *mp4Writer }
+
+func (m progressiveMuxer) writeSample(_, _, t *track, s *sample) error {
+	return m.mp4Writer.writeSample(t, s)
+}
+
+// FlvToMp4 将 r 中的 FLV 转封装为 MP4 写入 w，支持 H.264/HEVC 视频与 AAC 音频。
+// 普通 mp4 需要在结束时回填 mdat 的长度，所以 w 需要支持 Seek
+func FlvToMp4(r io.Reader, w io.WriteSeeker, opts Options) (*Stats, error) {
+	d, err := newDemuxer(r)
+	if err != nil {
+		return nil, err
+	}
+	var m mp4Muxer
+	if opts.Fragmented {
+		if opts.FragmentDuration <= 0 {
+			opts.FragmentDuration = defaultFragmentDuration
+		}
+		m = newFmp4Writer(w, opts.FragmentDuration)
+	} else {
+		mw, err := newMp4Writer(w)
+		if err != nil {
+			return nil, err
+		}
+		m = progressiveMuxer{mw}
+	}
+
+	stats := &Stats{}
+	for {
+		t, s, err := d.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return stats, err
+		}
+		if err := m.writeSample(d.video, d.audio, t, s); err != nil {
+			return stats, err
+		}
+		if t.video {
+			stats.VideoSamples++
+		} else {
+			stats.AudioSamples++
+		}
+	}
+	if stats.VideoSamples+stats.AudioSamples == 0 {
+		return stats, ErrNoTrack
+	}
+	if d.video != nil && stats.VideoSamples > 0 {
+		stats.VideoCodec = d.video.codec
+	}
+	if d.audio != nil && stats.AudioSamples > 0 {
+		stats.AudioCodec = d.audio.codec
+	}
+	if stats.Duration, err = m.close(d.video, d.audio); err != nil {
+		return stats, err
+	}
+	if fm, ok := m.(*fmp4Writer); ok {
+		stats.Fragments = fm.fragments
+	}
+	return stats, nil
+}
+
+// ConvertFile 将 FLV 文件 src 转封装为 MP4 文件 dst，失败时删除不完整的 dst
+func ConvertFile(src, dst string, opts Options) (stats *Stats, err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(dst)
+		}
+	}()
+	stats, err = FlvToMp4(in, out, opts)
+	if err != nil {
+		return stats, fmt.Errorf("failed to remux %s: %w", src, err)
+	}
+	return stats, nil
+}
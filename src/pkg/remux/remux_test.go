@@ -0,0 +1,290 @@
+package remux
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mp4Box 测试中解析出的 box，offset 为 box 在文件中的位置
+type mp4Box struct {
+	typ      string
+	offset   int
+	payload  []byte
+	children []*mp4Box
+}
+
+var containerBoxes = map[string]bool{
+	"moov": true, "trak": true, "mdia": true, "minf": true, "stbl": true,
+	"edts": true, "mvex": true, "moof": true, "traf": true, "dinf": true,
+}
+
+func parseBoxes(t *testing.T, b []byte, base int) []*mp4Box {
+	var ret []*mp4Box
+	for len(b) > 0 {
+		if !assert.GreaterOrEqual(t, len(b), 8) {
+			return ret
+		}
+		size, header := int(binary.BigEndian.Uint32(b)), 8
+		if size == 1 {
+			size, header = int(binary.BigEndian.Uint64(b[8:])), 16
+		}
+		if !assert.LessOrEqual(t, size, len(b)) {
+			return ret
+		}
+		box := &mp4Box{typ: string(b[4:8]), offset: base, payload: b[header:size]}
+		if containerBoxes[box.typ] {
+			box.children = parseBoxes(t, box.payload, base+header)
+		}
+		ret = append(ret, box)
+		b, base = b[size:], base+size
+	}
+	return ret
+}
+
+func (b *mp4Box) find(path ...string) *mp4Box {
+	for _, c := range b.children {
+		if c.typ == path[0] {
+			if len(path) == 1 {
+				return c
+			}
+			return c.find(path[1:]...)
+		}
+	}
+	return nil
+}
+
+func (b *mp4Box) all(typ string) []*mp4Box {
+	var ret []*mp4Box
+	for _, c := range b.children {
+		if c.typ == typ {
+			ret = append(ret, c)
+		}
+	}
+	return ret
+}
+
+func u32(b []byte, offset int) uint32 { return binary.BigEndian.Uint32(b[offset:]) }
+func u64(b []byte, offset int) uint64 { return binary.BigEndian.Uint64(b[offset:]) }
+
+// trackResult 从 mp4 中读出的一路轨道
+type trackResult struct {
+	codec     string
+	timescale uint32
+	samples   int
+	syncs     int
+	// duration 所有帧时长之和（毫秒）
+	duration time.Duration
+	// start 第一帧的解码时间（毫秒）
+	start time.Duration
+	// firstSample 第一帧在文件中的位置
+	firstSample int
+	width       uint16
+	height      uint16
+}
+
+func toDuration(v uint64, timescale uint32) time.Duration {
+	return time.Duration(v) * time.Second / time.Duration(timescale)
+}
+
+// readMp4 解析普通 mp4 或 fragmented mp4，按 track ID 返回每一路轨道
+func readMp4(t *testing.T, b []byte) (map[uint32]*trackResult, []string) {
+	root := &mp4Box{children: parseBoxes(t, b, 0)}
+	var top []string
+	for _, c := range root.children {
+		top = append(top, c.typ)
+	}
+	moov := root.find("moov")
+	if !assert.NotNil(t, moov) {
+		return nil, top
+	}
+	tracks := make(map[uint32]*trackResult)
+	for _, trak := range moov.all("trak") {
+		tkhd := trak.find("tkhd").payload
+		stbl := trak.find("mdia", "minf", "stbl")
+		r := &trackResult{
+			codec:     string(stbl.find("stsd").payload[12:16]),
+			timescale: u32(trak.find("mdia", "mdhd").payload, 20),
+			samples:   int(u32(stbl.find("stsz").payload, 8)),
+			width:     uint16(u32(tkhd, 88) >> 16),
+			height:    uint16(u32(tkhd, 92) >> 16),
+		}
+		var total uint64
+		stts := stbl.find("stts").payload
+		for i := 0; i < int(u32(stts, 4)); i++ {
+			total += uint64(u32(stts, 8+i*8)) * uint64(u32(stts, 12+i*8))
+		}
+		r.duration = toDuration(total, r.timescale)
+		if stss := stbl.find("stss"); stss != nil {
+			r.syncs = int(u32(stss.payload, 4))
+		} else {
+			r.syncs = r.samples
+		}
+		if edts := trak.find("edts", "elst"); edts != nil && int64(u64(edts.payload, 16)) == -1 {
+			r.start = time.Duration(u64(edts.payload, 8)) * time.Millisecond
+		}
+		if stco := stbl.find("stco"); stco != nil && u32(stco.payload, 4) > 0 {
+			r.firstSample = int(u32(stco.payload, 8))
+		}
+		tracks[u32(tkhd, 20)] = r
+	}
+
+	// fragmented mp4 的帧信息在各个 moof 中
+	fragmented := moov.find("mvex") != nil
+	for _, moof := range root.all("moof") {
+		for _, traf := range moof.all("traf") {
+			r := tracks[u32(traf.find("tfhd").payload, 4)]
+			trun := traf.find("trun").payload
+			if r.samples == 0 {
+				r.start = toDuration(u64(traf.find("tfdt").payload, 4), r.timescale)
+				r.firstSample = moof.offset + int(u32(trun, 8))
+			}
+			var total uint64
+			for i := 0; i < int(u32(trun, 4)); i++ {
+				total += uint64(u32(trun, 12+i*16))
+				if u32(trun, 20+i*16) == syncSampleFlags {
+					r.syncs++
+				}
+				r.samples++
+			}
+			r.duration += toDuration(total, r.timescale)
+		}
+	}
+	if fragmented {
+		assert.NotEmpty(t, root.all("moof"))
+	}
+	return tracks, top
+}
+
+func TestFlvToMp4(t *testing.T) {
+	type expectedTrack struct {
+		codec         string
+		samples       int
+		syncs         int
+		duration      time.Duration
+		start         time.Duration
+		width, height uint16
+	}
+	tests := []struct {
+		fixture string
+		video   *expectedTrack
+		audio   *expectedTrack
+		// fragments 每秒一个关键帧，fragmented mp4 每个关键帧一个片段
+		fragments int
+	}{
+		{
+			fixture:   "avc_aac.flv",
+			video:     &expectedTrack{codec: CodecAVC, samples: 90, syncs: 3, duration: 3001 * time.Millisecond, width: 1920, height: 1080},
+			audio:     &expectedTrack{codec: CodecAAC, samples: 130, syncs: 130, duration: 3018 * time.Millisecond, start: 20 * time.Millisecond},
+			fragments: 3,
+		},
+		{
+			fixture:   "hevc_aac.flv",
+			video:     &expectedTrack{codec: CodecHEVC, samples: 50, syncs: 2, duration: 2000 * time.Millisecond, width: 1280, height: 720},
+			audio:     &expectedTrack{codec: CodecAAC, samples: 94, syncs: 94, duration: 2005 * time.Millisecond},
+			fragments: 2,
+		},
+		{
+			fixture:   "audio_only.flv",
+			audio:     &expectedTrack{codec: CodecAAC, samples: 47, syncs: 47, duration: 1003 * time.Millisecond},
+			fragments: 1,
+		},
+	}
+	for _, tt := range tests {
+		for _, fragmented := range []bool{false, true} {
+			name := tt.fixture
+			if fragmented {
+				name += "/fragmented"
+			}
+			t.Run(name, func(t *testing.T) {
+				dst := filepath.Join(t.TempDir(), "out.mp4")
+				stats, err := ConvertFile(filepath.Join("testdata", tt.fixture), dst, Options{Fragmented: fragmented})
+				if !assert.NoError(t, err) {
+					return
+				}
+				b, err := os.ReadFile(dst)
+				assert.NoError(t, err)
+				tracks, top := readMp4(t, b)
+				if fragmented {
+					assert.Equal(t, []string{"ftyp", "moov", "moof", "mdat"}, top[:4])
+					assert.Equal(t, tt.fragments, stats.Fragments)
+				} else {
+					assert.Equal(t, []string{"ftyp", "mdat", "moov"}, top)
+				}
+
+				for id, want := range map[uint32]*expectedTrack{videoTrackID: tt.video, audioTrackID: tt.audio} {
+					got, ok := tracks[id]
+					if want == nil {
+						assert.False(t, ok)
+						continue
+					}
+					if !assert.True(t, ok) {
+						continue
+					}
+					assert.Equal(t, want.codec, got.codec)
+					assert.Equal(t, want.samples, got.samples)
+					assert.Equal(t, want.syncs, got.syncs)
+					assert.InDelta(t, want.duration, got.duration, float64(time.Millisecond))
+					assert.InDelta(t, want.start, got.start, float64(time.Millisecond))
+					assert.Equal(t, want.width, got.width)
+					assert.Equal(t, want.height, got.height)
+					// 第一帧的数据与 FLV 中的内容一致
+					prefix := []byte{0, 0, 0, 64, 5}
+					if id == audioTrackID {
+						prefix = []byte{0x21, 0x10, 0}
+					}
+					assert.Equal(t, prefix, b[got.firstSample:got.firstSample+len(prefix)])
+				}
+				if tt.video != nil {
+					assert.Equal(t, tt.video.samples, stats.VideoSamples)
+					assert.Equal(t, tt.video.codec, stats.VideoCodec)
+				}
+				assert.Equal(t, tt.audio.samples, stats.AudioSamples)
+				duration := tt.audio.start + tt.audio.duration
+				if tt.video != nil {
+					duration = max(duration, tt.video.start+tt.video.duration)
+				}
+				assert.InDelta(t, duration, stats.Duration, float64(time.Millisecond))
+			})
+		}
+	}
+}
+
+func TestParseAVCSPS(t *testing.T) {
+	for _, size := range [][2]int{{1920, 1080}, {1280, 720}, {854, 480}} {
+		w, h, err := parseAVCSPS(highProfileSPS(uint32(size[0]), uint32(size[1])))
+		assert.NoError(t, err)
+		assert.Equal(t, size, [2]int{w, h})
+	}
+	_, _, err := parseAVCSPS([]byte{0x67, 100})
+	assert.Error(t, err)
+}
+
+func TestConvertFileErrors(t *testing.T) {
+	dir := t.TempDir()
+	// 录制中断导致的不完整文件仍然可以转换
+	b, err := os.ReadFile(filepath.Join("testdata", "avc_aac.flv"))
+	assert.NoError(t, err)
+	src := filepath.Join(dir, "truncated.flv")
+	assert.NoError(t, os.WriteFile(src, b[:len(b)/2+7], 0644))
+	stats, err := ConvertFile(src, filepath.Join(dir, "truncated.mp4"), Options{})
+	assert.NoError(t, err)
+	assert.Greater(t, stats.VideoSamples, 0)
+	assert.Less(t, stats.VideoSamples, 90)
+
+	// 没有音视频帧时返回错误并删除输出文件
+	src = filepath.Join(dir, "empty.flv")
+	assert.NoError(t, os.WriteFile(src, b[:13], 0644))
+	dst := filepath.Join(dir, "empty.mp4")
+	_, err = ConvertFile(src, dst, Options{})
+	assert.ErrorIs(t, err, ErrNoTrack)
+	assert.NoFileExists(t, dst)
+
+	_, err = ConvertFile(filepath.Join(dir, "missing.flv"), dst, Options{})
+	assert.Error(t, err)
+	assert.NoFileExists(t, dst)
+}
@@ -0,0 +1,320 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultRegion = "us-east-1"
+
+// Config S3 兼容对象存储的连接配置
+type Config struct {
+	// Endpoint 服务地址，例如 http://127.0.0.1:9000、https://s3.amazonaws.com
+	Endpoint  string
+	Region    string
+	AccessKey string
+	SecretKey string
+	// PathStyle 使用 endpoint/bucket/key 形式的地址，MinIO 等自建服务通常需要开启；否则使用 bucket.endpoint/key
+	PathStyle bool
+}
+
+// Error S3 返回的错误
+type Error struct {
+	StatusCode int
+	Code       string `xml:"Code"`
+	Message    string `xml:"Message"`
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("s3: status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("s3: %s: %s (status %d)", e.Code, e.Message, e.StatusCode)
+}
+
+// IsNotFound 错误是否表示对象或分片上传不存在
+func IsNotFound(err error) bool {
+	e, ok := err.(*Error)
+	return ok && e.StatusCode == http.StatusNotFound
+}
+
+// Client 一个最小的 S3 客户端，只包含上传录制文件需要的接口
+type Client struct {
+	endpoint  *url.URL
+	signer    Signer
+	pathStyle bool
+	hc        *http.Client
+	// for test
+	now func() time.Time
+}
+
+func New(cfg Config) (*Client, error) {
+	u, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint: %s", cfg.Endpoint)
+	}
+	if cfg.Region == "" {
+		cfg.Region = defaultRegion
+	}
+	return &Client{
+		endpoint: u,
+		signer: Signer{
+			AccessKey: cfg.AccessKey,
+			SecretKey: cfg.SecretKey,
+			Region:    cfg.Region,
+			Service:   "s3",
+		},
+		pathStyle: cfg.PathStyle,
+		hc:        new(http.Client),
+		now:       time.Now,
+	}, nil
+}
+
+// ObjectURL 返回对象的地址
+func (c *Client) ObjectURL(bucket, key string) string {
+	u := *c.endpoint
+	// 保留 endpoint 中的路径前缀，便于通过反向代理访问
+	path := strings.TrimSuffix(u.EscapedPath(), "/") + "/"
+	if c.pathStyle {
+		path += uriEncode(bucket, true) + "/"
+	} else {
+		u.Host = bucket + "." + u.Host
+	}
+	path += uriEncode(key, false)
+	u.Path, u.RawPath, u.RawQuery = "", "", ""
+	return u.String() + path
+}
+
+// do 发送签名后的请求，状态码不是 2xx 时返回 *Error
+func (c *Client) do(ctx context.Context, method, bucket, key string, query url.Values, header http.Header, body []byte) (*http.Response, error) {
+	rawURL := c.ObjectURL(bucket, key)
+	if len(query) > 0 {
+		rawURL += "?" + canonicalQuery(query)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	payloadHash := hashHex(body)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	c.signer.Sign(req, payloadHash, c.now())
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		e := &Error{StatusCode: resp.StatusCode}
+		if data, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024)); err == nil {
+			xml.Unmarshal(data, e)
+		}
+		return nil, e
+	}
+	return resp, nil
+}
+
+// doXML 发送请求并将响应解析到 v
+func (c *Client) doXML(ctx context.Context, method, bucket, key string, query url.Values, body []byte, v any) error {
+	resp, err := c.do(ctx, method, bucket, key, query, nil, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if v == nil {
+		return nil
+	}
+	return xml.NewDecoder(resp.Body).Decode(v)
+}
+
+// ContentMD5 返回 Content-MD5 请求头的值以及用于和 ETag 比较的十六进制 MD5
+func ContentMD5(b []byte) (header string, etag string) {
+	sum := md5.Sum(b)
+	return base64.StdEncoding.EncodeToString(sum[:]), fmt.Sprintf("%x", sum)
+}
+
+// TrimETag 去掉 ETag 两侧的引号
+func TrimETag(etag string) string {
+	return strings.Trim(etag, `"`)
+}
+
+// ObjectInfo HeadObject 的结果
+type ObjectInfo struct {
+	Size int64
+	ETag string
+}
+
+func (c *Client) HeadObject(ctx context.Context, bucket, key string) (*ObjectInfo, error) {
+	resp, err := c.do(ctx, http.MethodHead, bucket, key, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return &ObjectInfo{Size: resp.ContentLength, ETag: TrimETag(resp.Header.Get("ETag"))}, nil
+}
+
+// PutObject 上传一个完整的对象，服务端会按 Content-MD5 校验内容，返回 ETag
+func (c *Client) PutObject(ctx context.Context, bucket, key, contentType string, body []byte) (string, error) {
+	md5Header, _ := ContentMD5(body)
+	header := http.Header{}
+	header.Set("Content-MD5", md5Header)
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	resp, err := c.do(ctx, http.MethodPut, bucket, key, nil, header, body)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	return TrimETag(resp.Header.Get("ETag")), nil
+}
+
+type initiateMultipartUploadResult struct {
+	UploadID string `xml:"UploadId"`
+}
+
+func (c *Client) CreateMultipartUpload(ctx context.Context, bucket, key, contentType string) (string, error) {
+	header := http.Header{}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	resp, err := c.do(ctx, http.MethodPost, bucket, key, url.Values{"uploads": {""}}, header, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	result := new(initiateMultipartUploadResult)
+	if err := xml.NewDecoder(resp.Body).Decode(result); err != nil {
+		return "", err
+	}
+	return result.UploadID, nil
+}
+
+// UploadPart 上传一个分片，服务端会按 Content-MD5 校验内容，返回 ETag
+func (c *Client) UploadPart(ctx context.Context, bucket, key, uploadID string, partNumber int, body []byte) (string, error) {
+	md5Header, _ := ContentMD5(body)
+	header := http.Header{}
+	header.Set("Content-MD5", md5Header)
+	query := url.Values{"partNumber": {strconv.Itoa(partNumber)}, "uploadId": {uploadID}}
+	resp, err := c.do(ctx, http.MethodPut, bucket, key, query, header, body)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	return TrimETag(resp.Header.Get("ETag")), nil
+}
+
+// Part 已上传的分片
+type Part struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+	Size       int64  `xml:"Size"`
+}
+
+type listPartsResult struct {
+	Parts                []Part `xml:"Part"`
+	IsTruncated          bool   `xml:"IsTruncated"`
+	NextPartNumberMarker int    `xml:"NextPartNumberMarker"`
+}
+
+// ListParts 返回分片上传中已经上传的全部分片
+func (c *Client) ListParts(ctx context.Context, bucket, key, uploadID string) ([]Part, error) {
+	var parts []Part
+	marker := 0
+	for {
+		query := url.Values{"uploadId": {uploadID}}
+		if marker > 0 {
+			query.Set("part-number-marker", strconv.Itoa(marker))
+		}
+		result := new(listPartsResult)
+		if err := c.doXML(ctx, http.MethodGet, bucket, key, query, nil, result); err != nil {
+			return nil, err
+		}
+		for _, p := range result.Parts {
+			p.ETag = TrimETag(p.ETag)
+			parts = append(parts, p)
+		}
+		if !result.IsTruncated || result.NextPartNumberMarker <= marker {
+			return parts, nil
+		}
+		marker = result.NextPartNumberMarker
+	}
+}
+
+// MultipartUpload 未完成的分片上传
+type MultipartUpload struct {
+	Key       string    `xml:"Key"`
+	UploadID  string    `xml:"UploadId"`
+	Initiated time.Time `xml:"Initiated"`
+}
+
+type listMultipartUploadsResult struct {
+	Uploads []MultipartUpload `xml:"Upload"`
+}
+
+// ListMultipartUploads 返回 key 以 prefix 开头的未完成的分片上传（只返回第一页）
+func (c *Client) ListMultipartUploads(ctx context.Context, bucket, prefix string) ([]MultipartUpload, error) {
+	result := new(listMultipartUploadsResult)
+	query := url.Values{"uploads": {""}, "prefix": {prefix}}
+	if err := c.doXML(ctx, http.MethodGet, bucket, "", query, nil, result); err != nil {
+		return nil, err
+	}
+	return result.Uploads, nil
+}
+
+type completePart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+type completeMultipartUpload struct {
+	XMLName xml.Name       `xml:"CompleteMultipartUpload"`
+	Parts   []completePart `xml:"Part"`
+}
+
+// completeMultipartUploadResult 合并失败时 S3 可能返回 200 和 Error
+type completeMultipartUploadResult struct {
+	XMLName xml.Name
+	ETag    string `xml:"ETag"`
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// CompleteMultipartUpload 按分片号顺序合并分片，返回对象的 ETag
+func (c *Client) CompleteMultipartUpload(ctx context.Context, bucket, key, uploadID string, parts []Part) (string, error) {
+	body := completeMultipartUpload{}
+	for _, p := range parts {
+		body.Parts = append(body.Parts, completePart{PartNumber: p.PartNumber, ETag: `"` + p.ETag + `"`})
+	}
+	data, err := xml.Marshal(body)
+	if err != nil {
+		return "", err
+	}
+	result := new(completeMultipartUploadResult)
+	if err := c.doXML(ctx, http.MethodPost, bucket, key, url.Values{"uploadId": {uploadID}}, data, result); err != nil {
+		return "", err
+	}
+	if result.XMLName.Local == "Error" {
+		return "", &Error{StatusCode: http.StatusOK, Code: result.Code, Message: result.Message}
+	}
+	return TrimETag(result.ETag), nil
+}
+
+func (c *Client) AbortMultipartUpload(ctx context.Context, bucket, key, uploadID string) error {
+	return c.doXML(ctx, http.MethodDelete, bucket, key, url.Values{"uploadId": {uploadID}}, nil, nil)
+}
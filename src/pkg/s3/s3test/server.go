@@ -0,0 +1,324 @@
+// Package s3test 提供一个内存中的 S3 兼容服务，用于测试上传，只支持 path-style 地址
+package s3test
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bililive-go/bililive-go/src/pkg/s3"
+)
+
+// Object 保存的对象
+type Object struct {
+	Data        []byte
+	ContentType string
+	ETag        string
+}
+
+type multipartUpload struct {
+	key         string
+	contentType string
+	initiated   time.Time
+	parts       map[int][]byte
+}
+
+// Server 内存中的 S3 服务，会校验请求签名、Content-MD5 与 x-amz-content-sha256
+type Server struct {
+	*httptest.Server
+	AccessKey string
+	SecretKey string
+	Region    string
+
+	lock    sync.Mutex
+	buckets map[string]map[string]*Object
+	uploads map[string]*multipartUpload
+	nextID  int
+	// failParts 接下来需要失败的分片上传请求数
+	failParts int
+	requests  []string
+}
+
+// NewServer 启动服务并创建 buckets
+func NewServer(accessKey, secretKey string, buckets ...string) *Server {
+	s := &Server{
+		AccessKey: accessKey,
+		SecretKey: secretKey,
+		Region:    "us-east-1",
+		buckets:   make(map[string]map[string]*Object),
+		uploads:   make(map[string]*multipartUpload),
+	}
+	for _, b := range buckets {
+		s.buckets[b] = make(map[string]*Object)
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Object 返回保存的对象，不存在时返回 nil
+func (s *Server) Object(bucket, key string) *Object {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.buckets[bucket][key]
+}
+
+// FailParts 让接下来的 n 个分片上传请求返回 500
+func (s *Server) FailParts(n int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.failParts = n
+}
+
+// Requests 返回收到的请求，格式为 "METHOD 操作"，例如 "PUT UploadPart"
+func (s *Server) Requests() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+// Uploads 返回未完成的分片上传数
+func (s *Server) Uploads() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return len(s.uploads)
+}
+
+type errorResponse struct {
+	XMLName xml.Name `xml:"Error"`
+	Code    string   `xml:"Code"`
+	Message string   `xml:"Message"`
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	xml.NewEncoder(w).Encode(errorResponse{Code: code, Message: message})
+}
+
+func writeXML(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(v)
+}
+
+// verify 校验签名与请求体的哈希
+func (s *Server) verify(r *http.Request, body []byte) error {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 ") {
+		return fmt.Errorf("unsupported authorization")
+	}
+	fields := make(map[string]string)
+	for _, field := range strings.Split(strings.TrimPrefix(auth, "AWS4-HMAC-SHA256 "), ", ") {
+		if k, v, ok := strings.Cut(field, "="); ok {
+			fields[k] = v
+		}
+	}
+	credential := strings.Split(fields["Credential"], "/")
+	if len(credential) != 5 || credential[0] != s.AccessKey || credential[2] != s.Region || credential[3] != "s3" {
+		return fmt.Errorf("invalid credential")
+	}
+	t, err := time.Parse("20060102T150405Z", r.Header.Get("X-Amz-Date"))
+	if err != nil {
+		return err
+	}
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	if sum := sha256.Sum256(body); hex.EncodeToString(sum[:]) != payloadHash {
+		return fmt.Errorf("x-amz-content-sha256 mismatch")
+	}
+	signer := s3.Signer{AccessKey: s.AccessKey, SecretKey: s.SecretKey, Region: s.Region, Service: "s3"}
+	if signer.Signature(r, payloadHash, strings.Split(fields["SignedHeaders"], ";"), t) != fields["Signature"] {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
+}
+
+func md5Hex(b []byte) string {
+	sum := md5.Sum(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "IncompleteBody", err.Error())
+		return
+	}
+	if err := s.verify(r, body); err != nil {
+		writeError(w, http.StatusForbidden, "SignatureDoesNotMatch", err.Error())
+		return
+	}
+	if md5Header := r.Header.Get("Content-MD5"); md5Header != "" {
+		sum := md5.Sum(body)
+		if base64.StdEncoding.EncodeToString(sum[:]) != md5Header {
+			writeError(w, http.StatusBadRequest, "BadDigest", "the Content-MD5 you specified did not match what we received")
+			return
+		}
+	}
+
+	bucketName, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	query := r.URL.Query()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	bucket, ok := s.buckets[bucketName]
+	if !ok {
+		writeError(w, http.StatusNotFound, "NoSuchBucket", "the specified bucket does not exist")
+		return
+	}
+
+	record := func(op string) { s.requests = append(s.requests, r.Method+" "+op) }
+	switch {
+	case key == "" && r.Method == http.MethodGet && query.Has("uploads"):
+		record("ListMultipartUploads")
+		s.listMultipartUploads(w, bucketName, query.Get("prefix"))
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		record("CreateMultipartUpload")
+		s.nextID++
+		id := fmt.Sprintf("%s-%d", bucketName, s.nextID)
+		s.uploads[id] = &multipartUpload{
+			key:         key,
+			contentType: r.Header.Get("Content-Type"),
+			initiated:   time.Now(),
+			parts:       make(map[int][]byte),
+		}
+		writeXML(w, struct {
+			XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+			Bucket   string   `xml:"Bucket"`
+			Key      string   `xml:"Key"`
+			UploadID string   `xml:"UploadId"`
+		}{Bucket: bucketName, Key: key, UploadID: id})
+	case query.Has("uploadId"):
+		upload, ok := s.uploads[query.Get("uploadId")]
+		if !ok || upload.key != key {
+			writeError(w, http.StatusNotFound, "NoSuchUpload", "the specified upload does not exist")
+			return
+		}
+		s.handleUpload(w, r, bucket, query.Get("uploadId"), upload, body)
+	case r.Method == http.MethodPut:
+		record("PutObject")
+		obj := &Object{Data: body, ContentType: r.Header.Get("Content-Type"), ETag: md5Hex(body)}
+		bucket[key] = obj
+		w.Header().Set("ETag", `"`+obj.ETag+`"`)
+	case r.Method == http.MethodHead || r.Method == http.MethodGet:
+		record(map[string]string{http.MethodHead: "HeadObject", http.MethodGet: "GetObject"}[r.Method])
+		obj, ok := bucket[key]
+		if !ok {
+			writeError(w, http.StatusNotFound, "NoSuchKey", "the specified key does not exist")
+			return
+		}
+		w.Header().Set("ETag", `"`+obj.ETag+`"`)
+		w.Header().Set("Content-Length", strconv.Itoa(len(obj.Data)))
+		if r.Method == http.MethodGet {
+			w.Write(obj.Data)
+		}
+	default:
+		writeError(w, http.StatusNotImplemented, "NotImplemented", r.Method+" is not implemented")
+	}
+}
+
+func (s *Server) listMultipartUploads(w http.ResponseWriter, bucket, prefix string) {
+	type upload struct {
+		Key       string    `xml:"Key"`
+		UploadID  string    `xml:"UploadId"`
+		Initiated time.Time `xml:"Initiated"`
+	}
+	result := struct {
+		XMLName xml.Name `xml:"ListMultipartUploadsResult"`
+		Bucket  string   `xml:"Bucket"`
+		Uploads []upload `xml:"Upload"`
+	}{Bucket: bucket}
+	for id, u := range s.uploads {
+		if strings.HasPrefix(id, bucket+"-") && strings.HasPrefix(u.key, prefix) {
+			result.Uploads = append(result.Uploads, upload{Key: u.key, UploadID: id, Initiated: u.initiated})
+		}
+	}
+	sort.Slice(result.Uploads, func(i, j int) bool { return result.Uploads[i].UploadID < result.Uploads[j].UploadID })
+	writeXML(w, result)
+}
+
+func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request, bucket map[string]*Object, id string, upload *multipartUpload, body []byte) {
+	switch r.Method {
+	case http.MethodPut:
+		s.requests = append(s.requests, "PUT UploadPart")
+		if s.failParts > 0 {
+			s.failParts--
+			writeError(w, http.StatusInternalServerError, "InternalError", "injected failure")
+			return
+		}
+		n, err := strconv.Atoi(r.URL.Query().Get("partNumber"))
+		if err != nil || n < 1 || n > 10000 {
+			writeError(w, http.StatusBadRequest, "InvalidArgument", "invalid part number")
+			return
+		}
+		upload.parts[n] = body
+		w.Header().Set("ETag", `"`+md5Hex(body)+`"`)
+	case http.MethodGet:
+		s.requests = append(s.requests, "GET ListParts")
+		type part struct {
+			PartNumber int    `xml:"PartNumber"`
+			ETag       string `xml:"ETag"`
+			Size       int    `xml:"Size"`
+		}
+		result := struct {
+			XMLName xml.Name `xml:"ListPartsResult"`
+			Parts   []part   `xml:"Part"`
+		}{}
+		for n, data := range upload.parts {
+			result.Parts = append(result.Parts, part{PartNumber: n, ETag: `"` + md5Hex(data) + `"`, Size: len(data)})
+		}
+		sort.Slice(result.Parts, func(i, j int) bool { return result.Parts[i].PartNumber < result.Parts[j].PartNumber })
+		writeXML(w, result)
+	case http.MethodPost:
+		s.requests = append(s.requests, "POST CompleteMultipartUpload")
+		var req struct {
+			Parts []struct {
+				PartNumber int    `xml:"PartNumber"`
+				ETag       string `xml:"ETag"`
+			} `xml:"Part"`
+		}
+		if err := xml.Unmarshal(body, &req); err != nil || len(req.Parts) == 0 {
+			writeError(w, http.StatusBadRequest, "MalformedXML", "invalid complete request")
+			return
+		}
+		var data bytes.Buffer
+		var md5s []byte
+		for i, p := range req.Parts {
+			part, ok := upload.parts[p.PartNumber]
+			if !ok || strings.Trim(p.ETag, `"`) != md5Hex(part) || (i > 0 && p.PartNumber <= req.Parts[i-1].PartNumber) {
+				writeError(w, http.StatusBadRequest, "InvalidPart", fmt.Sprintf("invalid part %d", p.PartNumber))
+				return
+			}
+			if i < len(req.Parts)-1 && len(part) < s3.MinPartSize {
+				writeError(w, http.StatusBadRequest, "EntityTooSmall", fmt.Sprintf("part %d is too small", p.PartNumber))
+				return
+			}
+			data.Write(part)
+			sum := md5.Sum(part)
+			md5s = append(md5s, sum[:]...)
+		}
+		obj := &Object{Data: data.Bytes(), ContentType: upload.contentType, ETag: fmt.Sprintf("%s-%d", md5Hex(md5s), len(req.Parts))}
+		bucket[upload.key] = obj
+		delete(s.uploads, id)
+		writeXML(w, struct {
+			XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+			Key     string   `xml:"Key"`
+			ETag    string   `xml:"ETag"`
+		}{Key: upload.key, ETag: `"` + obj.ETag + `"`})
+	case http.MethodDelete:
+		s.requests = append(s.requests, "DELETE AbortMultipartUpload")
+		delete(s.uploads, id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusNotImplemented, "NotImplemented", r.Method+" is not implemented")
+	}
+}
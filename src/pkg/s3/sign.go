@@ -0,0 +1,129 @@
+package s3
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	signAlgorithm = "AWS4-HMAC-SHA256"
+	amzDateFormat = "20060102T150405Z"
+	// EmptyPayloadHash 空请求体的 SHA256
+	EmptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// Signer 使用 AWS Signature Version 4 为请求签名
+type Signer struct {
+	AccessKey string
+	SecretKey string
+	Region    string
+	Service   string
+}
+
+// Sign 设置 X-Amz-Date 并写入 Authorization。参与签名的请求头为 host、content-type、content-md5 以及所有 x-amz-* 头，
+// payloadHash 为请求体的十六进制 SHA256
+func (s Signer) Sign(req *http.Request, payloadHash string, t time.Time) {
+	req.Header.Set("X-Amz-Date", t.UTC().Format(amzDateFormat))
+	var signedHeaders []string
+	for key := range req.Header {
+		key = strings.ToLower(key)
+		if key == "content-type" || key == "content-md5" || strings.HasPrefix(key, "x-amz-") {
+			signedHeaders = append(signedHeaders, key)
+		}
+	}
+	signedHeaders = append(signedHeaders, "host")
+	sort.Strings(signedHeaders)
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		signAlgorithm, s.AccessKey, s.scope(t), strings.Join(signedHeaders, ";"),
+		s.Signature(req, payloadHash, signedHeaders, t)))
+}
+
+func (s Signer) scope(t time.Time) string {
+	return strings.Join([]string{t.UTC().Format("20060102"), s.Region, s.Service, "aws4_request"}, "/")
+}
+
+// Signature 计算请求的签名，signedHeaders 为排好序的小写请求头名称。服务端可以用它校验收到的请求
+func (s Signer) Signature(req *http.Request, payloadHash string, signedHeaders []string, t time.Time) string {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	var headers strings.Builder
+	for _, key := range signedHeaders {
+		value := req.Header.Get(key)
+		if key == "host" {
+			value = host
+		}
+		headers.WriteString(key + ":" + strings.TrimSpace(value) + "\n")
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		headers.String(),
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+	stringToSign := strings.Join([]string{
+		signAlgorithm,
+		t.UTC().Format(amzDateFormat),
+		s.scope(t),
+		hashHex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), t.UTC().Format("20060102"))
+	for _, part := range []string{s.Region, s.Service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, uriEncode(key, true)+"="+uriEncode(value, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode 按 SigV4 的要求编码，只保留 RFC 3986 中的非保留字符；encodeSlash 为 false 时保留 /
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !encodeSlash) {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hashHex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,31 @@
+package s3
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// AWS Signature Version 4 测试集中的 get-vanilla
+func TestSignerSign(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	assert.NoError(t, err)
+	signer := Signer{
+		AccessKey: "AKIDEXAMPLE",
+		SecretKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		Region:    "us-east-1",
+		Service:   "service",
+	}
+	signer.Sign(req, EmptyPayloadHash, time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+	assert.Equal(t, "20150830T123600Z", req.Header.Get("X-Amz-Date"))
+	assert.Equal(t, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, "+
+		"SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		req.Header.Get("Authorization"))
+}
+
+func TestURIEncode(t *testing.T) {
+	assert.Equal(t, "a/b%20c/%E7%9B%B4%E6%92%AD%5B1%5D.flv", uriEncode("a/b c/直播[1].flv", false))
+	assert.Equal(t, "a%2Fb~_-.", uriEncode("a/b~_-.", true))
+}
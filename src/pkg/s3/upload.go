@@ -0,0 +1,156 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	// DefaultPartSize 默认的分片大小
+	DefaultPartSize = 16 << 20
+	// MinPartSize S3 要求除最后一个分片外每个分片至少 5MiB
+	MinPartSize = 5 << 20
+	// maxParts 一次分片上传最多的分片数
+	maxParts = 10000
+)
+
+// UploadOptions 上传文件的选项
+type UploadOptions struct {
+	// PartSize 分片大小，不超过该大小的文件直接上传；文件较大时会自动调大以满足分片数的限制
+	PartSize    int64
+	ContentType string
+	// Progress 报告已上传（包括续传时已存在）的字节数
+	Progress func(uploaded int64)
+}
+
+// UploadResult 上传的结果
+type UploadResult struct {
+	ETag string `json:"etag"`
+	Size int64  `json:"size"`
+	// Parts 分片数，直接上传时为 0
+	Parts int `json:"parts,omitempty"`
+	// ResumedParts 续传时已经存在、校验后跳过的分片数
+	ResumedParts int `json:"resumed_parts,omitempty"`
+}
+
+// UploadFile 上传本地文件。大于分片大小的文件使用分片上传，中断后再次调用会找到同一个 key 未完成的上传，
+// 跳过 MD5 一致的分片继续上传；失败时不会取消分片上传，以便下次续传。
+// 每个分片由服务端按 Content-MD5 校验，完成后再比较对象的大小
+func (c *Client) UploadFile(ctx context.Context, bucket, key, file string, opts UploadOptions) (*UploadResult, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := stat.Size()
+	partSize := opts.PartSize
+	if partSize <= 0 {
+		partSize = DefaultPartSize
+	}
+	partSize = max(partSize, MinPartSize, (size+maxParts-1)/maxParts)
+	progress := opts.Progress
+	if progress == nil {
+		progress = func(int64) {}
+	}
+
+	result := &UploadResult{Size: size}
+	if size <= partSize {
+		body, err := io.ReadAll(f)
+		if err != nil {
+			return nil, err
+		}
+		if result.ETag, err = c.PutObject(ctx, bucket, key, opts.ContentType, body); err != nil {
+			return nil, err
+		}
+		progress(size)
+	} else if err := c.uploadMultipart(ctx, bucket, key, f, size, partSize, opts.ContentType, progress, result); err != nil {
+		return nil, err
+	}
+
+	info, err := c.HeadObject(ctx, bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size != size {
+		return nil, fmt.Errorf("uploaded object size %d does not match file size %d", info.Size, size)
+	}
+	return result, nil
+}
+
+// findMultipartUpload 返回 key 最近一次未完成的分片上传
+func (c *Client) findMultipartUpload(ctx context.Context, bucket, key string) (string, error) {
+	uploads, err := c.ListMultipartUploads(ctx, bucket, key)
+	if err != nil {
+		return "", err
+	}
+	var found *MultipartUpload
+	for i := range uploads {
+		if u := &uploads[i]; u.Key == key && (found == nil || u.Initiated.After(found.Initiated)) {
+			found = u
+		}
+	}
+	if found == nil {
+		return "", nil
+	}
+	return found.UploadID, nil
+}
+
+func (c *Client) uploadMultipart(ctx context.Context, bucket, key string, f io.ReaderAt, size, partSize int64,
+	contentType string, progress func(int64), result *UploadResult) error {
+	uploadID, err := c.findMultipartUpload(ctx, bucket, key)
+	if err != nil {
+		return err
+	}
+	existing := make(map[int]Part)
+	if uploadID != "" {
+		parts, err := c.ListParts(ctx, bucket, key, uploadID)
+		if err != nil && !IsNotFound(err) {
+			return err
+		}
+		if IsNotFound(err) {
+			uploadID = ""
+		}
+		for _, p := range parts {
+			existing[p.PartNumber] = p
+		}
+	}
+	if uploadID == "" {
+		if uploadID, err = c.CreateMultipartUpload(ctx, bucket, key, contentType); err != nil {
+			return err
+		}
+	}
+
+	count := int((size + partSize - 1) / partSize)
+	parts := make([]Part, 0, count)
+	buf := make([]byte, partSize)
+	var uploaded int64
+	for i := 1; i <= count; i++ {
+		offset := int64(i-1) * partSize
+		n := min(partSize, size-offset)
+		if _, err := f.ReadAt(buf[:n], offset); err != nil {
+			return err
+		}
+		body := buf[:n]
+		_, sum := ContentMD5(body)
+		etag := sum
+		if p, ok := existing[i]; ok && p.ETag == sum && p.Size == n {
+			result.ResumedParts++
+		} else if etag, err = c.UploadPart(ctx, bucket, key, uploadID, i, body); err != nil {
+			return fmt.Errorf("failed to upload part %d: %w", i, err)
+		}
+		parts = append(parts, Part{PartNumber: i, ETag: etag, Size: n})
+		uploaded += n
+		progress(uploaded)
+	}
+	if result.ETag, err = c.CompleteMultipartUpload(ctx, bucket, key, uploadID, parts); err != nil {
+		return err
+	}
+	result.Parts = count
+	return nil
+}
@@ -0,0 +1,115 @@
+package s3_test
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bililive-go/bililive-go/src/pkg/s3"
+	"github.com/bililive-go/bililive-go/src/pkg/s3/s3test"
+)
+
+func writeRandomFile(t *testing.T, size int) (string, []byte) {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(int64(size))).Read(data)
+	file := filepath.Join(t.TempDir(), "record.flv")
+	assert.NoError(t, os.WriteFile(file, data, 0644))
+	return file, data
+}
+
+func newTestClient(t *testing.T, srv *s3test.Server, secretKey string) *s3.Client {
+	c, err := s3.New(s3.Config{Endpoint: srv.URL, AccessKey: srv.AccessKey, SecretKey: secretKey, PathStyle: true})
+	assert.NoError(t, err)
+	return c
+}
+
+func TestUploadFile(t *testing.T) {
+	srv := s3test.NewServer("minio", "minio123", "records")
+	defer srv.Close()
+	c := newTestClient(t, srv, "minio123")
+	ctx := context.Background()
+
+	file, data := writeRandomFile(t, 1024)
+	key := "哔哩哔哩/主播 A/[2024-01-01 20-00-00].flv"
+	result, err := c.UploadFile(ctx, "records", key, file, s3.UploadOptions{ContentType: "video/x-flv"})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, result.Parts)
+	if obj := srv.Object("records", key); assert.NotNil(t, obj) {
+		assert.Equal(t, data, obj.Data)
+		assert.Equal(t, "video/x-flv", obj.ContentType)
+	}
+	assert.Equal(t, srv.URL+"/records/%E5%93%94%E5%93%A9%E5%93%94%E5%93%A9/%E4%B8%BB%E6%92%AD%20A/%5B2024-01-01%2020-00-00%5D.flv",
+		c.ObjectURL("records", key))
+
+	_, err = newTestClient(t, srv, "wrong").UploadFile(ctx, "records", "a.flv", file, s3.UploadOptions{})
+	if e, ok := err.(*s3.Error); assert.True(t, ok) {
+		assert.Equal(t, "SignatureDoesNotMatch", e.Code)
+	}
+	_, err = c.UploadFile(ctx, "missing", "a.flv", file, s3.UploadOptions{})
+	assert.True(t, s3.IsNotFound(err))
+}
+
+func TestUploadFileMultipartResume(t *testing.T) {
+	srv := s3test.NewServer("minio", "minio123", "records")
+	defer srv.Close()
+	c := newTestClient(t, srv, "minio123")
+	ctx := context.Background()
+	file, data := writeRandomFile(t, 2*s3.MinPartSize+1024)
+
+	// 第一个分片上传后中断
+	var progress []int64
+	_, err := c.UploadFile(ctx, "records", "a.flv", file, s3.UploadOptions{
+		PartSize: s3.MinPartSize,
+		Progress: func(uploaded int64) {
+			progress = append(progress, uploaded)
+			srv.FailParts(1)
+		},
+	})
+	assert.Error(t, err)
+	assert.Equal(t, []int64{s3.MinPartSize}, progress)
+	assert.Nil(t, srv.Object("records", "a.flv"))
+	assert.Equal(t, 1, srv.Uploads())
+
+	progress = nil
+	result, err := c.UploadFile(ctx, "records", "a.flv", file, s3.UploadOptions{
+		PartSize: s3.MinPartSize,
+		Progress: func(uploaded int64) { progress = append(progress, uploaded) },
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, result.Parts)
+	assert.Equal(t, 1, result.ResumedParts)
+	assert.Equal(t, []int64{s3.MinPartSize, 2 * s3.MinPartSize, int64(len(data))}, progress)
+	if obj := srv.Object("records", "a.flv"); assert.NotNil(t, obj) {
+		assert.True(t, bytes.Equal(data, obj.Data))
+		assert.Equal(t, obj.ETag, result.ETag)
+	}
+	assert.Equal(t, 0, srv.Uploads())
+}
+
+// 设置 S3_TEST_ENDPOINT、S3_TEST_ACCESS_KEY、S3_TEST_SECRET_KEY、S3_TEST_BUCKET 后在真实的服务（例如本地的 MinIO）上测试
+func TestUploadFileWithServer(t *testing.T) {
+	endpoint := os.Getenv("S3_TEST_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("S3_TEST_ENDPOINT is not set")
+	}
+	c, err := s3.New(s3.Config{
+		Endpoint:  endpoint,
+		AccessKey: os.Getenv("S3_TEST_ACCESS_KEY"),
+		SecretKey: os.Getenv("S3_TEST_SECRET_KEY"),
+		PathStyle: true,
+	})
+	assert.NoError(t, err)
+	bucket := os.Getenv("S3_TEST_BUCKET")
+	for _, size := range []int{1024, 2*s3.MinPartSize + 1024} {
+		file, _ := writeRandomFile(t, size)
+		result, err := c.UploadFile(context.Background(), bucket, "bililive-go-test/直播 录像.flv", file, s3.UploadOptions{PartSize: s3.MinPartSize})
+		if assert.NoError(t, err) {
+			assert.EqualValues(t, size, result.Size)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"net/http"
+
+	"github.com/bililive-go/bililive-go/src/configs"
+)
+
+// SetProxy 设置 transport 使用的代理，proxy 为空时不修改，为 configs.ProxyDirect 时直连，不使用环境变量中的代理
+func SetProxy(transport *http.Transport, proxy string) error {
+	switch proxy {
+	case "":
+		return nil
+	case configs.ProxyDirect:
+		transport.Proxy = nil
+		return nil
+	}
+	u, err := configs.ParseProxyUrl(proxy)
+	if err != nil {
+		return err
+	}
+	transport.Proxy = http.ProxyURL(u)
+	return nil
+}
+
+// NewProxyClient 创建通过 proxy 访问的 http.Client，proxy 为空时与 http.DefaultClient 的行为相同，为 configs.ProxyDirect 时直连
+func NewProxyClient(proxy string) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if err := SetProxy(transport, proxy); err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: transport}, nil
+}
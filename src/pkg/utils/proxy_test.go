@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bililive-go/bililive-go/src/configs"
+)
+
+func TestNewProxyClient(t *testing.T) {
+	t.Setenv("HTTP_PROXY", "http://127.0.0.1:3128")
+	req := &http.Request{URL: &url.URL{Scheme: "http", Host: "example.com"}}
+	proxyOf := func(proxy string) *url.URL {
+		hc, err := NewProxyClient(proxy)
+		assert.NoError(t, err)
+		transport := hc.Transport.(*http.Transport)
+		if transport.Proxy == nil {
+			return nil
+		}
+		u, err := transport.Proxy(req)
+		assert.NoError(t, err)
+		return u
+	}
+
+	assert.Equal(t, "socks5://127.0.0.1:1080", proxyOf("socks5://127.0.0.1:1080").String())
+	// 明确设置为直连时不使用环境变量中的代理
+	assert.Nil(t, proxyOf(configs.ProxyDirect))
+	_, err := NewProxyClient("ftp://127.0.0.1")
+	assert.Error(t, err)
+}
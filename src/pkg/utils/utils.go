@@ -26,7 +26,7 @@ func init() {
 }
 
 func GetFFmpegPath(ctx context.Context) (string, error) {
-	path := instance.GetInstance(ctx).Config.FfmpegPath
+	path := instance.GetInstance(ctx).GetConfig().FfmpegPath
 	if path != "" {
 		_, err := os.Stat(path)
 		if err == nil {
@@ -0,0 +1,109 @@
+package recorders
+
+import (
+	"context"
+	"net/url"
+	"path"
+	"sync"
+
+	"github.com/bililive-go/bililive-go/src/instance"
+	"github.com/bililive-go/bililive-go/src/live"
+	"github.com/bililive-go/bililive-go/src/pkg/danmaku"
+)
+
+// danmakuRecorder 在录制期间保持弹幕连接，弹幕文件与视频文件同名并随视频分段一起切换
+type danmakuRecorder struct {
+	format string
+	cancel context.CancelFunc
+
+	lock   sync.Mutex
+	writer danmaku.Writer
+}
+
+// startDanmaku 未开启弹幕录制或直播平台不支持弹幕时不做任何事
+func (r *recorder) startDanmaku(ctx context.Context) {
+	cfg := instance.GetInstance(ctx).GetConfig()
+	if !cfg.Danmaku.Enable {
+		return
+	}
+	provider, ok := live.GetDanmakuProvider(r.Live)
+	if !ok {
+		return
+	}
+	client, err := provider.NewDanmakuClient()
+	if err != nil {
+		r.getLogger().WithError(err).Warn("failed to create danmaku client")
+		return
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	d := &danmakuRecorder{
+		format: cfg.Danmaku.Format,
+		cancel: cancel,
+	}
+	r.danmaku = d
+	go func() {
+		if err := client.Run(ctx, d.write); err != nil {
+			r.getLogger().WithError(err).Warn("danmaku client exited")
+		}
+	}()
+}
+
+func (r *recorder) stopDanmaku() {
+	if r.danmaku == nil {
+		return
+	}
+	r.danmaku.cancel()
+	r.danmaku.closeFile()
+}
+
+// openDanmakuFile 为新的视频文件创建对应的弹幕文件，返回弹幕文件名
+func (r *recorder) openDanmakuFile(info *live.Info, videoFile string) string {
+	if r.danmaku == nil {
+		return ""
+	}
+	file := danmaku.FileName(videoFile, r.danmaku.format)
+	meta := danmaku.Meta{
+		HostName:  info.HostName,
+		RoomName:  info.RoomName,
+		StartTime: r.StartTime(),
+	}
+	if u, err := url.Parse(r.Live.GetRawUrl()); err == nil {
+		meta.RoomID = path.Base(u.Path)
+	}
+	w, err := danmaku.NewWriter(r.danmaku.format, file, meta)
+	if err != nil {
+		r.getLogger().WithError(err).Warn("failed to create danmaku file")
+		return ""
+	}
+	r.danmaku.lock.Lock()
+	defer r.danmaku.lock.Unlock()
+	if r.danmaku.writer != nil {
+		r.danmaku.writer.Close()
+	}
+	r.danmaku.writer = w
+	return file
+}
+
+func (r *recorder) closeDanmakuFile() {
+	if r.danmaku != nil {
+		r.danmaku.closeFile()
+	}
+}
+
+func (d *danmakuRecorder) write(msg *danmaku.Message) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	// 两个视频文件之间收到的弹幕直接丢弃
+	if d.writer != nil {
+		d.writer.Write(msg)
+	}
+}
+
+func (d *danmakuRecorder) closeFile() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.writer != nil {
+		d.writer.Close()
+		d.writer = nil
+	}
+}
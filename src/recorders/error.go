@@ -1,9 +1,19 @@
 package recorders
 
-import "errors"
+import (
+	"errors"
+
+	"github.com/bililive-go/bililive-go/src/history"
+)
 
 var (
 	ErrRecorderExist          = errors.New("recorder is exist")
 	ErrRecorderNotExist       = errors.New("recorder is not exist")
 	ErrParserNotSupportStatus = errors.New("parser not support get status")
+	ErrParserNotSupportRelay  = errors.New("parser not support relay")
+	ErrRecorderNotRecording   = errors.New("recorder is not recording")
+	ErrStreamStalled          = errors.New("stream stalled")
+
+	// errSegmentSplit 达到分段条件，在同一次录制中切换到了新文件
+	errSegmentSplit = errors.New(history.ExitReasonSplit)
 )
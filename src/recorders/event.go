@@ -1,9 +1,22 @@
 package recorders
 
-import "github.com/bililive-go/bililive-go/src/pkg/events"
+import (
+	"github.com/bililive-go/bililive-go/src/live"
+	"github.com/bililive-go/bililive-go/src/pkg/events"
+)
 
 const (
 	RecorderStart   events.EventType = "RecorderStart"
 	RecorderStop    events.EventType = "RecorderStop"
 	RecorderRestart events.EventType = "RecorderRestart"
+	// PostProcessFinished 一个文件的录制后处理结束，事件对象为 *PostProcessResult
+	PostProcessFinished events.EventType = "PostProcessFinished"
 )
+
+// PostProcessResult 录制后处理的结果
+type PostProcessResult struct {
+	Live        live.Live
+	File        string
+	OutputFiles []string
+	Err         error
+}
@@ -0,0 +1,139 @@
+package recorders
+
+import (
+	"context"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/bililive-go/bililive-go/src/configs"
+	"github.com/bililive-go/bililive-go/src/live"
+	"github.com/bililive-go/bililive-go/src/pkg/parser"
+)
+
+// for test
+var (
+	// failoverCooldown 地址失败后在该时长内排在其他候选地址之后
+	failoverCooldown = 5 * time.Minute
+	// stallCheckInterval 检查 parser 状态的间隔
+	stallCheckInterval = 5 * time.Second
+)
+
+// sourceFailures 记录每个 CDN 域名最近一次失败的时间
+type sourceFailures map[string]time.Time
+
+func (f sourceFailures) failedAt(info *live.StreamUrlInfo, now time.Time) (time.Time, bool) {
+	t, ok := f[info.Url.Host]
+	if !ok || now.Sub(t) >= failoverCooldown {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// hostPriority 返回 host 在 preferredHosts 中的位置，未匹配时返回 len(preferredHosts)
+func hostPriority(host string, preferredHosts []string) int {
+	for i, pattern := range preferredHosts {
+		if strings.Contains(pattern, "*") {
+			if ok, _ := path.Match(pattern, host); ok {
+				return i
+			}
+		} else if strings.Contains(host, pattern) {
+			return i
+		}
+	}
+	return len(preferredHosts)
+}
+
+// rankStreamInfos 按配置对候选地址排序：最近失败过的地址排在最后（失败越早越靠前），
+// 其次按 preferred_hosts、sort_by 排序，其余保持平台返回的顺序
+func rankStreamInfos(infos []*live.StreamUrlInfo, cfg configs.StreamFailover, failures sourceFailures, now time.Time) []*live.StreamUrlInfo {
+	ret := make([]*live.StreamUrlInfo, len(infos))
+	copy(ret, infos)
+	sort.SliceStable(ret, func(i, j int) bool {
+		a, b := ret[i], ret[j]
+		aFailedAt, aFailed := failures.failedAt(a, now)
+		bFailedAt, bFailed := failures.failedAt(b, now)
+		if aFailed != bFailed {
+			return bFailed
+		}
+		if aFailed && !aFailedAt.Equal(bFailedAt) {
+			return aFailedAt.Before(bFailedAt)
+		}
+		if pa, pb := hostPriority(a.Url.Host, cfg.PreferredHosts), hostPriority(b.Url.Host, cfg.PreferredHosts); pa != pb {
+			return pa < pb
+		}
+		switch cfg.SortBy {
+		case configs.StreamSortByResolution:
+			if a.Resolution != b.Resolution {
+				return a.Resolution > b.Resolution
+			}
+			return a.Vbitrate > b.Vbitrate
+		case configs.StreamSortByBitrate:
+			if a.Vbitrate != b.Vbitrate {
+				return a.Vbitrate > b.Vbitrate
+			}
+			return a.Resolution > b.Resolution
+		}
+		return false
+	})
+	return ret
+}
+
+func sourceFields(info *live.StreamUrlInfo) logrus.Fields {
+	return logrus.Fields{
+		"source_host": info.Url.Host,
+		"source_name": info.Name,
+		"resolution":  info.Resolution,
+		"bitrate":     info.Vbitrate,
+	}
+}
+
+// parseTotalSize 从 parser 的状态中读取已写入的字节数
+func parseTotalSize(status map[string]string) (int64, bool) {
+	size, err := strconv.ParseInt(status["total_size"], 10, 64)
+	return size, err == nil
+}
+
+// watchStall 定期读取 parser 的状态，total_size 超过 timeout 没有增长时停止 parser 并标记 stalled，
+// 获取状态失败（例如超时）视为没有增长。
+// parser 不支持获取状态或 timeout 为 0 时不做检测。
+func (r *recorder) watchStall(ctx context.Context, p parser.Parser, timeout time.Duration, stalled *atomic.Bool) {
+	statusP, ok := p.(parser.StatusParser)
+	if !ok || timeout <= 0 {
+		return
+	}
+	ticker := time.NewTicker(stallCheckInterval)
+	defer ticker.Stop()
+	lastSize, lastProgress := int64(-1), time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		status, err := statusP.Status()
+		if size, ok := parseTotalSize(status); err == nil && ok && size != lastSize {
+			lastSize, lastProgress = size, time.Now()
+			continue
+		}
+		if time.Since(lastProgress) < timeout {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+		stalled.Store(true)
+		r.getLogger().Warnf("no data received in %s, switching to the next stream source", timeout)
+		if err := p.Stop(); err != nil {
+			r.getLogger().WithError(err).Warn("failed to stop stalled parser")
+		}
+		return
+	}
+}
@@ -0,0 +1,129 @@
+package recorders
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bluele/gcache"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bililive-go/bililive-go/src/configs"
+	"github.com/bililive-go/bililive-go/src/interfaces"
+	"github.com/bililive-go/bililive-go/src/live"
+)
+
+func newStreamInfo(rawUrl string, resolution, bitrate int) *live.StreamUrlInfo {
+	u, _ := url.Parse(rawUrl)
+	return &live.StreamUrlInfo{Url: u, Resolution: resolution, Vbitrate: bitrate}
+}
+
+func hosts(infos []*live.StreamUrlInfo) []string {
+	ret := make([]string, 0, len(infos))
+	for _, info := range infos {
+		ret = append(ret, info.Url.Host)
+	}
+	return ret
+}
+
+func TestRankStreamInfos(t *testing.T) {
+	now := time.Now()
+	infos := []*live.StreamUrlInfo{
+		newStreamInfo("https://a.cdn1.com/live.flv", 720, 2000),
+		newStreamInfo("https://b.cdn2.com/live.flv", 1080, 1500),
+		newStreamInfo("https://c.cdn3.com/live.flv", 1080, 4000),
+	}
+	cfg := configs.StreamFailover{}
+	assert.Equal(t, []string{"a.cdn1.com", "b.cdn2.com", "c.cdn3.com"}, hosts(rankStreamInfos(infos, cfg, nil, now)))
+
+	cfg.SortBy = configs.StreamSortByResolution
+	assert.Equal(t, []string{"c.cdn3.com", "b.cdn2.com", "a.cdn1.com"}, hosts(rankStreamInfos(infos, cfg, nil, now)))
+	cfg.SortBy = configs.StreamSortByBitrate
+	assert.Equal(t, []string{"c.cdn3.com", "a.cdn1.com", "b.cdn2.com"}, hosts(rankStreamInfos(infos, cfg, nil, now)))
+
+	cfg.PreferredHosts = []string{"*.cdn2.com", "cdn1"}
+	assert.Equal(t, []string{"b.cdn2.com", "a.cdn1.com", "c.cdn3.com"}, hosts(rankStreamInfos(infos, cfg, nil, now)))
+
+	// 最近失败过的地址排在最后，更早失败的优先重试，超过冷却时间后恢复原有顺序
+	failures := sourceFailures{
+		"b.cdn2.com": now.Add(-time.Minute),
+		"a.cdn1.com": now.Add(-2 * time.Minute),
+		"c.cdn3.com": now.Add(-failoverCooldown),
+	}
+	assert.Equal(t, []string{"c.cdn3.com", "a.cdn1.com", "b.cdn2.com"}, hosts(rankStreamInfos(infos, cfg, failures, now)))
+	// 不修改原切片
+	assert.Equal(t, []string{"a.cdn1.com", "b.cdn2.com", "c.cdn3.com"}, hosts(infos))
+}
+
+// fakeStatusParser 每次获取状态时 total_size 增长 step，step 为 0 时模拟卡住
+type fakeStatusParser struct {
+	size    int64
+	step    atomic.Int64
+	stopped chan struct{}
+	once    sync.Once
+}
+
+func (p *fakeStatusParser) ParseLiveStream(ctx context.Context, streamUrlInfo *live.StreamUrlInfo, live live.Live, file string) error {
+	<-p.stopped
+	return nil
+}
+
+func (p *fakeStatusParser) Stop() error {
+	p.once.Do(func() { close(p.stopped) })
+	return nil
+}
+
+func (p *fakeStatusParser) Status() (map[string]string, error) {
+	p.size += p.step.Load()
+	return map[string]string{"total_size": strconv.FormatInt(p.size, 10)}, nil
+}
+
+func TestWatchStall(t *testing.T) {
+	backup := stallCheckInterval
+	stallCheckInterval = 10 * time.Millisecond
+	defer func() { stallCheckInterval = backup }()
+
+	r := &recorder{
+		logger: &interfaces.Logger{Logger: logrus.New()},
+		cache:  gcache.New(1).LRU().Build(),
+	}
+	p := &fakeStatusParser{stopped: make(chan struct{})}
+	p.step.Store(1)
+	stalled := new(atomic.Bool)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		r.watchStall(ctx, p, 100*time.Millisecond, stalled)
+		close(done)
+	}()
+
+	// 持续有数据时不会被停止
+	time.Sleep(300 * time.Millisecond)
+	assert.False(t, stalled.Load())
+	select {
+	case <-p.stopped:
+		t.Fatal("parser should not be stopped")
+	default:
+	}
+
+	p.step.Store(0)
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("stall is not detected")
+	}
+	assert.True(t, stalled.Load())
+	<-p.stopped
+
+	// 未设置超时时不检测
+	p = &fakeStatusParser{stopped: make(chan struct{})}
+	stalled = new(atomic.Bool)
+	r.watchStall(ctx, p, 0, stalled)
+	assert.False(t, stalled.Load())
+}
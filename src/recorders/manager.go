@@ -8,6 +8,7 @@ import (
 	"github.com/bililive-go/bililive-go/src/configs"
 	"github.com/bililive-go/bililive-go/src/instance"
 	"github.com/bililive-go/bililive-go/src/interfaces"
+	"github.com/bililive-go/bililive-go/src/jobs"
 	"github.com/bililive-go/bililive-go/src/listeners"
 	"github.com/bililive-go/bililive-go/src/live"
 	"github.com/bililive-go/bililive-go/src/pkg/events"
@@ -17,7 +18,7 @@ import (
 func NewManager(ctx context.Context) Manager {
 	rm := &manager{
 		savers: make(map[types.LiveID]Recorder),
-		cfg:    instance.GetInstance(ctx).Config,
+		inst:   instance.GetInstance(ctx),
 	}
 	instance.GetInstance(ctx).RecorderManager = rm
 
@@ -41,20 +42,26 @@ var (
 type manager struct {
 	lock   sync.RWMutex
 	savers map[types.LiveID]Recorder
-	cfg    *configs.Config
+	// inst 配置可能被重新加载，每次使用时从 inst 读取
+	inst *instance.Instance
 }
 
 func (m *manager) registryListener(ctx context.Context, ed events.Dispatcher) {
-	ed.AddEventListener(listeners.LiveStart, events.NewEventListener(func(event *events.Event) {
+	addEvtListener := events.NewEventListener(func(event *events.Event) {
 		live := event.Object.(live.Live)
+		if m.isRejected(ctx, live) {
+			return
+		}
 		if err := m.AddRecorder(ctx, live); err != nil {
 			instance.GetInstance(ctx).Logger.Errorf("failed to add recorder, err: %v", err)
 		}
-	}))
+	})
+	ed.AddEventListener(listeners.LiveStart, addEvtListener)
+	ed.AddEventListener(listeners.RecordFilterAccepted, addEvtListener)
 
 	ed.AddEventListener(listeners.RoomNameChanged, events.NewEventListener(func(event *events.Event) {
 		live := event.Object.(live.Live)
-		if !m.HasRecorder(ctx, live.GetLiveId()) {
+		if !m.getVideoSplitStrategies(live).OnRoomNameChanged || !m.HasRecorder(ctx, live.GetLiveId()) {
 			return
 		}
 		if err := m.RestartRecorder(ctx, live); err != nil {
@@ -73,14 +80,30 @@ func (m *manager) registryListener(ctx context.Context, ed events.Dispatcher) {
 	})
 	ed.AddEventListener(listeners.LiveEnd, removeEvtListener)
 	ed.AddEventListener(listeners.ListenStop, removeEvtListener)
+	ed.AddEventListener(listeners.ScheduleWindowEnd, removeEvtListener)
+	ed.AddEventListener(listeners.RecordFilterRejected, removeEvtListener)
+}
+
+// isRejected 直播不满足录制规则时不开始录制
+func (m *manager) isRejected(ctx context.Context, live live.Live) bool {
+	lm, ok := m.inst.ListenerManager.(listeners.Manager)
+	if !ok {
+		return false
+	}
+	l, err := lm.GetListener(ctx, live.GetLiveId())
+	return err == nil && l.RejectReason() != ""
 }
 
 func (m *manager) Start(ctx context.Context) error {
 	inst := instance.GetInstance(ctx)
-	if inst.Config.RPC.Enable || len(inst.Lives) > 0 {
+	if inst.GetConfig().RPC.Enable || len(inst.GetLives()) > 0 {
 		inst.WaitGroup.Add(1)
 	}
-	m.registryListener(ctx, inst.EventDispatcher.(events.Dispatcher))
+	ed := inst.EventDispatcher.(events.Dispatcher)
+	m.registryListener(ctx, ed)
+	if q := jobs.GetQueue(ctx); q != nil {
+		registerJobSteps(ctx, q, ed)
+	}
 	return nil
 }
 
@@ -107,18 +130,21 @@ func (m *manager) AddRecorder(ctx context.Context, live live.Live) error {
 	}
 	m.savers[live.GetLiveId()] = recorder
 
-	if maxDur := m.cfg.VideoSplitStrategies.MaxDuration; maxDur != 0 {
+	if maxDur := m.getVideoSplitStrategies(live).MaxDuration; maxDur != 0 {
 		go m.cronRestart(ctx, live)
 	}
 	return recorder.Start(ctx)
 }
 
 func (m *manager) cronRestart(ctx context.Context, live live.Live) {
-	recorder, err := m.GetRecorder(ctx, live.GetLiveId())
+	rec, err := m.GetRecorder(ctx, live.GetLiveId())
 	if err != nil {
 		return
 	}
-	if time.Since(recorder.StartTime()) < m.cfg.VideoSplitStrategies.MaxDuration {
+	// 支持分段的 parser 会在关键帧处自行切换文件，不需要重启录制
+	r, ok := rec.(*recorder)
+	splitsNatively := ok && r.splitsNatively()
+	if splitsNatively || time.Since(rec.StartTime()) < m.getVideoSplitStrategies(live).MaxDuration {
 		time.AfterFunc(time.Minute/4, func() {
 			m.cronRestart(ctx, live)
 		})
@@ -129,6 +155,12 @@ func (m *manager) cronRestart(ctx context.Context, live live.Live) {
 	}
 }
 
+// getVideoSplitStrategies 获取直播间实际生效的分段策略（房间配置优先于全局配置）
+func (m *manager) getVideoSplitStrategies(live live.Live) configs.VideoSplitStrategies {
+	cfg := m.inst.GetConfig()
+	return cfg.GetVideoSplitStrategies(cfg.FindLiveRoomByUrl(live.GetRawUrl()))
+}
+
 func (m *manager) RestartRecorder(ctx context.Context, live live.Live) error {
 	if err := m.RemoveRecorder(ctx, live.GetLiveId()); err != nil {
 		return err
@@ -32,6 +32,7 @@ func TestManagerAddAndRemoveRecorder(t *testing.T) {
 	defer func() { newRecorder = backup }()
 	l := livemock.NewMockLive(ctrl)
 	l.EXPECT().GetLiveId().Return(types.LiveID("test")).AnyTimes()
+	l.EXPECT().GetRawUrl().Return("https://live.bilibili.com/1").AnyTimes()
 	assert.NoError(t, m.AddRecorder(context.Background(), l))
 	assert.Equal(t, ErrRecorderExist, m.AddRecorder(context.Background(), l))
 	ln, err := m.GetRecorder(context.Background(), "test")
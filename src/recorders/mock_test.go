@@ -11,6 +11,7 @@ package recorders
 
 import (
 	context "context"
+	io "io"
 	reflect "reflect"
 	time "time"
 
@@ -70,6 +71,20 @@ func (mr *MockRecorderMockRecorder) GetStatus() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStatus", reflect.TypeOf((*MockRecorder)(nil).GetStatus))
 }
 
+// Relay mocks base method.
+func (m *MockRecorder) Relay(ctx context.Context, w io.Writer) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Relay", ctx, w)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Relay indicates an expected call of Relay.
+func (mr *MockRecorderMockRecorder) Relay(ctx, w any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Relay", reflect.TypeOf((*MockRecorder)(nil).Relay), ctx, w)
+}
+
 // Start mocks base method.
 func (m *MockRecorder) Start(ctx context.Context) error {
 	m.ctrl.T.Helper()
@@ -0,0 +1,303 @@
+package recorders
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/template"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/bililive-go/bililive-go/src/configs"
+	"github.com/bililive-go/bililive-go/src/history"
+	"github.com/bililive-go/bililive-go/src/instance"
+	"github.com/bililive-go/bililive-go/src/jobs"
+	"github.com/bililive-go/bililive-go/src/live"
+	"github.com/bililive-go/bililive-go/src/pkg/events"
+	"github.com/bililive-go/bililive-go/src/pkg/parser/native/flv"
+	"github.com/bililive-go/bililive-go/src/pkg/remux"
+	"github.com/bililive-go/bililive-go/src/pkg/utils"
+	"github.com/bililive-go/bililive-go/src/upload"
+)
+
+// postProcessSteps 按配置返回录制文件需要执行的后处理步骤。
+// jobs.steps 为空时设置了 custom_commandline 就只执行它，否则依次执行 fix_flv、convert_mp4；最后执行 upload
+func postProcessSteps(cfg *configs.Config, onRecordFinished configs.OnRecordFinished) []string {
+	enabled := map[string]bool{
+		configs.JobStepFixFlv:            onRecordFinished.FixFlvAtFirst,
+		configs.JobStepConvertMp4:        onRecordFinished.ConvertToMp4,
+		configs.JobStepCustomCommandline: onRecordFinished.CustomCommandline != "",
+		configs.JobStepUpload:            cfg.Upload.Enable,
+	}
+	order := cfg.Jobs.Steps
+	if len(order) == 0 {
+		order = []string{configs.JobStepFixFlv, configs.JobStepConvertMp4, configs.JobStepUpload}
+		if enabled[configs.JobStepCustomCommandline] {
+			order = []string{configs.JobStepCustomCommandline, configs.JobStepUpload}
+		}
+	}
+	steps := make([]string, 0, len(order))
+	for _, step := range order {
+		if enabled[step] {
+			steps = append(steps, step)
+		}
+	}
+	return steps
+}
+
+// postProcessor 执行录制后处理的各个步骤，每个步骤的输入为上一个步骤的输出文件
+type postProcessor struct {
+	config           *configs.Config
+	onRecordFinished configs.OnRecordFinished
+	info             *live.Info
+	logger           *logrus.Entry
+}
+
+// newJobPostProcessor 为后处理任务创建 postProcessor，直播间已被移除时模板中的 .Live 为 nil
+func newJobPostProcessor(ctx context.Context, job *jobs.Job) *postProcessor {
+	inst := instance.GetInstance(ctx)
+	cfg := inst.GetConfig()
+	l, _ := inst.GetLive(job.LiveID)
+	return &postProcessor{
+		config:           cfg,
+		onRecordFinished: cfg.GetOnRecordFinished(cfg.FindLiveRoomByUrl(job.LiveUrl)),
+		info:             &live.Info{Live: l, HostName: job.HostName, RoomName: job.RoomName},
+		logger: inst.Logger.WithFields(logrus.Fields{
+			"host": job.HostName,
+			"room": job.RoomName,
+			"job":  job.ID,
+		}),
+	}
+}
+
+func (p *postProcessor) runStep(ctx context.Context, step string, files []string) ([]string, error) {
+	switch step {
+	case configs.JobStepFixFlv:
+		return p.fixFlv(files), nil
+	case configs.JobStepConvertMp4:
+		return p.convertAllToMp4(ctx, files)
+	case configs.JobStepCustomCommandline:
+		return p.customCommandline(ctx, files)
+	case configs.JobStepUpload:
+		return p.upload(ctx, files)
+	default:
+		return nil, fmt.Errorf("unknown post process step: %s", step)
+	}
+}
+
+// run 依次执行 steps，出错时停止并返回已有的输出文件
+func (p *postProcessor) run(ctx context.Context, fileName string, steps []string) ([]string, error) {
+	files := []string{fileName}
+	for _, step := range steps {
+		outputFiles, err := p.runStep(ctx, step, files)
+		if outputFiles != nil {
+			files = outputFiles
+		}
+		if err != nil {
+			return files, err
+		}
+	}
+	return files, nil
+}
+
+// fixFlv 修复 flv 文件，失败时跳过这一步
+func (p *postProcessor) fixFlv(files []string) []string {
+	ret := make([]string, 0, len(files))
+	for _, fileName := range files {
+		outputFiles, stats, err := flv.FixFile(fileName)
+		if err != nil {
+			p.logger.WithError(err).Error("failed to fix flv file, skip this step")
+		} else if stats != nil {
+			p.logger.WithFields(logrus.Fields{
+				"duplicates":      stats.Duplicates,
+				"out_of_order":    stats.OutOfOrder,
+				"discontinuities": stats.Discontinuities,
+				"truncated":       stats.Truncated,
+			}).Infof("flv file is fixed into %d file(s)", len(outputFiles))
+		}
+		ret = append(ret, outputFiles...)
+	}
+	return ret
+}
+
+// convertAllToMp4 将文件逐个转换为 mp4，失败的文件保留在输出中；已经是 mp4 的文件（上一次执行时转换成功）直接跳过
+func (p *postProcessor) convertAllToMp4(ctx context.Context, files []string) (convertedFiles []string, err error) {
+	convertedFiles = make([]string, 0, len(files))
+	for _, outputFile := range files {
+		if strings.EqualFold(filepath.Ext(outputFile), ".mp4") {
+			convertedFiles = append(convertedFiles, outputFile)
+			continue
+		}
+		//格式转换时去除原本后缀名
+		newFileName := outputFile[0:strings.LastIndex(outputFile, ".")]
+		if convertErr := p.convertToMp4(ctx, outputFile, newFileName+".mp4"); convertErr != nil {
+			err = convertErr
+			convertedFiles = append(convertedFiles, outputFile)
+			continue
+		}
+		convertedFiles = append(convertedFiles, newFileName+".mp4")
+		if p.onRecordFinished.DeleteFlvAfterConvert {
+			os.Remove(outputFile)
+		}
+	}
+	return convertedFiles, err
+}
+
+// convertToMp4 将录像转换为 mp4。remuxer 为 native 时使用内置的转封装，不支持的格式或失败时回退到 ffmpeg
+func (p *postProcessor) convertToMp4(ctx context.Context, fileName, mp4File string) error {
+	if p.onRecordFinished.Remuxer == configs.RemuxerNative && strings.EqualFold(filepath.Ext(fileName), ".flv") {
+		stats, err := remux.ConvertFile(fileName, mp4File, remux.Options{Fragmented: p.onRecordFinished.FragmentedMp4})
+		if err == nil {
+			p.logger.WithFields(logrus.Fields{
+				"video_samples": stats.VideoSamples,
+				"audio_samples": stats.AudioSamples,
+				"duration":      stats.Duration,
+			}).Infof("converted to %s", filepath.Base(mp4File))
+			return nil
+		}
+		p.logger.WithError(err).Warn("failed to convert to mp4 with native remuxer, fall back to ffmpeg")
+	}
+
+	ffmpegPath, err := utils.GetFFmpegPath(ctx)
+	if err != nil {
+		p.logger.WithError(err).Error("failed to find ffmpeg")
+		return err
+	}
+	args := []string{"-hide_banner", "-i", fileName, "-c", "copy"}
+	if p.onRecordFinished.FragmentedMp4 {
+		args = append(args, "-movflags", "frag_keyframe+empty_moov+default_base_moof")
+	}
+	convertCmd := exec.CommandContext(ctx, ffmpegPath, append(args, mp4File)...)
+	if err := convertCmd.Run(); err != nil {
+		if convertCmd.Process != nil {
+			convertCmd.Process.Kill()
+		}
+		p.logger.Debugln(err)
+		return err
+	}
+	return nil
+}
+
+// customCommandline 对每个文件执行一次自定义命令
+func (p *postProcessor) customCommandline(ctx context.Context, files []string) ([]string, error) {
+	ffmpegPath, err := utils.GetFFmpegPath(ctx)
+	if err != nil {
+		p.logger.WithError(err).Error("failed to find ffmpeg")
+		return nil, err
+	}
+	customTmpl, errCmdTmpl := template.New("custom_commandline").Funcs(utils.GetFuncMap(p.config)).Parse(p.onRecordFinished.CustomCommandline)
+	if errCmdTmpl != nil {
+		p.logger.WithError(errCmdTmpl).Error("custom commandline parse failure")
+		return nil, errCmdTmpl
+	}
+
+	outputFiles := make([]string, 0, len(files))
+	for _, fileName := range files {
+		buf := new(bytes.Buffer)
+		if execErr := customTmpl.Execute(buf, struct {
+			*live.Info
+			FileName string
+			Ffmpeg   string
+		}{
+			Info:     p.info,
+			FileName: fileName,
+			Ffmpeg:   ffmpegPath,
+		}); execErr != nil {
+			p.logger.WithError(execErr).Errorln("failed to render custom commandline")
+			return nil, execErr
+		}
+		bash := ""
+		args := []string{}
+		switch runtime.GOOS {
+		case "linux":
+			bash = "sh"
+			args = []string{"-c"}
+		case "windows":
+			bash = "cmd"
+			args = []string{"/C"}
+		default:
+			p.logger.Warnln("Unsupport system ", runtime.GOOS)
+		}
+		args = append(args, buf.String())
+		p.logger.Debugf("start executing custom_commandline: %s", args[len(args)-1])
+		cmd := exec.CommandContext(ctx, bash, args...)
+		if p.config.Debug {
+			cmd.Stdout = os.Stdout
+			cmd.Stderr = os.Stderr
+		}
+		if err := cmd.Run(); err != nil {
+			p.logger.WithError(err).Debugf("custom commandline execute failure (%s %s)\n", bash, strings.Join(args, " "))
+			return nil, err
+		}
+		if p.onRecordFinished.DeleteFlvAfterConvert {
+			os.Remove(fileName)
+		} else {
+			outputFiles = append(outputFiles, fileName)
+		}
+		p.logger.Debugf("end executing custom_commandline: %s", args[len(args)-1])
+	}
+	return outputFiles, nil
+}
+
+// upload 上传文件，返回仍保留在本地的文件。
+// 出错时返回已上传且未删除的文件与剩余未上传的文件，重试时已删除的文件不会再次上传
+func (p *postProcessor) upload(ctx context.Context, files []string) ([]string, error) {
+	u := upload.GetUploader(ctx)
+	if u == nil {
+		return nil, errors.New("uploader is not enabled")
+	}
+	kept := make([]string, 0, len(files))
+	for i, fileName := range files {
+		status, err := u.Upload(ctx, p.info, fileName)
+		if err != nil {
+			return append(kept, files[i:]...), err
+		}
+		if !status.Deleted {
+			kept = append(kept, fileName)
+		}
+	}
+	return kept, nil
+}
+
+// registerJobSteps 将后处理步骤注册到任务队列，并在任务结束后更新录制历史、发出 PostProcessFinished 事件
+func registerJobSteps(ctx context.Context, q *jobs.Queue, ed events.Dispatcher) {
+	for _, step := range []string{configs.JobStepFixFlv, configs.JobStepConvertMp4, configs.JobStepCustomCommandline, configs.JobStepUpload} {
+		q.RegisterStep(step, func(ctx context.Context, job *jobs.Job) ([]string, error) {
+			return newJobPostProcessor(ctx, job).runStep(ctx, step, job.Files)
+		})
+	}
+	ed.AddEventListener(jobs.JobFinished, events.NewEventListener(func(event *events.Event) {
+		job := event.Object.(*jobs.Job)
+		inst := instance.GetInstance(ctx)
+		var err error
+		if job.Status != jobs.StatusSucceeded {
+			if job.Error != "" {
+				err = errors.New(job.Error)
+			} else {
+				err = fmt.Errorf("post process job is %s", job.Status)
+			}
+		}
+		if store := history.GetStore(ctx); store != nil && job.RecordID != 0 {
+			if record, getErr := store.Get(job.RecordID); getErr == nil {
+				if updateErr := updateRecordPostProcess(store, record, job.Files, err); updateErr != nil {
+					inst.Logger.WithError(updateErr).Warn("failed to update record history")
+				}
+			}
+		}
+		// 直播间已被移除时不再发出事件
+		if l, ok := inst.GetLive(job.LiveID); ok {
+			ed.DispatchEvent(events.NewEvent(PostProcessFinished, &PostProcessResult{
+				Live:        l,
+				File:        job.File,
+				OutputFiles: job.Files,
+				Err:         err,
+			}))
+		}
+	}))
+}
@@ -0,0 +1,32 @@
+package recorders
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bililive-go/bililive-go/src/configs"
+)
+
+func TestPostProcessSteps(t *testing.T) {
+	cfg := configs.NewConfig()
+	onRecordFinished := configs.OnRecordFinished{FixFlvAtFirst: true, ConvertToMp4: true}
+	assert.Equal(t, []string{configs.JobStepFixFlv, configs.JobStepConvertMp4}, postProcessSteps(cfg, onRecordFinished))
+
+	// 未配置步骤顺序时 custom_commandline 取代内置的步骤
+	onRecordFinished.CustomCommandline = "echo {{ .FileName }}"
+	assert.Equal(t, []string{configs.JobStepCustomCommandline}, postProcessSteps(cfg, onRecordFinished))
+
+	cfg.Jobs.Steps = []string{configs.JobStepConvertMp4, configs.JobStepFixFlv, configs.JobStepCustomCommandline}
+	assert.Equal(t, cfg.Jobs.Steps, postProcessSteps(cfg, onRecordFinished))
+	onRecordFinished.ConvertToMp4 = false
+	assert.Equal(t, []string{configs.JobStepFixFlv, configs.JobStepCustomCommandline}, postProcessSteps(cfg, onRecordFinished))
+
+	assert.Empty(t, postProcessSteps(cfg, configs.OnRecordFinished{}))
+
+	// 未配置步骤顺序时最后上传
+	cfg.Jobs.Steps = nil
+	cfg.Upload.Enable = true
+	assert.Equal(t, []string{configs.JobStepCustomCommandline, configs.JobStepUpload}, postProcessSteps(cfg, onRecordFinished))
+	assert.Equal(t, []string{configs.JobStepUpload}, postProcessSteps(cfg, configs.OnRecordFinished{}))
+}
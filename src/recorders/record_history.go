@@ -0,0 +1,93 @@
+package recorders
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"github.com/bililive-go/bililive-go/src/history"
+	"github.com/bililive-go/bililive-go/src/live"
+)
+
+func statFiles(files []string) ([]history.File, int64) {
+	ret := make([]history.File, 0, len(files))
+	var total int64
+	for _, file := range files {
+		stat, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+		ret = append(ret, history.File{Path: file, Size: stat.Size()})
+		total += stat.Size()
+	}
+	return ret, total
+}
+
+// addRecord 在录制开始时写入一条录制历史，未启用录制历史时返回 nil
+func (r *recorder) addRecord(info *live.Info, fileName, source string) *history.Record {
+	if r.history == nil {
+		return nil
+	}
+	record := &history.Record{
+		LiveID:    r.Live.GetLiveId(),
+		LiveUrl:   r.Live.GetRawUrl(),
+		Platform:  r.Live.GetPlatformCNName(),
+		HostName:  info.HostName,
+		RoomName:  info.RoomName,
+		StartTime: r.StartTime(),
+		Files:     []history.File{{Path: fileName}},
+		Source:    source,
+	}
+	if err := r.history.Add(record); err != nil {
+		r.getLogger().WithError(err).Warn("failed to add record history")
+		return nil
+	}
+	return record
+}
+
+func (r *recorder) finishRecord(record *history.Record, files []string, parseErr error) {
+	if record == nil {
+		return
+	}
+	record.EndTime = time.Now()
+	record.Files, record.TotalBytes = statFiles(files)
+	select {
+	case <-r.stop:
+		record.ExitReason = history.ExitReasonStopped
+	default:
+		switch {
+		case parseErr == nil:
+			record.ExitReason = history.ExitReasonFinished
+		case errors.Is(parseErr, errSegmentSplit):
+			record.ExitReason = history.ExitReasonSplit
+		default:
+			record.ExitReason = history.ExitReasonError
+			record.Error = parseErr.Error()
+		}
+	}
+	if err := r.history.Update(record); err != nil {
+		r.getLogger().WithError(err).Warn("failed to update record history")
+	}
+}
+
+func (r *recorder) finishRecordPostProcess(record *history.Record, outputFiles []string, err error) {
+	if record == nil {
+		return
+	}
+	if err := updateRecordPostProcess(r.history, record, outputFiles, err); err != nil {
+		r.getLogger().WithError(err).Warn("failed to update record history")
+	}
+}
+
+// updateRecordPostProcess 将后处理的结果写入录制历史
+func updateRecordPostProcess(store history.Store, record *history.Record, outputFiles []string, err error) error {
+	record.OutputFiles, _ = statFiles(outputFiles)
+	if err != nil {
+		record.PostProcess = history.PostProcessFailed
+		record.PostProcessError = err.Error()
+	} else {
+		record.PostProcess = history.PostProcessSuccess
+		record.PostProcessError = ""
+	}
+	return store.Update(record)
+}
@@ -0,0 +1,43 @@
+package recorders
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bililive-go/bililive-go/src/history"
+)
+
+// updateStore 只实现 Update 的录制历史存储
+type updateStore struct {
+	history.Store
+}
+
+func (updateStore) Update(*history.Record) error {
+	return nil
+}
+
+func TestFinishRecord(t *testing.T) {
+	r := &recorder{history: updateStore{}, stop: make(chan struct{})}
+	for _, c := range []struct {
+		err        error
+		exitReason string
+		errMsg     string
+	}{
+		{nil, history.ExitReasonFinished, ""},
+		{fmt.Errorf("parser: %w", errSegmentSplit), history.ExitReasonSplit, ""},
+		{errors.New("exit status 1"), history.ExitReasonError, "exit status 1"},
+	} {
+		record := &history.Record{}
+		r.finishRecord(record, nil, c.err)
+		assert.Equal(t, c.exitReason, record.ExitReason)
+		assert.Equal(t, c.errMsg, record.Error)
+	}
+
+	close(r.stop)
+	record := &history.Record{}
+	r.finishRecord(record, nil, errors.New("stopped"))
+	assert.Equal(t, history.ExitReasonStopped, record.ExitReason)
+}
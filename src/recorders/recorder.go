@@ -5,11 +5,10 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"net/url"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"runtime"
 	"strconv"
 	"strings"
 	"sync"
@@ -21,15 +20,18 @@ import (
 	"github.com/sirupsen/logrus"
 
 	"github.com/bililive-go/bililive-go/src/configs"
+	"github.com/bililive-go/bililive-go/src/history"
 	"github.com/bililive-go/bililive-go/src/instance"
 	"github.com/bililive-go/bililive-go/src/interfaces"
+	"github.com/bililive-go/bililive-go/src/jobs"
 	"github.com/bililive-go/bililive-go/src/live"
 	"github.com/bililive-go/bililive-go/src/pkg/events"
 	"github.com/bililive-go/bililive-go/src/pkg/parser"
 	"github.com/bililive-go/bililive-go/src/pkg/parser/ffmpeg"
+	"github.com/bililive-go/bililive-go/src/pkg/parser/hls"
 	"github.com/bililive-go/bililive-go/src/pkg/parser/native/flv"
 	"github.com/bililive-go/bililive-go/src/pkg/utils"
-	"github.com/bililive-go/bililive-go/src/tools"
+	"github.com/bililive-go/bililive-go/src/storage"
 )
 
 const (
@@ -41,14 +43,19 @@ const (
 
 // for test
 var (
-	newParser = func(u *url.URL, useNativeFlvParser bool, cfg map[string]string) (parser.Parser, error) {
+	newParser = func(u *url.URL, feature configs.Feature, cfg map[string]string) (parser.Parser, error) {
 		parserName := ffmpeg.Name
-		if strings.Contains(u.Path, ".flv") && useNativeFlvParser {
+		if strings.Contains(u.Path, ".flv") && feature.UseNativeFlvParser {
 			parserName = flv.Name
+		} else if strings.Contains(u.Path, "m3u8") && feature.UseNativeHlsParser {
+			parserName = hls.Name
 		}
 		return parser.New(parserName, cfg)
 	}
 
+	// lowSpaceRetryInterval 磁盘空间不足时重新检查的间隔
+	lowSpaceRetryInterval = 30 * time.Second
+
 	mkdir = func(path string) error {
 		return os.MkdirAll(path, os.ModePerm)
 	}
@@ -69,20 +76,24 @@ type Recorder interface {
 	Start(ctx context.Context) error
 	StartTime() time.Time
 	GetStatus() (map[string]string, error)
+	// Relay 将正在录制的直播流写入 w，直到 ctx 结束、录制停止或写入失败
+	Relay(ctx context.Context, w io.Writer) error
 	Close()
 }
 
 type recorder struct {
-	Live       live.Live
-	OutPutPath string
+	Live live.Live
 
-	config     *configs.Config
 	ed         events.Dispatcher
 	logger     *interfaces.Logger
 	cache      gcache.Cache
-	startTime  time.Time
+	startTime  atomic.Int64 // 当前分段开始的时间（unix 纳秒），分段时由 parser 的协程更新
 	parser     parser.Parser
 	parserLock *sync.RWMutex
+	history    history.Store
+	danmaku    *danmakuRecorder
+	failures   sourceFailures
+	storage    *storage.Manager
 
 	stop  chan struct{}
 	state uint32
@@ -90,21 +101,37 @@ type recorder struct {
 
 func NewRecorder(ctx context.Context, live live.Live) (Recorder, error) {
 	inst := instance.GetInstance(ctx)
-	return &recorder{
+	r := &recorder{
 		Live:       live,
-		OutPutPath: instance.GetInstance(ctx).Config.OutPutPath,
-		config:     inst.Config,
 		cache:      inst.Cache,
-		startTime:  time.Now(),
 		ed:         inst.EventDispatcher.(events.Dispatcher),
 		logger:     inst.Logger,
 		state:      begin,
 		stop:       make(chan struct{}),
 		parserLock: new(sync.RWMutex),
-	}, nil
+		history:    history.GetStore(ctx),
+		failures:   make(sourceFailures),
+		storage:    storage.GetManager(ctx),
+	}
+	r.startTime.Store(time.Now().UnixNano())
+	return r, nil
 }
 
 func (r *recorder) tryRecord(ctx context.Context) {
+	// 每次尝试录制时读取当前的配置，重新加载配置后从下一次录制开始生效
+	cfg := instance.GetInstance(ctx).GetConfig()
+	room := r.getLiveRoom(cfg)
+	if r.storage != nil {
+		if err := r.storage.CheckSpace(cfg.GetOutPutPath(room)); err != nil {
+			r.getLogger().WithError(err).Warnf("recording is paused, will check again after %s", lowSpaceRetryInterval)
+			select {
+			case <-r.stop:
+			case <-time.After(lowSpaceRetryInterval):
+			}
+			return
+		}
+	}
+
 	var streamInfos []*live.StreamUrlInfo
 	var err error
 	if streamInfos, err = r.Live.GetStreamInfos(); err == live.ErrNotImplemented {
@@ -126,133 +153,177 @@ func (r *recorder) tryRecord(ctx context.Context) {
 	obj, _ := r.cache.Get(r.Live)
 	info := obj.(*live.Info)
 
-	tmpl := getDefaultFileNameTmpl(r.config)
-	if r.config.OutputTmpl != "" {
-		_tmpl, errTmpl := template.New("user_filename").Funcs(utils.GetFuncMap(r.config)).Parse(r.config.OutputTmpl)
+	streamInfo := rankStreamInfos(streamInfos, cfg.StreamFailover, r.failures, time.Now())[0]
+	url := streamInfo.Url
+	fileName := r.renderFileName(cfg, info, room, url)
+	outputPath, _ := filepath.Split(fileName)
+
+	if err = mkdir(outputPath); err != nil {
+		r.getLogger().WithError(err).Errorf("failed to create output path[%s]", outputPath)
+		return
+	}
+	splitStrategies := cfg.GetVideoSplitStrategies(room)
+	parserCfg := map[string]string{
+		"timeout_in_us": strconv.Itoa(cfg.TimeoutInUs),
+		"max_file_size": strconv.Itoa(splitStrategies.MaxFileSize),
+		"max_duration":  splitStrategies.MaxDuration.String(),
+		"proxy":         cfg.GetProxy(room),
+	}
+	if cfg.Debug {
+		parserCfg["debug"] = "true"
+	}
+	if cfg.Feature.HlsConcurrency > 0 {
+		parserCfg["hls_concurrency"] = strconv.Itoa(cfg.Feature.HlsConcurrency)
+	}
+	p, err := newParser(url, cfg.Feature, parserCfg)
+	if err != nil {
+		r.getLogger().WithError(err).Error("failed to init parse")
+		return
+	}
+	r.setAndCloseParser(p)
+	r.startTime.Store(time.Now().UnixNano())
+	seg := r.startSegment(info, fileName, url.Host)
+	if sp, ok := p.(parser.SplittableParser); ok {
+		sp.SetSplitHandler(func(finished string) (string, error) {
+			obj, _ := r.cache.Get(r.Live)
+			nextInfo := obj.(*live.Info)
+			next := uniqueFileName(r.renderFileName(cfg, nextInfo, room, url), finished)
+			if err := mkdir(filepath.Dir(next)); err != nil {
+				return "", err
+			}
+			prev := seg
+			r.startTime.Store(time.Now().UnixNano())
+			seg = r.startSegment(nextInfo, next, url.Host)
+			r.getLogger().Infof("split strategy reached, continue recording in %s", next)
+			// 后处理在后台进行，不阻塞录制
+			go r.finishSegment(ctx, info, prev, errSegmentSplit)
+			info = nextInfo
+			return next, nil
+		})
+	}
+	r.getLogger().WithFields(sourceFields(streamInfo)).Infof("recording %s", fileName)
+	r.getLogger().Debugln("Start ParseLiveStream(" + url.String() + ", " + fileName + ")")
+	watchCtx, cancelWatch := context.WithCancel(ctx)
+	stalled := new(atomic.Bool)
+	go r.watchStall(watchCtx, p, cfg.StreamFailover.StallTimeout, stalled)
+	parseErr := p.ParseLiveStream(ctx, streamInfo, r.Live, fileName)
+	cancelWatch()
+	if stalled.Load() {
+		parseErr = ErrStreamStalled
+	}
+	r.getLogger().Println(parseErr)
+	if parseErr != nil && !r.isStopped() {
+		// 下次优先尝试其他地址
+		r.failures[url.Host] = time.Now()
+	}
+	r.getLogger().Debugln("End ParseLiveStream(" + url.String() + ", " + fileName + ")")
+	r.closeDanmakuFile()
+	r.finishSegment(ctx, info, seg, parseErr)
+}
+
+// renderFileName 按文件名模板生成录制文件的路径
+func (r *recorder) renderFileName(cfg *configs.Config, info *live.Info, room *configs.LiveRoom, streamUrl *url.URL) string {
+	tmpl := getDefaultFileNameTmpl(cfg)
+	if outputTmpl := cfg.GetOutputTmpl(room); outputTmpl != "" {
+		_tmpl, errTmpl := template.New("user_filename").Funcs(utils.GetFuncMap(cfg)).Parse(outputTmpl)
 		if errTmpl == nil {
 			tmpl = _tmpl
 		}
 	}
 
 	buf := new(bytes.Buffer)
-	if err = tmpl.Execute(buf, info); err != nil {
+	if err := tmpl.Execute(buf, info); err != nil {
 		panic(fmt.Sprintf("failed to render filename, err: %v", err))
 	}
-	fileName := filepath.Join(r.OutPutPath, buf.String())
-	outputPath, _ := filepath.Split(fileName)
-	streamInfo := streamInfos[0]
-	url := streamInfo.Url
+	fileName := filepath.Join(cfg.GetOutPutPath(room), buf.String())
 
-	if strings.Contains(url.Path, "m3u8") {
+	if strings.Contains(streamUrl.Path, "m3u8") {
 		fileName = fileName[:len(fileName)-4] + ".ts"
 	}
 
 	if info.AudioOnly {
 		fileName = fileName[:strings.LastIndex(fileName, ".")] + ".aac"
 	}
+	return fileName
+}
 
-	if err = mkdir(outputPath); err != nil {
-		r.getLogger().WithError(err).Errorf("failed to create output path[%s]", outputPath)
-		return
-	}
-	parserCfg := map[string]string{
-		"timeout_in_us": strconv.Itoa(r.config.TimeoutInUs),
+// uniqueFileName 文件名模板不含时间等信息时，为新的分段加上序号，避免覆盖上一个文件
+func uniqueFileName(fileName, prev string) string {
+	ext := filepath.Ext(fileName)
+	base := strings.TrimSuffix(fileName, ext)
+	ret := fileName
+	for i := 1; ret == prev || fileExists(ret); i++ {
+		ret = fmt.Sprintf("%s_%d%s", base, i, ext)
 	}
-	if r.config.Debug {
-		parserCfg["debug"] = "true"
-	}
-	p, err := newParser(url, r.config.Feature.UseNativeFlvParser, parserCfg)
-	if err != nil {
-		r.getLogger().WithError(err).Error("failed to init parse")
-		return
+	return ret
+}
+
+func fileExists(file string) bool {
+	_, err := os.Stat(file)
+	return err == nil
+}
+
+// segment 一次录制中正在写入的文件，以及对应的录制历史与弹幕文件
+type segment struct {
+	file        string
+	record      *history.Record
+	danmakuFile string
+}
+
+func (r *recorder) startSegment(info *live.Info, fileName, source string) *segment {
+	return &segment{
+		file:        fileName,
+		record:      r.addRecord(info, fileName, source),
+		danmakuFile: r.openDanmakuFile(info, fileName),
 	}
-	r.setAndCloseParser(p)
-	r.startTime = time.Now()
-	r.getLogger().Debugln("Start ParseLiveStream(" + url.String() + ", " + fileName + ")")
-	r.getLogger().Println(r.parser.ParseLiveStream(ctx, streamInfo, r.Live, fileName))
-	r.getLogger().Debugln("End ParseLiveStream(" + url.String() + ", " + fileName + ")")
-	removeEmptyFile(fileName)
-	ffmpegPath, err := utils.GetFFmpegPath(ctx)
-	if err != nil {
-		r.getLogger().WithError(err).Error("failed to find ffmpeg")
-		return
+}
+
+// finishSegment 在文件写入完成后更新录制历史，并将后处理加入任务队列；未启用任务队列时直接执行
+func (r *recorder) finishSegment(ctx context.Context, info *live.Info, seg *segment, parseErr error) {
+	removeEmptyFile(seg.file)
+	recordFiles := []string{seg.file}
+	if seg.danmakuFile != "" {
+		recordFiles = append(recordFiles, seg.danmakuFile)
 	}
-	cmdStr := strings.Trim(r.config.OnRecordFinished.CustomCommandline, "")
-	if len(cmdStr) > 0 {
-		customTmpl, errCmdTmpl := template.New("custom_commandline").Funcs(utils.GetFuncMap(r.config)).Parse(cmdStr)
-		if errCmdTmpl != nil {
-			r.getLogger().WithError(errCmdTmpl).Error("custom commandline parse failure")
-			return
-		}
+	r.finishRecord(seg.record, recordFiles, parseErr)
 
-		buf := new(bytes.Buffer)
-		if execErr := customTmpl.Execute(buf, struct {
-			*live.Info
-			FileName string
-			Ffmpeg   string
-		}{
-			Info:     info,
-			FileName: fileName,
-			Ffmpeg:   ffmpegPath,
-		}); execErr != nil {
-			r.getLogger().WithError(execErr).Errorln("failed to render custom commandline")
-			return
-		}
-		bash := ""
-		args := []string{}
-		switch runtime.GOOS {
-		case "linux":
-			bash = "sh"
-			args = []string{"-c"}
-		case "windows":
-			bash = "cmd"
-			args = []string{"/C"}
-		default:
-			r.getLogger().Warnln("Unsupport system ", runtime.GOOS)
-		}
-		args = append(args, buf.String())
-		r.getLogger().Debugf("start executing custom_commandline: %s", args[1])
-		cmd := exec.Command(bash, args...)
-		if r.config.Debug {
-			cmd.Stdout = os.Stdout
-			cmd.Stderr = os.Stderr
-		}
-		if err = cmd.Run(); err != nil {
-			r.getLogger().WithError(err).Debugf("custom commandline execute failure (%s %s)\n", bash, strings.Join(args, " "))
-		} else if r.config.OnRecordFinished.DeleteFlvAfterConvert {
-			os.Remove(fileName)
+	cfg := instance.GetInstance(ctx).GetConfig()
+	onRecordFinished := cfg.GetOnRecordFinished(r.getLiveRoom(cfg))
+	steps := postProcessSteps(cfg, onRecordFinished)
+	if q := jobs.GetQueue(ctx); q != nil && len(steps) > 0 && fileExists(seg.file) {
+		job := &jobs.Job{
+			LiveID:   r.Live.GetLiveId(),
+			LiveUrl:  r.Live.GetRawUrl(),
+			HostName: info.HostName,
+			RoomName: info.RoomName,
+			File:     seg.file,
+			Steps:    steps,
 		}
-		r.getLogger().Debugf("end executing custom_commandline: %s", args[1])
-	} else {
-		outputFiles := []string{fileName}
-		if r.config.OnRecordFinished.FixFlvAtFirst {
-			outputFiles, err = tools.FixFlvByBililiveRecorder(ctx, fileName)
-			if err != nil {
-				r.getLogger().WithError(err).Error("failed to fix flv file, skip this step")
-			}
+		if seg.record != nil {
+			job.RecordID = seg.record.ID
 		}
-		if r.config.OnRecordFinished.ConvertToMp4 {
-			for _, outputFile := range outputFiles {
-				//格式转换时去除原本后缀名
-				newFileName := outputFile[0:strings.LastIndex(outputFile, ".")]
-				convertCmd := exec.Command(
-					ffmpegPath,
-					"-hide_banner",
-					"-i",
-					outputFile,
-					"-c",
-					"copy",
-					newFileName+".mp4",
-				)
-				if err = convertCmd.Run(); err != nil {
-					convertCmd.Process.Kill()
-					r.getLogger().Debugln(err)
-				} else if r.config.OnRecordFinished.DeleteFlvAfterConvert {
-					os.Remove(outputFile)
-				}
-			}
+		// 结果由任务结束时的 JobFinished 事件处理
+		err := q.Enqueue(job)
+		if err == nil {
+			r.getLogger().Infof("post process job %d is queued: %v", job.ID, steps)
+			return
 		}
+		r.getLogger().WithError(err).Warn("failed to queue post process job, run it directly")
 	}
+	p := &postProcessor{config: cfg, onRecordFinished: onRecordFinished, info: info, logger: r.getLogger()}
+	outputFiles, err := p.run(ctx, seg.file, steps)
+	r.finishRecordPostProcess(seg.record, outputFiles, err)
+	r.ed.DispatchEvent(events.NewEvent(PostProcessFinished, &PostProcessResult{
+		Live:        r.Live,
+		File:        seg.file,
+		OutputFiles: outputFiles,
+		Err:         err,
+	}))
+}
+
+// getLiveRoom 获取 cfg 中直播间的配置，找不到时返回 nil 以使用全局配置
+func (r *recorder) getLiveRoom(cfg *configs.Config) *configs.LiveRoom {
+	return cfg.FindLiveRoomByUrl(r.Live.GetRawUrl())
 }
 
 func (r *recorder) run(ctx context.Context) {
@@ -266,12 +337,27 @@ func (r *recorder) run(ctx context.Context) {
 	}
 }
 
+func (r *recorder) isStopped() bool {
+	select {
+	case <-r.stop:
+		return true
+	default:
+		return false
+	}
+}
+
 func (r *recorder) getParser() parser.Parser {
 	r.parserLock.RLock()
 	defer r.parserLock.RUnlock()
 	return r.parser
 }
 
+// splitsNatively 当前 parser 是否会按分段策略自行切换文件
+func (r *recorder) splitsNatively() bool {
+	_, ok := r.getParser().(parser.SplittableParser)
+	return ok
+}
+
 func (r *recorder) setAndCloseParser(p parser.Parser) {
 	r.parserLock.Lock()
 	defer r.parserLock.Unlock()
@@ -287,6 +373,7 @@ func (r *recorder) Start(ctx context.Context) error {
 	if !atomic.CompareAndSwapUint32(&r.state, begin, pending) {
 		return nil
 	}
+	r.startDanmaku(ctx)
 	go r.run(ctx)
 	r.getLogger().Info("Record Start")
 	r.ed.DispatchEvent(events.NewEvent(RecorderStart, r.Live))
@@ -295,7 +382,7 @@ func (r *recorder) Start(ctx context.Context) error {
 }
 
 func (r *recorder) StartTime() time.Time {
-	return r.startTime
+	return time.Unix(0, r.startTime.Load())
 }
 
 func (r *recorder) Close() {
@@ -308,6 +395,7 @@ func (r *recorder) Close() {
 			r.getLogger().WithError(err).Warn("failed to end recorder")
 		}
 	}
+	r.stopDanmaku()
 	r.getLogger().Info("Record End")
 	r.ed.DispatchEvent(events.NewEvent(RecorderStop, r.Live))
 }
@@ -328,6 +416,19 @@ func (r *recorder) getFields() map[string]any {
 	}
 }
 
+func (r *recorder) Relay(ctx context.Context, w io.Writer) error {
+	p := r.getParser()
+	if p == nil {
+		// 两次录制尝试之间没有正在运行的解析器
+		return ErrRecorderNotRecording
+	}
+	relayP, ok := p.(parser.RelayParser)
+	if !ok {
+		return ErrParserNotSupportRelay
+	}
+	return relayP.Relay(ctx, w)
+}
+
 func (r *recorder) GetStatus() (map[string]string, error) {
 	statusP, ok := r.getParser().(parser.StatusParser)
 	if !ok {
@@ -0,0 +1,16 @@
+package recorders
+
+import (
+	"context"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRelayNotRecording(t *testing.T) {
+	// 重连期间没有解析器，应提示未在录制而不是解析器不支持转发
+	r := &recorder{parserLock: new(sync.RWMutex)}
+	assert.ErrorIs(t, r.Relay(context.Background(), io.Discard), ErrRecorderNotRecording)
+}
@@ -0,0 +1,257 @@
+package reload
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/bililive-go/bililive-go/src/configs"
+	"github.com/bililive-go/bililive-go/src/instance"
+	"github.com/bililive-go/bililive-go/src/listeners"
+	"github.com/bililive-go/bililive-go/src/live"
+	"github.com/bililive-go/bililive-go/src/notify/webhook"
+	"github.com/bililive-go/bililive-go/src/pkg/events"
+	"github.com/bililive-go/bililive-go/src/recorders"
+)
+
+const (
+	// ConfigReloaded 重新加载配置成功，对象为 *Result
+	ConfigReloaded events.EventType = "ConfigReloaded"
+	// ConfigReloadFailed 新的配置无效或应用失败，仍在使用旧的配置，对象为 *Result
+	ConfigReloadFailed events.EventType = "ConfigReloadFailed"
+)
+
+// for test
+var newLive = live.New
+
+// Result 一次重新加载的结果
+type Result struct {
+	Time  time.Time `json:"time"`
+	File  string    `json:"file,omitempty"`
+	Error string    `json:"error,omitempty"`
+	// 以下为变化的直播间地址
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+	Updated []string `json:"updated,omitempty"`
+}
+
+// ReloadFile 从 inst.Config.File 重新读取配置并应用，不会修改配置文件
+func ReloadFile(ctx context.Context) (*Result, error) {
+	file := instance.GetInstance(ctx).GetConfig().File
+	if file == "" {
+		return report(ctx, &Result{Time: time.Now()}, fmt.Errorf("config file is not used"))
+	}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return report(ctx, &Result{Time: time.Now(), File: file}, err)
+	}
+	newConfig, err := configs.NewConfigWithBytes(b)
+	if err != nil {
+		return report(ctx, &Result{Time: time.Now(), File: file}, err)
+	}
+	newConfig.File = file
+	return Apply(ctx, newConfig)
+}
+
+// Apply 校验新的配置，与正在使用的配置比较后应用变化：
+// 添加、删除直播间，更新直播间的选项并重启受影响的录制，其他直播间的录制不会中断。
+// 轮询间隔、通知与 cookies 立即生效；新的配置无效时保留旧的配置并返回错误。
+func Apply(ctx context.Context, newConfig *configs.Config) (*Result, error) {
+	// 从 http 请求调用时，新建的监控器与 webhook 不能随请求结束而停止
+	ctx = context.WithoutCancel(ctx)
+	inst := instance.GetInstance(ctx)
+	// 与 api 对配置的修改串行执行，同一时间只有一次重新加载
+	defer inst.LockConfigUpdate()()
+	oldConfig := inst.GetConfig()
+	result := &Result{Time: time.Now(), File: oldConfig.File}
+	if newConfig.File == "" {
+		newConfig.File = oldConfig.File
+	}
+	if err := newConfig.Verify(); err != nil {
+		return report(ctx, result, err)
+	}
+	for name, changed := range map[string]bool{
+		"rpc":           !reflect.DeepEqual(oldConfig.RPC, newConfig.RPC),
+		"log":           oldConfig.Log != newConfig.Log,
+		"app_data_path": oldConfig.AppDataPath != newConfig.AppDataPath,
+		"upload.enable": oldConfig.Upload.Enable != newConfig.Upload.Enable,
+	} {
+		if changed {
+			inst.Logger.Warnf("changes of %s take effect after restart", name)
+		}
+	}
+
+	// 直播间的 LiveId 不在配置文件中，先从旧的配置中继承
+	oldRooms := make(map[string]*configs.LiveRoom, len(oldConfig.LiveRooms))
+	for i := range oldConfig.LiveRooms {
+		oldRooms[oldConfig.LiveRooms[i].Url] = &oldConfig.LiveRooms[i]
+	}
+	newRooms := make(map[string]bool, len(newConfig.LiveRooms))
+	for i := range newConfig.LiveRooms {
+		room := &newConfig.LiveRooms[i]
+		if newRooms[room.Url] {
+			return report(ctx, result, fmt.Errorf("live room %s is duplicated", room.Url))
+		}
+		newRooms[room.Url] = true
+		if old, ok := oldRooms[room.Url]; ok {
+			room.LiveId = old.LiveId
+		}
+	}
+
+	// 新的直播间在发布配置之前创建并写入 LiveId，发布后的配置不再修改，创建失败时值为 nil
+	added := make(map[string]live.Live)
+	for i := range newConfig.LiveRooms {
+		room := &newConfig.LiveRooms[i]
+		if _, ok := oldRooms[room.Url]; ok {
+			if _, exist := inst.GetLive(room.LiveId); exist {
+				continue
+			}
+		}
+		result.Added = append(result.Added, room.Url)
+		l, err := newLive(ctx, room, inst.Cache)
+		if err != nil {
+			inst.Logger.WithError(err).WithField("url", room.Url).Error("failed to add live")
+		} else {
+			room.LiveId = l.GetLiveId()
+		}
+		added[room.Url] = l
+	}
+
+	newConfig.RefreshLiveRoomIndexCache()
+	inst.SetConfig(newConfig)
+	configs.SetCurrentConfig(newConfig)
+
+	lm := inst.ListenerManager.(listeners.Manager)
+	rm := inst.RecorderManager.(recorders.Manager)
+	for _, old := range oldConfig.LiveRooms {
+		if newRooms[old.Url] {
+			continue
+		}
+		result.Removed = append(result.Removed, old.Url)
+		if lm.HasListener(ctx, old.LiveId) {
+			if err := lm.RemoveListener(ctx, old.LiveId); err != nil {
+				inst.Logger.WithError(err).WithField("url", old.Url).Error("failed to remove listener")
+			}
+		}
+		inst.RemoveLive(old.LiveId)
+	}
+	for i := range newConfig.LiveRooms {
+		room := &newConfig.LiveRooms[i]
+		if l, isNew := added[room.Url]; isNew {
+			if l != nil {
+				if err := addLive(ctx, room, l); err != nil {
+					inst.Logger.WithError(err).WithField("url", room.Url).Error("failed to add live")
+				}
+			}
+			continue
+		}
+		old := oldRooms[room.Url]
+		l, _ := inst.GetLive(room.LiveId)
+		if liveOptionsChanged(oldConfig, newConfig, old, room) {
+			// 更新 cookies 等选项，下次获取直播间信息时生效
+			l.UpdateLiveOptionsbyConfig(ctx, room)
+		}
+		optionsChanged := recordOptionsChanged(oldConfig, newConfig, old, room)
+		if !optionsChanged && old.IsListening == room.IsListening {
+			continue
+		}
+		result.Updated = append(result.Updated, room.Url)
+		switch {
+		case room.IsListening && !lm.HasListener(ctx, l.GetLiveId()):
+			if err := lm.AddListener(ctx, l); err != nil {
+				inst.Logger.WithError(err).WithField("url", room.Url).Error("failed to add listener")
+			}
+		case !room.IsListening && lm.HasListener(ctx, l.GetLiveId()):
+			if err := lm.RemoveListener(ctx, l.GetLiveId()); err != nil {
+				inst.Logger.WithError(err).WithField("url", room.Url).Error("failed to remove listener")
+			}
+		case optionsChanged && rm.HasRecorder(ctx, l.GetLiveId()):
+			// 画质、输出路径等选项只在开始录制时读取，需要重启录制
+			if err := rm.RestartRecorder(ctx, l); err != nil {
+				inst.Logger.WithError(err).WithField("url", room.Url).Error("failed to restart recorder")
+			}
+		}
+	}
+
+	if !reflect.DeepEqual(oldConfig.Notify.Webhook, newConfig.Notify.Webhook) {
+		restartWebhook(ctx)
+	}
+	inst.Logger.WithFields(map[string]any{
+		"added":   len(result.Added),
+		"removed": len(result.Removed),
+		"updated": len(result.Updated),
+	}).Info("config reloaded")
+	dispatch(ctx, ConfigReloaded, result)
+	return result, nil
+}
+
+// liveOptionsChanged 直播间自身的选项、所在域名的 cookies 或实际使用的代理是否变化
+func liveOptionsChanged(oldConfig, newConfig *configs.Config, old, room *configs.LiveRoom) bool {
+	if !reflect.DeepEqual(*old, *room) || oldConfig.GetProxy(old) != newConfig.GetProxy(room) {
+		return true
+	}
+	u, err := url.Parse(room.Url)
+	if err != nil {
+		return true
+	}
+	oldCookie, oldOk := oldConfig.Cookies[u.Host]
+	newCookie, newOk := newConfig.Cookies[u.Host]
+	return oldCookie != newCookie || oldOk != newOk
+}
+
+// recordOptionsChanged 直播间自身的选项或实际使用的全局选项（输出路径、文件名模板、分段策略、后处理、代理等）是否变化，
+// 这些选项只在开始录制时读取
+func recordOptionsChanged(oldConfig, newConfig *configs.Config, old, room *configs.LiveRoom) bool {
+	oldRoom, newRoom := *old, *room
+	oldRoom.IsListening, newRoom.IsListening = false, false
+	if !reflect.DeepEqual(oldRoom, newRoom) {
+		return true
+	}
+	return oldConfig.GetOutPutPath(old) != newConfig.GetOutPutPath(room) ||
+		oldConfig.GetOutputTmpl(old) != newConfig.GetOutputTmpl(room) ||
+		!reflect.DeepEqual(oldConfig.GetVideoSplitStrategies(old), newConfig.GetVideoSplitStrategies(room)) ||
+		!reflect.DeepEqual(oldConfig.GetOnRecordFinished(old), newConfig.GetOnRecordFinished(room)) ||
+		oldConfig.GetProxy(old) != newConfig.GetProxy(room) ||
+		!reflect.DeepEqual(oldConfig.StreamFailover, newConfig.StreamFailover) ||
+		oldConfig.TimeoutInUs != newConfig.TimeoutInUs ||
+		oldConfig.Danmaku != newConfig.Danmaku
+}
+
+func addLive(ctx context.Context, room *configs.LiveRoom, l live.Live) error {
+	inst := instance.GetInstance(ctx)
+	if !inst.AddLive(l) {
+		return fmt.Errorf("live id: %s is exist", l.GetLiveId())
+	}
+	if room.IsListening {
+		return inst.ListenerManager.(listeners.Manager).AddListener(ctx, l)
+	}
+	return nil
+}
+
+// restartWebhook webhook 的推送地址在启动时解析，配置变化后需要重新创建
+func restartWebhook(ctx context.Context) {
+	inst := instance.GetInstance(ctx)
+	if n := webhook.GetNotifier(ctx); n != nil {
+		n.Close(ctx)
+	}
+	if err := webhook.NewNotifier(ctx).Start(ctx); err != nil {
+		inst.Logger.WithError(err).Error("failed to restart webhook notifier, webhook is disabled")
+		inst.WebhookNotifier = nil
+	}
+}
+
+func report(ctx context.Context, result *Result, err error) (*Result, error) {
+	result.Error = err.Error()
+	instance.GetInstance(ctx).Logger.WithError(err).Error("failed to reload config, the old config is still in use")
+	dispatch(ctx, ConfigReloadFailed, result)
+	return result, err
+}
+
+func dispatch(ctx context.Context, typ events.EventType, result *Result) {
+	if ed, ok := instance.GetInstance(ctx).EventDispatcher.(events.Dispatcher); ok {
+		ed.DispatchEvent(events.NewEvent(typ, result))
+	}
+}
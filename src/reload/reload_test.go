@@ -0,0 +1,235 @@
+package reload
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bluele/gcache"
+	"github.com/stretchr/testify/assert"
+	gomock "go.uber.org/mock/gomock"
+
+	"github.com/bililive-go/bililive-go/src/configs"
+	"github.com/bililive-go/bililive-go/src/instance"
+	"github.com/bililive-go/bililive-go/src/listeners"
+	livepkg "github.com/bililive-go/bililive-go/src/live"
+	livemock "github.com/bililive-go/bililive-go/src/live/mock"
+	"github.com/bililive-go/bililive-go/src/log"
+	"github.com/bililive-go/bililive-go/src/pkg/events"
+	"github.com/bililive-go/bililive-go/src/recorders"
+	"github.com/bililive-go/bililive-go/src/types"
+)
+
+func newMockLive(ctrl *gomock.Controller, url string) *livemock.MockLive {
+	l := livemock.NewMockLive(ctrl)
+	l.EXPECT().GetLiveId().Return(types.LiveID(url)).AnyTimes()
+	l.EXPECT().GetRawUrl().Return(url).AnyTimes()
+	l.EXPECT().GetPlatformCNName().Return("platform").AnyTimes()
+	l.EXPECT().GetInfo().Return(&livepkg.Info{Status: false}, nil).AnyTimes()
+	l.EXPECT().UpdateLiveOptionsbyConfig(gomock.Any(), gomock.Any()).AnyTimes()
+	return l
+}
+
+func newTestConfig(dir string, rooms ...configs.LiveRoom) *configs.Config {
+	cfg := configs.NewConfig()
+	cfg.OutPutPath = dir
+	cfg.Log.SaveLastLog = false
+	cfg.LiveRooms = rooms
+	return cfg
+}
+
+// newTestInstance 创建包含监控器与录制器管理模块的实例，rooms 中的直播间都已开始监控
+func newTestInstance(t *testing.T, ctrl *gomock.Controller, cfg *configs.Config) context.Context {
+	inst := &instance.Instance{
+		Config: cfg,
+		Cache:  gcache.New(4).LRU().Build(),
+		Lives:  make(map[types.LiveID]livepkg.Live),
+	}
+	ctx := context.WithValue(context.Background(), instance.Key, inst)
+	log.New(ctx)
+	events.NewDispatcher(ctx)
+	lm := listeners.NewManager(ctx)
+	recorders.NewManager(ctx)
+	for i := range cfg.LiveRooms {
+		room := &cfg.LiveRooms[i]
+		l := newMockLive(ctrl, room.Url)
+		room.LiveId = l.GetLiveId()
+		inst.Lives[room.LiveId] = l
+		if room.IsListening {
+			assert.NoError(t, lm.AddListener(ctx, l))
+		}
+	}
+	cfg.RefreshLiveRoomIndexCache()
+	t.Cleanup(func() {
+		for id := range inst.Lives {
+			lm.RemoveListener(ctx, id)
+		}
+	})
+	return ctx
+}
+
+func TestApply(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	dir := t.TempDir()
+	old := newTestConfig(dir,
+		configs.LiveRoom{Url: "https://live.bilibili.com/1", IsListening: true},
+		configs.LiveRoom{Url: "https://live.bilibili.com/2", IsListening: true},
+		configs.LiveRoom{Url: "https://live.bilibili.com/3", IsListening: false},
+	)
+	ctx := newTestInstance(t, ctrl, old)
+	inst := instance.GetInstance(ctx)
+	lm := inst.ListenerManager.(listeners.Manager)
+	backup := newLive
+	defer func() { newLive = backup }()
+	newLive = func(ctx context.Context, room *configs.LiveRoom, cache gcache.Cache) (livepkg.Live, error) {
+		return newMockLive(ctrl, room.Url), nil
+	}
+	results := make(chan *Result, 1)
+	inst.EventDispatcher.(events.Dispatcher).AddEventListener(ConfigReloadFailed, events.NewEventListener(func(event *events.Event) {
+		results <- event.Object.(*Result)
+	}))
+
+	// 无效的配置不会被应用
+	invalid := newTestConfig(dir, configs.LiveRoom{Url: "https://live.bilibili.com/1"})
+	invalid.Interval = 0
+	_, err := Apply(ctx, invalid)
+	assert.Error(t, err)
+	assert.Same(t, old, inst.Config)
+	select {
+	case result := <-results:
+		assert.NotEmpty(t, result.Error)
+	case <-time.After(time.Second):
+		t.Fatal("ConfigReloadFailed is not dispatched")
+	}
+
+	// 1 不变，2 被删除，3 开始监控，4 为新增
+	newConfig := newTestConfig(dir,
+		configs.LiveRoom{Url: "https://live.bilibili.com/1", IsListening: true},
+		configs.LiveRoom{Url: "https://live.bilibili.com/3", IsListening: true},
+		configs.LiveRoom{Url: "https://live.bilibili.com/4", IsListening: true},
+	)
+	newConfig.Interval = 60
+	result, err := Apply(ctx, newConfig)
+	assert.NoError(t, err)
+	assert.Same(t, newConfig, inst.Config)
+	assert.Same(t, newConfig, configs.GetCurrentConfig())
+	assert.Equal(t, []string{"https://live.bilibili.com/4"}, result.Added)
+	assert.Equal(t, []string{"https://live.bilibili.com/2"}, result.Removed)
+	assert.Equal(t, []string{"https://live.bilibili.com/3"}, result.Updated)
+	assert.Len(t, inst.Lives, 3)
+	assert.NotContains(t, inst.Lives, types.LiveID("https://live.bilibili.com/2"))
+	for _, id := range []types.LiveID{"https://live.bilibili.com/1", "https://live.bilibili.com/3", "https://live.bilibili.com/4"} {
+		assert.True(t, lm.HasListener(ctx, id), id)
+	}
+	assert.False(t, lm.HasListener(ctx, "https://live.bilibili.com/2"))
+	assert.Equal(t, types.LiveID("https://live.bilibili.com/4"), newConfig.LiveRooms[2].LiveId)
+}
+
+func TestRecordOptionsChanged(t *testing.T) {
+	room := configs.LiveRoom{Url: "https://live.bilibili.com/1", IsListening: true}
+	old := newTestConfig("/a", room)
+	assert.False(t, recordOptionsChanged(old, newTestConfig("/a", room), &room, &room))
+
+	// 只修改是否监控不需要重启录制
+	stopped := room
+	stopped.IsListening = false
+	assert.False(t, recordOptionsChanged(old, newTestConfig("/a", stopped), &room, &stopped))
+
+	// 房间自身的选项变化
+	changed := room
+	changed.Quality = 1
+	assert.True(t, recordOptionsChanged(old, newTestConfig("/a", changed), &room, &changed))
+
+	// 全局的输出路径与代理变化
+	assert.True(t, recordOptionsChanged(old, newTestConfig("/b", room), &room, &room))
+	proxied := newTestConfig("/a", room)
+	proxied.Proxy.Url = "http://127.0.0.1:8080"
+	assert.True(t, recordOptionsChanged(old, proxied, &room, &room))
+
+	// 房间设置了自己的输出路径时，全局的输出路径变化不影响该房间
+	own := room
+	own.OutPutPath = "/own"
+	assert.False(t, recordOptionsChanged(newTestConfig("/a", own), newTestConfig("/b", own), &own, &own))
+}
+
+func TestLiveOptionsChanged(t *testing.T) {
+	room := configs.LiveRoom{Url: "https://live.bilibili.com/1", IsListening: true}
+	old := newTestConfig("/a", room)
+	assert.False(t, liveOptionsChanged(old, newTestConfig("/b", room), &room, &room))
+
+	cookies := newTestConfig("/a", room)
+	cookies.Cookies = map[string]string{"live.bilibili.com": "a=b"}
+	assert.True(t, liveOptionsChanged(old, cookies, &room, &room))
+	// 其他域名的 cookies 不影响
+	cookies.Cookies = map[string]string{"www.douyu.com": "a=b"}
+	assert.False(t, liveOptionsChanged(old, cookies, &room, &room))
+
+	proxied := newTestConfig("/a", room)
+	proxied.Proxy.Domains = map[string]string{"live.bilibili.com": "http://127.0.0.1:8080"}
+	assert.True(t, liveOptionsChanged(old, proxied, &room, &room))
+}
+
+func TestReloadFile(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yml")
+	cfg := newTestConfig(dir, configs.LiveRoom{Url: "https://live.bilibili.com/1", IsListening: true})
+	cfg.File = file
+	ctx := newTestInstance(t, ctrl, cfg)
+	inst := instance.GetInstance(ctx)
+
+	content := []byte("interval: 45\nout_put_path: " + dir + "\nlog:\n  save_last_log: false\nlive_rooms:\n- url: https://live.bilibili.com/1\n  is_listening: true\n")
+	assert.NoError(t, os.WriteFile(file, content, 0644))
+	_, err := ReloadFile(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 45, inst.Config.Interval)
+	assert.Equal(t, file, inst.Config.File)
+	// 重新加载不会改写配置文件
+	b, err := os.ReadFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, content, b)
+
+	assert.NoError(t, os.WriteFile(file, []byte("interval: [\n"), 0644))
+	_, err = ReloadFile(ctx)
+	assert.Error(t, err)
+	assert.Equal(t, 45, inst.Config.Interval)
+}
+
+func TestWatcher(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	backup := debounce
+	debounce = 10 * time.Millisecond
+	defer func() { debounce = backup }()
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yml")
+	cfg := newTestConfig(dir, configs.LiveRoom{Url: "https://live.bilibili.com/1"})
+	cfg.File = file
+	ctx := newTestInstance(t, ctrl, cfg)
+	inst := instance.GetInstance(ctx)
+	reloaded := make(chan *Result, 4)
+	inst.EventDispatcher.(events.Dispatcher).AddEventListener(ConfigReloaded, events.NewEventListener(func(event *events.Event) {
+		reloaded <- event.Object.(*Result)
+	}))
+
+	w := NewWatcher(ctx)
+	assert.NoError(t, w.Start(ctx))
+	defer w.Close(ctx)
+	// 同目录下的其他文件不会触发重新加载
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "other.yml"), []byte("a: b"), 0644))
+	// 先写入临时文件再重命名，与编辑器保存文件的方式相同
+	tmp := filepath.Join(dir, "config.yml.tmp")
+	content := "interval: 90\nout_put_path: " + dir + "\nlog:\n  save_last_log: false\nlive_rooms:\n- url: https://live.bilibili.com/1\n"
+	assert.NoError(t, os.WriteFile(tmp, []byte(content), 0644))
+	assert.NoError(t, os.Rename(tmp, file))
+	select {
+	case <-reloaded:
+		assert.Equal(t, 90, inst.Config.Interval)
+	case <-time.After(5 * time.Second):
+		t.Fatal("config is not reloaded")
+	}
+}
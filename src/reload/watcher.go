@@ -0,0 +1,87 @@
+package reload
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/sirupsen/logrus"
+
+	"github.com/bililive-go/bililive-go/src/instance"
+)
+
+// for test
+var debounce = 500 * time.Millisecond
+
+// Watcher 监控配置文件，文件变化时重新加载配置
+type Watcher struct {
+	inst    *instance.Instance
+	logger  *logrus.Entry
+	file    string
+	watcher *fsnotify.Watcher
+	wg      sync.WaitGroup
+}
+
+func NewWatcher(ctx context.Context) *Watcher {
+	inst := instance.GetInstance(ctx)
+	w := &Watcher{inst: inst}
+	inst.ConfigWatcher = w
+	return w
+}
+
+func (w *Watcher) Start(ctx context.Context) error {
+	w.logger = w.inst.Logger.WithField("module", "reload")
+	file, err := filepath.Abs(w.inst.GetConfig().File)
+	if err != nil {
+		return err
+	}
+	w.file = file
+	if w.watcher, err = fsnotify.NewWatcher(); err != nil {
+		return err
+	}
+	// 编辑器保存时可能先写入临时文件再重命名，因此监控所在目录而不是文件本身
+	if err = w.watcher.Add(filepath.Dir(file)); err != nil {
+		w.watcher.Close()
+		return err
+	}
+	w.wg.Add(1)
+	go w.run(ctx)
+	return nil
+}
+
+func (w *Watcher) Close(ctx context.Context) {
+	if w.watcher != nil {
+		w.watcher.Close()
+	}
+	w.wg.Wait()
+}
+
+func (w *Watcher) run(ctx context.Context) {
+	defer w.wg.Done()
+	// 一次保存通常会产生多个事件，等文件稳定后再重新加载
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+	defer timer.Stop()
+	for {
+		select {
+		case evt, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(evt.Name) != w.file || !evt.Has(fsnotify.Write|fsnotify.Create|fsnotify.Rename) {
+				continue
+			}
+			timer.Reset(debounce)
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			w.logger.WithError(err).Warn("failed to watch config file")
+		case <-timer.C:
+			w.logger.WithField("file", w.file).Info("config file changed, reloading")
+			ReloadFile(ctx)
+		}
+	}
+}
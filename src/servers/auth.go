@@ -0,0 +1,273 @@
+package servers
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/gorilla/mux"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/bililive-go/bililive-go/src/configs"
+	"github.com/bililive-go/bililive-go/src/instance"
+)
+
+var (
+	errNoCredentials      = errors.New("authentication required")
+	errInvalidCredentials = errors.New("invalid credentials")
+)
+
+// publicRoutes 无需鉴权的路由，web ui 的静态文件不包含任何数据
+var publicRoutes = map[string]bool{
+	"/": true,
+}
+
+// adminRoutes 除了非 GET 请求外，还需要 admin 权限的路由
+var adminRoutes = map[string]bool{
+	apiRouterPrefix + "/config":              true,
+	apiRouterPrefix + "/raw-config":          true,
+	apiRouterPrefix + "/cookies":             true,
+	apiRouterPrefix + "/lives/{id}/{action}": true,
+	apiRouterPrefix + "/webhooks/deliveries": true,
+	"/tools":                                 true,
+	"/tools/":                                true,
+	"/debug/":                                true,
+}
+
+// requiredRole 获取访问当前路由需要的角色，返回空字符串表示无需鉴权
+func requiredRole(r *http.Request) string {
+	var tmpl string
+	if route := mux.CurrentRoute(r); route != nil {
+		tmpl, _ = route.GetPathTemplate()
+	}
+	if publicRoutes[tmpl] {
+		return ""
+	}
+	if adminRoutes[tmpl] {
+		return configs.RoleAdmin
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		return configs.RoleViewer
+	default:
+		return configs.RoleAdmin
+	}
+}
+
+func hasRole(role, required string) bool {
+	return required == configs.RoleViewer || role == configs.RoleAdmin
+}
+
+// principal 通过鉴权的用户
+type principal struct {
+	Name string
+	Role string
+}
+
+// authenticator 根据当前配置校验请求携带的凭据。
+// 每次请求都读取最新的配置，修改配置后无需重启。
+type authenticator struct {
+	lock sync.Mutex
+	// passwords 缓存校验通过的密码摘要，避免每次请求都计算 bcrypt
+	passwords    map[string][sha256.Size]byte
+	oidcCfg      configs.OIDCAuth
+	oidcVerifier *oidc.IDTokenVerifier
+}
+
+func newAuthenticator() *authenticator {
+	return &authenticator{passwords: make(map[string][sha256.Size]byte)}
+}
+
+func (a *authenticator) middleware(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inst := instance.GetInstance(r.Context())
+		cfg := inst.GetConfig().RPC.Auth
+		required := requiredRole(r)
+		if !cfg.Enable || required == "" {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		p, err := a.authenticate(r, &cfg)
+		if err != nil {
+			if err != errNoCredentials {
+				inst.Logger.WithError(err).WithField("RemoteAddr", r.RemoteAddr).Warn("authentication failed")
+			}
+			if len(cfg.Users) > 0 {
+				w.Header().Set("WWW-Authenticate", `Basic realm="bililive-go", charset="UTF-8"`)
+			} else {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="bililive-go"`)
+			}
+			writeJsonWithStatusCode(w, http.StatusUnauthorized, commonResp{
+				ErrNo:  http.StatusUnauthorized,
+				ErrMsg: errNoCredentials.Error(),
+			})
+			return
+		}
+		if !hasRole(p.Role, required) {
+			writeJsonWithStatusCode(w, http.StatusForbidden, commonResp{
+				ErrNo:  http.StatusForbidden,
+				ErrMsg: fmt.Sprintf("%s role is required", required),
+			})
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// authenticate 依次尝试 basic auth、静态 token 与 OIDC JWT
+func (a *authenticator) authenticate(r *http.Request, cfg *configs.Auth) (*principal, error) {
+	if username, password, ok := r.BasicAuth(); ok {
+		return a.authenticateUser(cfg, username, password)
+	}
+	var token string
+	if auth := r.Header.Get("Authorization"); len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+		token = strings.TrimSpace(auth[7:])
+	} else {
+		// EventSource 与文件下载链接无法设置请求头
+		token = r.URL.Query().Get("access_token")
+	}
+	if token == "" {
+		return nil, errNoCredentials
+	}
+	for _, t := range cfg.Tokens {
+		if subtle.ConstantTimeCompare([]byte(t.Token), []byte(token)) == 1 {
+			return &principal{Name: t.Name, Role: t.Role}, nil
+		}
+	}
+	if cfg.OIDC != nil && strings.Count(token, ".") == 2 {
+		return a.authenticateJWT(r.Context(), cfg.OIDC, token)
+	}
+	return nil, errInvalidCredentials
+}
+
+func (a *authenticator) authenticateUser(cfg *configs.Auth, username, password string) (*principal, error) {
+	for _, u := range cfg.Users {
+		if u.Username != username {
+			continue
+		}
+		key := u.Username + "\x00" + u.PasswordHash
+		sum := sha256.Sum256([]byte(password))
+		a.lock.Lock()
+		cached, ok := a.passwords[key]
+		a.lock.Unlock()
+		if ok && subtle.ConstantTimeCompare(cached[:], sum[:]) == 1 {
+			return &principal{Name: u.Username, Role: u.Role}, nil
+		}
+		if err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)); err != nil {
+			return nil, errInvalidCredentials
+		}
+		a.lock.Lock()
+		a.passwords[key] = sum
+		a.lock.Unlock()
+		return &principal{Name: u.Username, Role: u.Role}, nil
+	}
+	return nil, errInvalidCredentials
+}
+
+// getOIDCVerifier 获取 OIDC 的 token 校验器，配置变化时重新创建
+func (a *authenticator) getOIDCVerifier(cfg *configs.OIDCAuth) (*oidc.IDTokenVerifier, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.oidcVerifier != nil && reflect.DeepEqual(a.oidcCfg, *cfg) {
+		return a.oidcVerifier, nil
+	}
+	oidcConfig := &oidc.Config{
+		ClientID:          cfg.Audience,
+		SkipClientIDCheck: cfg.Audience == "",
+	}
+	var verifier *oidc.IDTokenVerifier
+	if cfg.JWKSUrl != "" {
+		verifier = oidc.NewVerifier(cfg.Issuer, oidc.NewRemoteKeySet(context.Background(), cfg.JWKSUrl), oidcConfig)
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		provider, err := oidc.NewProvider(ctx, cfg.Issuer)
+		if err != nil {
+			return nil, fmt.Errorf("failed to discover oidc provider: %w", err)
+		}
+		verifier = provider.Verifier(oidcConfig)
+	}
+	a.oidcCfg, a.oidcVerifier = *cfg, verifier
+	return verifier, nil
+}
+
+func (a *authenticator) authenticateJWT(ctx context.Context, cfg *configs.OIDCAuth, token string) (*principal, error) {
+	verifier, err := a.getOIDCVerifier(cfg)
+	if err != nil {
+		return nil, err
+	}
+	idToken, err := verifier.Verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	claims := make(map[string]any)
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, err
+	}
+	p := &principal{Name: idToken.Subject, Role: cfg.GetDefaultRole()}
+	for _, key := range []string{"preferred_username", "email"} {
+		if name, ok := claims[key].(string); ok && name != "" {
+			p.Name = name
+			break
+		}
+	}
+	if cfg.RoleClaim != "" {
+		var values []string
+		switch v := claims[cfg.RoleClaim].(type) {
+		case string:
+			values = []string{v}
+		case []any:
+			for _, item := range v {
+				if s, ok := item.(string); ok {
+					values = append(values, s)
+				}
+			}
+		}
+		for _, v := range values {
+			for _, admin := range cfg.AdminValues {
+				if v == admin {
+					p.Role = configs.RoleAdmin
+				}
+			}
+		}
+	}
+	return p, nil
+}
+
+// CORSMiddleware 按配置处理跨域请求，未配置允许的来源时不返回任何跨域响应头；
+// 通过 "*" 允许的来源不能携带 cookie 等凭据
+func CORSMiddleware(ctx context.Context, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		cfg := instance.GetInstance(ctx).GetConfig().RPC.CORS
+		allowed := ""
+		if origin != "" {
+			allowed = cfg.AllowedOrigin(origin)
+		}
+		if allowed == "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Origin")
+		w.Header().Set("Access-Control-Allow-Origin", allowed)
+		if allowed != "*" {
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
+			w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, Last-Event-ID")
+			w.Header().Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,179 @@
+package servers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/bililive-go/bililive-go/src/configs"
+	"github.com/bililive-go/bililive-go/src/instance"
+	blog "github.com/bililive-go/bililive-go/src/log"
+)
+
+// oidcProvider 签发 JWT 并提供 JWKS
+type oidcProvider struct {
+	*httptest.Server
+	signer jose.Signer
+}
+
+func newOIDCProvider(t *testing.T) *oidcProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	jwk := jose.JSONWebKey{Key: key, KeyID: "test", Algorithm: string(jose.RS256), Use: "sig"}
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: jwk}, nil)
+	assert.NoError(t, err)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{jwk.Public()}})
+	}))
+	t.Cleanup(s.Close)
+	return &oidcProvider{Server: s, signer: signer}
+}
+
+func (p *oidcProvider) sign(t *testing.T, claims map[string]any) string {
+	b, err := json.Marshal(claims)
+	assert.NoError(t, err)
+	jws, err := p.signer.Sign(b)
+	assert.NoError(t, err)
+	token, err := jws.CompactSerialize()
+	assert.NoError(t, err)
+	return token
+}
+
+func TestAuth(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	assert.NoError(t, err)
+	provider := newOIDCProvider(t)
+
+	cfg := configs.NewConfig()
+	cfg.RPC.Auth = configs.Auth{
+		Enable: true,
+		Tokens: []configs.AuthToken{
+			{Name: "viewer", Token: "viewer-token", Role: configs.RoleViewer},
+			{Name: "admin", Token: "admin-token", Role: configs.RoleAdmin},
+		},
+		Users: []configs.AuthUser{{Username: "alice", PasswordHash: string(hash), Role: configs.RoleAdmin}},
+		OIDC: &configs.OIDCAuth{
+			Issuer:      "https://issuer.example.com",
+			JWKSUrl:     provider.URL,
+			Audience:    "bililive-go",
+			RoleClaim:   "groups",
+			AdminValues: []string{"bililive-admins"},
+		},
+	}
+	cfg.RPC.CORS.AllowedOrigins = []string{"https://ui.example.com"}
+	assert.NoError(t, cfg.Verify())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctx = context.WithValue(ctx, instance.Key, &instance.Instance{Config: cfg})
+	blog.New(ctx)
+	handler := CORSMiddleware(ctx, initMux(ctx, newEventStream(nil)))
+
+	jwt := func(groups ...string) string {
+		return provider.sign(t, map[string]any{
+			"iss":    "https://issuer.example.com",
+			"aud":    "bililive-go",
+			"sub":    "bob",
+			"exp":    time.Now().Add(time.Hour).Unix(),
+			"groups": groups,
+		})
+	}
+	expiredJWT := provider.sign(t, map[string]any{
+		"iss": "https://issuer.example.com",
+		"aud": "bililive-go",
+		"sub": "bob",
+		"exp": time.Now().Add(-time.Hour).Unix(),
+	})
+
+	do := func(method, target string, setup func(r *http.Request)) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		if setup != nil {
+			setup(req)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+	bearer := func(token string) func(r *http.Request) {
+		return func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+token) }
+	}
+
+	for _, c := range []struct {
+		name   string
+		method string
+		target string
+		setup  func(r *http.Request)
+		code   int
+	}{
+		{"no credentials", http.MethodGet, "/api/lives", nil, http.StatusUnauthorized},
+		{"invalid token", http.MethodGet, "/api/lives", bearer("invalid"), http.StatusUnauthorized},
+		{"viewer lists lives", http.MethodGet, "/api/lives", bearer("viewer-token"), http.StatusOK},
+		{"token in query", http.MethodGet, "/api/lives?access_token=viewer-token", nil, http.StatusOK},
+		{"viewer reads config", http.MethodGet, "/api/config", bearer("viewer-token"), http.StatusForbidden},
+		{"viewer reads cookies", http.MethodGet, "/api/cookies", bearer("viewer-token"), http.StatusForbidden},
+		{"viewer stops live", http.MethodGet, "/api/lives/id/stop", bearer("viewer-token"), http.StatusForbidden},
+		{"viewer removes live", http.MethodDelete, "/api/lives/id", bearer("viewer-token"), http.StatusForbidden},
+		{"viewer opens tools", http.MethodGet, "/tools/", bearer("viewer-token"), http.StatusForbidden},
+		{"viewer downloads file", http.MethodGet, "/files/", bearer("viewer-token"), http.StatusOK},
+		{"admin reads config", http.MethodGet, "/api/config", bearer("admin-token"), http.StatusOK},
+		{"admin removes live", http.MethodDelete, "/api/lives/id", bearer("admin-token"), http.StatusNotFound},
+		{"basic auth", http.MethodGet, "/api/config", func(r *http.Request) { r.SetBasicAuth("alice", "secret") }, http.StatusOK},
+		{"basic auth cached", http.MethodGet, "/api/config", func(r *http.Request) { r.SetBasicAuth("alice", "secret") }, http.StatusOK},
+		{"wrong password", http.MethodGet, "/api/lives", func(r *http.Request) { r.SetBasicAuth("alice", "wrong") }, http.StatusUnauthorized},
+		{"unknown user", http.MethodGet, "/api/lives", func(r *http.Request) { r.SetBasicAuth("bob", "secret") }, http.StatusUnauthorized},
+		{"oidc viewer", http.MethodGet, "/api/lives", bearer(jwt("users")), http.StatusOK},
+		{"oidc viewer reads config", http.MethodGet, "/api/config", bearer(jwt("users")), http.StatusForbidden},
+		{"oidc admin", http.MethodGet, "/api/config", bearer(jwt("users", "bililive-admins")), http.StatusOK},
+		{"oidc expired", http.MethodGet, "/api/lives", bearer(expiredJWT), http.StatusUnauthorized},
+	} {
+		assert.Equal(t, c.code, do(c.method, c.target, c.setup).Code, c.name)
+	}
+
+	// web ui 的静态文件无需鉴权
+	assert.NotEqual(t, http.StatusUnauthorized, do(http.MethodGet, "/", nil).Code)
+
+	w := do(http.MethodGet, "/api/lives", nil)
+	assert.Contains(t, w.Header().Get("WWW-Authenticate"), "Basic")
+
+	// 跨域预检请求不需要鉴权，只允许配置的来源
+	w = do(http.MethodOptions, "/api/lives", func(r *http.Request) {
+		r.Header.Set("Origin", "https://ui.example.com")
+		r.Header.Set("Access-Control-Request-Method", http.MethodDelete)
+	})
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "https://ui.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	w = do(http.MethodGet, "/api/lives", func(r *http.Request) {
+		r.Header.Set("Origin", "https://evil.example.com")
+		bearer("viewer-token")(r)
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	// "*" 允许的来源不能携带凭据
+	cfg.RPC.CORS.AllowedOrigins = []string{"*", "https://ui.example.com"}
+	w = do(http.MethodGet, "/", func(r *http.Request) { r.Header.Set("Origin", "https://evil.example.com") })
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+	w = do(http.MethodGet, "/", func(r *http.Request) { r.Header.Set("Origin", "https://ui.example.com") })
+	assert.Equal(t, "https://ui.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+
+	// 关闭鉴权后恢复原有行为
+	cfg.RPC.Auth.Enable = false
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/api/config", nil).Code)
+}
+
+func TestRedactedRequestURI(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/api/events?access_token=secret&types=LiveStart", nil)
+	assert.Equal(t, "/api/events?access_token=%2A%2A%2A%2A%2A%2A&types=LiveStart", redactedRequestURI(r))
+	r = httptest.NewRequest(http.MethodGet, "/api/lives?a=1", nil)
+	assert.Equal(t, "/api/lives?a=1", redactedRequestURI(r))
+}
@@ -0,0 +1,330 @@
+package servers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bluele/gcache"
+
+	"github.com/bililive-go/bililive-go/src/jobs"
+	"github.com/bililive-go/bililive-go/src/listeners"
+	"github.com/bililive-go/bililive-go/src/live"
+	"github.com/bililive-go/bililive-go/src/pkg/events"
+	"github.com/bililive-go/bililive-go/src/recorders"
+	"github.com/bililive-go/bililive-go/src/reload"
+	"github.com/bililive-go/bililive-go/src/storage"
+	"github.com/bililive-go/bililive-go/src/upload"
+)
+
+const (
+	// eventBufferSize 缓存的最近事件数量，断线重连的客户端可以通过 Last-Event-ID 补齐
+	eventBufferSize = 1024
+	// subscriberBufferSize 客户端来不及接收时最多积压的事件数量，超过后断开该客户端
+	subscriberBufferSize = 64
+)
+
+// streamReset 客户端请求的 Last-Event-ID 已无法补齐（服务重启或事件已被移出缓存）时最先发送的事件，
+// 客户端应重新获取完整的状态，之后推送缓存中的全部事件
+const streamReset events.EventType = "Reset"
+
+// for test
+var eventKeepAliveInterval = 15 * time.Second
+
+// streamEventTypes 推送给客户端的事件
+var streamEventTypes = []events.EventType{
+	listeners.ListenStart,
+	listeners.ListenStop,
+	listeners.LiveStart,
+	listeners.LiveEnd,
+	listeners.RoomNameChanged,
+	listeners.RoomInitializingFinished,
+	listeners.ScheduleWindowEnd,
+	listeners.RecordFilterRejected,
+	listeners.RecordFilterAccepted,
+	recorders.RecorderStart,
+	recorders.RecorderStop,
+	recorders.PostProcessFinished,
+	jobs.JobFinished,
+	upload.UploadFinished,
+	reload.ConfigReloaded,
+	reload.ConfigReloadFailed,
+	storage.LowDiskSpace,
+	storage.DiskSpaceRecovered,
+	storage.RecordingDeleted,
+}
+
+// streamLive 事件中直播间的信息
+type streamLive struct {
+	LiveID   string `json:"live_id"`
+	LiveUrl  string `json:"live_url"`
+	Platform string `json:"platform"`
+	HostName string `json:"host_name"`
+	RoomName string `json:"room_name"`
+	Status   bool   `json:"status"`
+}
+
+// streamPostProcess PostProcessFinished 事件的附加信息
+type streamPostProcess struct {
+	File        string   `json:"file"`
+	OutputFiles []string `json:"output_files"`
+	Error       string   `json:"error,omitempty"`
+}
+
+// streamEvent 推送给客户端的一条事件
+type streamEvent struct {
+	ID          uint64             `json:"id"`
+	Type        events.EventType   `json:"type"`
+	Time        time.Time          `json:"time"`
+	Live        *streamLive        `json:"live,omitempty"`
+	PostProcess *streamPostProcess `json:"post_process,omitempty"`
+	// Data 其他事件的对象，例如 storage 模块的 *storage.VolumeStatus、jobs 模块的 *jobs.Job、upload 模块的 *upload.Status
+	Data any `json:"data,omitempty"`
+}
+
+// eventStream 订阅事件总线，为每个事件分配递增的序号，并通过 SSE 推送给客户端。
+// SSE 的事件 id 为 <epoch>-<序号>，epoch 在每次启动时生成，用于识别重启前的事件 id
+type eventStream struct {
+	cache     gcache.Cache
+	ed        events.Dispatcher
+	listeners map[events.EventType]*events.EventListener
+	epoch     string
+
+	lock        sync.Mutex
+	lastID      uint64
+	buffer      []*streamEvent
+	subscribers map[chan *streamEvent]struct{}
+	closed      bool
+}
+
+func newEventStream(cache gcache.Cache) *eventStream {
+	return &eventStream{
+		cache:       cache,
+		listeners:   make(map[events.EventType]*events.EventListener),
+		epoch:       strconv.FormatInt(time.Now().UnixNano(), 36),
+		subscribers: make(map[chan *streamEvent]struct{}),
+	}
+}
+
+func (s *eventStream) start(ed events.Dispatcher) {
+	s.ed = ed
+	for _, typ := range streamEventTypes {
+		listener := events.NewEventListener(s.publish)
+		s.listeners[typ] = listener
+		ed.AddEventListener(typ, listener)
+	}
+}
+
+// close 取消订阅并断开所有客户端，需要在关闭 http server 之前调用
+func (s *eventStream) close() {
+	if s.ed != nil {
+		for typ, listener := range s.listeners {
+			s.ed.RemoveEventListener(typ, listener)
+		}
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.closed = true
+	for ch := range s.subscribers {
+		close(ch)
+		delete(s.subscribers, ch)
+	}
+}
+
+func (s *eventStream) newStreamLive(l live.Live) *streamLive {
+	ret := &streamLive{
+		LiveID:   string(l.GetLiveId()),
+		LiveUrl:  l.GetRawUrl(),
+		Platform: l.GetPlatformCNName(),
+	}
+	if s.cache != nil {
+		if obj, err := s.cache.Get(l); err == nil {
+			info := obj.(*live.Info)
+			ret.HostName, ret.RoomName, ret.Status = info.HostName, info.RoomName, info.Status
+		}
+	}
+	return ret
+}
+
+func (s *eventStream) publish(event *events.Event) {
+	e := &streamEvent{Type: event.Type, Time: time.Now()}
+	switch obj := event.Object.(type) {
+	case live.Live:
+		e.Live = s.newStreamLive(obj)
+	case live.InitializingFinishedParam:
+		e.Live = s.newStreamLive(obj.Live)
+	case *recorders.PostProcessResult:
+		e.Live = s.newStreamLive(obj.Live)
+		e.PostProcess = &streamPostProcess{File: obj.File, OutputFiles: obj.OutputFiles}
+		if obj.Err != nil {
+			e.PostProcess.Error = obj.Err.Error()
+		}
+	default:
+		e.Data = obj
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		return
+	}
+	s.lastID++
+	e.ID = s.lastID
+	s.buffer = append(s.buffer, e)
+	if len(s.buffer) > eventBufferSize {
+		s.buffer = s.buffer[len(s.buffer)-eventBufferSize:]
+	}
+	for ch := range s.subscribers {
+		select {
+		case ch <- e:
+		default:
+			// 客户端接收过慢，断开后由客户端携带 Last-Event-ID 重连补齐
+			close(ch)
+			delete(s.subscribers, ch)
+		}
+	}
+}
+
+// subscribe 注册一个订阅者，并返回序号大于 lastID 的缓存事件。
+// epoch 为空表示新的客户端；epoch 不是本次启动的，或者 lastID 之后的事件已被移出缓存时，
+// 返回的第一个事件为 streamReset，之后为缓存中的全部事件
+func (s *eventStream) subscribe(epoch string, lastID uint64) (chan *streamEvent, []*streamEvent) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	ch := make(chan *streamEvent, subscriberBufferSize)
+	if s.closed {
+		close(ch)
+		return ch, nil
+	}
+	s.subscribers[ch] = struct{}{}
+	var backlog []*streamEvent
+	if epoch != "" && (epoch != s.epoch || lastID > s.lastID ||
+		(len(s.buffer) > 0 && lastID+1 < s.buffer[0].ID)) {
+		// 从 reset 事件的 id 恢复时可以继续补齐
+		reset := &streamEvent{ID: s.lastID, Type: streamReset, Time: time.Now()}
+		if len(s.buffer) > 0 {
+			reset.ID = s.buffer[0].ID - 1
+		}
+		backlog = append(backlog, reset)
+		lastID = 0
+	}
+	for _, e := range s.buffer {
+		if e.ID > lastID {
+			backlog = append(backlog, e)
+		}
+	}
+	return ch, backlog
+}
+
+func (s *eventStream) unsubscribe(ch chan *streamEvent) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.subscribers[ch]; ok {
+		close(ch)
+		delete(s.subscribers, ch)
+	}
+}
+
+func (s *eventStream) write(w http.ResponseWriter, e *streamEvent) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s-%d\nevent: %s\ndata: %s\n\n", s.epoch, e.ID, e.Type, b)
+	return err
+}
+
+// parseEventID 解析 <epoch>-<序号> 格式的事件 id，只有序号时视为本次启动的事件
+func (s *eventStream) parseEventID(id string) (epoch string, seq uint64, err error) {
+	epoch = s.epoch
+	if i := strings.LastIndexByte(id, '-'); i >= 0 {
+		epoch, id = id[:i], id[i+1:]
+	}
+	seq, err = strconv.ParseUint(id, 10, 64)
+	if err != nil || epoch == "" {
+		return "", 0, fmt.Errorf("invalid event id: %s", id)
+	}
+	return epoch, seq, nil
+}
+
+// ServeHTTP 以 SSE 的形式推送事件。
+// 支持通过 Last-Event-ID 请求头（或 last_event_id 参数）从指定事件之后恢复，
+// 以及通过 types 参数（逗号分隔）过滤事件类型，streamReset 事件不会被过滤。
+func (s *eventStream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeMsg(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+	var (
+		epoch  string
+		lastID uint64
+	)
+	if lastEventID != "" {
+		var err error
+		if epoch, lastID, err = s.parseEventID(lastEventID); err != nil {
+			writeJsonWithStatusCode(w, http.StatusBadRequest, commonResp{
+				ErrNo:  http.StatusBadRequest,
+				ErrMsg: "invalid Last-Event-ID",
+			})
+			return
+		}
+	}
+	var typeFilter map[events.EventType]bool
+	if types := r.URL.Query().Get("types"); types != "" {
+		typeFilter = make(map[events.EventType]bool)
+		for _, typ := range strings.Split(types, ",") {
+			typeFilter[events.EventType(strings.TrimSpace(typ))] = true
+		}
+	}
+	send := func(e *streamEvent) error {
+		if typeFilter != nil && !typeFilter[e.Type] && e.Type != streamReset {
+			return nil
+		}
+		return s.write(w, e)
+	}
+
+	ch, backlog := s.subscribe(epoch, lastID)
+	defer s.unsubscribe(ch)
+
+	w.Header().Set(contentType, "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	for _, e := range backlog {
+		if err := send(e); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	ticker := time.NewTicker(eventKeepAliveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-ch:
+			if !ok {
+				return
+			}
+			if err := send(e); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
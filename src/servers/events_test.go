@@ -0,0 +1,169 @@
+package servers
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bluele/gcache"
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+	gomock "go.uber.org/mock/gomock"
+
+	"github.com/bililive-go/bililive-go/src/configs"
+	"github.com/bililive-go/bililive-go/src/instance"
+	"github.com/bililive-go/bililive-go/src/listeners"
+	livepkg "github.com/bililive-go/bililive-go/src/live"
+	livemock "github.com/bililive-go/bililive-go/src/live/mock"
+	blog "github.com/bililive-go/bililive-go/src/log"
+	"github.com/bililive-go/bililive-go/src/pkg/events"
+	"github.com/bililive-go/bililive-go/src/recorders"
+	"github.com/bililive-go/bililive-go/src/types"
+)
+
+type sseEvent struct {
+	id   string
+	typ  string
+	data string
+}
+
+// readEvents 从 SSE 响应中读取 n 个事件
+func readEvents(t *testing.T, r *bufio.Reader, n int) []sseEvent {
+	var ret []sseEvent
+	var e sseEvent
+	for len(ret) < n {
+		line, err := r.ReadString('\n')
+		if !assert.NoError(t, err) {
+			return ret
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case line == "":
+			if e.id != "" {
+				ret = append(ret, e)
+			}
+			e = sseEvent{}
+		case strings.HasPrefix(line, "id: "):
+			e.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			e.typ = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			e.data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+	return ret
+}
+
+func TestEventStream(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cache := gcache.New(4).LRU().Build()
+	inst := &instance.Instance{Config: configs.NewConfig(), Cache: cache}
+	ctx := context.WithValue(context.Background(), instance.Key, inst)
+	blog.New(ctx)
+	ed := events.NewDispatcher(ctx)
+
+	live := livemock.NewMockLive(ctrl)
+	live.EXPECT().GetLiveId().Return(types.LiveID("id")).AnyTimes()
+	live.EXPECT().GetPlatformCNName().Return("哔哩哔哩").AnyTimes()
+	live.EXPECT().GetRawUrl().Return("https://live.bilibili.com/1").AnyTimes()
+	cache.Set(live, &livepkg.Info{HostName: "host", RoomName: "room", Status: true})
+
+	stream := newEventStream(cache)
+	stream.start(ed)
+	s := httptest.NewServer(stream)
+	defer s.Close()
+
+	connect := func(lastEventID, query string) *bufio.Reader {
+		req, err := http.NewRequest(http.MethodGet, s.URL+query, nil)
+		assert.NoError(t, err)
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+		return bufio.NewReader(resp.Body)
+	}
+
+	r := connect("", "")
+	ed.DispatchEvent(events.NewEvent(listeners.LiveStart, live))
+	got := readEvents(t, r, 1)
+	assert.Equal(t, stream.epoch+"-1", got[0].id)
+	assert.Equal(t, string(listeners.LiveStart), got[0].typ)
+	assert.Equal(t, "room", gjson.Get(got[0].data, "live.room_name").String())
+	assert.Equal(t, "id", gjson.Get(got[0].data, "live.live_id").String())
+
+	ed.DispatchEvent(events.NewEvent(recorders.PostProcessFinished, &recorders.PostProcessResult{
+		Live:        live,
+		File:        "/a/b.flv",
+		OutputFiles: []string{"/a/b.mp4"},
+	}))
+	got = readEvents(t, r, 1)
+	assert.Equal(t, stream.epoch+"-2", got[0].id)
+	assert.Equal(t, "/a/b.mp4", gjson.Get(got[0].data, "post_process.output_files.0").String())
+	ed.DispatchEvent(events.NewEvent(listeners.LiveEnd, live))
+	readEvents(t, r, 1)
+
+	// 断线重连后补齐缺失的事件
+	got = readEvents(t, connect(stream.epoch+"-1", ""), 2)
+	assert.Equal(t, stream.epoch+"-2", got[0].id)
+	assert.Equal(t, stream.epoch+"-3", got[1].id)
+	// 只有序号时视为本次启动的事件
+	got = readEvents(t, connect("2", ""), 1)
+	assert.Equal(t, stream.epoch+"-3", got[0].id)
+
+	// 重启前的事件 id 无法补齐，先发送 Reset 再推送全部缓存的事件
+	for _, lastEventID := range []string{"old-2", stream.epoch + "-10"} {
+		got = readEvents(t, connect(lastEventID, "?types="+string(listeners.LiveEnd)), 2)
+		assert.Equal(t, string(streamReset), got[0].typ)
+		assert.Equal(t, stream.epoch+"-0", got[0].id)
+		assert.Equal(t, stream.epoch+"-3", got[1].id)
+	}
+
+	// 按事件类型过滤
+	r = connect("", "?types="+string(listeners.LiveEnd))
+	got = readEvents(t, r, 1)
+	assert.Equal(t, stream.epoch+"-3", got[0].id)
+	ed.DispatchEvent(events.NewEvent(listeners.LiveStart, live))
+	ed.DispatchEvent(events.NewEvent(listeners.LiveEnd, live))
+	got = readEvents(t, r, 1)
+	assert.Equal(t, string(listeners.LiveEnd), got[0].typ)
+
+	// 关闭后断开所有客户端
+	stream.close()
+	_, err := r.ReadString('\n')
+	assert.Error(t, err)
+}
+
+func TestEventStreamInvalidLastEventID(t *testing.T) {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/events", nil)
+	req.Header.Set("Last-Event-ID", "abc")
+	newEventStream(nil).ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestEventStreamGap(t *testing.T) {
+	stream := newEventStream(nil)
+	for i := 0; i < eventBufferSize+2; i++ {
+		stream.publish(events.NewEvent(listeners.LiveStart, nil))
+	}
+	// 序号 2 之后的事件已被移出缓存
+	_, backlog := stream.subscribe(stream.epoch, 1)
+	assert.Len(t, backlog, eventBufferSize+1)
+	assert.Equal(t, streamReset, backlog[0].Type)
+	assert.Equal(t, uint64(2), backlog[0].ID)
+	assert.Equal(t, uint64(3), backlog[1].ID)
+
+	_, backlog = stream.subscribe(stream.epoch, 2)
+	assert.Len(t, backlog, eventBufferSize)
+	assert.Equal(t, uint64(3), backlog[0].ID)
+}
@@ -0,0 +1,92 @@
+package servers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/bililive-go/bililive-go/src/configs"
+	"github.com/bililive-go/bililive-go/src/instance"
+)
+
+// roomRootPrefix 直播间单独设置的输出目录在文件列表的根目录中显示为 @<live_id> 目录
+const roomRootPrefix = "@"
+
+// roomRoot 与全局输出目录不同的直播间输出目录
+type roomRoot struct {
+	// Name 文件列表中的目录名，即 roomRootPrefix + 直播间的 live id
+	Name    string
+	RoomUrl string
+	Path    string
+}
+
+// roomRoots 返回与全局输出目录不同的直播间输出目录，多个直播间使用同一目录时只返回第一个
+func roomRoots(cfg *configs.Config) []roomRoot {
+	seen := map[string]bool{filepath.Clean(cfg.OutPutPath): true}
+	roots := make([]roomRoot, 0)
+	for i := range cfg.LiveRooms {
+		room := &cfg.LiveRooms[i]
+		path := filepath.Clean(cfg.GetOutPutPath(room))
+		if room.LiveId == "" || seen[path] {
+			continue
+		}
+		seen[path] = true
+		roots = append(roots, roomRoot{Name: roomRootPrefix + string(room.LiveId), RoomUrl: room.Url, Path: path})
+	}
+	return roots
+}
+
+// splitFileRoot 返回 path 所在的输出目录与目录中的相对路径：
+// 以 @<live_id> 开头时为该直播间的输出目录，否则为全局的输出目录
+func splitFileRoot(cfg *configs.Config, path string) (root, rel string, err error) {
+	path = strings.TrimPrefix(path, "/")
+	first, rest, _ := strings.Cut(path, "/")
+	if id, ok := strings.CutPrefix(first, roomRootPrefix); ok {
+		for _, r := range roomRoots(cfg) {
+			if r.Name == first {
+				return r.Path, rest, nil
+			}
+		}
+		return "", "", fmt.Errorf("output path of live room %s is not found", id)
+	}
+	return cfg.OutPutPath, path, nil
+}
+
+// resolveFilePath 返回 path 对应的绝对路径，不允许访问输出目录之外的文件
+func resolveFilePath(cfg *configs.Config, path string) (string, error) {
+	root, rel, err := splitFileRoot(cfg, path)
+	if err != nil {
+		return "", err
+	}
+	base, err := filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+	absPath, err := filepath.Abs(filepath.Join(base, rel))
+	if err != nil {
+		return "", err
+	}
+	if absPath != base && !strings.HasPrefix(absPath, base+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside of the output path", path)
+	}
+	return absPath, nil
+}
+
+// serveFiles 下载录制文件，每次请求时从当前配置中读取输出目录
+func serveFiles(w http.ResponseWriter, r *http.Request) {
+	cfg := instance.GetInstance(r.Context()).GetConfig()
+	root, rel, err := splitFileRoot(cfg, strings.TrimPrefix(r.URL.Path, "/files/"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	r2 := new(http.Request)
+	*r2 = *r
+	r2.URL = new(url.URL)
+	*r2.URL = *r.URL
+	r2.URL.Path = "/" + rel
+	r2.URL.RawPath = ""
+	http.FileServer(http.Dir(root)).ServeHTTP(w, r2)
+}
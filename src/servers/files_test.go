@@ -0,0 +1,84 @@
+package servers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+
+	"github.com/bililive-go/bililive-go/src/configs"
+	"github.com/bililive-go/bililive-go/src/instance"
+)
+
+func TestFiles(t *testing.T) {
+	global, own := t.TempDir(), t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(global, "a.flv"), []byte("global"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(own, "b.flv"), []byte("own"), 0644))
+	cfg := configs.NewConfig()
+	cfg.OutPutPath = global
+	cfg.LiveRooms = []configs.LiveRoom{
+		{Url: "https://live.bilibili.com/1", LiveId: "1"},
+		{Url: "https://live.bilibili.com/2", LiveId: "2", OutPutPath: own},
+		{Url: "https://live.bilibili.com/3", LiveId: "3", OutPutPath: own},
+	}
+	inst := &instance.Instance{Config: cfg}
+	ctx := context.WithValue(context.Background(), instance.Key, inst)
+
+	// 使用同一目录的直播间只列出一次
+	assert.Equal(t, []roomRoot{{Name: "@2", RoomUrl: "https://live.bilibili.com/2", Path: own}}, roomRoots(cfg))
+	_, err := resolveFilePath(cfg, "@3/b.flv")
+	assert.Error(t, err)
+	_, err = resolveFilePath(cfg, "../a.flv")
+	assert.Error(t, err)
+	_, err = resolveFilePath(cfg, "@2/../a.flv")
+	assert.Error(t, err)
+
+	m := mux.NewRouter()
+	m.HandleFunc("/api/file/{path:.*}", getFileInfo)
+	m.PathPrefix("/files/").HandlerFunc(serveFiles)
+	get := func(path string) (int, string) {
+		req := httptest.NewRequest(http.MethodGet, path, nil).WithContext(ctx)
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, req)
+		b, _ := io.ReadAll(w.Body)
+		return w.Code, string(b)
+	}
+
+	_, body := get("/api/file/")
+	assert.Equal(t, []string{"a.flv", "@2"}, stringsOf(gjson.Get(body, "files.#.name").Array()))
+	assert.Equal(t, "https://live.bilibili.com/2", gjson.Get(body, "files.1.room_url").String())
+	_, body = get("/api/file/@2")
+	assert.Equal(t, []string{"b.flv"}, stringsOf(gjson.Get(body, "files.#.name").Array()))
+
+	code, body := get("/files/a.flv")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "global", body)
+	code, body = get("/files/@2/b.flv")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "own", body)
+	code, _ = get("/files/@4/b.flv")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	// 重新加载配置后使用新的输出目录
+	newConfig := configs.NewConfig()
+	newConfig.OutPutPath = own
+	inst.SetConfig(newConfig)
+	code, body = get("/files/b.flv")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "own", body)
+}
+
+func stringsOf(results []gjson.Result) []string {
+	ret := make([]string, 0, len(results))
+	for _, r := range results {
+		ret = append(ret, r.String())
+	}
+	return ret
+}
@@ -9,10 +9,11 @@ import (
 	"net/http"
 	"net/url"
 	"os"
-	"path/filepath"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/tidwall/gjson"
@@ -20,11 +21,17 @@ import (
 
 	"github.com/bililive-go/bililive-go/src/configs"
 	"github.com/bililive-go/bililive-go/src/consts"
+	"github.com/bililive-go/bililive-go/src/history"
 	"github.com/bililive-go/bililive-go/src/instance"
+	"github.com/bililive-go/bililive-go/src/jobs"
 	"github.com/bililive-go/bililive-go/src/listeners"
 	"github.com/bililive-go/bililive-go/src/live"
+	"github.com/bililive-go/bililive-go/src/notify/webhook"
 	"github.com/bililive-go/bililive-go/src/recorders"
+	"github.com/bililive-go/bililive-go/src/reload"
+	"github.com/bililive-go/bililive-go/src/storage"
 	"github.com/bililive-go/bililive-go/src/types"
+	"github.com/bililive-go/bililive-go/src/upload"
 )
 
 // FIXME: remove this
@@ -32,7 +39,12 @@ func parseInfo(ctx context.Context, l live.Live) *live.Info {
 	inst := instance.GetInstance(ctx)
 	obj, _ := inst.Cache.Get(l)
 	info := obj.(*live.Info)
-	info.Listening = inst.ListenerManager.(listeners.Manager).HasListener(ctx, l.GetLiveId())
+	lm := inst.ListenerManager.(listeners.Manager)
+	info.Listening = lm.HasListener(ctx, l.GetLiveId())
+	info.RejectReason = ""
+	if listener, err := lm.GetListener(ctx, l.GetLiveId()); err == nil {
+		info.RejectReason = listener.RejectReason()
+	}
 	info.Recording = inst.RecorderManager.(recorders.Manager).HasRecorder(ctx, l.GetLiveId())
 	if info.HostName == "" {
 		info.HostName = "获取失败"
@@ -46,7 +58,7 @@ func parseInfo(ctx context.Context, l live.Live) *live.Info {
 func getAllLives(writer http.ResponseWriter, r *http.Request) {
 	inst := instance.GetInstance(r.Context())
 	lives := liveSlice(make([]*live.Info, 0, 4))
-	for _, v := range inst.Lives {
+	for _, v := range inst.GetLives() {
 		lives = append(lives, parseInfo(r.Context(), v))
 	}
 	sort.Sort(lives)
@@ -56,7 +68,7 @@ func getAllLives(writer http.ResponseWriter, r *http.Request) {
 func getLive(writer http.ResponseWriter, r *http.Request) {
 	inst := instance.GetInstance(r.Context())
 	vars := mux.Vars(r)
-	live, ok := inst.Lives[types.LiveID(vars["id"])]
+	live, ok := inst.GetLive(types.LiveID(vars["id"]))
 	if !ok {
 		writeJsonWithStatusCode(writer, http.StatusNotFound, commonResp{
 			ErrNo:  http.StatusNotFound,
@@ -71,15 +83,14 @@ func parseLiveAction(writer http.ResponseWriter, r *http.Request) {
 	inst := instance.GetInstance(r.Context())
 	vars := mux.Vars(r)
 	resp := commonResp{}
-	live, ok := inst.Lives[types.LiveID(vars["id"])]
+	live, ok := inst.GetLive(types.LiveID(vars["id"]))
 	if !ok {
 		resp.ErrNo = http.StatusNotFound
 		resp.ErrMsg = fmt.Sprintf("live id: %s can not find", vars["id"])
 		writeJsonWithStatusCode(writer, http.StatusNotFound, resp)
 		return
 	}
-	room, err := inst.Config.GetLiveRoomByUrl(live.GetRawUrl())
-	if err != nil {
+	if inst.GetConfig().FindLiveRoomByUrl(live.GetRawUrl()) == nil {
 		resp.ErrNo = http.StatusNotFound
 		resp.ErrMsg = fmt.Sprintf("room : %s can not find", live.GetRawUrl())
 		writeJsonWithStatusCode(writer, http.StatusNotFound, resp)
@@ -93,7 +104,7 @@ func parseLiveAction(writer http.ResponseWriter, r *http.Request) {
 			writeJsonWithStatusCode(writer, http.StatusBadRequest, resp)
 			return
 		} else {
-			room.IsListening = true
+			setListening(inst, live.GetRawUrl(), true)
 		}
 	case "stop":
 		if err := stopListening(r.Context(), live.GetLiveId()); err != nil {
@@ -102,7 +113,7 @@ func parseLiveAction(writer http.ResponseWriter, r *http.Request) {
 			writeJsonWithStatusCode(writer, http.StatusBadRequest, resp)
 			return
 		} else {
-			room.IsListening = false
+			setListening(inst, live.GetRawUrl(), false)
 		}
 	default:
 		resp.ErrNo = http.StatusBadRequest
@@ -113,6 +124,16 @@ func parseLiveAction(writer http.ResponseWriter, r *http.Request) {
 	writeJSON(writer, parseInfo(r.Context(), live))
 }
 
+// setListening 修改房间配置中的监控状态
+func setListening(inst *instance.Instance, url string, isListening bool) {
+	inst.UpdateConfig(func(cfg *configs.Config) error {
+		if room := cfg.FindLiveRoomByUrl(url); room != nil {
+			room.IsListening = isListening
+		}
+		return nil
+	})
+}
+
 func startListening(ctx context.Context, live live.Live) error {
 	inst := instance.GetInstance(ctx)
 	return inst.ListenerManager.(listeners.Manager).AddListener(ctx, live)
@@ -177,33 +198,33 @@ func addLiveImpl(ctx context.Context, urlStr string, isListen bool) (info *live.
 		return nil, errors.New("can't parse url: " + urlStr)
 	}
 	inst := instance.GetInstance(ctx)
-	needAppend := false
-	liveRoom, err := inst.Config.GetLiveRoomByUrl(u.String())
-	if err != nil {
-		liveRoom = &configs.LiveRoom{
-			Url:         u.String(),
-			IsListening: isListen,
-		}
-		needAppend = true
+	// 已发布的配置不能修改，使用房间配置的副本
+	liveRoom := configs.LiveRoom{
+		Url:         u.String(),
+		IsListening: isListen,
+	}
+	if room := inst.GetConfig().FindLiveRoomByUrl(u.String()); room != nil {
+		liveRoom = *room
 	}
-	newLive, err := live.New(ctx, liveRoom, inst.Cache)
+	newLive, err := live.New(ctx, &liveRoom, inst.Cache)
 	if err != nil {
 		return nil, err
 	}
 	liveRoom.LiveId = newLive.GetLiveId()
-	if _, ok := inst.Lives[newLive.GetLiveId()]; !ok {
-		inst.Lives[newLive.GetLiveId()] = newLive
+	if inst.AddLive(newLive) {
 		if isListen {
 			inst.ListenerManager.(listeners.Manager).AddListener(ctx, newLive)
 		}
 		info = parseInfo(ctx, newLive)
 
-		if needAppend {
-			if liveRoom == nil {
-				return nil, errors.New("liveRoom is nil, cannot append to LiveRooms")
+		inst.UpdateConfig(func(cfg *configs.Config) error {
+			if room := cfg.FindLiveRoomByUrl(liveRoom.Url); room != nil {
+				room.LiveId = liveRoom.LiveId
+			} else {
+				cfg.LiveRooms = append(cfg.LiveRooms, liveRoom)
 			}
-			inst.Config.LiveRooms = append(inst.Config.LiveRooms, *liveRoom)
-		}
+			return nil
+		})
 	}
 	return info, nil
 }
@@ -211,7 +232,7 @@ func addLiveImpl(ctx context.Context, urlStr string, isListen bool) (info *live.
 func removeLive(writer http.ResponseWriter, r *http.Request) {
 	inst := instance.GetInstance(r.Context())
 	vars := mux.Vars(r)
-	live, ok := inst.Lives[types.LiveID(vars["id"])]
+	live, ok := inst.GetLive(types.LiveID(vars["id"]))
 	if !ok {
 		writeJsonWithStatusCode(writer, http.StatusNotFound, commonResp{
 			ErrNo:  http.StatusNotFound,
@@ -239,19 +260,28 @@ func removeLiveImpl(ctx context.Context, live live.Live) error {
 			return err
 		}
 	}
-	delete(inst.Lives, live.GetLiveId())
-	inst.Config.RemoveLiveRoomByUrl(live.GetRawUrl())
+	inst.RemoveLive(live.GetLiveId())
+	inst.UpdateConfig(func(cfg *configs.Config) error {
+		cfg.RemoveLiveRoomByUrl(live.GetRawUrl())
+		return nil
+	})
 	return nil
 }
 
 func getConfig(writer http.ResponseWriter, r *http.Request) {
-	writeJSON(writer, instance.GetInstance(r.Context()).Config)
+	config, err := instance.GetInstance(r.Context()).GetConfig().Redacted()
+	if err != nil {
+		writeJsonWithStatusCode(writer, http.StatusInternalServerError, commonResp{
+			ErrNo:  http.StatusInternalServerError,
+			ErrMsg: err.Error(),
+		})
+		return
+	}
+	writeJSON(writer, config)
 }
 
 func putConfig(writer http.ResponseWriter, r *http.Request) {
-	config := instance.GetInstance(r.Context()).Config
-	config.RefreshLiveRoomIndexCache()
-	if err := config.Marshal(); err != nil {
+	if err := instance.GetInstance(r.Context()).GetConfig().Marshal(); err != nil {
 		writeJsonWithStatusCode(writer, http.StatusBadRequest, commonResp{
 			ErrNo:  http.StatusBadRequest,
 			ErrMsg: err.Error(),
@@ -264,7 +294,15 @@ func putConfig(writer http.ResponseWriter, r *http.Request) {
 }
 
 func getRawConfig(writer http.ResponseWriter, r *http.Request) {
-	b, err := yaml.Marshal(instance.GetInstance(r.Context()).Config)
+	config, err := instance.GetInstance(r.Context()).GetConfig().Redacted()
+	if err != nil {
+		writeJsonWithStatusCode(writer, http.StatusInternalServerError, commonResp{
+			ErrNo:  http.StatusInternalServerError,
+			ErrMsg: err.Error(),
+		})
+		return
+	}
+	b, err := yaml.Marshal(config)
 	if err != nil {
 		writeJsonWithStatusCode(writer, http.StatusInternalServerError, commonResp{
 			ErrNo:  http.StatusBadRequest,
@@ -298,73 +336,28 @@ func putRawConfig(writer http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
-	oldConfig := inst.Config
-	oldConfig.RefreshLiveRoomIndexCache()
-	inst.Config = newConfig
+	oldConfig := inst.GetConfig()
+	// 返回给客户端的配置隐藏了敏感信息，未修改的字段使用原来的值
+	if err := newConfig.RestoreRedacted(oldConfig); err != nil {
+		writeJsonWithStatusCode(writer, http.StatusBadRequest, commonResp{
+			ErrNo:  http.StatusBadRequest,
+			ErrMsg: err.Error(),
+		})
+		return
+	}
 	newConfig.File = oldConfig.File
-	if err := applyLiveRoomsByConfig(ctx, oldConfig); err != nil {
+	if _, err := reload.Apply(ctx, newConfig); err != nil {
 		writeJSON(writer, map[string]any{
 			"error": err.Error(),
 		})
 		return
 	}
 	newConfig.Marshal()
-	newConfig.RefreshLiveRoomIndexCache()
-	configs.SetCurrentConfig(newConfig)
 	writeJSON(writer, commonResp{
 		Data: "OK",
 	})
 }
 
-func applyLiveRoomsByConfig(ctx context.Context, oldConfig *configs.Config) error {
-	inst := instance.GetInstance(ctx)
-	newConfig := inst.Config
-	newLiveRooms := newConfig.LiveRooms
-	newUrlMap := make(map[string]*configs.LiveRoom)
-	for index := range newLiveRooms {
-		newRoom := &newLiveRooms[index]
-		newUrlMap[newRoom.Url] = newRoom
-		if room, err := oldConfig.GetLiveRoomByUrl(newRoom.Url); err != nil {
-			// add live
-			if _, err := addLiveImpl(ctx, newRoom.Url, newRoom.IsListening); err != nil {
-				return err
-			}
-		} else {
-			live, ok := inst.Lives[types.LiveID(room.LiveId)]
-			if !ok {
-				return fmt.Errorf("live id: %s can not find", room.LiveId)
-			}
-			live.UpdateLiveOptionsbyConfig(ctx, newRoom)
-			if room.IsListening != newRoom.IsListening {
-				if newRoom.IsListening {
-					// start listening
-					if err := startListening(ctx, live); err != nil {
-						return err
-					}
-				} else {
-					// stop listening
-					if err := stopListening(ctx, live.GetLiveId()); err != nil {
-						return err
-					}
-				}
-			}
-			newRoom.LiveId = room.LiveId
-		}
-	}
-	loopRooms := oldConfig.LiveRooms
-	for _, room := range loopRooms {
-		if _, ok := newUrlMap[room.Url]; !ok {
-			// remove live
-			live, ok := inst.Lives[types.LiveID(room.LiveId)]
-			if !ok {
-				return fmt.Errorf("live id: %s can not find", room.LiveId)
-			}
-			removeLiveImpl(ctx, live)
-		}
-	}
-	return nil
-}
-
 func getInfo(writer http.ResponseWriter, r *http.Request) {
 	writeJSON(writer, consts.AppInfo)
 }
@@ -373,23 +366,9 @@ func getFileInfo(writer http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	path := vars["path"]
 
-	inst := instance.GetInstance(r.Context())
-	base, err := filepath.Abs(inst.Config.OutPutPath)
-	if err != nil {
-		writeJSON(writer, commonResp{
-			ErrMsg: "无效输出目录",
-		})
-		return
-	}
-
-	absPath, err := filepath.Abs(filepath.Join(base, path))
+	cfg := instance.GetInstance(r.Context()).GetConfig()
+	absPath, err := resolveFilePath(cfg, path)
 	if err != nil {
-		writeJSON(writer, commonResp{
-			ErrMsg: "无效路径",
-		})
-		return
-	}
-	if !strings.HasPrefix(absPath, base) {
 		writeJSON(writer, commonResp{
 			ErrMsg: "异常路径",
 		})
@@ -409,6 +388,8 @@ func getFileInfo(writer http.ResponseWriter, r *http.Request) {
 		Name         string `json:"name"`
 		LastModified int64  `json:"last_modified"`
 		Size         int64  `json:"size"`
+		// RoomUrl 直播间单独设置的输出目录所属的直播间
+		RoomUrl string `json:"room_url,omitempty"`
 	}
 	jsonFiles := make([]jsonFile, len(files))
 	json := struct {
@@ -429,6 +410,16 @@ func getFileInfo(writer http.ResponseWriter, r *http.Request) {
 			jsonFiles[i].Size = info.Size()
 		}
 	}
+	if strings.Trim(path, "/") == "" {
+		// 根目录中列出直播间单独设置的输出目录
+		for _, root := range roomRoots(cfg) {
+			entry := jsonFile{IsFolder: true, Name: root.Name, RoomUrl: root.RoomUrl}
+			if info, err := os.Stat(root.Path); err == nil {
+				entry.LastModified = info.ModTime().Unix()
+			}
+			jsonFiles = append(jsonFiles, entry)
+		}
+	}
 	json.Files = jsonFiles
 
 	writeJSON(writer, json)
@@ -436,16 +427,25 @@ func getFileInfo(writer http.ResponseWriter, r *http.Request) {
 
 func getLiveHostCookie(writer http.ResponseWriter, r *http.Request) {
 	inst := instance.GetInstance(r.Context())
+	redacted, err := inst.GetConfig().Redacted()
+	if err != nil {
+		writeJsonWithStatusCode(writer, http.StatusInternalServerError, commonResp{
+			ErrNo:  http.StatusInternalServerError,
+			ErrMsg: err.Error(),
+		})
+		return
+	}
+	cookies := redacted.Cookies
 	hostCookieMap := make(map[string]*live.InfoCookie)
 	keys := make([]string, 0)
-	for _, v := range inst.Lives {
+	for _, v := range inst.GetLives() {
 		urltmp, _ := url.Parse(v.GetRawUrl())
 		if _, ok := hostCookieMap[urltmp.Host]; ok {
 			continue
 		}
 		v1, _ := v.GetInfo()
 		host := urltmp.Host
-		if cookie, ok := inst.Config.Cookies[host]; ok {
+		if cookie, ok := cookies[host]; ok {
 			tmp := &live.InfoCookie{Platform_cn_name: v1.Live.GetPlatformCNName(), Host: host, Cookie: cookie}
 			hostCookieMap[host] = tmp
 		} else {
@@ -477,6 +477,13 @@ func putLiveHostCookie(writer http.ResponseWriter, r *http.Request) {
 
 	host := data.Get("Host").Str
 	cookie := data.Get("Cookie").Str
+	if cookie == configs.RedactedSecret {
+		// 未修改隐藏的 cookie
+		writeJSON(writer, commonResp{
+			Data: "OK",
+		})
+		return
+	}
 	if cookie == "" {
 
 	} else {
@@ -489,17 +496,20 @@ func putLiveHostCookie(writer http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
-	if inst.Config.Cookies == nil {
-		inst.Config.Cookies = make(map[string]string)
-	}
-	inst.Config.Cookies[host] = cookie
-	for _, v := range inst.Config.LiveRooms {
+	config, _ := inst.UpdateConfig(func(cfg *configs.Config) error {
+		if cfg.Cookies == nil {
+			cfg.Cookies = make(map[string]string)
+		}
+		cfg.Cookies[host] = cookie
+		return nil
+	})
+	for _, v := range config.LiveRooms {
 		tmpurl, _ := url.Parse(v.Url)
 		if tmpurl.Host != host {
 			continue
 		}
-		live := inst.Lives[v.LiveId]
-		if live == nil {
+		live, ok := inst.GetLive(v.LiveId)
+		if !ok {
 			writeJsonWithStatusCode(writer, http.StatusBadRequest, commonResp{
 				ErrNo:  http.StatusBadRequest,
 				ErrMsg: "can't find live by url: " + v.Url,
@@ -508,8 +518,261 @@ func putLiveHostCookie(writer http.ResponseWriter, r *http.Request) {
 		}
 		live.UpdateLiveOptionsbyConfig(ctx, &v)
 	}
-	inst.Config.Marshal()
+	config.Marshal()
 	writeJSON(writer, commonResp{
 		Data: "OK",
 	})
 }
+
+// parseTimeParam 解析时间参数，支持 RFC3339、"2006-01-02" 与 unix 秒
+// 仅包含日期时，endOfDay 为 true 则取当天结束时刻
+func parseTimeParam(value string, endOfDay bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		if endOfDay {
+			t = t.Add(24*time.Hour - time.Nanosecond)
+		}
+		return t, nil
+	}
+	if sec, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time: %s", value)
+}
+
+func getRecordings(writer http.ResponseWriter, r *http.Request) {
+	store := history.GetStore(r.Context())
+	if store == nil {
+		writeJsonWithStatusCode(writer, http.StatusServiceUnavailable, commonResp{
+			ErrNo:  http.StatusServiceUnavailable,
+			ErrMsg: "record history is not enabled",
+		})
+		return
+	}
+	query := r.URL.Query()
+	filter := history.Filter{
+		LiveID:   types.LiveID(query.Get("live_id")),
+		Platform: query.Get("platform"),
+	}
+	var err error
+	if filter.From, err = parseTimeParam(query.Get("from"), false); err == nil {
+		filter.To, err = parseTimeParam(query.Get("to"), true)
+	}
+	if err == nil && query.Get("limit") != "" {
+		filter.Limit, err = strconv.Atoi(query.Get("limit"))
+	}
+	if err != nil {
+		writeJsonWithStatusCode(writer, http.StatusBadRequest, commonResp{
+			ErrNo:  http.StatusBadRequest,
+			ErrMsg: err.Error(),
+		})
+		return
+	}
+	records, err := store.Query(filter)
+	if err != nil {
+		writeJsonWithStatusCode(writer, http.StatusInternalServerError, commonResp{
+			ErrNo:  http.StatusInternalServerError,
+			ErrMsg: err.Error(),
+		})
+		return
+	}
+	writeJSON(writer, records)
+}
+
+func getRecording(writer http.ResponseWriter, r *http.Request) {
+	store := history.GetStore(r.Context())
+	if store == nil {
+		writeJsonWithStatusCode(writer, http.StatusServiceUnavailable, commonResp{
+			ErrNo:  http.StatusServiceUnavailable,
+			ErrMsg: "record history is not enabled",
+		})
+		return
+	}
+	vars := mux.Vars(r)
+	id, err := strconv.ParseUint(vars["id"], 10, 64)
+	if err != nil {
+		writeJsonWithStatusCode(writer, http.StatusBadRequest, commonResp{
+			ErrNo:  http.StatusBadRequest,
+			ErrMsg: fmt.Sprintf("invalid record id: %s", vars["id"]),
+		})
+		return
+	}
+	record, err := store.Get(id)
+	if err != nil {
+		writeJsonWithStatusCode(writer, http.StatusNotFound, commonResp{
+			ErrNo:  http.StatusNotFound,
+			ErrMsg: err.Error(),
+		})
+		return
+	}
+	writeJSON(writer, record)
+}
+
+func getWebhookDeliveries(writer http.ResponseWriter, r *http.Request) {
+	notifier := webhook.GetNotifier(r.Context())
+	if notifier == nil {
+		writeJsonWithStatusCode(writer, http.StatusServiceUnavailable, commonResp{
+			ErrNo:  http.StatusServiceUnavailable,
+			ErrMsg: "webhook is not enabled",
+		})
+		return
+	}
+	writeJSON(writer, notifier.Deliveries())
+}
+
+func getStorage(writer http.ResponseWriter, r *http.Request) {
+	m := storage.GetManager(r.Context())
+	if m == nil {
+		writeJsonWithStatusCode(writer, http.StatusServiceUnavailable, commonResp{
+			ErrNo:  http.StatusServiceUnavailable,
+			ErrMsg: "storage manager is not enabled",
+		})
+		return
+	}
+	writeJSON(writer, map[string]any{
+		"volumes":   m.Volumes(),
+		"retention": instance.GetInstance(r.Context()).GetConfig().Storage.Retention,
+	})
+}
+
+// cleanupStorage 按保留规则清理录制，只有 dry_run=false 时才会实际删除文件
+func cleanupStorage(writer http.ResponseWriter, r *http.Request) {
+	m := storage.GetManager(r.Context())
+	if m == nil {
+		writeJsonWithStatusCode(writer, http.StatusServiceUnavailable, commonResp{
+			ErrNo:  http.StatusServiceUnavailable,
+			ErrMsg: "storage manager is not enabled",
+		})
+		return
+	}
+	dryRun := true
+	if v := r.URL.Query().Get("dry_run"); v != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			writeJsonWithStatusCode(writer, http.StatusBadRequest, commonResp{
+				ErrNo:  http.StatusBadRequest,
+				ErrMsg: "invalid dry_run",
+			})
+			return
+		}
+	}
+	result, err := m.Cleanup(dryRun)
+	if err != nil {
+		writeJsonWithStatusCode(writer, http.StatusInternalServerError, commonResp{
+			ErrNo:  http.StatusInternalServerError,
+			ErrMsg: err.Error(),
+		})
+		return
+	}
+	writeJSON(writer, result)
+}
+
+func getJobs(writer http.ResponseWriter, r *http.Request) {
+	q := jobs.GetQueue(r.Context())
+	if q == nil {
+		writeJsonWithStatusCode(writer, http.StatusServiceUnavailable, commonResp{
+			ErrNo:  http.StatusServiceUnavailable,
+			ErrMsg: "job queue is not enabled",
+		})
+		return
+	}
+	query := r.URL.Query()
+	filter := jobs.Filter{
+		Status: jobs.Status(query.Get("status")),
+		LiveID: types.LiveID(query.Get("live_id")),
+	}
+	var err error
+	switch filter.Status {
+	case "", jobs.StatusPending, jobs.StatusRunning, jobs.StatusSucceeded, jobs.StatusFailed, jobs.StatusCanceled:
+	default:
+		err = fmt.Errorf("invalid status: %s", filter.Status)
+	}
+	if err == nil && query.Get("limit") != "" {
+		filter.Limit, err = strconv.Atoi(query.Get("limit"))
+	}
+	if err != nil {
+		writeJsonWithStatusCode(writer, http.StatusBadRequest, commonResp{
+			ErrNo:  http.StatusBadRequest,
+			ErrMsg: err.Error(),
+		})
+		return
+	}
+	list, err := q.List(filter)
+	if err != nil {
+		writeJsonWithStatusCode(writer, http.StatusInternalServerError, commonResp{
+			ErrNo:  http.StatusInternalServerError,
+			ErrMsg: err.Error(),
+		})
+		return
+	}
+	writeJSON(writer, list)
+}
+
+// handleJob 解析路径中的任务 ID 并执行 fn，任务不存在时返回 404，状态不允许该操作时返回 409
+func handleJob(writer http.ResponseWriter, r *http.Request, fn func(q *jobs.Queue, id uint64) (*jobs.Job, error)) {
+	q := jobs.GetQueue(r.Context())
+	if q == nil {
+		writeJsonWithStatusCode(writer, http.StatusServiceUnavailable, commonResp{
+			ErrNo:  http.StatusServiceUnavailable,
+			ErrMsg: "job queue is not enabled",
+		})
+		return
+	}
+	vars := mux.Vars(r)
+	id, err := strconv.ParseUint(vars["id"], 10, 64)
+	if err != nil {
+		writeJsonWithStatusCode(writer, http.StatusBadRequest, commonResp{
+			ErrNo:  http.StatusBadRequest,
+			ErrMsg: fmt.Sprintf("invalid job id: %s", vars["id"]),
+		})
+		return
+	}
+	job, err := fn(q, id)
+	if err != nil {
+		code := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, jobs.ErrJobNotExist):
+			code = http.StatusNotFound
+		case errors.Is(err, jobs.ErrJobNotRetryable), errors.Is(err, jobs.ErrJobNotCancelable):
+			code = http.StatusConflict
+		}
+		writeJsonWithStatusCode(writer, code, commonResp{
+			ErrNo:  code,
+			ErrMsg: err.Error(),
+		})
+		return
+	}
+	writeJSON(writer, job)
+}
+
+func getJob(writer http.ResponseWriter, r *http.Request) {
+	handleJob(writer, r, (*jobs.Queue).Get)
+}
+
+// retryJob 重新执行失败或被取消的任务
+func retryJob(writer http.ResponseWriter, r *http.Request) {
+	handleJob(writer, r, (*jobs.Queue).Retry)
+}
+
+// cancelJob 取消等待中或正在执行的任务
+func cancelJob(writer http.ResponseWriter, r *http.Request) {
+	handleJob(writer, r, (*jobs.Queue).Cancel)
+}
+
+// getUploads 返回最近的上传状态，最新的在前
+func getUploads(writer http.ResponseWriter, r *http.Request) {
+	u := upload.GetUploader(r.Context())
+	if u == nil {
+		writeJsonWithStatusCode(writer, http.StatusServiceUnavailable, commonResp{
+			ErrNo:  http.StatusServiceUnavailable,
+			ErrMsg: "uploader is not enabled",
+		})
+		return
+	}
+	writeJSON(writer, u.Uploads())
+}
@@ -3,6 +3,7 @@ package servers
 import (
 	"net/http"
 
+	"github.com/bililive-go/bililive-go/src/configs"
 	"github.com/bililive-go/bililive-go/src/instance"
 )
 
@@ -10,9 +11,21 @@ func log(handler http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		instance.GetInstance(r.Context()).Logger.WithFields(map[string]any{
 			"Method":     r.Method,
-			"Path":       r.RequestURI,
+			"Path":       redactedRequestURI(r),
 			"RemoteAddr": r.RemoteAddr,
 		}).Debug("Http Request")
 		handler.ServeHTTP(w, r)
 	})
 }
+
+// redactedRequestURI 返回隐藏了 access_token 参数的请求地址，避免 api token 被写入日志
+func redactedRequestURI(r *http.Request) string {
+	query := r.URL.Query()
+	if !query.Has("access_token") {
+		return r.RequestURI
+	}
+	query.Set("access_token", configs.RedactedSecret)
+	u := *r.URL
+	u.RawQuery = query.Encode()
+	return u.RequestURI()
+}
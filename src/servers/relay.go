@@ -0,0 +1,91 @@
+package servers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/bililive-go/bililive-go/src/instance"
+	"github.com/bililive-go/bililive-go/src/recorders"
+	"github.com/bililive-go/bililive-go/src/types"
+)
+
+// relayWriter 在第一次写入时发送响应头，之后每次写入都立即 flush
+type relayWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	started bool
+}
+
+func (w *relayWriter) Write(b []byte) (int, error) {
+	if !w.started {
+		w.started = true
+		w.w.Header().Set("Content-Type", "video/x-flv")
+		w.w.Header().Set("Cache-Control", "no-cache")
+		w.w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.w.Write(b)
+	if err == nil {
+		w.flusher.Flush()
+	}
+	return n, err
+}
+
+// relayLive 以 HTTP-FLV 的形式转发正在录制的直播流，不会重新请求直播源
+func relayLive(writer http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	inst := instance.GetInstance(ctx)
+	vars := mux.Vars(r)
+	liveID := types.LiveID(vars["id"])
+	if _, ok := inst.GetLive(liveID); !ok {
+		writeJsonWithStatusCode(writer, http.StatusNotFound, commonResp{
+			ErrNo:  http.StatusNotFound,
+			ErrMsg: fmt.Sprintf("live id: %s can not find", vars["id"]),
+		})
+		return
+	}
+	flusher, ok := writer.(http.Flusher)
+	if !ok {
+		writeMsg(writer, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+	rec, err := inst.RecorderManager.(recorders.Manager).GetRecorder(ctx, liveID)
+	if err != nil {
+		writeJsonWithStatusCode(writer, http.StatusNotFound, commonResp{
+			ErrNo:  http.StatusNotFound,
+			ErrMsg: fmt.Sprintf("live id: %s is not recording", vars["id"]),
+		})
+		return
+	}
+
+	w := &relayWriter{w: writer, flusher: flusher}
+	err = rec.Relay(ctx, w)
+	if w.started {
+		return
+	}
+	switch {
+	case errors.Is(err, recorders.ErrRecorderNotRecording):
+		writeJsonWithStatusCode(writer, http.StatusNotFound, commonResp{
+			ErrNo:  http.StatusNotFound,
+			ErrMsg: fmt.Sprintf("live id: %s is not recording", vars["id"]),
+		})
+	case errors.Is(err, recorders.ErrParserNotSupportRelay):
+		writeJsonWithStatusCode(writer, http.StatusConflict, commonResp{
+			ErrNo:  http.StatusConflict,
+			ErrMsg: "relay is only supported by the native flv parser",
+		})
+	case err != nil:
+		writeJsonWithStatusCode(writer, http.StatusInternalServerError, commonResp{
+			ErrNo:  http.StatusInternalServerError,
+			ErrMsg: err.Error(),
+		})
+	default:
+		// 录制在收到直播流之前就结束了
+		writeJsonWithStatusCode(writer, http.StatusNotFound, commonResp{
+			ErrNo:  http.StatusNotFound,
+			ErrMsg: fmt.Sprintf("live id: %s is not recording", vars["id"]),
+		})
+	}
+}
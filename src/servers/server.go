@@ -15,6 +15,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"github.com/bililive-go/bililive-go/src/instance"
+	"github.com/bililive-go/bililive-go/src/pkg/events"
 	"github.com/bililive-go/bililive-go/src/tools"
 	"github.com/bililive-go/bililive-go/src/webapp"
 )
@@ -25,6 +26,7 @@ const (
 
 type Server struct {
 	server *http.Server
+	events *eventStream
 }
 
 // dynamicHandler 持有一个可热切换的 http.Handler。
@@ -44,7 +46,7 @@ func (d *dynamicHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	http.Error(w, "Tools Web UI 未就绪", http.StatusServiceUnavailable)
 }
 
-func initMux(ctx context.Context) *mux.Router {
+func initMux(ctx context.Context, stream *eventStream) *mux.Router {
 	m := mux.NewRouter()
 	m.Use(func(handler http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -58,7 +60,7 @@ func initMux(ctx context.Context) *mux.Router {
 				),
 			)
 		})
-	}, log)
+	}, log, newAuthenticator().middleware)
 
 	// api router
 	apiRoute := m.PathPrefix(apiRouterPrefix).Subrouter()
@@ -72,24 +74,26 @@ func initMux(ctx context.Context) *mux.Router {
 	apiRoute.HandleFunc("/lives", addLives).Methods("POST")
 	apiRoute.HandleFunc("/lives/{id}", getLive).Methods("GET")
 	apiRoute.HandleFunc("/lives/{id}", removeLive).Methods("DELETE")
+	// 需要在 /lives/{id}/{action} 之前注册
+	apiRoute.HandleFunc("/lives/{id}/stream.flv", relayLive).Methods("GET")
 	apiRoute.HandleFunc("/lives/{id}/{action}", parseLiveAction).Methods("GET")
 	apiRoute.HandleFunc("/file/{path:.*}", getFileInfo).Methods("GET")
 	apiRoute.HandleFunc("/cookies", getLiveHostCookie).Methods("GET")
 	apiRoute.HandleFunc("/cookies", putLiveHostCookie).Methods("PUT")
+	apiRoute.HandleFunc("/recordings", getRecordings).Methods("GET")
+	apiRoute.HandleFunc("/recordings/{id}", getRecording).Methods("GET")
+	apiRoute.HandleFunc("/webhooks/deliveries", getWebhookDeliveries).Methods("GET")
+	apiRoute.HandleFunc("/storage", getStorage).Methods("GET")
+	apiRoute.HandleFunc("/storage/cleanup", cleanupStorage).Methods("POST")
+	apiRoute.HandleFunc("/jobs", getJobs).Methods("GET")
+	apiRoute.HandleFunc("/jobs/{id}", getJob).Methods("GET")
+	apiRoute.HandleFunc("/jobs/{id}/retry", retryJob).Methods("POST")
+	apiRoute.HandleFunc("/jobs/{id}/cancel", cancelJob).Methods("POST")
+	apiRoute.HandleFunc("/uploads", getUploads).Methods("GET")
+	apiRoute.Handle("/events", stream).Methods("GET")
 	apiRoute.Handle("/metrics", promhttp.Handler())
 
-	m.PathPrefix("/files/").Handler(
-		CORSMiddleware(
-			http.StripPrefix(
-				"/files/",
-				http.FileServer(
-					http.Dir(
-						instance.GetInstance(ctx).Config.OutPutPath,
-					),
-				),
-			),
-		),
-	)
+	m.PathPrefix("/files/").HandlerFunc(serveFiles)
 
 	// /tools -> /tools/ 的 301 重定向（保留查询参数）
 	m.HandleFunc("/tools", func(w http.ResponseWriter, r *http.Request) {
@@ -154,29 +158,24 @@ func initMux(ctx context.Context) *mux.Router {
 	return m
 }
 
-func CORSMiddleware(h http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
-		w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
-		h.ServeHTTP(w, r)
-	})
-}
-
 func NewServer(ctx context.Context) *Server {
 	inst := instance.GetInstance(ctx)
 	config := inst.Config
+	stream := newEventStream(inst.Cache)
 	httpServer := &http.Server{
 		Addr:    config.RPC.Bind,
-		Handler: initMux(ctx),
+		Handler: CORSMiddleware(ctx, initMux(ctx, stream)),
 	}
-	server := &Server{server: httpServer}
+	server := &Server{server: httpServer, events: stream}
 	inst.Server = server
 	return server
 }
 
 func (s *Server) Start(ctx context.Context) error {
 	inst := instance.GetInstance(ctx)
+	if ed, ok := inst.EventDispatcher.(events.Dispatcher); ok {
+		s.events.start(ed)
+	}
 	inst.WaitGroup.Add(1)
 	go func() {
 		listener, err := net.Listen("tcp4", s.server.Addr)
@@ -197,6 +196,8 @@ func (s *Server) Start(ctx context.Context) error {
 func (s *Server) Close(ctx context.Context) {
 	inst := instance.GetInstance(ctx)
 	inst.WaitGroup.Done()
+	// 先断开事件流的长连接，否则 Shutdown 会一直等待这些请求结束
+	s.events.close()
 	ctx2, cancel := context.WithCancel(ctx)
 	if err := s.server.Shutdown(ctx2); err != nil {
 		inst.Logger.WithError(err).Error("failed to shutdown server")
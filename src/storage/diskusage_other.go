@@ -0,0 +1,14 @@
+//go:build !windows
+
+package storage
+
+import "golang.org/x/sys/unix"
+
+func diskUsage(path string) (total, free uint64, err error) {
+	var stat unix.Statfs_t
+	if err = unix.Statfs(path, &stat); err != nil {
+		return 0, 0, err
+	}
+	// Bavail 为非 root 用户可用的块数
+	return uint64(stat.Blocks) * uint64(stat.Bsize), uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
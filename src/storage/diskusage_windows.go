@@ -0,0 +1,16 @@
+//go:build windows
+
+package storage
+
+import "golang.org/x/sys/windows"
+
+func diskUsage(path string) (total, free uint64, err error) {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	if err = windows.GetDiskFreeSpaceEx(p, &free, &total, nil); err != nil {
+		return 0, 0, err
+	}
+	return total, free, nil
+}
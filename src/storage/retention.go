@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/bililive-go/bililive-go/src/configs"
+	"github.com/bililive-go/bililive-go/src/history"
+)
+
+// 保留规则
+const (
+	ReasonMaxAge   = "max_age"
+	ReasonKeepLast = "keep_last"
+	ReasonMaxSize  = "max_size_per_streamer"
+)
+
+// for test
+var (
+	statFile = func(file string) (int64, bool) {
+		stat, err := os.Stat(file)
+		if err != nil {
+			return 0, false
+		}
+		return stat.Size(), true
+	}
+	removeFile = func(file string) error {
+		if err := os.Remove(file); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+)
+
+// recordFiles 返回一次录制仍存在的文件及其总大小
+func recordFiles(record *history.Record) ([]string, int64) {
+	var files []string
+	var size int64
+	seen := make(map[string]bool)
+	for _, list := range [][]history.File{record.Files, record.OutputFiles} {
+		for _, f := range list {
+			if seen[f.Path] {
+				continue
+			}
+			seen[f.Path] = true
+			if n, ok := statFile(f.Path); ok {
+				files = append(files, f.Path)
+				size += n
+			}
+		}
+	}
+	return files, size
+}
+
+// planCleanup 按保留规则计算需要删除的录制，records 需按开始时间倒序排列。
+// 规则按直播间分别计算，进行中的录制不会被删除；max_size_per_streamer 总是保留最新的一次录制。
+func planCleanup(records []*history.Record, rule configs.Retention, now time.Time) []*DeletedRecord {
+	type streamer struct {
+		kept int
+		size int64
+	}
+	streamers := make(map[string]*streamer)
+	var ret []*DeletedRecord
+	for _, record := range records {
+		s, ok := streamers[record.LiveUrl]
+		if !ok {
+			s = new(streamer)
+			streamers[record.LiveUrl] = s
+		}
+		files, size := recordFiles(record)
+		if record.InProgress() {
+			s.kept++
+			s.size += size
+			continue
+		}
+		var reason string
+		switch {
+		case rule.MaxAge > 0 && now.Sub(record.StartTime) > rule.MaxAge:
+			reason = ReasonMaxAge
+		case rule.KeepLast > 0 && s.kept >= rule.KeepLast:
+			reason = ReasonKeepLast
+		case rule.MaxSizePerStreamer > 0 && s.kept > 0 && s.size+size > rule.MaxSizePerStreamer:
+			reason = ReasonMaxSize
+		}
+		if reason == "" {
+			s.kept++
+			s.size += size
+			continue
+		}
+		ret = append(ret, &DeletedRecord{
+			ID:        record.ID,
+			LiveUrl:   record.LiveUrl,
+			HostName:  record.HostName,
+			StartTime: record.StartTime,
+			Reason:    reason,
+			Files:     files,
+			Bytes:     size,
+		})
+	}
+	return ret
+}
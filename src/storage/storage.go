@@ -0,0 +1,359 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+
+	"github.com/bililive-go/bililive-go/src/configs"
+	"github.com/bililive-go/bililive-go/src/history"
+	"github.com/bililive-go/bililive-go/src/instance"
+	"github.com/bililive-go/bililive-go/src/pkg/events"
+)
+
+const (
+	// LowDiskSpace 输出目录所在磁盘的剩余空间低于阈值，对象为 *VolumeStatus
+	LowDiskSpace events.EventType = "LowDiskSpace"
+	// DiskSpaceRecovered 剩余空间恢复到阈值以上，对象为 *VolumeStatus
+	DiskSpaceRecovered events.EventType = "DiskSpaceRecovered"
+	// RecordingDeleted 按保留规则删除了一次录制，对象为 *DeletedRecord
+	RecordingDeleted events.EventType = "RecordingDeleted"
+)
+
+var ErrLowDiskSpace = errors.New("low disk space")
+
+// for test
+var getDiskUsage = diskUsage
+
+// defaultCheckInterval check_interval 未设置时使用的检查间隔
+const defaultCheckInterval = time.Minute
+
+var (
+	volumeFreeBytes = prometheus.NewDesc(
+		prometheus.BuildFQName("bgo", "storage", "free_bytes"),
+		"free bytes of the volume of output path",
+		[]string{"path"},
+		nil,
+	)
+	volumeTotalBytes = prometheus.NewDesc(
+		prometheus.BuildFQName("bgo", "storage", "total_bytes"),
+		"total bytes of the volume of output path",
+		[]string{"path"},
+		nil,
+	)
+	volumeLowSpace = prometheus.NewDesc(
+		prometheus.BuildFQName("bgo", "storage", "low_space"),
+		"whether the free space of the volume is below min_free_space",
+		[]string{"path"},
+		nil,
+	)
+	deletedRecordsTotal = prometheus.NewDesc(
+		prometheus.BuildFQName("bgo", "storage", "deleted_records_total"),
+		"recordings deleted by retention rules",
+		nil,
+		nil,
+	)
+	deletedBytesTotal = prometheus.NewDesc(
+		prometheus.BuildFQName("bgo", "storage", "deleted_bytes_total"),
+		"bytes of files deleted by retention rules",
+		nil,
+		nil,
+	)
+)
+
+// VolumeStatus 一个输出目录的磁盘空间状态
+type VolumeStatus struct {
+	Path         string    `json:"path"`
+	Total        uint64    `json:"total"`
+	Free         uint64    `json:"free"`
+	MinFreeSpace int64     `json:"min_free_space"`
+	Low          bool      `json:"low"`
+	CheckedAt    time.Time `json:"checked_at"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// DeletedRecord 按保留规则删除（或 dry run 时将要删除）的一次录制
+type DeletedRecord struct {
+	ID        uint64    `json:"id"`
+	LiveUrl   string    `json:"live_url"`
+	HostName  string    `json:"host_name"`
+	StartTime time.Time `json:"start_time"`
+	// Reason 命中的规则：max_age、keep_last 或 max_size_per_streamer
+	Reason string   `json:"reason"`
+	Files  []string `json:"files"`
+	Bytes  int64    `json:"bytes"`
+}
+
+// CleanupResult 一次清理的结果
+type CleanupResult struct {
+	DryRun     bool             `json:"dry_run"`
+	Records    []*DeletedRecord `json:"records"`
+	TotalBytes int64            `json:"total_bytes"`
+}
+
+// Manager 监控输出目录的剩余空间，并按保留规则清理旧的录制
+type Manager struct {
+	inst   *instance.Instance
+	ed     events.Dispatcher
+	logger *logrus.Entry
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	lock           sync.Mutex
+	volumes        map[string]*VolumeStatus
+	deletedRecords uint64
+	deletedBytes   int64
+
+	// cleanupLock 保证同一时间只有一个清理任务
+	cleanupLock sync.Mutex
+}
+
+func NewManager(ctx context.Context) *Manager {
+	inst := instance.GetInstance(ctx)
+	m := &Manager{
+		inst:    inst,
+		volumes: make(map[string]*VolumeStatus),
+	}
+	inst.StorageManager = m
+	return m
+}
+
+// GetManager 获取当前实例中的存储管理模块，未启用时返回 nil
+func GetManager(ctx context.Context) *Manager {
+	inst := instance.GetInstance(ctx)
+	if inst == nil || inst.StorageManager == nil {
+		return nil
+	}
+	m, _ := inst.StorageManager.(*Manager)
+	return m
+}
+
+func (m *Manager) Start(ctx context.Context) error {
+	m.logger = m.inst.Logger.WithField("module", "storage")
+	m.ed, _ = m.inst.EventDispatcher.(events.Dispatcher)
+	if err := prometheus.Register(m); err != nil {
+		return err
+	}
+	m.ctx, m.cancel = context.WithCancel(ctx)
+	m.wg.Add(1)
+	go m.run()
+	return nil
+}
+
+func (m *Manager) Close(ctx context.Context) {
+	if m.cancel != nil {
+		m.cancel()
+	}
+	m.wg.Wait()
+	prometheus.Unregister(m)
+}
+
+func (m *Manager) config() *configs.Config {
+	return m.inst.GetConfig()
+}
+
+func (m *Manager) run() {
+	defer m.wg.Done()
+	m.checkAll()
+	lastCleanup := time.Now()
+	for {
+		cfg := m.config().Storage
+		interval := cfg.CheckInterval
+		if interval <= 0 {
+			interval = defaultCheckInterval
+		}
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+		m.checkAll()
+		if cfg.Retention.Enable && time.Since(lastCleanup) >= cfg.Retention.Interval {
+			lastCleanup = time.Now()
+			if _, err := m.Cleanup(cfg.Retention.DryRun); err != nil {
+				m.logger.WithError(err).Error("failed to cleanup recordings")
+			}
+		}
+	}
+}
+
+// outputPaths 返回全局与各直播间配置的输出目录
+func (m *Manager) outputPaths() []string {
+	cfg := m.config()
+	paths := []string{cfg.OutPutPath}
+	seen := map[string]bool{cfg.OutPutPath: true}
+	for i := range cfg.LiveRooms {
+		if p := cfg.LiveRooms[i].OutPutPath; p != "" && !seen[p] {
+			seen[p] = true
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+func (m *Manager) checkAll() {
+	if m.config().Storage.MinFreeSpace <= 0 {
+		return
+	}
+	for _, path := range m.outputPaths() {
+		m.check(path)
+	}
+}
+
+// check 获取 path 所在磁盘的空间，并在低于阈值或恢复时发出事件
+func (m *Manager) check(path string) *VolumeStatus {
+	minFree := m.config().Storage.MinFreeSpace
+	status := &VolumeStatus{Path: path, MinFreeSpace: minFree, CheckedAt: time.Now()}
+	total, free, err := getDiskUsage(path)
+	if err != nil {
+		status.Error = err.Error()
+	} else {
+		status.Total, status.Free = total, free
+		status.Low = minFree > 0 && free < uint64(minFree)
+	}
+
+	m.lock.Lock()
+	prev := m.volumes[path]
+	m.volumes[path] = status
+	m.lock.Unlock()
+
+	wasLow := prev != nil && prev.Low
+	switch {
+	case status.Low && !wasLow:
+		m.logger.Warnf("free space of %s is %d bytes, below min_free_space %d bytes, new recordings are paused", path, free, minFree)
+		m.dispatch(LowDiskSpace, status)
+	case !status.Low && wasLow && err == nil:
+		m.logger.Infof("free space of %s recovered to %d bytes", path, free)
+		m.dispatch(DiskSpaceRecovered, status)
+	}
+	return status
+}
+
+func (m *Manager) dispatch(typ events.EventType, obj any) {
+	if m.ed != nil {
+		m.ed.DispatchEvent(events.NewEvent(typ, obj))
+	}
+}
+
+// CheckSpace 检查 path 所在磁盘是否有足够的空间开始新的录制，空间不足时返回 ErrLowDiskSpace。
+// 获取磁盘空间失败时不阻止录制。
+func (m *Manager) CheckSpace(path string) error {
+	if m.config().Storage.MinFreeSpace <= 0 {
+		return nil
+	}
+	if status := m.check(path); status.Low {
+		return fmt.Errorf("%w: %d bytes free on %s", ErrLowDiskSpace, status.Free, path)
+	}
+	return nil
+}
+
+// Volumes 返回各输出目录最近一次检查的结果
+func (m *Manager) Volumes() []*VolumeStatus {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	ret := make([]*VolumeStatus, 0, len(m.volumes))
+	for _, v := range m.volumes {
+		status := *v
+		ret = append(ret, &status)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Path < ret[j].Path })
+	return ret
+}
+
+// isUnderOutputPath 只删除位于输出目录下的文件
+func (m *Manager) isUnderOutputPath(file string) bool {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return false
+	}
+	for _, path := range m.outputPaths() {
+		root, err := filepath.Abs(path)
+		if err != nil {
+			continue
+		}
+		if rel, err := filepath.Rel(root, abs); err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Cleanup 按保留规则清理录制，dryRun 为 true 时只返回将要删除的录制
+func (m *Manager) Cleanup(dryRun bool) (*CleanupResult, error) {
+	store, _ := m.inst.RecordHistory.(history.Store)
+	if store == nil {
+		return nil, errors.New("record history is not enabled")
+	}
+	m.cleanupLock.Lock()
+	defer m.cleanupLock.Unlock()
+
+	records, err := store.Query(history.Filter{})
+	if err != nil {
+		return nil, err
+	}
+	result := &CleanupResult{DryRun: dryRun, Records: make([]*DeletedRecord, 0)}
+	for _, d := range planCleanup(records, m.config().Storage.Retention, time.Now()) {
+		result.Records = append(result.Records, d)
+		result.TotalBytes += d.Bytes
+		if dryRun {
+			m.logger.Infof("[dry run] recording %d of %s would be deleted by %s, %d bytes", d.ID, d.HostName, d.Reason, d.Bytes)
+			continue
+		}
+		for _, file := range d.Files {
+			if !m.isUnderOutputPath(file) {
+				m.logger.Warnf("skip deleting %s, it is not under any output path", file)
+				continue
+			}
+			if err := removeFile(file); err != nil {
+				m.logger.WithError(err).Warnf("failed to delete %s", file)
+			}
+		}
+		if err := store.Delete(d.ID); err != nil {
+			m.logger.WithError(err).Warnf("failed to delete record %d", d.ID)
+		}
+		m.lock.Lock()
+		m.deletedRecords++
+		m.deletedBytes += d.Bytes
+		m.lock.Unlock()
+		m.logger.Infof("recording %d of %s is deleted by %s, %d bytes", d.ID, d.HostName, d.Reason, d.Bytes)
+		m.dispatch(RecordingDeleted, d)
+	}
+	return result, nil
+}
+
+func (m *Manager) Describe(ch chan<- *prometheus.Desc) {
+	ch <- volumeFreeBytes
+	ch <- volumeTotalBytes
+	ch <- volumeLowSpace
+	ch <- deletedRecordsTotal
+	ch <- deletedBytesTotal
+}
+
+func (m *Manager) Collect(ch chan<- prometheus.Metric) {
+	for _, v := range m.Volumes() {
+		if v.Error != "" {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(volumeFreeBytes, prometheus.GaugeValue, float64(v.Free), v.Path)
+		ch <- prometheus.MustNewConstMetric(volumeTotalBytes, prometheus.GaugeValue, float64(v.Total), v.Path)
+		low := 0.0
+		if v.Low {
+			low = 1
+		}
+		ch <- prometheus.MustNewConstMetric(volumeLowSpace, prometheus.GaugeValue, low, v.Path)
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	ch <- prometheus.MustNewConstMetric(deletedRecordsTotal, prometheus.CounterValue, float64(m.deletedRecords))
+	ch <- prometheus.MustNewConstMetric(deletedBytesTotal, prometheus.CounterValue, float64(m.deletedBytes))
+}
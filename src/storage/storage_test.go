@@ -0,0 +1,194 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bililive-go/bililive-go/src/configs"
+	"github.com/bililive-go/bililive-go/src/history"
+	"github.com/bililive-go/bililive-go/src/instance"
+	"github.com/bililive-go/bililive-go/src/interfaces"
+	"github.com/bililive-go/bililive-go/src/pkg/events"
+)
+
+func writeFile(t *testing.T, path string, size int) history.File {
+	assert.NoError(t, os.WriteFile(path, make([]byte, size), 0644))
+	return history.File{Path: path, Size: int64(size)}
+}
+
+func TestPlanCleanup(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	const roomA, roomB = "https://live.bilibili.com/1", "https://live.bilibili.com/2"
+	newRecord := func(id uint64, url string, age time.Duration, size int) *history.Record {
+		return &history.Record{
+			ID:        id,
+			LiveUrl:   url,
+			StartTime: now.Add(-age),
+			EndTime:   now.Add(-age + time.Minute),
+			Files:     []history.File{writeFile(t, filepath.Join(dir, filepath.Base(url)+"_"+time.Duration(id).String()+".flv"), size)},
+		}
+	}
+	// 按开始时间倒序
+	records := []*history.Record{
+		newRecord(5, roomA, time.Hour, 100),
+		newRecord(4, roomB, 2*time.Hour, 100),
+		newRecord(3, roomA, 3*time.Hour, 100),
+		newRecord(2, roomA, 4*time.Hour, 100),
+		newRecord(1, roomA, 48*time.Hour, 100),
+	}
+	records[0].EndTime = time.Time{}
+
+	ids := func(list []*DeletedRecord) map[uint64]string {
+		ret := make(map[uint64]string)
+		for _, d := range list {
+			ret[d.ID] = d.Reason
+		}
+		return ret
+	}
+
+	assert.Empty(t, planCleanup(records, configs.Retention{}, now))
+	assert.Equal(t, map[uint64]string{1: ReasonMaxAge}, ids(planCleanup(records, configs.Retention{MaxAge: 24 * time.Hour}, now)))
+	// 进行中的录制也计入保留数量
+	assert.Equal(t, map[uint64]string{2: ReasonKeepLast, 1: ReasonKeepLast}, ids(planCleanup(records, configs.Retention{KeepLast: 2}, now)))
+	assert.Equal(t, map[uint64]string{2: ReasonMaxSize, 1: ReasonMaxSize}, ids(planCleanup(records, configs.Retention{MaxSizePerStreamer: 250}, now)))
+	// 总是保留最新的一次录制
+	assert.Equal(t, map[uint64]string{2: ReasonMaxSize, 1: ReasonMaxSize}, ids(planCleanup(records[1:], configs.Retention{MaxSizePerStreamer: 50}, now)))
+
+	deleted := planCleanup(records, configs.Retention{MaxAge: 24 * time.Hour}, now)
+	assert.Equal(t, int64(100), deleted[0].Bytes)
+	assert.Equal(t, []string{records[4].Files[0].Path}, deleted[0].Files)
+}
+
+func newTestManager(t *testing.T, dir string) (context.Context, *Manager) {
+	cfg := configs.NewConfig()
+	cfg.AppDataPath = filepath.Join(dir, ".appdata")
+	cfg.OutPutPath = dir
+	inst := &instance.Instance{
+		Config:          cfg,
+		Logger:          &interfaces.Logger{Logger: logrus.New()},
+		EventDispatcher: events.NewDispatcher(context.Background()),
+	}
+	ctx := context.WithValue(context.Background(), instance.Key, inst)
+	store := history.NewStore(ctx)
+	assert.NoError(t, store.Start(ctx))
+	t.Cleanup(func() { store.Close(ctx) })
+	m := NewManager(ctx)
+	m.logger = inst.Logger.WithField("module", "storage")
+	m.ed = inst.EventDispatcher.(events.Dispatcher)
+	return ctx, m
+}
+
+func TestCleanup(t *testing.T) {
+	dir := t.TempDir()
+	ctx, m := newTestManager(t, dir)
+	assert.Equal(t, m, GetManager(ctx))
+	store := history.GetStore(ctx)
+	m.config().Storage.Retention = configs.Retention{KeepLast: 1}
+
+	base := time.Now().Add(-time.Hour)
+	var records []*history.Record
+	for i := 0; i < 3; i++ {
+		r := &history.Record{
+			LiveUrl:   "https://live.bilibili.com/1",
+			StartTime: base.Add(time.Duration(i) * time.Minute),
+			EndTime:   base.Add(time.Duration(i)*time.Minute + 30*time.Second),
+			Files:     []history.File{writeFile(t, filepath.Join(dir, time.Duration(i).String()+".flv"), 10)},
+		}
+		assert.NoError(t, store.Add(r))
+		records = append(records, r)
+	}
+	// 不在输出目录下的文件不会被删除
+	outside := filepath.Join(t.TempDir(), "outside.flv")
+	records[0].OutputFiles = []history.File{writeFile(t, outside, 5)}
+	assert.NoError(t, store.Update(records[0]))
+
+	deleted := make(chan *DeletedRecord, 2)
+	m.ed.AddEventListener(RecordingDeleted, events.NewEventListener(func(event *events.Event) {
+		deleted <- event.Object.(*DeletedRecord)
+	}))
+
+	result, err := m.Cleanup(true)
+	assert.NoError(t, err)
+	assert.True(t, result.DryRun)
+	assert.Len(t, result.Records, 2)
+	assert.Equal(t, int64(25), result.TotalBytes)
+	for _, r := range records {
+		assert.FileExists(t, r.Files[0].Path)
+	}
+	all, _ := store.Query(history.Filter{})
+	assert.Len(t, all, 3)
+
+	result, err = m.Cleanup(false)
+	assert.NoError(t, err)
+	assert.False(t, result.DryRun)
+	assert.Len(t, result.Records, 2)
+	assert.FileExists(t, records[2].Files[0].Path)
+	assert.NoFileExists(t, records[1].Files[0].Path)
+	assert.NoFileExists(t, records[0].Files[0].Path)
+	assert.FileExists(t, outside)
+	all, _ = store.Query(history.Filter{})
+	assert.Len(t, all, 1)
+	assert.Equal(t, records[2].ID, all[0].ID)
+	for i := 0; i < 2; i++ {
+		select {
+		case d := <-deleted:
+			assert.Equal(t, ReasonKeepLast, d.Reason)
+		case <-time.After(time.Second):
+			t.Fatal("RecordingDeleted is not dispatched")
+		}
+	}
+}
+
+func TestCheckSpace(t *testing.T) {
+	backup := getDiskUsage
+	defer func() { getDiskUsage = backup }()
+	var free uint64 = 100
+	getDiskUsage = func(path string) (uint64, uint64, error) { return 1000, free, nil }
+
+	dir := t.TempDir()
+	_, m := newTestManager(t, dir)
+	received := make(chan events.EventType, 4)
+	for _, typ := range []events.EventType{LowDiskSpace, DiskSpaceRecovered} {
+		m.ed.AddEventListener(typ, events.NewEventListener(func(event *events.Event) {
+			received <- event.Type
+		}))
+	}
+	expectEvent := func(typ events.EventType) {
+		select {
+		case got := <-received:
+			assert.Equal(t, typ, got)
+		case <-time.After(time.Second):
+			t.Fatalf("%s is not dispatched", typ)
+		}
+	}
+
+	// 未设置阈值时不检查
+	assert.NoError(t, m.CheckSpace(dir))
+	assert.Empty(t, m.Volumes())
+
+	m.config().Storage.MinFreeSpace = 200
+	assert.ErrorIs(t, m.CheckSpace(dir), ErrLowDiskSpace)
+	expectEvent(LowDiskSpace)
+	// 持续不足时不重复发出事件
+	assert.ErrorIs(t, m.CheckSpace(dir), ErrLowDiskSpace)
+	volumes := m.Volumes()
+	assert.Len(t, volumes, 1)
+	assert.True(t, volumes[0].Low)
+
+	free = 300
+	assert.NoError(t, m.CheckSpace(dir))
+	expectEvent(DiskSpaceRecovered)
+	assert.False(t, m.Volumes()[0].Low)
+	select {
+	case typ := <-received:
+		t.Fatalf("unexpected event %s", typ)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,140 @@
+package upload
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/kira1928/remotetools"
+
+	"github.com/bililive-go/bililive-go/src/configs"
+)
+
+// rcloneBackend 调用 rclone copyto 上传到已配置的 remote，上传后通过 rclone lsjson 校验文件大小
+type rcloneBackend struct {
+	cfg configs.RcloneUpload
+}
+
+func newRcloneBackend(cfg configs.RcloneUpload) (Backend, error) {
+	return &rcloneBackend{cfg: cfg}, nil
+}
+
+// rclonePath 查找 rclone：配置的路径、remotetools 已安装的 rclone、PATH，都找不到时通过 remotetools 下载
+func rclonePath(cfg configs.RcloneUpload) (string, error) {
+	if cfg.Path != "" {
+		if _, err := os.Stat(cfg.Path); err != nil {
+			return "", err
+		}
+		return cfg.Path, nil
+	}
+	tool, toolErr := remotetools.Get().GetTool("rclone")
+	if toolErr == nil && tool.DoesToolExist() {
+		return tool.GetToolPath(), nil
+	}
+	if path, err := exec.LookPath("rclone"); err == nil {
+		return path, nil
+	}
+	if toolErr != nil {
+		return "", fmt.Errorf("failed to find rclone: %w", toolErr)
+	}
+	if err := tool.Install(); err != nil {
+		return "", fmt.Errorf("failed to install rclone: %w", err)
+	}
+	return tool.GetToolPath(), nil
+}
+
+// remotePath 拼接 remote 与远端路径
+func (b *rcloneBackend) remotePath(path string) string {
+	remote := b.cfg.Remote
+	if strings.HasSuffix(remote, ":") || strings.HasSuffix(remote, "/") {
+		return remote + path
+	}
+	return remote + "/" + path
+}
+
+func (b *rcloneBackend) command(ctx context.Context, rclone string, args ...string) *exec.Cmd {
+	if b.cfg.ConfigFile != "" {
+		args = append(args, "--config", b.cfg.ConfigFile)
+	}
+	return exec.CommandContext(ctx, rclone, args...)
+}
+
+// rcloneLog rclone 使用 --use-json-log 时输出的一行日志
+type rcloneLog struct {
+	Level string `json:"level"`
+	Msg   string `json:"msg"`
+	Stats *struct {
+		Bytes int64 `json:"bytes"`
+	} `json:"stats"`
+}
+
+// parseRcloneLog 从 rclone 的日志中读取上传进度，返回最后一条错误信息
+func parseRcloneLog(r *bufio.Scanner, progress func(uploaded int64)) string {
+	var lastError string
+	for r.Scan() {
+		line := r.Bytes()
+		var l rcloneLog
+		if err := json.Unmarshal(line, &l); err != nil {
+			if text := strings.TrimSpace(string(line)); text != "" {
+				lastError = text
+			}
+			continue
+		}
+		if l.Stats != nil && progress != nil {
+			progress(l.Stats.Bytes)
+		}
+		if l.Level == "error" || l.Level == "critical" {
+			lastError = l.Msg
+		}
+	}
+	return lastError
+}
+
+func (b *rcloneBackend) Upload(ctx context.Context, file, path string, progress func(uploaded int64)) (string, error) {
+	stat, err := os.Stat(file)
+	if err != nil {
+		return "", err
+	}
+	rclone, err := rclonePath(b.cfg)
+	if err != nil {
+		return "", err
+	}
+	remotePath := b.remotePath(path)
+
+	args := append([]string{"copyto", file, remotePath, "--use-json-log", "--stats", "1s", "--stats-log-level", "NOTICE"}, b.cfg.Args...)
+	cmd := b.command(ctx, rclone, args...)
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return "", err
+	}
+	if err := cmd.Start(); err != nil {
+		return "", err
+	}
+	lastError := parseRcloneLog(bufio.NewScanner(stderr), progress)
+	if err := cmd.Wait(); err != nil {
+		if lastError != "" {
+			return "", fmt.Errorf("rclone copyto failed: %w: %s", err, lastError)
+		}
+		return "", fmt.Errorf("rclone copyto failed: %w", err)
+	}
+
+	out, err := b.command(ctx, rclone, "lsjson", "--stat", remotePath).Output()
+	if err != nil {
+		return "", fmt.Errorf("rclone lsjson failed: %w", err)
+	}
+	var info struct {
+		Size int64 `json:"Size"`
+	}
+	if err := json.NewDecoder(bytes.NewReader(out)).Decode(&info); err != nil {
+		return "", fmt.Errorf("failed to parse rclone lsjson output: %w", err)
+	}
+	if info.Size != stat.Size() {
+		return "", fmt.Errorf("size of uploaded file mismatch, local: %d, remote: %d", stat.Size(), info.Size)
+	}
+	return remotePath, nil
+}
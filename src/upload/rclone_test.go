@@ -0,0 +1,59 @@
+package upload
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bililive-go/bililive-go/src/configs"
+)
+
+// fakeRclone 模拟 rclone 的 copyto 与 lsjson，remote 为本地目录
+const fakeRclone = `#!/bin/sh
+case "$1" in
+copyto)
+	mkdir -p "$(dirname "$3")" && cp "$2" "$3" || exit 1
+	echo '{"level":"notice","msg":"Transferred","stats":{"bytes":4}}' >&2
+	echo '{"level":"notice","msg":"Transferred","stats":{"bytes":8}}' >&2
+	;;
+lsjson)
+	[ -f "$3" ] || { echo '{"level":"error","msg":"object not found"}' >&2; exit 3; }
+	echo "{\"Path\":\"$(basename "$3")\",\"Size\":$(wc -c < "$3" | tr -d ' ')}"
+	;;
+esac
+`
+
+func TestRcloneBackend(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake rclone is a shell script")
+	}
+	dir := t.TempDir()
+	rclone := filepath.Join(dir, "rclone")
+	assert.NoError(t, os.WriteFile(rclone, []byte(fakeRclone), 0755))
+	file := filepath.Join(dir, "a.flv")
+	assert.NoError(t, os.WriteFile(file, []byte("flv data"), 0644))
+	remote := filepath.Join(dir, "remote")
+
+	b, err := newRcloneBackend(configs.RcloneUpload{Remote: remote, Path: rclone})
+	assert.NoError(t, err)
+	var progress []int64
+	dst, err := b.Upload(context.Background(), file, "主播/a.flv", func(uploaded int64) { progress = append(progress, uploaded) })
+	assert.NoError(t, err)
+	assert.Equal(t, remote+"/主播/a.flv", dst)
+	assert.Equal(t, []int64{4, 8}, progress)
+	data, err := os.ReadFile(filepath.Join(remote, "主播", "a.flv"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("flv data"), data)
+
+	assert.Equal(t, "nas:主播/a.flv", (&rcloneBackend{cfg: configs.RcloneUpload{Remote: "nas:"}}).remotePath("主播/a.flv"))
+
+	// rclone 找不到时返回错误
+	b, err = newRcloneBackend(configs.RcloneUpload{Remote: remote, Path: filepath.Join(dir, "missing")})
+	assert.NoError(t, err)
+	_, err = b.Upload(context.Background(), file, "b.flv", nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,46 @@
+package upload
+
+import (
+	"context"
+	"mime"
+	"path"
+
+	"github.com/bililive-go/bililive-go/src/configs"
+	"github.com/bililive-go/bililive-go/src/pkg/s3"
+)
+
+// s3Backend 上传到 S3 兼容的对象存储，大文件分片上传并支持续传
+type s3Backend struct {
+	client *s3.Client
+	cfg    configs.S3Upload
+}
+
+func newS3Backend(cfg configs.S3Upload) (Backend, error) {
+	client, err := s3.New(s3.Config{
+		Endpoint:  cfg.Endpoint,
+		Region:    cfg.Region,
+		AccessKey: cfg.AccessKey,
+		SecretKey: cfg.SecretKey,
+		PathStyle: cfg.PathStyle,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &s3Backend{client: client, cfg: cfg}, nil
+}
+
+func (b *s3Backend) Upload(ctx context.Context, file, key string, progress func(uploaded int64)) (string, error) {
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	_, err := b.client.UploadFile(ctx, b.cfg.Bucket, key, file, s3.UploadOptions{
+		PartSize:    b.cfg.PartSize,
+		ContentType: contentType,
+		Progress:    progress,
+	})
+	if err != nil {
+		return "", err
+	}
+	return b.client.ObjectURL(b.cfg.Bucket, key), nil
+}
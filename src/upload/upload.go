@@ -0,0 +1,234 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/bililive-go/bililive-go/src/configs"
+	"github.com/bililive-go/bililive-go/src/instance"
+	"github.com/bililive-go/bililive-go/src/live"
+	"github.com/bililive-go/bililive-go/src/pkg/events"
+	"github.com/bililive-go/bililive-go/src/pkg/utils"
+)
+
+// UploadFinished 一个文件上传成功或失败，对象为 *Status
+const UploadFinished events.EventType = "UploadFinished"
+
+// maxStatusLogs 保留的最近上传记录数
+const maxStatusLogs = 200
+
+// State 上传状态
+type State string
+
+const (
+	StateUploading State = "uploading"
+	StateSucceeded State = "succeeded"
+	StateFailed    State = "failed"
+)
+
+// Backend 上传目标
+type Backend interface {
+	// Upload 上传本地文件 file 到远端路径 path，校验成功后返回远端地址；progress 报告已上传的字节数
+	Upload(ctx context.Context, file, path string, progress func(uploaded int64)) (string, error)
+}
+
+func newBackend(cfg configs.Upload) (Backend, error) {
+	switch cfg.Backend {
+	case configs.UploadBackendS3:
+		return newS3Backend(cfg.S3)
+	case configs.UploadBackendWebDAV:
+		return newWebDAVBackend(cfg.WebDAV)
+	case configs.UploadBackendRclone:
+		return newRcloneBackend(cfg.Rclone)
+	default:
+		return nil, fmt.Errorf("unsupported upload backend: %s", cfg.Backend)
+	}
+}
+
+// Status 一次上传的状态
+type Status struct {
+	ID       uint64 `json:"id"`
+	Backend  string `json:"backend"`
+	File     string `json:"file"`
+	Path     string `json:"path"`
+	URL      string `json:"url,omitempty"`
+	Size     int64  `json:"size"`
+	Uploaded int64  `json:"uploaded"`
+	State    State  `json:"state"`
+	Error    string `json:"error,omitempty"`
+	// Deleted 上传成功后是否已删除本地文件
+	Deleted    bool      `json:"deleted,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at,omitzero"`
+}
+
+// Uploader 将录制文件上传到配置的目标，并保留最近的上传状态
+type Uploader struct {
+	inst   *instance.Instance
+	ed     events.Dispatcher
+	logger *logrus.Entry
+
+	lock    sync.RWMutex
+	uploads []*Status
+	lastID  uint64
+}
+
+func NewUploader(ctx context.Context) *Uploader {
+	inst := instance.GetInstance(ctx)
+	u := &Uploader{inst: inst}
+	inst.Uploader = u
+	return u
+}
+
+// GetUploader 获取当前实例中的上传模块，未启用时返回 nil
+func GetUploader(ctx context.Context) *Uploader {
+	inst := instance.GetInstance(ctx)
+	if inst == nil || inst.Uploader == nil {
+		return nil
+	}
+	u, _ := inst.Uploader.(*Uploader)
+	return u
+}
+
+func (u *Uploader) Start(ctx context.Context) error {
+	u.logger = u.inst.Logger.WithField("module", "upload")
+	u.ed, _ = u.inst.EventDispatcher.(events.Dispatcher)
+	return nil
+}
+
+func (u *Uploader) Close(ctx context.Context) {}
+
+// Uploads 返回最近的上传状态，最新的在前
+func (u *Uploader) Uploads() []*Status {
+	u.lock.RLock()
+	defer u.lock.RUnlock()
+	ret := make([]*Status, 0, len(u.uploads))
+	for i := len(u.uploads) - 1; i >= 0; i-- {
+		s := *u.uploads[i]
+		ret = append(ret, &s)
+	}
+	return ret
+}
+
+func (u *Uploader) add(status *Status) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	u.lastID++
+	status.ID = u.lastID
+	u.uploads = append(u.uploads, status)
+	if len(u.uploads) > maxStatusLogs {
+		u.uploads = u.uploads[len(u.uploads)-maxStatusLogs:]
+	}
+}
+
+// update 在锁内修改上传状态
+func (u *Uploader) update(fn func()) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	fn()
+}
+
+// RenderPath 按 path_tmpl 生成文件的远端路径
+func RenderPath(cfg *configs.Config, info *live.Info, file string) (string, error) {
+	tmpl, err := template.New("upload_path").Funcs(utils.GetFuncMap(cfg)).Parse(cfg.Upload.PathTmpl)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse upload path_tmpl: %w", err)
+	}
+	var room *configs.LiveRoom
+	if info.Live != nil {
+		room = cfg.FindLiveRoomByUrl(info.Live.GetRawUrl())
+	}
+	relPath, err := filepath.Rel(cfg.GetOutPutPath(room), file)
+	if err != nil || strings.HasPrefix(relPath, "..") {
+		relPath = filepath.Base(file)
+	}
+	buf := new(bytes.Buffer)
+	if err := tmpl.Execute(buf, struct {
+		*live.Info
+		FileName string
+		RelPath  string
+	}{
+		Info:     info,
+		FileName: file,
+		RelPath:  filepath.ToSlash(relPath),
+	}); err != nil {
+		return "", fmt.Errorf("failed to render upload path_tmpl: %w", err)
+	}
+	path := strings.TrimLeft(strings.TrimSpace(buf.String()), "/")
+	if path == "" {
+		return "", fmt.Errorf("upload path of %s is empty", file)
+	}
+	return path, nil
+}
+
+// Upload 上传一个文件，成功且开启了 delete_after_upload 时删除本地文件
+func (u *Uploader) Upload(ctx context.Context, info *live.Info, file string) (*Status, error) {
+	cfg := u.inst.GetConfig()
+	stat, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+	path, err := RenderPath(cfg, info, file)
+	if err != nil {
+		return nil, err
+	}
+	backend, err := newBackend(cfg.Upload)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &Status{
+		Backend:   cfg.Upload.Backend,
+		File:      file,
+		Path:      path,
+		Size:      stat.Size(),
+		State:     StateUploading,
+		StartedAt: time.Now(),
+	}
+	u.add(status)
+	logger := u.logger.WithFields(logrus.Fields{"file": file, "path": path})
+	logger.Infof("uploading to %s", status.Backend)
+	url, err := backend.Upload(ctx, file, path, func(uploaded int64) {
+		u.update(func() { status.Uploaded = uploaded })
+	})
+	u.update(func() {
+		status.FinishedAt = time.Now()
+		if err != nil {
+			status.State = StateFailed
+			status.Error = err.Error()
+			return
+		}
+		status.State = StateSucceeded
+		status.URL = url
+		status.Uploaded = status.Size
+	})
+	if err != nil {
+		logger.WithError(err).Warn("failed to upload")
+	} else {
+		logger.Infof("uploaded to %s", url)
+		if cfg.Upload.DeleteAfterUpload {
+			if removeErr := os.Remove(file); removeErr != nil {
+				logger.WithError(removeErr).Warn("failed to delete uploaded file")
+			} else {
+				u.update(func() { status.Deleted = true })
+			}
+		}
+	}
+
+	u.lock.RLock()
+	ret := *status
+	u.lock.RUnlock()
+	if u.ed != nil {
+		u.ed.DispatchEvent(events.NewEvent(UploadFinished, &ret))
+	}
+	return &ret, err
+}
@@ -0,0 +1,111 @@
+package upload
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bililive-go/bililive-go/src/configs"
+	"github.com/bililive-go/bililive-go/src/instance"
+	"github.com/bililive-go/bililive-go/src/interfaces"
+	"github.com/bililive-go/bililive-go/src/live"
+	"github.com/bililive-go/bililive-go/src/pkg/events"
+	"github.com/bililive-go/bililive-go/src/pkg/s3/s3test"
+)
+
+func TestRenderPath(t *testing.T) {
+	cfg := configs.NewConfig()
+	cfg.OutPutPath = filepath.Join("/srv", "bililive")
+	info := &live.Info{HostName: "主播", RoomName: "房间"}
+	file := filepath.Join(cfg.OutPutPath, "哔哩哔哩", "主播", "a.flv")
+
+	path, err := RenderPath(cfg, info, file)
+	assert.NoError(t, err)
+	assert.Equal(t, "哔哩哔哩/主播/a.flv", path)
+
+	// 不在输出目录下的文件只保留文件名
+	path, err = RenderPath(cfg, info, filepath.Join("/tmp", "a.flv"))
+	assert.NoError(t, err)
+	assert.Equal(t, "a.flv", path)
+
+	cfg.Upload.PathTmpl = `/records/{{ .HostName }}/{{ base .FileName }}`
+	path, err = RenderPath(cfg, info, file)
+	assert.NoError(t, err)
+	assert.Equal(t, "records/主播/a.flv", path)
+
+	cfg.Upload.PathTmpl = `{{ "" }}`
+	_, err = RenderPath(cfg, info, file)
+	assert.Error(t, err)
+}
+
+func TestUploader(t *testing.T) {
+	srv := s3test.NewServer("minio", "minio123", "records")
+	defer srv.Close()
+
+	dir := t.TempDir()
+	cfg := configs.NewConfig()
+	cfg.OutPutPath = dir
+	cfg.Upload = configs.Upload{
+		Enable:            true,
+		Backend:           configs.UploadBackendS3,
+		PathTmpl:          "{{ .RelPath }}",
+		DeleteAfterUpload: true,
+		S3: configs.S3Upload{
+			Endpoint:  srv.URL,
+			Bucket:    "records",
+			AccessKey: "minio",
+			SecretKey: "minio123",
+			PathStyle: true,
+		},
+	}
+	inst := &instance.Instance{
+		Config:          cfg,
+		Logger:          &interfaces.Logger{Logger: logrus.New()},
+		EventDispatcher: events.NewDispatcher(context.Background()),
+	}
+	ctx := context.WithValue(context.Background(), instance.Key, inst)
+	u := NewUploader(ctx)
+	assert.NoError(t, u.Start(ctx))
+	assert.Equal(t, u, GetUploader(ctx))
+
+	finished := make(chan *Status, 2)
+	inst.EventDispatcher.(events.Dispatcher).AddEventListener(UploadFinished, events.NewEventListener(func(event *events.Event) {
+		finished <- event.Object.(*Status)
+	}))
+
+	file := filepath.Join(dir, "主播", "a.flv")
+	assert.NoError(t, os.MkdirAll(filepath.Dir(file), 0755))
+	assert.NoError(t, os.WriteFile(file, []byte("flv data"), 0644))
+	status, err := u.Upload(ctx, &live.Info{}, file)
+	assert.NoError(t, err)
+	assert.Equal(t, StateSucceeded, status.State)
+	assert.Equal(t, "主播/a.flv", status.Path)
+	assert.Equal(t, int64(8), status.Uploaded)
+	assert.True(t, status.Deleted)
+	assert.NoFileExists(t, file)
+	if obj := srv.Object("records", "主播/a.flv"); assert.NotNil(t, obj) {
+		assert.Equal(t, []byte("flv data"), obj.Data)
+	}
+	assert.Equal(t, status, <-finished)
+
+	// 上传失败时保留本地文件
+	cfg.Upload.S3.Bucket = "missing"
+	file = filepath.Join(dir, "b.flv")
+	assert.NoError(t, os.WriteFile(file, []byte("flv data"), 0644))
+	status, err = u.Upload(ctx, &live.Info{}, file)
+	assert.Error(t, err)
+	assert.Equal(t, StateFailed, status.State)
+	assert.False(t, status.Deleted)
+	assert.FileExists(t, file)
+	assert.Equal(t, status, <-finished)
+
+	uploads := u.Uploads()
+	if assert.Len(t, uploads, 2) {
+		assert.Equal(t, uint64(2), uploads[0].ID)
+		assert.Equal(t, "b.flv", uploads[0].Path)
+	}
+}
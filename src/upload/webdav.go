@@ -0,0 +1,201 @@
+package upload
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/bililive-go/bililive-go/src/configs"
+)
+
+// progressInterval 上传进度的最小报告间隔（字节）
+const progressInterval = 1 << 20
+
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:"><d:prop><d:getcontentlength/><d:getetag/></d:prop></d:propfind>`
+
+// webdavBackend 上传到 WebDAV 服务，上传后通过 PROPFIND 校验文件大小，服务端返回了 ETag 时同时校验 ETag
+type webdavBackend struct {
+	hc       *http.Client
+	endpoint string
+	cfg      configs.WebDAVUpload
+}
+
+func newWebDAVBackend(cfg configs.WebDAVUpload) (Backend, error) {
+	u, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webdav endpoint: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid webdav endpoint: %s", cfg.Endpoint)
+	}
+	return &webdavBackend{
+		hc:       new(http.Client),
+		endpoint: strings.TrimRight(cfg.Endpoint, "/"),
+		cfg:      cfg,
+	}, nil
+}
+
+// url 返回远端路径的地址，路径的每一段都会被转义
+func (b *webdavBackend) url(path string) string {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return b.endpoint + "/" + strings.Join(segments, "/")
+}
+
+func (b *webdavBackend) do(ctx context.Context, method, rawURL string, header http.Header, body io.Reader, size int64) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if b.cfg.Username != "" || b.cfg.Password != "" {
+		req.SetBasicAuth(b.cfg.Username, b.cfg.Password)
+	}
+	return b.hc.Do(req)
+}
+
+// mkdirAll 逐级创建 path 的父目录，目录已存在时服务端返回 405
+func (b *webdavBackend) mkdirAll(ctx context.Context, path string) error {
+	dirs := strings.Split(strings.Trim(path, "/"), "/")
+	for i := 1; i < len(dirs); i++ {
+		dir := b.url(strings.Join(dirs[:i], "/")) + "/"
+		resp, err := b.do(ctx, "MKCOL", dir, nil, nil, 0)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 && resp.StatusCode != http.StatusMethodNotAllowed {
+			return fmt.Errorf("failed to create webdav directory %s: %s", dir, resp.Status)
+		}
+	}
+	return nil
+}
+
+type webdavMultistatus struct {
+	Responses []struct {
+		Propstats []struct {
+			Prop struct {
+				ContentLength string `xml:"getcontentlength"`
+				ETag          string `xml:"getetag"`
+			} `xml:"prop"`
+			Status string `xml:"status"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+// stat 通过 PROPFIND 获取远端文件的大小与 ETag
+func (b *webdavBackend) stat(ctx context.Context, rawURL string) (size int64, etag string, err error) {
+	header := http.Header{
+		"Depth":        {"0"},
+		"Content-Type": {"application/xml; charset=utf-8"},
+	}
+	resp, err := b.do(ctx, "PROPFIND", rawURL, header, strings.NewReader(propfindBody), int64(len(propfindBody)))
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusMultiStatus {
+		return 0, "", fmt.Errorf("failed to stat webdav file: %s", resp.Status)
+	}
+	var ms webdavMultistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return 0, "", fmt.Errorf("failed to parse webdav PROPFIND response: %w", err)
+	}
+	for _, r := range ms.Responses {
+		for _, ps := range r.Propstats {
+			if ps.Status != "" && !strings.Contains(ps.Status, " 200 ") {
+				continue
+			}
+			if ps.Prop.ContentLength != "" {
+				if size, err = strconv.ParseInt(ps.Prop.ContentLength, 10, 64); err != nil {
+					return 0, "", fmt.Errorf("invalid webdav content length: %s", ps.Prop.ContentLength)
+				}
+				return size, ps.Prop.ETag, nil
+			}
+		}
+	}
+	return 0, "", fmt.Errorf("webdav PROPFIND response has no content length")
+}
+
+// normalizeETag 去掉弱校验前缀与引号
+func normalizeETag(etag string) string {
+	return strings.Trim(strings.TrimPrefix(strings.TrimSpace(etag), "W/"), `"`)
+}
+
+func (b *webdavBackend) Upload(ctx context.Context, file, path string, progress func(uploaded int64)) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	if err := b.mkdirAll(ctx, path); err != nil {
+		return "", err
+	}
+
+	rawURL := b.url(path)
+	body := &progressReader{r: f, fn: progress}
+	resp, err := b.do(ctx, http.MethodPut, rawURL, nil, body, stat.Size())
+	if err != nil {
+		return "", err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return "", fmt.Errorf("failed to upload to webdav: %s", resp.Status)
+	}
+	body.report()
+
+	size, etag, err := b.stat(ctx, rawURL)
+	if err != nil {
+		return "", err
+	}
+	if size != stat.Size() {
+		return "", fmt.Errorf("size of uploaded file mismatch, local: %d, remote: %d", stat.Size(), size)
+	}
+	if putETag := normalizeETag(resp.Header.Get("ETag")); putETag != "" && etag != "" && putETag != normalizeETag(etag) {
+		return "", fmt.Errorf("etag of uploaded file mismatch, put: %s, remote: %s", putETag, etag)
+	}
+	return rawURL, nil
+}
+
+// progressReader 在读取时报告进度，每读取 progressInterval 字节报告一次
+type progressReader struct {
+	r        io.Reader
+	fn       func(uploaded int64)
+	n        int64
+	reported int64
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.n += int64(n)
+	if p.n-p.reported >= progressInterval {
+		p.report()
+	}
+	return n, err
+}
+
+func (p *progressReader) report() {
+	if p.fn != nil && p.n != p.reported {
+		p.reported = p.n
+		p.fn(p.n)
+	}
+}
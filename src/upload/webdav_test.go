@@ -0,0 +1,112 @@
+package upload
+
+import (
+	"context"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bililive-go/bililive-go/src/configs"
+)
+
+// webdavServer 内存中的 WebDAV 服务，只实现上传用到的 MKCOL、PUT 与 PROPFIND
+type webdavServer struct {
+	lock  sync.Mutex
+	dirs  map[string]bool
+	files map[string][]byte
+	// truncate 保存文件时丢弃的字节数，用于测试校验
+	truncate int
+}
+
+func newWebDAVServer(t *testing.T) (*webdavServer, *httptest.Server) {
+	s := &webdavServer{dirs: map[string]bool{"/dav": true}, files: map[string][]byte{}}
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+	return s, srv
+}
+
+func (s *webdavServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "pass" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	name := path.Clean(r.URL.Path)
+	switch r.Method {
+	case "MKCOL":
+		switch {
+		case s.dirs[name] || s.files[name] != nil:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		case !s.dirs[path.Dir(name)]:
+			w.WriteHeader(http.StatusConflict)
+		default:
+			s.dirs[name] = true
+			w.WriteHeader(http.StatusCreated)
+		}
+	case http.MethodPut:
+		if !s.dirs[path.Dir(name)] {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		s.files[name] = data[:len(data)-s.truncate]
+		w.Header().Set("ETag", fmt.Sprintf(`"%x"`, md5.Sum(data)))
+		w.WriteHeader(http.StatusCreated)
+	case "PROPFIND":
+		data, ok := s.files[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusMultiStatus)
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?>
+<D:multistatus xmlns:D="DAV:"><D:response><D:href>%s</D:href><D:propstat><D:prop>
+<D:getcontentlength>%d</D:getcontentlength><D:getetag>"%x"</D:getetag>
+</D:prop><D:status>HTTP/1.1 200 OK</D:status></D:propstat></D:response></D:multistatus>`, r.URL.Path, len(data), md5.Sum(data))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestWebDAVBackend(t *testing.T) {
+	s, srv := newWebDAVServer(t)
+	ctx := context.Background()
+	file := filepath.Join(t.TempDir(), "a.flv")
+	assert.NoError(t, os.WriteFile(file, []byte("flv data"), 0644))
+
+	b, err := newWebDAVBackend(configs.WebDAVUpload{Endpoint: srv.URL + "/dav/", Username: "user", Password: "pass"})
+	assert.NoError(t, err)
+	var progress []int64
+	url, err := b.Upload(ctx, file, "哔哩哔哩/主播 A/a.flv", func(uploaded int64) { progress = append(progress, uploaded) })
+	assert.NoError(t, err)
+	assert.Equal(t, srv.URL+"/dav/%E5%93%94%E5%93%A9%E5%93%94%E5%93%A9/%E4%B8%BB%E6%92%AD%20A/a.flv", url)
+	assert.Equal(t, []int64{8}, progress)
+	assert.Equal(t, []byte("flv data"), s.files["/dav/哔哩哔哩/主播 A/a.flv"])
+
+	// 已存在的目录可以再次上传
+	_, err = b.Upload(ctx, file, "哔哩哔哩/主播 A/b.flv", nil)
+	assert.NoError(t, err)
+
+	// 远端文件不完整时校验失败
+	s.truncate = 1
+	_, err = b.Upload(ctx, file, "c.flv", nil)
+	assert.ErrorContains(t, err, "size of uploaded file mismatch")
+
+	b, err = newWebDAVBackend(configs.WebDAVUpload{Endpoint: srv.URL + "/dav", Username: "user", Password: "wrong"})
+	assert.NoError(t, err)
+	_, err = b.Upload(ctx, file, "d.flv", nil)
+	assert.Error(t, err)
+
+	_, err = newWebDAVBackend(configs.WebDAVUpload{Endpoint: "nas:5005"})
+	assert.Error(t, err)
+}